@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"super-payment/internal/config"
+	"super-payment/internal/repository"
+	"super-payment/internal/seed"
+)
+
+// seed populates a database with demo data for local development:
+//
+//	go run ./cmd/seed [-dsn "user:pass@tcp(host:3306)/super_payment?parseTime=True"]
+//
+// Without -dsn the database settings are read from the environment like the server does.
+func main() {
+	cfg := config.Load()
+
+	dsn := flag.String("dsn", "", "MySQL DSN (defaults to the DB_* environment settings)")
+	flag.Parse()
+	if *dsn == "" {
+		*dsn = cfg.GetDSN()
+	}
+
+	repo, err := repository.NewMySQLRepository(*dsn)
+	if err != nil {
+		log.Fatalf("Failed to initialize repository: %v", err)
+	}
+	defer func() {
+		if err := repo.Close(); err != nil {
+			log.Printf("Error closing repository: %v", err)
+		}
+	}()
+
+	result, err := seed.Run(repo, cfg)
+	if err != nil {
+		log.Fatalf("Failed to seed demo data: %v", err)
+	}
+
+	if result.Skipped {
+		log.Printf("Demo user %s already exists, nothing to do", seed.DemoEmail)
+		return
+	}
+	log.Printf("Created demo company %d with %d business partners and %d invoices",
+		result.CompanyID, len(result.BusinessPartnerIDs), len(result.InvoiceIDs))
+	log.Printf("Log in as %s / %s", seed.DemoEmail, seed.DemoPassword)
+}
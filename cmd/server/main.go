@@ -1,19 +1,49 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
+	"os/signal"
 	"super-payment/internal/api"
 	"super-payment/internal/config"
+	"super-payment/internal/events"
 	"super-payment/internal/repository"
+	"super-payment/internal/scheduler"
 	"super-payment/internal/service"
+	"super-payment/internal/webhook"
+	"syscall"
+	"time"
+)
+
+// Build information, injected at build time with
+// -ldflags "-X main.version=... -X main.commit=... -X main.buildTime=..."
+var (
+	version   = "dev"
+	commit    = "dev"
+	buildTime = "unknown"
 )
 
 func main() {
 	// Load configuration
 	cfg := config.Load()
+	cfg.Build = config.BuildInfo{Version: version, Commit: commit, BuildTime: buildTime}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	cfg.LogSummary(log.Default())
+	log.Printf("Rates: default_fee_rate=%g default_consumption_tax_rate=%g", service.DefaultFeeRate, service.DefaultConsumptionTaxRate)
 
 	// Initialize repository
-	repo, err := repository.NewMySQLRepository(cfg.GetDSN())
+	var queryLog *log.Logger
+	if cfg.Database.Debug {
+		queryLog = log.Default()
+	}
+	repo, err := repository.NewMySQLRepositoryWithLogging(cfg.GetDSN(), repository.LogOptions{
+		QueryLog:           queryLog,
+		SlowQueryThreshold: cfg.Database.SlowQueryThreshold,
+	})
 	if err != nil {
 		log.Fatalf("Failed to initialize repository: %v", err)
 	}
@@ -24,7 +54,14 @@ func main() {
 	}()
 
 	// Initialize service
-	svc := service.NewInvoiceService(repo)
+	svc := service.NewInvoiceService(repo, cfg)
+
+	// Subscribe side effects to domain events, and let in-flight handlers finish before the
+	// repository is closed
+	events.SubscribeAuditLog(svc.Events(), log.Default())
+	webhooks := webhook.NewDispatcher(repo, cfg)
+	webhooks.Subscribe(svc.Events())
+	defer svc.Events().Wait()
 
 	// Initialize HTTP handler
 	handler := api.NewHandler(svc, cfg)
@@ -32,11 +69,59 @@ func main() {
 	// Setup routes
 	router := handler.SetupRoutes()
 
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Start background jobs
+	jobs := scheduler.New()
+	jobs.Register("mark-overdue-invoices", time.Duration(cfg.Scheduler.OverdueIntervalMinutes)*time.Minute,
+		func(ctx context.Context) error {
+			count, err := svc.MarkOverdueInvoices()
+			if err == nil && count > 0 {
+				log.Printf("Marked %d overdue invoices", count)
+			}
+			return err
+		})
+	jobs.Register("purge-deleted-invoices", time.Duration(cfg.Scheduler.PurgeIntervalMinutes)*time.Minute,
+		func(ctx context.Context) error {
+			count, err := svc.PurgeDeletedInvoices()
+			if err == nil && count > 0 {
+				log.Printf("Purged %d deleted invoices", count)
+			}
+			return err
+		})
+	jobs.Register("retry-webhook-deliveries", time.Duration(cfg.Scheduler.WebhookRetryIntervalMinutes)*time.Minute,
+		func(ctx context.Context) error {
+			count, err := webhooks.RetryDue(ctx, time.Now())
+			if count > 0 {
+				log.Printf("Delivered %d queued webhook deliveries", count)
+			}
+			return err
+		})
+	jobs.Start(ctx)
+	defer jobs.Stop()
+
 	// Start server
 	serverAddr := cfg.GetServerAddress()
-	log.Printf("Starting server on %s", serverAddr)
+	server := &http.Server{
+		Addr:              serverAddr,
+		Handler:           router,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		log.Printf("Starting server on %s", serverAddr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down server")
 
-	if err := router.Run(serverAddr); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error shutting down server: %v", err)
 	}
 }
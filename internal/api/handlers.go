@@ -1,16 +1,30 @@
 package api
 
 import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"net/url"
+	"reflect"
 	"strconv"
+	"strings"
 	"super-payment/internal/config"
+	"super-payment/internal/ical"
 	"super-payment/internal/middleware"
 	"super-payment/internal/models"
+	"super-payment/internal/pdf"
+	"super-payment/internal/repository"
 	"super-payment/internal/service"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
 )
 
 // Handler holds the HTTP handlers
@@ -19,8 +33,10 @@ type Handler struct {
 	config  *config.Config
 }
 
-// NewHandler creates a new HTTP handler
+// NewHandler creates a new HTTP handler. Timestamps in its responses are rendered in the
+// configured timezone.
 func NewHandler(service service.Service, config *config.Config) *Handler {
+	models.SetTimestampLocation(config.GetLocation())
 	return &Handler{
 		service: service,
 		config:  config,
@@ -32,43 +48,229 @@ func (h *Handler) SetupRoutes() *gin.Engine {
 	// Set Gin mode
 	gin.SetMode(gin.ReleaseMode)
 
+	// Report validation failures using JSON field names
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(jsonFieldName)
+	}
+
 	router := gin.New()
 
+	// Resolve the client IP from X-Forwarded-For only when the request comes through a trusted proxy
+	if err := router.SetTrustedProxies(h.config.Server.TrustedProxies); err != nil {
+		log.Printf("Invalid TRUSTED_PROXIES %v, trusting no proxies: %v", h.config.Server.TrustedProxies, err)
+		_ = router.SetTrustedProxies(nil)
+	}
+
+	// Answer known paths requested with an unsupported method with 405
+	router.HandleMethodNotAllowed = true
+	router.NoMethod(h.methodNotAllowed)
+	router.NoRoute(h.notFound)
+
 	// Add middleware
 	router.Use(middleware.LoggingMiddleware())
+	router.Use(middleware.IdempotencyKeyMiddleware())
 	router.Use(middleware.ErrorHandlingMiddleware())
-	router.Use(middleware.CORSMiddleware())
+	router.Use(middleware.CORSMiddleware(h.config, router.Routes))
+	router.Use(middleware.RateLimitMiddleware(h.config))
+	router.Use(middleware.JSONContentTypeMiddleware(fileRoutes...))
+	router.Use(middleware.APIVersionMiddleware())
+	router.Use(middleware.TimeoutMiddleware(h.config))
 
 	// Health check
 	router.GET("/health", h.healthCheck)
+	router.GET("/version", h.version)
+	// Exposes internals, so it is restricted to operators
+	router.GET("/health/detailed", middleware.AdminTokenMiddleware(h.config), h.detailedHealthCheck)
 
 	// Public routes
 	auth := router.Group("/api/auth")
 	{
 		auth.POST("/register", h.register)
 		auth.POST("/login", h.login)
+		auth.GET("/email-available", middleware.RateLimit(h.config.Auth.EmailCheckRateLimitPerMinute), h.emailAvailable)
+		auth.GET("/introspect", middleware.JWTMiddleware(h.config, h.service), h.introspect)
+		auth.POST("/calendar-token", middleware.JWTMiddleware(h.config, h.service), h.createCalendarToken)
+		auth.POST("/read-token", middleware.JWTMiddleware(h.config, h.service), h.createReadToken)
 	}
 
+	// Calendar feed, authenticated by a calendar-scoped token in the query string since
+	// calendar clients cannot send an Authorization header
+	router.GET("/api/invoices/calendar.ics", middleware.QueryTokenMiddleware(h.config, h.service, middleware.ScopeCalendar), h.getInvoiceCalendar)
+
 	// Protected routes
 	api := router.Group("/api")
-	api.Use(middleware.JWTMiddleware(h.config))
+	api.Use(middleware.JWTMiddleware(h.config, h.service))
+	api.Use(middleware.ETagMiddleware(h.config, fileRoutes...))
 	{
 		// Invoice routes
 		api.POST("/invoices", h.createInvoice)
+		api.POST("/invoices/calculate-batch", h.calculateBatch)
+		api.POST("/invoices/bulk-delete", h.bulkDeleteInvoices)
 		api.GET("/invoices", h.getInvoices)
+		api.GET("/invoices/overdue", h.getOverdueInvoices)
+		api.GET("/invoices/count", h.countInvoices)
+		api.GET("/invoices/status-facets", h.getInvoiceStatusFacets)
+		api.GET("/invoices/summary", h.getInvoiceSummary)
+		api.GET("/invoices/by-number/:number", h.getInvoiceByNumber)
 		api.GET("/invoices/:id", h.getInvoiceByID)
+		api.HEAD("/invoices/:id", h.headInvoice)
+		api.GET("/invoices/:id/pdf", h.downloadInvoicePDF)
+		api.POST("/invoices/:id/issue", h.issueInvoice)
+		api.POST("/invoices/:id/clone", h.cloneInvoice)
+		api.POST("/invoices/:id/recalculate", h.recalculateInvoice)
+		api.POST("/invoices/:id/attachments", h.addInvoiceAttachment)
+		api.GET("/invoices/:id/attachments", h.getInvoiceAttachments)
+		api.GET("/invoices/:id/history", h.getInvoiceHistory)
+		api.PATCH("/invoices/:id/status", h.updateInvoiceStatus)
+		api.POST("/invoices/:id/payments", h.recordInvoicePayment)
 
 		// Business partner routes
 		api.POST("/business-partners", h.createBusinessPartner)
 		api.GET("/business-partners", h.getBusinessPartners)
+		api.GET("/business-partners/export", h.exportBusinessPartners)
+		api.POST("/business-partners/import", h.importBusinessPartners)
+		api.POST("/business-partners/validate", h.validateBusinessPartners)
+		api.PATCH("/business-partners/:id", h.patchBusinessPartner)
+		api.POST("/business-partners/:id/merge/:targetId", h.mergeBusinessPartners)
+		api.GET("/business-partners/:id/stats", h.getBusinessPartnerStats)
+		api.GET("/business-partners/:id/invoices", h.getBusinessPartnerInvoices)
+		api.GET("/business-partners/:id/invoices.zip", h.downloadBusinessPartnerInvoices)
+		api.GET("/business-partners/:id/statement", h.downloadBusinessPartnerStatement)
+		api.POST("/business-partners/:id/bank-accounts", h.createBankAccount)
+		api.PUT("/business-partners/:id/bank-accounts/:accountId", h.updateBankAccount)
+		api.POST("/business-partners/:id/bank-accounts/:accountId/primary", h.setPrimaryBankAccount)
+
+		// Report routes
+		api.GET("/reports/monthly", h.getMonthlyReport)
+		api.GET("/reports/aging", h.getAgingReport)
 
 		// Company routes
 		api.POST("/companies", h.createCompany)
+		api.GET("/company/settings", h.getCompanySettings)
+		api.PUT("/company/settings", h.updateCompanySettings)
+		api.POST("/company/settings/preview-rate", h.previewRateChange)
+		api.GET("/company/webhooks", h.getWebhookSettings)
+		api.PUT("/company/webhooks", h.updateWebhookSettings)
+		api.GET("/company/webhooks/deliveries", h.getWebhookDeliveries)
+		api.GET("/company/users", h.getCompanyUsers)
+		api.POST("/company/users/:id/deactivate", h.deactivateUser)
+		api.POST("/company/users/:id/reactivate", h.reactivateUser)
+
+		// Account routes
+		api.PATCH("/me", h.updateProfile)
+	}
+
+	// Operator routes
+	admin := router.Group("/api/admin")
+	admin.Use(middleware.AdminTokenMiddleware(h.config))
+	{
+		admin.POST("/invoices/process-overdue", h.processOverdueInvoices)
+		admin.POST("/invoices/purge-deleted", h.purgeDeletedInvoices)
+		admin.PUT("/companies/:id/fee-rate", h.setCompanyFeeRate)
+		admin.GET("/reports/fee-revenue", h.getFeeRevenueReport)
 	}
 
 	return router
 }
 
+// fileRoutes lists the endpoints that exchange files rather than JSON documents
+var fileRoutes = []string{
+	"/api/invoices/calendar.ics",
+	"/api/invoices/:id/pdf",
+	"/api/business-partners/export",
+	"/api/business-partners/import",
+	"/api/business-partners/:id/invoices.zip",
+	"/api/business-partners/:id/statement",
+}
+
+// jsonFieldName returns the JSON name of a struct field for validation error reporting
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// parseFieldSelection parses the comma-separated fields query parameter clients request sparse
+// responses with. It returns nil when no field is requested.
+func parseFieldSelection(c *gin.Context) map[string]bool {
+	fields := make(map[string]bool)
+	for _, field := range strings.Split(c.Query("fields"), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields[field] = true
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// selectFields returns the exported fields of a struct, or pointer to one, whose JSON names are
+// in fields, keyed by those names. Unknown names are ignored.
+func selectFields(v interface{}, fields map[string]bool) map[string]interface{} {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	selected := make(map[string]interface{}, len(fields))
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if name := jsonFieldName(field); name != "" && field.IsExported() && fields[name] {
+			selected[name] = value.Field(i).Interface()
+		}
+	}
+	return selected
+}
+
+// selectFieldsOfEach applies selectFields to every element of a slice of structs
+func selectFieldsOfEach(v interface{}, fields map[string]bool) []map[string]interface{} {
+	slice := reflect.ValueOf(v)
+	selected := make([]map[string]interface{}, slice.Len())
+	for i := range selected {
+		selected[i] = selectFields(slice.Index(i).Interface(), fields)
+	}
+	return selected
+}
+
+// validationFailed responds to a well-formed request whose content breaks a business rule with
+// 422, keeping 400 for bodies that cannot be parsed or bound
+func validationFailed(c *gin.Context, err error) {
+	c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+		Error:   "validation_error",
+		Message: err.Error(),
+	})
+}
+
+// bindingErrorResponse builds a validation error response, listing every failing field
+// when the error comes from struct validation
+func bindingErrorResponse(err error) models.ErrorResponse {
+	response := models.ErrorResponse{
+		Error:   "validation_error",
+		Message: err.Error(),
+	}
+
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		for _, fieldErr := range validationErrors {
+			response.Fields = append(response.Fields, models.FieldError{
+				Field: fieldPath(fieldErr),
+				Rule:  fieldErr.Tag(),
+			})
+		}
+	}
+
+	return response
+}
+
+// fieldPath returns the dotted JSON path of a failing field, without the Go type name prefix
+func fieldPath(fieldErr validator.FieldError) string {
+	namespace := strings.SplitN(fieldErr.Namespace(), ".", 2)
+	structNamespace := strings.SplitN(fieldErr.StructNamespace(), ".", 2)
+	if len(namespace) == 2 && namespace[0] == structNamespace[0] {
+		return namespace[1]
+	}
+	return fieldErr.Namespace()
+}
+
 // healthCheck handles health check requests
 func (h *Handler) healthCheck(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
@@ -78,6 +280,75 @@ func (h *Handler) healthCheck(c *gin.Context) {
 	})
 }
 
+// detailedHealthCheck handles dependency health requests, answering 503 while the database is unreachable
+func (h *Handler) detailedHealthCheck(c *gin.Context) {
+	details := h.service.GetHealthDetails()
+
+	status := http.StatusOK
+	if !details.Database.Connected {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, details)
+}
+
+// version handles build information requests
+func (h *Handler) version(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"version":    h.config.Build.Version,
+		"commit":     h.config.Build.Commit,
+		"build_time": h.config.Build.BuildTime,
+	})
+}
+
+// serverError responds to a failed operation. Lost database connections are reported as
+// 503 service_unavailable so clients know to retry; anything else is a 500 with the given code.
+func serverError(c *gin.Context, code string, err error) {
+	if repository.IsConnectionError(err) {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{
+			Error:   "service_unavailable",
+			Message: "The service is temporarily unavailable, please retry later",
+		})
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		Error:   code,
+		Message: err.Error(),
+	})
+}
+
+// adminRequired responds to a member attempting an operation reserved for company admins
+func adminRequired(c *gin.Context, err error) {
+	c.JSON(http.StatusForbidden, models.ErrorResponse{
+		Error:   "forbidden",
+		Message: err.Error(),
+	})
+}
+
+// duplicateBankAccount responds that a business partner already has the bank account
+func duplicateBankAccount(c *gin.Context, err error) {
+	c.JSON(http.StatusConflict, models.ErrorResponse{
+		Error:   "duplicate_bank_account",
+		Message: err.Error(),
+	})
+}
+
+// methodNotAllowed handles requests using an unsupported method on a known route
+func (h *Handler) methodNotAllowed(c *gin.Context) {
+	c.JSON(http.StatusMethodNotAllowed, models.ErrorResponse{
+		Error:   "method_not_allowed",
+		Message: fmt.Sprintf("method %s is not allowed for this route", c.Request.Method),
+	})
+}
+
+// notFound handles requests to unknown routes
+func (h *Handler) notFound(c *gin.Context) {
+	c.JSON(http.StatusNotFound, models.ErrorResponse{
+		Error:   "not_found",
+		Message: "route not found",
+	})
+}
+
 // register handles user registration
 func (h *Handler) register(c *gin.Context) {
 	var req struct {
@@ -86,36 +357,49 @@ func (h *Handler) register(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "validation_error",
-			Message: err.Error(),
-		})
+		c.JSON(http.StatusBadRequest, bindingErrorResponse(err))
+		return
+	}
+
+	if domain, blocked := h.config.BlockedEmailDomain(req.User.Email); blocked {
+		validationFailed(c, fmt.Errorf("Email domain %s is not allowed", domain))
+		return
+	}
+
+	// Check the password before creating the company so a rejected registration leaves nothing behind
+	if err := h.service.ValidatePassword(req.User.Password); err != nil {
+		validationFailed(c, err)
 		return
 	}
 
 	// Create company first
 	if err := h.service.CreateCompany(&req.Company); err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "company_creation_failed",
-			Message: err.Error(),
-		})
+		switch {
+		case errors.Is(err, service.ErrCompanyAlreadyExists):
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error:   "company_already_exists",
+				Message: err.Error(),
+			})
+		case errors.Is(err, service.ErrInvalidContactInfo):
+			validationFailed(c, err)
+		default:
+			serverError(c, "company_creation_failed", err)
+		}
 		return
 	}
 
-	// Create user from registration request
+	// Create user from registration request; the registrant administers the new company
 	user := models.User{
 		CompanyID: req.Company.ID,
 		FullName:  req.User.FullName,
 		Email:     req.User.Email,
 		Password:  req.User.Password,
+		Role:      models.UserRoleAdmin,
 	}
 
 	// Create user
 	if err := h.service.RegisterUser(&user); err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "user_registration_failed",
-			Message: err.Error(),
-		})
+		serverError(c, "user_registration_failed", err)
 		return
 	}
 
@@ -140,14 +424,25 @@ func (h *Handler) login(c *gin.Context) {
 	var req models.LoginRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "validation_error",
-			Message: err.Error(),
-		})
+		c.JSON(http.StatusBadRequest, bindingErrorResponse(err))
 		return
 	}
 
 	user, err := h.service.LoginUser(req.Email, req.Password)
+	if errors.Is(err, service.ErrAccountLocked) {
+		c.JSON(http.StatusLocked, models.ErrorResponse{
+			Error:   "account_locked",
+			Message: "Too many failed login attempts, please try again later",
+		})
+		return
+	}
+	if errors.Is(err, service.ErrAccountDisabled) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "account_disabled",
+			Message: "This account has been deactivated",
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
 			Error:   "authentication_failed",
@@ -156,7 +451,11 @@ func (h *Handler) login(c *gin.Context) {
 		return
 	}
 
-	token, err := middleware.GenerateJWT(user, h.config)
+	expiry := time.Duration(h.config.JWT.ExpiryHours) * time.Hour
+	if req.RememberMe {
+		expiry = time.Duration(h.config.JWT.RememberMeExpiryDays) * 24 * time.Hour
+	}
+	token, err := middleware.GenerateJWTWithExpiry(user, expiry, h.config)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "token_generation_failed",
@@ -171,9 +470,9 @@ func (h *Handler) login(c *gin.Context) {
 	})
 }
 
-// createInvoice handles invoice creation
-func (h *Handler) createInvoice(c *gin.Context) {
-	userID, err := middleware.GetUserIDFromContext(c)
+// introspect handles reporting the validity and remaining lifetime of the caller's token
+func (h *Handler) introspect(c *gin.Context) {
+	claims, err := middleware.GetClaimsFromContext(c)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
 			Error:   "unauthorized",
@@ -182,43 +481,40 @@ func (h *Handler) createInvoice(c *gin.Context) {
 		return
 	}
 
-	var req models.CreateInvoiceRequest
-
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "validation_error",
-			Message: err.Error(),
-		})
-		return
+	response := models.TokenIntrospectionResponse{
+		Valid:     true,
+		UserID:    claims.UserID,
+		CompanyID: claims.CompanyID,
+	}
+	if claims.ExpiresAt != nil {
+		response.ExpiresAt = claims.ExpiresAt.Time
+		response.SecondsRemaining = int64(time.Until(claims.ExpiresAt.Time).Seconds())
 	}
 
-	// Additional validation
-	if err := req.Validate(); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "validation_error",
-			Message: err.Error(),
-		})
+	c.JSON(http.StatusOK, response)
+}
+
+// emailAvailable handles checking whether an email is still free during signup. The route is rate
+// limited separately since the answer reveals which emails are registered.
+func (h *Handler) emailAvailable(c *gin.Context) {
+	var req models.EmailAvailabilityRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, bindingErrorResponse(err))
 		return
 	}
 
-	invoice, err := h.service.CreateInvoice(userID, &req)
+	available, err := h.service.IsEmailAvailable(req.Email)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "invoice_creation_failed",
-			Message: err.Error(),
-		})
+		serverError(c, "email_check_failed", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, models.SuccessResponse{
-		Message: "Invoice created successfully",
-		Data:    invoice,
-	})
+	c.JSON(http.StatusOK, models.EmailAvailabilityResponse{Available: available})
 }
 
-// getInvoices handles invoice retrieval with filters
-func (h *Handler) getInvoices(c *gin.Context) {
-	userID, err := middleware.GetUserIDFromContext(c)
+// createCalendarToken handles issuing a token for subscribing to the invoice calendar feed
+func (h *Handler) createCalendarToken(c *gin.Context) {
+	claims, err := middleware.GetClaimsFromContext(c)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
 			Error:   "unauthorized",
@@ -227,71 +523,27 @@ func (h *Handler) getInvoices(c *gin.Context) {
 		return
 	}
 
-	var req models.GetInvoicesRequest
-
-	// Parse query parameters manually for better control
-	if startDateStr := c.Query("start_date"); startDateStr != "" {
-		if startDate, err := time.Parse(time.RFC3339, startDateStr); err != nil {
-			c.JSON(http.StatusBadRequest, models.ErrorResponse{
-				Error:   "validation_error",
-				Message: fmt.Sprintf("Invalid start_date format: %v", err),
-			})
-			return
-		} else {
-			req.StartDate = &startDate
-		}
-	}
-
-	if endDateStr := c.Query("end_date"); endDateStr != "" {
-		if endDate, err := time.Parse(time.RFC3339, endDateStr); err != nil {
-			c.JSON(http.StatusBadRequest, models.ErrorResponse{
-				Error:   "validation_error",
-				Message: fmt.Sprintf("Invalid end_date format: %v", err),
-			})
-			return
-		} else {
-			req.EndDate = &endDate
-		}
-	}
-
-	if status := c.Query("status"); status != "" {
-		req.Status = &status
-	}
-
-	// Parse pagination parameters
-	if pageStr := c.Query("page"); pageStr != "" {
-		if page, err := strconv.Atoi(pageStr); err == nil && page > 0 {
-			req.Page = page
-		}
-	} else {
-		req.Page = 1
-	}
-
-	if limitStr := c.Query("limit"); limitStr != "" {
-		if limit, err := strconv.Atoi(limitStr); err == nil && limit > 0 {
-			req.Limit = limit
-		}
-	} else {
-		req.Limit = 20
-	}
-
-	invoices, err := h.service.GetInvoices(userID, &req)
+	user := &models.User{ID: claims.UserID, CompanyID: claims.CompanyID, Email: claims.Email}
+	expiry := time.Duration(h.config.JWT.CalendarTokenExpiryDays) * 24 * time.Hour
+	token, expiresAt, err := middleware.GenerateScopedJWT(user, middleware.ScopeCalendar, expiry, h.config)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "invoice_retrieval_failed",
-			Message: err.Error(),
+			Error:   "token_generation_failed",
+			Message: "Failed to generate token",
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, models.SuccessResponse{
-		Message: "Invoices retrieved successfully",
-		Data:    invoices,
+	c.JSON(http.StatusOK, models.CalendarTokenResponse{
+		Token:     token,
+		ExpiresAt: expiresAt,
+		FeedPath:  "/api/invoices/calendar.ics?token=" + url.QueryEscape(token),
 	})
 }
 
-// getInvoiceByID handles single invoice retrieval
-func (h *Handler) getInvoiceByID(c *gin.Context) {
+// createReadToken handles an admin issuing a short-lived token that only grants read access,
+// for external auditors
+func (h *Handler) createReadToken(c *gin.Context) {
 	userID, err := middleware.GetUserIDFromContext(c)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
@@ -301,33 +553,34 @@ func (h *Handler) getInvoiceByID(c *gin.Context) {
 		return
 	}
 
-	idStr := c.Param("id")
-	invoiceID, err := strconv.ParseUint(idStr, 10, 32)
+	user, err := h.service.GetAdminUser(userID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "invalid_id",
-			Message: "Invalid invoice ID",
-		})
+		if errors.Is(err, service.ErrAdminRequired) {
+			adminRequired(c, err)
+			return
+		}
+		serverError(c, "token_generation_failed", err)
 		return
 	}
 
-	invoice, err := h.service.GetInvoiceByID(userID, uint(invoiceID))
+	expiry := time.Duration(h.config.JWT.ReadTokenExpiryHours) * time.Hour
+	token, expiresAt, err := middleware.GenerateScopedJWT(user, middleware.ScopeRead, expiry, h.config)
 	if err != nil {
-		c.JSON(http.StatusNotFound, models.ErrorResponse{
-			Error:   "invoice_not_found",
-			Message: err.Error(),
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "token_generation_failed",
+			Message: "Failed to generate token",
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, models.SuccessResponse{
-		Message: "Invoice retrieved successfully",
-		Data:    invoice,
+	c.JSON(http.StatusOK, models.ReadTokenResponse{
+		Token:     token,
+		ExpiresAt: expiresAt,
 	})
 }
 
-// createBusinessPartner handles business partner creation
-func (h *Handler) createBusinessPartner(c *gin.Context) {
+// createInvoice handles invoice creation
+func (h *Handler) createInvoice(c *gin.Context) {
 	userID, err := middleware.GetUserIDFromContext(c)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
@@ -337,43 +590,56 @@ func (h *Handler) createBusinessPartner(c *gin.Context) {
 		return
 	}
 
-	var req models.BusinessPartnerCreateRequest
+	var req models.CreateInvoiceRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "validation_error",
-			Message: err.Error(),
-		})
+		c.JSON(http.StatusBadRequest, bindingErrorResponse(err))
 		return
 	}
 
 	// Additional validation
-	if err := req.Validate(); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "validation_error",
-			Message: err.Error(),
-		})
+	if err := req.Validate(h.config.GetLocation()); err != nil {
+		validationFailed(c, err)
+		return
+	}
+	if err := req.ValidateCurrency(h.config.App.AllowedCurrencies); err != nil {
+		validationFailed(c, err)
 		return
 	}
 
-	partner := req.ToBusinessPartner()
+	req.Draft = c.Query("draft") == "true"
 
-	if err := h.service.CreateBusinessPartner(userID, partner); err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "business_partner_creation_failed",
-			Message: err.Error(),
-		})
+	// Optionally warn about a likely duplicate unless the client forces creation
+	if c.Query("check_duplicate") == "true" && c.Query("force") != "true" {
+		duplicate, err := h.service.FindDuplicateInvoice(userID, &req)
+		if err != nil {
+			serverError(c, "invoice_creation_failed", err)
+			return
+		}
+		if duplicate != nil {
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error:   "possible_duplicate",
+				Message: "A matching unprocessed invoice was already created today; pass force=true to create it anyway",
+				Data:    duplicate,
+			})
+			return
+		}
+	}
+
+	invoice, err := h.service.CreateInvoice(userID, &req)
+	if err != nil {
+		serverError(c, "invoice_creation_failed", err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, models.SuccessResponse{
-		Message: "Business partner created successfully",
-		Data:    partner,
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Invoice created successfully",
+		Data:    invoice,
 	})
 }
 
-// getBusinessPartners handles business partner retrieval
-func (h *Handler) getBusinessPartners(c *gin.Context) {
+// getInvoices handles invoice retrieval with filters
+func (h *Handler) getInvoices(c *gin.Context) {
 	userID, err := middleware.GetUserIDFromContext(c)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
@@ -383,41 +649,2346 @@ func (h *Handler) getBusinessPartners(c *gin.Context) {
 		return
 	}
 
-	partners, err := h.service.GetBusinessPartners(userID)
+	var req models.GetInvoicesRequest
+	h.listInvoices(c, userID, &req)
+}
+
+// getBusinessPartnerInvoices handles invoice retrieval for one business partner of the caller's
+// company, taking the same filters as getInvoices
+func (h *Handler) getBusinessPartnerInvoices(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "business_partner_retrieval_failed",
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
 			Message: err.Error(),
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, models.SuccessResponse{
-		Message: "Business partners retrieved successfully",
-		Data:    partners,
-	})
-}
-
-// createCompany handles company creation (for admin use)
-func (h *Handler) createCompany(c *gin.Context) {
-	var company models.Company
-
-	if err := c.ShouldBindJSON(&company); err != nil {
+	partnerID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "validation_error",
-			Message: err.Error(),
+			Error:   "invalid_id",
+			Message: "Invalid business partner ID",
 		})
 		return
 	}
 
-	if err := h.service.CreateCompany(&company); err != nil {
+	id := uint(partnerID)
+	req := models.GetInvoicesRequest{BusinessPartnerID: &id}
+	h.listInvoices(c, userID, &req)
+}
+
+// listInvoices parses the invoice list filters and pagination into req and responds with the
+// matching invoices of the user's company
+func (h *Handler) listInvoices(c *gin.Context, userID uint, req *models.GetInvoicesRequest) {
+	if err := h.parseInvoiceFilters(c, req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	parsePagination(c, req)
+
+	if displayCurrency := c.Query("display_currency"); displayCurrency != "" {
+		displayCurrency = strings.ToUpper(displayCurrency)
+		if _, ok := h.config.App.FXRates[displayCurrency]; !ok {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "validation_error",
+				Message: fmt.Sprintf("Unsupported display_currency %q: no exchange rate is configured", displayCurrency),
+			})
+			return
+		}
+		req.DisplayCurrency = &displayCurrency
+	}
+
+	if afterStr, ok := c.GetQuery("after"); ok {
+		var after uint64
+		if afterStr != "" {
+			var err error
+			after, err = strconv.ParseUint(afterStr, 10, 32)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, models.ErrorResponse{
+					Error:   "validation_error",
+					Message: "Invalid after cursor",
+				})
+				return
+			}
+		}
+		cursor := uint(after)
+		req.After = &cursor
+	}
+
+	invoices, err := h.service.GetInvoicesContext(c.Request.Context(), userID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrAdminRequired):
+			adminRequired(c, err)
+		case errors.Is(err, service.ErrBusinessPartnerNotFound):
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "business_partner_not_found",
+				Message: err.Error(),
+			})
+		default:
+			serverError(c, "invoice_retrieval_failed", err)
+		}
+		return
+	}
+
+	// BI tools that cannot handle nested objects can ask for the flat shape, and clients saving
+	// bandwidth for only some fields of either shape
+	flat := c.Query("flat") == "true"
+	fields := parseFieldSelection(c)
+
+	var data interface{} = invoices
+	if flat {
+		data = models.FlattenInvoices(invoices)
+	}
+	if fields != nil {
+		data = selectFieldsOfEach(data, fields)
+	}
+
+	if req.After == nil {
+		// Clients written against v1 expect the page without pagination details
+		if middleware.GetAPIVersion(c) == middleware.APIVersion1 {
+			c.JSON(http.StatusOK, models.SuccessResponse{
+				Message: "Invoices retrieved successfully",
+				Data:    data,
+			})
+			return
+		}
+
+		total, err := h.service.CountInvoicesContext(c.Request.Context(), userID, req)
+		if err != nil {
+			serverError(c, "invoice_retrieval_failed", err)
+			return
+		}
+		c.JSON(http.StatusOK, models.InvoicePageResponse{
+			Message:    "Invoices retrieved successfully",
+			Data:       data,
+			Page:       req.Page,
+			Limit:      req.Limit,
+			Total:      total,
+			TotalPages: (total + req.Limit - 1) / req.Limit,
+		})
+		return
+	}
+
+	// A full page means there may be more invoices after the last one
+	var nextCursor *uint
+	if len(invoices) > 0 && len(invoices) == req.Limit {
+		lastID := invoices[len(invoices)-1].ID
+		nextCursor = &lastID
+	}
+
+	switch data := data.(type) {
+	case []map[string]interface{}:
+		c.JSON(http.StatusOK, models.SparseInvoiceListResponse{
+			Message:    "Invoices retrieved successfully",
+			Data:       data,
+			NextCursor: nextCursor,
+		})
+		return
+	case []models.FlatInvoice:
+		c.JSON(http.StatusOK, models.FlatInvoiceListResponse{
+			Message:    "Invoices retrieved successfully",
+			Data:       data,
+			NextCursor: nextCursor,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.InvoiceListResponse{
+		Message:    "Invoices retrieved successfully",
+		Data:       invoices,
+		NextCursor: nextCursor,
+	})
+}
+
+// parseInvoiceFilters parses the invoice list filter query parameters into the request
+func (h *Handler) parseInvoiceFilters(c *gin.Context, req *models.GetInvoicesRequest) error {
+	// Parse query parameters manually for better control
+	if startDateStr := c.Query("start_date"); startDateStr != "" {
+		startDate, err := h.parseDateParam(startDateStr)
+		if err != nil {
+			return fmt.Errorf("Invalid start_date format: %v", err)
+		}
+		req.StartDate = &startDate
+	}
+
+	if endDateStr := c.Query("end_date"); endDateStr != "" {
+		endDate, err := h.parseDateParam(endDateStr)
+		if err != nil {
+			return fmt.Errorf("Invalid end_date format: %v", err)
+		}
+		req.EndDate = &endDate
+	}
+
+	if status := c.Query("status"); status != "" {
+		req.Status = &status
+	}
+
+	if search := c.Query("search"); search != "" {
+		req.Search = &search
+	}
+
+	if partnerName := c.Query("partner_name"); partnerName != "" {
+		req.PartnerName = &partnerName
+	}
+
+	// created_by=me lists the caller's own invoices; other user IDs are for company admins
+	if createdBy := c.Query("created_by"); createdBy != "" {
+		var createdByUserID uint
+		if createdBy == "me" {
+			userID, err := middleware.GetUserIDFromContext(c)
+			if err != nil {
+				return err
+			}
+			createdByUserID = userID
+		} else {
+			id, err := strconv.ParseUint(createdBy, 10, 32)
+			if err != nil {
+				return fmt.Errorf("Invalid created_by: must be \"me\" or a user ID")
+			}
+			createdByUserID = uint(id)
+		}
+		req.CreatedByUserID = &createdByUserID
+	}
+
+	if minAmountStr := c.Query("min_amount"); minAmountStr != "" {
+		minAmount, err := strconv.ParseFloat(minAmountStr, 64)
+		if err != nil {
+			return fmt.Errorf("Invalid min_amount: %v", err)
+		}
+		req.MinAmount = &minAmount
+	}
+
+	if maxAmountStr := c.Query("max_amount"); maxAmountStr != "" {
+		maxAmount, err := strconv.ParseFloat(maxAmountStr, 64)
+		if err != nil {
+			return fmt.Errorf("Invalid max_amount: %v", err)
+		}
+		req.MaxAmount = &maxAmount
+	}
+
+	if req.MinAmount != nil && req.MaxAmount != nil && *req.MinAmount > *req.MaxAmount {
+		return fmt.Errorf("min_amount must be less than or equal to max_amount")
+	}
+
+	return nil
+}
+
+// countInvoices handles counting invoices matching the list filters
+func (h *Handler) countInvoices(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var req models.GetInvoicesRequest
+
+	if err := h.parseInvoiceFilters(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	count, err := h.service.CountInvoicesContext(c.Request.Context(), userID, &req)
+	if err != nil {
+		if errors.Is(err, service.ErrAdminRequired) {
+			adminRequired(c, err)
+			return
+		}
+		serverError(c, "invoice_retrieval_failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Invoices counted successfully",
+		Data:    gin.H{"count": count},
+	})
+}
+
+// getInvoiceStatusFacets handles listing invoice counts per status for filter UIs
+func (h *Handler) getInvoiceStatusFacets(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	facets, err := h.service.GetInvoiceStatusFacets(userID)
+	if err != nil {
+		serverError(c, "invoice_retrieval_failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Invoice status facets retrieved successfully",
+		Data:    facets,
+	})
+}
+
+// getInvoiceSummary handles retrieval of invoice counts and amount, fee and tax totals per status
+// and currency
+func (h *Handler) getInvoiceSummary(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	summary, err := h.service.GetInvoiceSummary(userID)
+	if err != nil {
+		serverError(c, "invoice_retrieval_failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Invoice summary retrieved successfully",
+		Data:    summary,
+	})
+}
+
+// parsePagination parses the page and limit query parameters into the request
+func parsePagination(c *gin.Context, req *models.GetInvoicesRequest) {
+	if pageStr := c.Query("page"); pageStr != "" {
+		if page, err := strconv.Atoi(pageStr); err == nil && page > 0 {
+			req.Page = page
+		}
+	} else {
+		req.Page = 1
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if limit, err := strconv.Atoi(limitStr); err == nil && limit > 0 {
+			req.Limit = limit
+		}
+	} else {
+		req.Limit = 20
+	}
+}
+
+// getOverdueInvoices handles retrieval of unprocessed invoices past their due date
+func (h *Handler) getOverdueInvoices(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var req models.GetInvoicesRequest
+	parsePagination(c, &req)
+
+	invoices, err := h.service.GetOverdueInvoicesContext(c.Request.Context(), userID, &req)
+	if err != nil {
+		serverError(c, "invoice_retrieval_failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Overdue invoices retrieved successfully",
+		Data:    invoices,
+	})
+}
+
+// getInvoiceCalendar handles the iCalendar feed of upcoming unprocessed invoice due dates
+func (h *Handler) getInvoiceCalendar(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	invoices, err := h.service.GetUpcomingInvoices(userID)
+	if err != nil {
+		serverError(c, "invoice_retrieval_failed", err)
+		return
+	}
+
+	c.Header("Content-Type", "text/calendar; charset=utf-8")
+	c.Header("Content-Disposition", `inline; filename="invoices.ics"`)
+	c.Status(http.StatusOK)
+
+	if _, err := ical.InvoiceCalendar(invoices).WriteTo(c.Writer); err != nil {
+		_ = c.Error(err)
+	}
+}
+
+// processOverdueInvoices handles the operator trigger for the overdue invoice job
+func (h *Handler) processOverdueInvoices(c *gin.Context) {
+	count, err := h.service.MarkOverdueInvoices()
+	if err != nil {
+		serverError(c, "overdue_processing_failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Overdue invoices processed successfully",
+		Data:    gin.H{"processed": count},
+	})
+}
+
+// purgeDeletedInvoices handles the operator trigger for the deleted invoice purge job
+func (h *Handler) purgeDeletedInvoices(c *gin.Context) {
+	count, err := h.service.PurgeDeletedInvoices()
+	if err != nil {
+		serverError(c, "invoice_purge_failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Deleted invoices purged successfully",
+		Data:    gin.H{"purged": count},
+	})
+}
+
+// getFeeRevenueReport handles retrieval of the fees collected across all companies per month
+func (h *Handler) getFeeRevenueReport(c *gin.Context) {
+	var start, end time.Time
+	for _, param := range []struct {
+		name string
+		date *time.Time
+	}{{"start", &start}, {"end", &end}} {
+		value := c.Query(param.name)
+		if value == "" {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "validation_error",
+				Message: fmt.Sprintf("%s is required", param.name),
+			})
+			return
+		}
+		date, err := h.parseDateParam(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "validation_error",
+				Message: fmt.Sprintf("Invalid %s format: %v", param.name, err),
+			})
+			return
+		}
+		*param.date = date
+	}
+	if end.Before(start) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "validation_error",
+			Message: "end must not be before start",
+		})
+		return
+	}
+
+	totals, err := h.service.GetFeeRevenue(start, end)
+	if err != nil {
+		serverError(c, "report_generation_failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Fee revenue report retrieved successfully",
+		Data:    totals,
+	})
+}
+
+// setCompanyFeeRate handles setting the fee rate negotiated by a company
+func (h *Handler) setCompanyFeeRate(c *gin.Context) {
+	companyID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid company ID",
+		})
+		return
+	}
+
+	var req models.UpdateCompanyFeeRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, bindingErrorResponse(err))
+		return
+	}
+
+	if err := h.service.SetCompanyFeeRate(uint(companyID), req.FeeRate); err != nil {
+		if errors.Is(err, service.ErrCompanyNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "company_not_found",
+				Message: err.Error(),
+			})
+			return
+		}
+		serverError(c, "fee_rate_update_failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Company fee rate updated successfully",
+		Data:    gin.H{"company_id": companyID, "fee_rate": req.FeeRate},
+	})
+}
+
+// parseDateParam parses a date-only (YYYY-MM-DD) or RFC3339 query value in the configured timezone.
+// Date-only values are normalized to midnight of that day.
+func (h *Handler) parseDateParam(value string) (time.Time, error) {
+	loc := h.config.GetLocation()
+	if date, err := time.ParseInLocation("2006-01-02", value, loc); err == nil {
+		return date, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.In(loc), nil
+}
+
+// headInvoice handles checking that an invoice of the user's company exists, answering 200 or
+// 404 without a body
+func (h *Handler) headInvoice(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+
+	invoiceID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.service.GetInvoiceByIDContext(c.Request.Context(), userID, uint(invoiceID)); err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// getInvoiceByID handles single invoice retrieval
+func (h *Handler) getInvoiceByID(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	idStr := c.Param("id")
+	invoiceID, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid invoice ID",
+		})
+		return
+	}
+
+	// expand is a comma-separated list of related resources to include
+	expandBankAccount := false
+	if expand := c.Query("expand"); expand != "" {
+		for _, field := range strings.Split(expand, ",") {
+			switch strings.TrimSpace(field) {
+			case "bank_account":
+				expandBankAccount = true
+			default:
+				c.JSON(http.StatusBadRequest, models.ErrorResponse{
+					Error:   "validation_error",
+					Message: fmt.Sprintf("unsupported expand value %q", field),
+				})
+				return
+			}
+		}
+	}
+
+	invoice, err := h.service.GetInvoiceByIDContext(c.Request.Context(), userID, uint(invoiceID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "invoice_not_found",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if expandBankAccount {
+		invoice.BankAccount, err = h.service.GetPrimaryBankAccount(userID, invoice.BusinessPartnerID)
+		if err != nil {
+			serverError(c, "bank_account_retrieval_failed", err)
+			return
+		}
+	}
+
+	var data interface{} = invoice
+	if fields := parseFieldSelection(c); fields != nil {
+		data = selectFields(invoice, fields)
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Invoice retrieved successfully",
+		Data:    data,
+	})
+}
+
+// getInvoiceByNumber handles retrieval of an invoice by its invoice number
+func (h *Handler) getInvoiceByNumber(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	invoice, err := h.service.GetInvoiceByNumberContext(c.Request.Context(), userID, c.Param("number"))
+	if err != nil {
+		if errors.Is(err, service.ErrInvoiceNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "invoice_not_found",
+				Message: "Invoice not found",
+			})
+			return
+		}
+		serverError(c, "invoice_retrieval_failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Invoice retrieved successfully",
+		Data:    invoice,
+	})
+}
+
+// downloadInvoicePDF handles downloading an invoice as a PDF rendered with the requested template
+func (h *Handler) downloadInvoicePDF(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	idStr := c.Param("id")
+	invoiceID, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid invoice ID",
+		})
+		return
+	}
+
+	templateName := c.DefaultQuery("template", pdf.DefaultTemplate)
+	render, ok := pdf.Template(templateName)
+	if !ok {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "validation_error",
+			Message: fmt.Sprintf("Unknown template %q, must be one of: %s", templateName, strings.Join(pdf.TemplateNames(), ", ")),
+		})
+		return
+	}
+
+	invoice, err := h.service.GetInvoiceByIDContext(c.Request.Context(), userID, uint(invoiceID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "invoice_not_found",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.Header("Content-Type", "application/pdf")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pdf"`, invoice.InvoiceNumber))
+	c.Status(http.StatusOK)
+
+	if _, err := render(invoice).WriteTo(c.Writer); err != nil {
+		_ = c.Error(err)
+	}
+}
+
+// issueInvoice handles issuing a draft invoice
+func (h *Handler) issueInvoice(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	idStr := c.Param("id")
+	invoiceID, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid invoice ID",
+		})
+		return
+	}
+
+	invoice, err := h.service.IssueInvoice(userID, uint(invoiceID))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvoiceNotFound):
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "invoice_not_found",
+				Message: err.Error(),
+			})
+		case errors.Is(err, service.ErrInvoiceNotDraft):
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error:   "invalid_status",
+				Message: err.Error(),
+			})
+		case errors.Is(err, service.ErrPeriodClosed):
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error:   "period_closed",
+				Message: err.Error(),
+			})
+		case errors.Is(err, service.ErrInvoiceChanged):
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error:   "invoice_changed",
+				Message: err.Error(),
+			})
+		default:
+			serverError(c, "invoice_issue_failed", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Invoice issued successfully",
+		Data:    invoice,
+	})
+}
+
+// cloneInvoice handles creating a new invoice from an existing one
+func (h *Handler) cloneInvoice(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	idStr := c.Param("id")
+	invoiceID, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid invoice ID",
+		})
+		return
+	}
+
+	// The request body is optional
+	var req models.CloneInvoiceRequest
+	if c.Request.Body != nil && c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, bindingErrorResponse(err))
+			return
+		}
+	}
+
+	if !req.PaymentDueDate.IsZero() {
+		if err := models.ValidatePaymentDueDate(req.PaymentDueDate, time.Now(), h.config.GetLocation()); err != nil {
+			validationFailed(c, err)
+			return
+		}
+	}
+
+	invoice, err := h.service.CloneInvoice(userID, uint(invoiceID), &req, c.Query("draft") == "true")
+	if err != nil {
+		if errors.Is(err, service.ErrInvoiceNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "invoice_not_found",
+				Message: err.Error(),
+			})
+			return
+		}
+		serverError(c, "invoice_creation_failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Invoice cloned successfully",
+		Data:    invoice,
+	})
+}
+
+// addInvoiceAttachment handles linking a document kept in external storage to an invoice
+func (h *Handler) addInvoiceAttachment(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	invoiceID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid invoice ID",
+		})
+		return
+	}
+
+	var req models.CreateInvoiceAttachmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, bindingErrorResponse(err))
+		return
+	}
+
+	if err := req.Validate(h.config.App.AttachmentContentTypes); err != nil {
+		validationFailed(c, err)
+		return
+	}
+
+	attachment, err := h.service.AddInvoiceAttachment(userID, uint(invoiceID), &req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvoiceNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "invoice_not_found",
+				Message: err.Error(),
+			})
+			return
+		}
+		serverError(c, "attachment_creation_failed", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Message: "Attachment added successfully",
+		Data:    attachment,
+	})
+}
+
+// getInvoiceAttachments handles listing the documents attached to an invoice
+func (h *Handler) getInvoiceAttachments(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	invoiceID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid invoice ID",
+		})
+		return
+	}
+
+	attachments, err := h.service.GetInvoiceAttachments(userID, uint(invoiceID))
+	if err != nil {
+		if errors.Is(err, service.ErrInvoiceNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "invoice_not_found",
+				Message: err.Error(),
+			})
+			return
+		}
+		serverError(c, "attachment_retrieval_failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Attachments retrieved successfully",
+		Data:    attachments,
+	})
+}
+
+// getInvoiceHistory handles retrieval of the status history of an invoice, paginated with page and
+// limit and optionally filtered by action
+func (h *Handler) getInvoiceHistory(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	invoiceID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid invoice ID",
+		})
+		return
+	}
+
+	var req models.GetInvoiceHistoryRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, bindingErrorResponse(err))
+		return
+	}
+
+	if req.Action != nil && !models.IsInvoiceAction(*req.Action) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "validation_error",
+			Message: fmt.Sprintf("Invalid action %q: must be one of %s", *req.Action, strings.Join(models.InvoiceActions, ", ")),
+		})
+		return
+	}
+
+	histories, total, err := h.service.GetInvoiceHistory(userID, uint(invoiceID), &req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvoiceNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "invoice_not_found",
+				Message: err.Error(),
+			})
+			return
+		}
+		serverError(c, "invoice_history_retrieval_failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.InvoiceHistoryResponse{
+		Message:    "Invoice history retrieved successfully",
+		Data:       histories,
+		Page:       req.Page,
+		Limit:      req.Limit,
+		Total:      total,
+		TotalPages: (total + req.Limit - 1) / req.Limit,
+	})
+}
+
+// recalculateInvoice handles re-pricing an unprocessed invoice with the current rates
+func (h *Handler) recalculateInvoice(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	invoiceID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid invoice ID",
+		})
+		return
+	}
+
+	invoice, err := h.service.RecalculateInvoice(userID, uint(invoiceID))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvoiceNotFound):
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "invoice_not_found",
+				Message: err.Error(),
+			})
+		case errors.Is(err, service.ErrPeriodClosed):
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error:   "period_closed",
+				Message: err.Error(),
+			})
+		case errors.Is(err, service.ErrInvoiceLocked):
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error:   "invoice_locked",
+				Message: err.Error(),
+			})
+		case errors.Is(err, service.ErrInvoiceChanged):
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error:   "invoice_changed",
+				Message: err.Error(),
+			})
+		default:
+			serverError(c, "invoice_recalculation_failed", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Invoice recalculated successfully",
+		Data:    invoice,
+	})
+}
+
+// calculateBatch handles quoting the fee, tax and invoice amount of several payment amounts
+func (h *Handler) calculateBatch(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var req models.CalculateBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, bindingErrorResponse(err))
+		return
+	}
+
+	breakdowns, err := h.service.CalculateBatch(userID, &req)
+	if err != nil {
+		serverError(c, "calculation_failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Invoice amounts calculated successfully",
+		Data:    breakdowns,
+	})
+}
+
+// updateInvoiceStatus handles changing the status of an invoice
+func (h *Handler) updateInvoiceStatus(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	idStr := c.Param("id")
+	invoiceID, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid invoice ID",
+		})
+		return
+	}
+
+	var req models.UpdateInvoiceStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, bindingErrorResponse(err))
+		return
+	}
+
+	invoice, err := h.service.UpdateInvoiceStatus(userID, uint(invoiceID), req.Status)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvoiceNotFound):
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "invoice_not_found",
+				Message: err.Error(),
+			})
+		case errors.Is(err, service.ErrPeriodClosed):
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error:   "period_closed",
+				Message: err.Error(),
+			})
+		case errors.Is(err, service.ErrInvalidStatusTransition):
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error:   "invalid_status",
+				Message: err.Error(),
+			})
+		case errors.Is(err, service.ErrInvoiceChanged):
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error:   "invoice_changed",
+				Message: err.Error(),
+			})
+		default:
+			serverError(c, "invoice_update_failed", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Invoice status updated successfully",
+		Data:    invoice,
+	})
+}
+
+// bulkDeleteInvoices handles deleting several draft or unprocessed invoices at once
+func (h *Handler) bulkDeleteInvoices(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var req models.BulkDeleteInvoicesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, bindingErrorResponse(err))
+		return
+	}
+
+	results, err := h.service.DeleteInvoices(userID, &req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvoicesNotDeletable) {
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error:   "invoices_not_deletable",
+				Message: "No invoices were deleted: only draft and unprocessed invoices of your company in an open accounting period can be deleted",
+				Data:    results,
+			})
+			return
+		}
+		serverError(c, "invoice_deletion_failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Invoices deleted successfully",
+		Data:    results,
+	})
+}
+
+// recordInvoicePayment handles recording a payment received against an invoice
+func (h *Handler) recordInvoicePayment(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	idStr := c.Param("id")
+	invoiceID, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid invoice ID",
+		})
+		return
+	}
+
+	var req models.RecordPaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, bindingErrorResponse(err))
+		return
+	}
+
+	invoice, err := h.service.RecordInvoicePayment(userID, uint(invoiceID), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvoiceNotFound):
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "invoice_not_found",
+				Message: err.Error(),
+			})
+		case errors.Is(err, service.ErrPeriodClosed):
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error:   "period_closed",
+				Message: err.Error(),
+			})
+		case errors.Is(err, service.ErrInvalidStatusTransition):
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error:   "invalid_status",
+				Message: err.Error(),
+			})
+		case errors.Is(err, service.ErrInvoiceChanged):
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error:   "invoice_changed",
+				Message: err.Error(),
+			})
+		case errors.Is(err, service.ErrInvalidPaymentAmount):
+			validationFailed(c, err)
+		default:
+			serverError(c, "payment_record_failed", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Message: "Payment recorded successfully",
+		Data:    invoice,
+	})
+}
+
+// getMonthlyReport handles retrieval of monthly invoice totals for a year
+func (h *Handler) getMonthlyReport(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	year := time.Now().In(h.config.GetLocation()).Year()
+	if yearStr := c.Query("year"); yearStr != "" {
+		year, err = strconv.Atoi(yearStr)
+		if err != nil || year < 1 || year > 9999 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "validation_error",
+				Message: "Invalid year",
+			})
+			return
+		}
+	}
+
+	totals, err := h.service.GetMonthlyInvoiceTotals(userID, year)
+	if err != nil {
+		serverError(c, "report_generation_failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Monthly report retrieved successfully",
+		Data:    totals,
+	})
+}
+
+// getAgingReport handles retrieval of the accounts receivable aging of unpaid invoices
+func (h *Handler) getAgingReport(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	buckets, err := h.service.GetInvoiceAging(userID)
+	if err != nil {
+		serverError(c, "report_generation_failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Aging report retrieved successfully",
+		Data:    buckets,
+	})
+}
+
+// createBusinessPartner handles business partner creation
+func (h *Handler) createBusinessPartner(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var req models.BusinessPartnerCreateRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, bindingErrorResponse(err))
+		return
+	}
+
+	// Additional validation
+	if err := req.Validate(); err != nil {
+		validationFailed(c, err)
+		return
+	}
+
+	// Optionally warn about a likely duplicate unless the client forces creation
+	if c.Query("check_duplicate") == "true" && c.Query("force") != "true" {
+		duplicate, err := h.service.FindDuplicateBusinessPartner(userID, req.CorporateName)
+		if err != nil {
+			serverError(c, "business_partner_creation_failed", err)
+			return
+		}
+		if duplicate != nil {
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error:   "possible_duplicate_partner",
+				Message: "A business partner with the same name already exists; pass force=true to create it anyway",
+				Data:    duplicate,
+			})
+			return
+		}
+	}
+
+	partner := req.ToBusinessPartner()
+
+	if err := h.service.CreateBusinessPartner(userID, partner); err != nil {
+		if errors.Is(err, service.ErrInvalidContactInfo) {
+			validationFailed(c, err)
+			return
+		}
+		serverError(c, "business_partner_creation_failed", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Message: "Business partner created successfully",
+		Data:    partner,
+	})
+}
+
+// patchBusinessPartner handles partial business partner updates
+func (h *Handler) patchBusinessPartner(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	partnerID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid business partner ID",
+		})
+		return
+	}
+
+	var req models.BusinessPartnerPatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, bindingErrorResponse(err))
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		validationFailed(c, err)
+		return
+	}
+
+	partner, err := h.service.PatchBusinessPartner(userID, uint(partnerID), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrBusinessPartnerNotFound):
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "business_partner_not_found",
+				Message: err.Error(),
+			})
+		case errors.Is(err, service.ErrInvalidContactInfo):
+			validationFailed(c, err)
+		default:
+			serverError(c, "business_partner_update_failed", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Business partner updated successfully",
+		Data:    partner,
+	})
+}
+
+// getBusinessPartners handles business partner retrieval
+func (h *Handler) getBusinessPartners(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var req models.GetBusinessPartnersRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, bindingErrorResponse(err))
+		return
+	}
+
+	partners, err := h.service.GetBusinessPartners(userID, &req)
+	if err != nil {
+		serverError(c, "business_partner_retrieval_failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Business partners retrieved successfully",
+		Data:    partners,
+	})
+}
+
+// getBusinessPartnerStats handles retrieval of a business partner's invoice aggregates
+func (h *Handler) getBusinessPartnerStats(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	idStr := c.Param("id")
+	partnerID, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid business partner ID",
+		})
+		return
+	}
+
+	stats, err := h.service.GetBusinessPartnerStats(userID, uint(partnerID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "business_partner_not_found",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Business partner stats retrieved successfully",
+		Data:    stats,
+	})
+}
+
+// downloadBusinessPartnerInvoices handles downloading a business partner's invoices as a ZIP of PDFs
+func (h *Handler) downloadBusinessPartnerInvoices(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	idStr := c.Param("id")
+	partnerID, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid business partner ID",
+		})
+		return
+	}
+
+	invoices, err := h.service.GetBusinessPartnerInvoices(userID, uint(partnerID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "business_partner_not_found",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="business_partner_%d_invoices.zip"`, partnerID))
+	c.Status(http.StatusOK)
+
+	// Each PDF is rendered straight into the response so the archive is never held in memory
+	archive := zip.NewWriter(c.Writer)
+	for _, invoice := range invoices {
+		entry, err := archive.Create(invoice.InvoiceNumber + ".pdf")
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+		if _, err := pdf.InvoiceDocument(invoice).WriteTo(entry); err != nil {
+			_ = c.Error(err)
+			return
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		_ = c.Error(err)
+	}
+}
+
+// downloadBusinessPartnerStatement handles downloading the monthly statement of a business
+// partner as a PDF. year and month default to the current month.
+func (h *Handler) downloadBusinessPartnerStatement(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	idStr := c.Param("id")
+	partnerID, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid business partner ID",
+		})
+		return
+	}
+
+	now := time.Now().In(h.config.GetLocation())
+	year, month := now.Year(), int(now.Month())
+	if yearStr := c.Query("year"); yearStr != "" {
+		year, err = strconv.Atoi(yearStr)
+		if err != nil || year < 1 || year > 9999 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "validation_error",
+				Message: "Invalid year",
+			})
+			return
+		}
+	}
+	if monthStr := c.Query("month"); monthStr != "" {
+		month, err = strconv.Atoi(monthStr)
+		if err != nil || month < 1 || month > 12 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "validation_error",
+				Message: "Invalid month",
+			})
+			return
+		}
+	}
+
+	statement, err := h.service.GetBusinessPartnerStatement(userID, uint(partnerID), year, time.Month(month))
+	if err != nil {
+		if errors.Is(err, service.ErrBusinessPartnerNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "business_partner_not_found",
+				Message: err.Error(),
+			})
+			return
+		}
+		serverError(c, "statement_generation_failed", err)
+		return
+	}
+
+	c.Header("Content-Type", "application/pdf")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="business_partner_%d_statement_%04d-%02d.pdf"`, partnerID, year, month))
+	c.Status(http.StatusOK)
+
+	if _, err := pdf.StatementDocument(statement).WriteTo(c.Writer); err != nil {
+		_ = c.Error(err)
+	}
+}
+
+// createBankAccount handles adding a bank account to a business partner
+func (h *Handler) createBankAccount(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	partnerID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid business partner ID",
+		})
+		return
+	}
+
+	var req models.BankAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, bindingErrorResponse(err))
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		validationFailed(c, err)
+		return
+	}
+
+	account, err := h.service.CreateBankAccount(userID, uint(partnerID), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrBusinessPartnerNotFound):
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "business_partner_not_found",
+				Message: err.Error(),
+			})
+		case errors.Is(err, service.ErrDuplicateBankAccount):
+			duplicateBankAccount(c, err)
+		default:
+			serverError(c, "bank_account_creation_failed", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Message: "Bank account created successfully",
+		Data:    account,
+	})
+}
+
+// updateBankAccount handles updating a business partner's bank account
+func (h *Handler) updateBankAccount(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	partnerID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid business partner ID",
+		})
+		return
+	}
+
+	accountID, err := strconv.ParseUint(c.Param("accountId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid bank account ID",
+		})
+		return
+	}
+
+	var req models.BankAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, bindingErrorResponse(err))
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		validationFailed(c, err)
+		return
+	}
+
+	account, err := h.service.UpdateBankAccount(userID, uint(partnerID), uint(accountID), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrBankAccountNotFound):
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "bank_account_not_found",
+				Message: err.Error(),
+			})
+		case errors.Is(err, service.ErrDuplicateBankAccount):
+			duplicateBankAccount(c, err)
+		default:
+			serverError(c, "bank_account_update_failed", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Bank account updated successfully",
+		Data:    account,
+	})
+}
+
+// setPrimaryBankAccount handles marking a bank account as its business partner's primary account
+func (h *Handler) setPrimaryBankAccount(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	partnerID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid business partner ID",
+		})
+		return
+	}
+
+	accountID, err := strconv.ParseUint(c.Param("accountId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid bank account ID",
+		})
+		return
+	}
+
+	account, err := h.service.SetPrimaryBankAccount(userID, uint(partnerID), uint(accountID))
+	if err != nil {
+		if errors.Is(err, service.ErrBankAccountNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "bank_account_not_found",
+				Message: err.Error(),
+			})
+			return
+		}
+		serverError(c, "bank_account_update_failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Primary bank account updated successfully",
+		Data:    account,
+	})
+}
+
+// mergeBusinessPartners handles moving all invoices of one business partner to another, used to
+// consolidate duplicate partners. With ?delete_source=true the source partner is deleted afterwards.
+func (h *Handler) mergeBusinessPartners(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	sourceID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid business partner ID",
+		})
+		return
+	}
+
+	targetID, err := strconv.ParseUint(c.Param("targetId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid target business partner ID",
+		})
+		return
+	}
+
+	result, err := h.service.MergeBusinessPartners(userID, uint(sourceID), uint(targetID), c.Query("delete_source") == "true")
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrAdminRequired):
+			adminRequired(c, err)
+		case errors.Is(err, service.ErrMergeIntoSelf):
+			validationFailed(c, err)
+		case errors.Is(err, service.ErrBusinessPartnerNotFound):
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "business_partner_not_found",
+				Message: err.Error(),
+			})
+		case errors.Is(err, service.ErrPeriodClosed):
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error:   "period_closed",
+				Message: err.Error(),
+			})
+		default:
+			serverError(c, "business_partner_merge_failed", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Business partners merged successfully",
+		Data:    result,
+	})
+}
+
+// exportBusinessPartners handles business partner export as CSV
+func (h *Handler) exportBusinessPartners(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if format := c.DefaultQuery("format", "csv"); format != "csv" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "validation_error",
+			Message: fmt.Sprintf("Unsupported export format: %s", format),
+		})
+		return
+	}
+
+	partners, err := h.service.GetBusinessPartners(userID, nil)
+	if err != nil {
+		serverError(c, "business_partner_retrieval_failed", err)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="business_partners.csv"`)
+	c.Status(http.StatusOK)
+
+	writer := csv.NewWriter(c.Writer)
+	_ = writer.Write([]string{"id", "corporate_name", "representative", "phone_number", "postal_code", "address", "created_at"})
+	for _, partner := range partners {
+		_ = writer.Write([]string{
+			strconv.FormatUint(uint64(partner.ID), 10),
+			partner.CorporateName,
+			partner.Representative,
+			partner.PhoneNumber,
+			partner.PostalCode,
+			partner.Address,
+			partner.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	writer.Flush()
+
+	if err := writer.Error(); err != nil {
+		_ = c.Error(err)
+	}
+}
+
+// importBusinessPartners handles creating business partners from an uploaded CSV file. Every
+// row is validated; the valid rows are created together and the response reports the outcome
+// of each row.
+func (h *Handler) importBusinessPartners(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "validation_error",
+			Message: "A CSV file must be uploaded in the file field",
+		})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		serverError(c, "business_partner_import_failed", err)
+		return
+	}
+	defer file.Close()
+
+	records, err := readBusinessPartnerCSV(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	response := models.BusinessPartnerImportResponse{Results: make([]models.BusinessPartnerImportResult, len(records))}
+	var partners []*models.BusinessPartner
+	var imported []int
+	for i, req := range records {
+		// The header is row 1
+		result := &response.Results[i]
+		result.Row = i + 2
+
+		if err := validateImportRow(&req); err != nil {
+			result.Error = err.Error()
+			response.Failed++
+			continue
+		}
+		partners = append(partners, req.ToBusinessPartner())
+		imported = append(imported, i)
+	}
+
+	if len(partners) > 0 {
+		if err := h.service.ImportBusinessPartners(userID, partners); err != nil {
+			if errors.Is(err, service.ErrInvalidContactInfo) {
+				validationFailed(c, err)
+				return
+			}
+			serverError(c, "business_partner_import_failed", err)
+			return
+		}
+	}
+	for n, i := range imported {
+		response.Results[i].Success = true
+		response.Results[i].BusinessPartnerID = partners[n].ID
+	}
+	response.Imported = len(partners)
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Business partners imported",
+		Data:    response,
+	})
+}
+
+// validateBusinessPartners handles checking a batch of business partner records against the
+// rules of the create endpoint without creating anything, reporting the outcome of each record
+func (h *Handler) validateBusinessPartners(c *gin.Context) {
+	// Bound without gin's struct validation, which would reject the whole batch at the first
+	// invalid record
+	var records []models.BusinessPartnerCreateRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&records); err != nil || records == nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "validation_error",
+			Message: "Request body must be a JSON array of business partners",
+		})
+		return
+	}
+
+	response := models.BusinessPartnerValidationResponse{Results: make([]models.BusinessPartnerValidationResult, len(records))}
+	for i := range records {
+		result := &response.Results[i]
+		result.Index = i
+
+		if err := validateImportRow(&records[i]); err != nil {
+			result.Errors = []string{err.Error()}
+			response.Invalid++
+			continue
+		}
+		result.Valid = true
+		response.Valid++
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Business partners validated",
+		Data:    response,
+	})
+}
+
+// readBusinessPartnerCSV reads business partner rows from a CSV file whose header must list
+// exactly models.BusinessPartnerImportColumns
+func readBusinessPartnerCSV(r io.Reader) ([]models.BusinessPartnerCreateRequest, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(models.BusinessPartnerImportColumns)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV file: %v", err)
+	}
+	// Spreadsheet applications often prefix UTF-8 files with a byte order mark
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	for i, column := range models.BusinessPartnerImportColumns {
+		if strings.ToLower(strings.TrimSpace(header[i])) != column {
+			return nil, fmt.Errorf("CSV header must be: %s", strings.Join(models.BusinessPartnerImportColumns, ","))
+		}
+	}
+
+	var records []models.BusinessPartnerCreateRequest
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV file: %v", err)
+		}
+		records = append(records, models.BusinessPartnerCreateRequest{
+			CorporateName:  strings.TrimSpace(record[0]),
+			Representative: strings.TrimSpace(record[1]),
+			PhoneNumber:    strings.TrimSpace(record[2]),
+			PostalCode:     strings.TrimSpace(record[3]),
+			Address:        strings.TrimSpace(record[4]),
+		})
+	}
+
+	return records, nil
+}
+
+// validateImportRow applies the same validation to an imported or pre-validated row as to a JSON
+// create request
+func validateImportRow(req *models.BusinessPartnerCreateRequest) error {
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			fields := make([]string, 0, len(validationErrors))
+			for _, fieldErr := range validationErrors {
+				fields = append(fields, fieldPath(fieldErr))
+			}
+			return fmt.Errorf("missing required fields: %s", strings.Join(fields, ", "))
+		}
+		return err
+	}
+	return req.Validate()
+}
+
+// updateProfile handles users changing their own full name and email. The response carries a
+// new token, as the previous ones still hold the old email.
+func (h *Handler) updateProfile(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var req models.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, bindingErrorResponse(err))
+		return
+	}
+	if err := req.Validate(); err != nil {
+		validationFailed(c, err)
+		return
+	}
+	if req.Email != nil {
+		if domain, blocked := h.config.BlockedEmailDomain(*req.Email); blocked {
+			validationFailed(c, fmt.Errorf("Email domain %s is not allowed", domain))
+			return
+		}
+	}
+
+	user, err := h.service.UpdateProfile(userID, &req)
+	if err != nil {
+		if errors.Is(err, service.ErrEmailTaken) {
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error:   "email_taken",
+				Message: err.Error(),
+			})
+			return
+		}
+		serverError(c, "profile_update_failed", err)
+		return
+	}
+
+	token, err := middleware.GenerateJWT(user, h.config)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "company_creation_failed",
+			Error:   "token_generation_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.AuthResponse{
+		Token: token,
+		User:  *user,
+	})
+}
+
+// getCompanyUsers handles listing and searching the users of the caller's company
+func (h *Handler) getCompanyUsers(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var req models.GetUsersRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, bindingErrorResponse(err))
+		return
+	}
+
+	users, total, err := h.service.GetCompanyUsers(userID, &req)
+	if err != nil {
+		if errors.Is(err, service.ErrAdminRequired) {
+			adminRequired(c, err)
+			return
+		}
+		serverError(c, "user_retrieval_failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.UserListResponse{
+		Message:    "Users retrieved successfully",
+		Data:       users,
+		Page:       req.Page,
+		Limit:      req.Limit,
+		Total:      total,
+		TotalPages: (total + req.Limit - 1) / req.Limit,
+	})
+}
+
+// deactivateUser handles disabling the login of a user of the caller's company
+func (h *Handler) deactivateUser(c *gin.Context) {
+	h.setUserActive(c, false)
+}
+
+// reactivateUser handles re-enabling the login of a deactivated user of the caller's company
+func (h *Handler) reactivateUser(c *gin.Context) {
+	h.setUserActive(c, true)
+}
+
+// setUserActive deactivates or reactivates the user in the id path parameter
+func (h *Handler) setUserActive(c *gin.Context, active bool) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	targetID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid user ID",
+		})
+		return
+	}
+
+	user, err := h.service.SetUserActive(userID, uint(targetID), active)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrAdminRequired):
+			adminRequired(c, err)
+		case errors.Is(err, service.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "user_not_found",
+				Message: "User not found",
+			})
+		case errors.Is(err, service.ErrSelfDeactivation):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "validation_error",
+				Message: err.Error(),
+			})
+		default:
+			serverError(c, "user_update_failed", err)
+		}
+		return
+	}
+
+	message := "User deactivated successfully"
+	if active {
+		message = "User reactivated successfully"
+	}
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: message,
+		Data:    user,
+	})
+}
+
+// getCompanySettings handles retrieval of the rates and payment terms of the caller's company
+func (h *Handler) getCompanySettings(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	settings, err := h.service.GetCompanySettings(userID)
+	if err != nil {
+		serverError(c, "company_settings_retrieval_failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Company settings retrieved successfully",
+		Data:    settings,
+	})
+}
+
+// updateCompanySettings handles changing the rates and payment terms of the caller's company
+func (h *Handler) updateCompanySettings(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var req models.UpdateCompanySettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, bindingErrorResponse(err))
+		return
+	}
+
+	if err := req.Validate(h.config.App.AllowedTaxRates); err != nil {
+		validationFailed(c, err)
+		return
+	}
+
+	settings, err := h.service.UpdateCompanySettings(userID, &req)
+	if err != nil {
+		if errors.Is(err, service.ErrAdminRequired) {
+			adminRequired(c, err)
+			return
+		}
+		serverError(c, "company_settings_update_failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Company settings updated successfully",
+		Data:    settings,
+	})
+}
+
+// previewRateChange handles comparing the fees of unprocessed invoices under proposed rates with
+// their current fees
+func (h *Handler) previewRateChange(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var req models.PreviewRateChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, bindingErrorResponse(err))
+		return
+	}
+
+	if err := req.Validate(h.config.App.AllowedTaxRates); err != nil {
+		validationFailed(c, err)
+		return
+	}
+
+	preview, err := h.service.PreviewRateChange(userID, &req)
+	if err != nil {
+		serverError(c, "rate_preview_failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Rate change preview calculated successfully",
+		Data:    preview,
+	})
+}
+
+// getWebhookSettings handles retrieval of the webhook endpoint of the caller's company
+func (h *Handler) getWebhookSettings(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	settings, err := h.service.GetWebhookSettings(userID)
+	if err != nil {
+		serverError(c, "webhook_settings_retrieval_failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Webhook settings retrieved successfully",
+		Data:    settings,
+	})
+}
+
+// updateWebhookSettings handles setting or clearing the webhook endpoint of the caller's company
+func (h *Handler) updateWebhookSettings(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var req models.UpdateWebhookSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, bindingErrorResponse(err))
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		validationFailed(c, err)
+		return
+	}
+
+	settings, err := h.service.UpdateWebhookSettings(userID, &req)
+	if err != nil {
+		if errors.Is(err, service.ErrAdminRequired) {
+			adminRequired(c, err)
+			return
+		}
+		serverError(c, "webhook_settings_update_failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Webhook settings updated successfully",
+		Data:    settings,
+	})
+}
+
+// getWebhookDeliveries handles listing the failed webhook deliveries of the caller's company and
+// the state of their retries
+func (h *Handler) getWebhookDeliveries(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
 			Message: err.Error(),
 		})
 		return
 	}
 
+	var req models.GetWebhookDeliveriesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, bindingErrorResponse(err))
+		return
+	}
+
+	deliveries, err := h.service.GetWebhookDeliveries(userID, &req)
+	if err != nil {
+		serverError(c, "webhook_delivery_retrieval_failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Webhook deliveries retrieved successfully",
+		Data:    deliveries,
+	})
+}
+
+// createCompany handles company creation (for admin use)
+func (h *Handler) createCompany(c *gin.Context) {
+	var company models.Company
+
+	if err := c.ShouldBindJSON(&company); err != nil {
+		c.JSON(http.StatusBadRequest, bindingErrorResponse(err))
+		return
+	}
+
+	if err := h.service.CreateCompany(&company); err != nil {
+		switch {
+		case errors.Is(err, service.ErrCompanyAlreadyExists):
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error:   "company_already_exists",
+				Message: err.Error(),
+			})
+		case errors.Is(err, service.ErrInvalidContactInfo):
+			validationFailed(c, err)
+		default:
+			serverError(c, "company_creation_failed", err)
+		}
+		return
+	}
+
 	c.JSON(http.StatusCreated, models.SuccessResponse{
 		Message: "Company created successfully",
 		Data:    company,
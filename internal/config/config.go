@@ -3,23 +3,47 @@ package config
 import (
 	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // embed the zone database so TIMEZONE works on minimal images
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/joho/godotenv"
 )
 
 // Config holds all configuration for the application
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	JWT      JWTConfig
+	Server    ServerConfig
+	Database  DatabaseConfig
+	JWT       JWTConfig
+	Auth      AuthConfig
+	App       AppConfig
+	Admin     AdminConfig
+	Scheduler SchedulerConfig
+	Webhook   WebhookConfig
+	Build     BuildInfo
 }
 
 // ServerConfig holds server configuration
 type ServerConfig struct {
 	Port string
 	Host string
+	// TrustedProxies lists the proxy IPs/CIDRs whose X-Forwarded-For header is honoured when
+	// resolving the client IP. Empty trusts no proxy and uses the connection's remote address.
+	TrustedProxies []string
+	// RateLimitPerMinute caps requests per client IP per minute (0 disables rate limiting)
+	RateLimitPerMinute int
+	// RequestTimeout is the deadline for handling a single request (0 disables it)
+	RequestTimeout time.Duration
+	// CORSMaxAge is how long browsers may cache CORS preflight responses (0 omits Access-Control-Max-Age)
+	CORSMaxAge time.Duration
+	// CacheControl is sent with successful GET responses of the API, which also get an ETag so
+	// clients can revalidate them (empty disables response caching headers)
+	CacheControl string
 }
 
 // DatabaseConfig holds database configuration
@@ -29,12 +53,166 @@ type DatabaseConfig struct {
 	User     string
 	Password string
 	Name     string
+	// Debug logs every executed SQL statement with its args and duration
+	Debug bool
+	// SlowQueryThreshold logs a warning for every SQL statement taking at least this long
+	// (0 disables the warning)
+	SlowQueryThreshold time.Duration
 }
 
+// MinJWTSecretLength is the minimum length of the secret tokens are signed with
+const MinJWTSecretLength = 16
+
+// placeholderJWTSecrets are the example JWT secrets from the documentation, which are public and so
+// must never sign real tokens
+var placeholderJWTSecrets = []string{
+	"your-secret-key-change-in-production",
+	"your-super-secret-jwt-key-change-in-production",
+	"your-super-secret-jwt-key-change-in-production-environment",
+}
+
+// MinDeletedInvoiceRetentionDays is the shortest time deleted invoices are kept before they may be purged
+const MinDeletedInvoiceRetentionDays = 30
+
 // JWTConfig holds JWT configuration
 type JWTConfig struct {
 	Secret      string
 	ExpiryHours int
+	// RememberMeExpiryDays is the lifetime of tokens issued to logins asking to be remembered
+	RememberMeExpiryDays int
+	// CalendarTokenExpiryDays is the lifetime of calendar feed tokens, which are embedded in
+	// subscription URLs and so cannot be refreshed by the calendar client
+	CalendarTokenExpiryDays int
+	// ReadTokenExpiryHours is the lifetime of read-only tokens issued to external auditors
+	ReadTokenExpiryHours int
+}
+
+// AuthConfig holds login protection configuration
+type AuthConfig struct {
+	// MaxFailedLogins failed logins for one email within FailedLoginWindow lock the account
+	// for LockoutDuration (0 disables the lockout)
+	MaxFailedLogins   int
+	FailedLoginWindow time.Duration
+	LockoutDuration   time.Duration
+	PasswordPolicy    PasswordPolicy
+	// EmailCheckRateLimitPerMinute caps email availability checks per client IP per minute, so
+	// the check cannot be used to enumerate registered emails (0 disables the limit)
+	EmailCheckRateLimitPerMinute int
+}
+
+// PasswordPolicy holds the complexity requirements for user passwords
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+}
+
+// Unmet returns a description of each requirement the password does not meet
+func (p PasswordPolicy) Unmet(password string) []string {
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSymbol = true
+		}
+	}
+
+	var unmet []string
+	if utf8.RuneCountInString(password) < p.MinLength {
+		unmet = append(unmet, fmt.Sprintf("at least %d characters", p.MinLength))
+	}
+	if p.RequireUpper && !hasUpper {
+		unmet = append(unmet, "an uppercase letter")
+	}
+	if p.RequireLower && !hasLower {
+		unmet = append(unmet, "a lowercase letter")
+	}
+	if p.RequireDigit && !hasDigit {
+		unmet = append(unmet, "a digit")
+	}
+	if p.RequireSymbol && !hasSymbol {
+		unmet = append(unmet, "a symbol")
+	}
+	return unmet
+}
+
+// AppConfig holds application-wide business configuration
+type AppConfig struct {
+	Timezone               string
+	Location               *time.Location
+	DefaultPaymentTermDays int
+	TaxAppliesTo           string
+	CompanyNameUniqueness  string
+	// AccountingCutoffDate (YYYY-MM-DD) closes the accounting period: invoices issued on or
+	// before it can no longer be updated. Empty leaves every period open.
+	AccountingCutoffDate string
+	AccountingCutoff     *time.Time
+	// DeletedInvoiceRetentionDays is how long soft-deleted invoices are kept before the purge job
+	// removes them, at least MinDeletedInvoiceRetentionDays
+	DeletedInvoiceRetentionDays int
+	// BlockedEmailDomains lists email domains (lower-cased) that may not register
+	BlockedEmailDomains []string
+	// AllowedCurrencies lists the ISO 4217 codes (upper-cased) invoices may be issued in
+	AllowedCurrencies []string
+	// AttachmentContentTypes lists the media types (lower-cased) of documents that may be attached to invoices
+	AttachmentContentTypes []string
+	// AllowedTaxRates lists the consumption tax rates a company may select
+	AllowedTaxRates []float64
+	// FXRates holds static exchange rates for presenting amounts in another currency, as units of
+	// each ISO 4217 currency (upper-cased) per 1 JPY, the default invoice currency
+	FXRates map[string]float64
+}
+
+// Consumption tax bases for AppConfig.TaxAppliesTo
+const (
+	TaxAppliesToFee           = "fee"
+	TaxAppliesToPaymentAmount = "payment_amount"
+)
+
+// Company name uniqueness modes for AppConfig.CompanyNameUniqueness
+const (
+	CompanyNameUniquenessLenient = "lenient"
+	CompanyNameUniquenessStrict  = "strict"
+)
+
+// AdminConfig holds configuration for operator-only endpoints
+type AdminConfig struct {
+	Token string
+}
+
+// BuildInfo identifies the running build. It is not read from the environment: main sets it
+// from variables injected with -ldflags at build time.
+type BuildInfo struct {
+	Version   string
+	Commit    string
+	BuildTime string
+}
+
+// SchedulerConfig holds intervals for background jobs (0 disables a job)
+type SchedulerConfig struct {
+	OverdueIntervalMinutes      int
+	WebhookRetryIntervalMinutes int
+	PurgeIntervalMinutes        int
+}
+
+// WebhookConfig holds configuration for delivering events to company webhook endpoints
+type WebhookConfig struct {
+	// Timeout bounds each delivery request
+	Timeout time.Duration
+	// MaxAttempts is the number of delivery attempts, the first included, before a delivery is dead
+	MaxAttempts int
+	// RetryBackoff is the delay before the first retry; it doubles with each further attempt
+	RetryBackoff time.Duration
+	// AllowPrivateTargets lets deliveries reach loopback, private and link-local addresses
+	AllowPrivateTargets bool
 }
 
 // Load loads configuration from environment variables
@@ -46,41 +224,216 @@ func Load() *Config {
 
 	config := &Config{
 		Server: ServerConfig{
-			Port: getEnv("SERVER_PORT", "8080"),
-			Host: getEnv("SERVER_HOST", "localhost"),
+			Port:               getEnv("SERVER_PORT", "8080"),
+			Host:               getEnv("SERVER_HOST", "localhost"),
+			TrustedProxies:     getEnvAsList("TRUSTED_PROXIES"),
+			RateLimitPerMinute: getEnvAsInt("RATE_LIMIT_PER_MINUTE", 0),
+			RequestTimeout:     time.Duration(getEnvAsInt("REQUEST_TIMEOUT_SECONDS", 30)) * time.Second,
+			CORSMaxAge:         time.Duration(getEnvAsInt("CORS_MAX_AGE_SECONDS", 600)) * time.Second,
+			CacheControl:       getEnv("CACHE_CONTROL", ""),
 		},
 		Database: DatabaseConfig{
-			Host:     getEnv("DB_HOST", "localhost"),
-			Port:     getEnv("DB_PORT", "3306"),
-			User:     getEnv("DB_USER", "root"),
-			Password: getEnv("DB_PASSWORD", ""),
-			Name:     getEnv("DB_NAME", "super_payment"),
+			Host:               getEnv("DB_HOST", "localhost"),
+			Port:               getEnv("DB_PORT", "3306"),
+			User:               getEnv("DB_USER", "root"),
+			Password:           getEnv("DB_PASSWORD", ""),
+			Name:               getEnv("DB_NAME", "super_payment"),
+			Debug:              getEnvAsBool("DB_DEBUG", false),
+			SlowQueryThreshold: time.Duration(getEnvAsInt("DB_SLOW_QUERY_THRESHOLD_MS", 500)) * time.Millisecond,
 		},
 		JWT: JWTConfig{
-			Secret:      getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
-			ExpiryHours: getEnvAsInt("JWT_EXPIRY_HOURS", 24),
+			Secret:                  getEnv("JWT_SECRET", ""),
+			ExpiryHours:             getEnvAsInt("JWT_EXPIRY_HOURS", 24),
+			RememberMeExpiryDays:    getEnvAsInt("JWT_REMEMBER_ME_EXPIRY_DAYS", 30),
+			CalendarTokenExpiryDays: getEnvAsInt("CALENDAR_TOKEN_EXPIRY_DAYS", 365),
+			ReadTokenExpiryHours:    getEnvAsInt("READ_TOKEN_EXPIRY_HOURS", 4),
+		},
+		Auth: AuthConfig{
+			MaxFailedLogins:   getEnvAsInt("LOGIN_MAX_FAILED_ATTEMPTS", 5),
+			FailedLoginWindow: time.Duration(getEnvAsInt("LOGIN_FAILURE_WINDOW_MINUTES", 15)) * time.Minute,
+			LockoutDuration:   time.Duration(getEnvAsInt("LOGIN_LOCKOUT_MINUTES", 15)) * time.Minute,
+			PasswordPolicy: PasswordPolicy{
+				MinLength:     getEnvAsInt("PASSWORD_MIN_LENGTH", 8),
+				RequireUpper:  getEnvAsBool("PASSWORD_REQUIRE_UPPER", false),
+				RequireLower:  getEnvAsBool("PASSWORD_REQUIRE_LOWER", false),
+				RequireDigit:  getEnvAsBool("PASSWORD_REQUIRE_DIGIT", false),
+				RequireSymbol: getEnvAsBool("PASSWORD_REQUIRE_SYMBOL", false),
+			},
+			EmailCheckRateLimitPerMinute: getEnvAsInt("EMAIL_CHECK_RATE_LIMIT_PER_MINUTE", 10),
+		},
+		App: AppConfig{
+			Timezone:                    getEnv("TIMEZONE", "Asia/Tokyo"),
+			DefaultPaymentTermDays:      getEnvAsInt("DEFAULT_PAYMENT_TERM_DAYS", 30),
+			TaxAppliesTo:                getEnv("TAX_APPLIES_TO", TaxAppliesToFee),
+			CompanyNameUniqueness:       getEnv("COMPANY_NAME_UNIQUENESS", CompanyNameUniquenessLenient),
+			AccountingCutoffDate:        getEnv("ACCOUNTING_CUTOFF_DATE", ""),
+			DeletedInvoiceRetentionDays: getEnvAsInt("DELETED_INVOICE_RETENTION_DAYS", 365),
+			BlockedEmailDomains:         getEnvAsList("BLOCKED_EMAIL_DOMAINS"),
+			AllowedCurrencies:           getEnvAsList("ALLOWED_CURRENCIES"),
+			AttachmentContentTypes:      getEnvAsList("ATTACHMENT_CONTENT_TYPES"),
+			AllowedTaxRates:             getEnvAsFloatList("ALLOWED_TAX_RATES"),
+			FXRates:                     parseFXRates(getEnv("FX_RATES", "")),
+		},
+		Admin: AdminConfig{
+			Token: getEnv("ADMIN_TOKEN", ""),
+		},
+		Scheduler: SchedulerConfig{
+			OverdueIntervalMinutes:      getEnvAsInt("OVERDUE_JOB_INTERVAL_MINUTES", 60),
+			WebhookRetryIntervalMinutes: getEnvAsInt("WEBHOOK_RETRY_JOB_INTERVAL_MINUTES", 1),
+			PurgeIntervalMinutes:        getEnvAsInt("PURGE_JOB_INTERVAL_MINUTES", 1440),
 		},
+		Webhook: WebhookConfig{
+			Timeout:             time.Duration(getEnvAsInt("WEBHOOK_TIMEOUT_SECONDS", 10)) * time.Second,
+			MaxAttempts:         getEnvAsInt("WEBHOOK_MAX_ATTEMPTS", 6),
+			RetryBackoff:        time.Duration(getEnvAsInt("WEBHOOK_RETRY_BACKOFF_SECONDS", 60)) * time.Second,
+			AllowPrivateTargets: getEnvAsBool("WEBHOOK_ALLOW_PRIVATE_TARGETS", false),
+		},
+		Build: BuildInfo{
+			Version:   "dev",
+			Commit:    "dev",
+			BuildTime: "unknown",
+		},
+	}
+
+	location, err := time.LoadLocation(config.App.Timezone)
+	if err != nil {
+		log.Printf("Invalid TIMEZONE %q, falling back to UTC: %v", config.App.Timezone, err)
+		config.App.Timezone = "UTC"
+		location = time.UTC
+	}
+	config.App.Location = location
+
+	if config.App.TaxAppliesTo != TaxAppliesToFee && config.App.TaxAppliesTo != TaxAppliesToPaymentAmount {
+		log.Printf("Invalid TAX_APPLIES_TO %q, falling back to %q", config.App.TaxAppliesTo, TaxAppliesToFee)
+		config.App.TaxAppliesTo = TaxAppliesToFee
+	}
+
+	if config.App.AccountingCutoffDate != "" {
+		cutoff, err := time.ParseInLocation("2006-01-02", config.App.AccountingCutoffDate, location)
+		if err != nil {
+			log.Printf("Invalid ACCOUNTING_CUTOFF_DATE %q, ignoring: %v", config.App.AccountingCutoffDate, err)
+		} else {
+			config.App.AccountingCutoff = &cutoff
+		}
+	}
+
+	if config.App.DeletedInvoiceRetentionDays < MinDeletedInvoiceRetentionDays {
+		log.Printf("DELETED_INVOICE_RETENTION_DAYS %d is below the minimum, using %d", config.App.DeletedInvoiceRetentionDays, MinDeletedInvoiceRetentionDays)
+		config.App.DeletedInvoiceRetentionDays = MinDeletedInvoiceRetentionDays
+	}
+
+	if config.App.CompanyNameUniqueness != CompanyNameUniquenessLenient && config.App.CompanyNameUniqueness != CompanyNameUniquenessStrict {
+		log.Printf("Invalid COMPANY_NAME_UNIQUENESS %q, falling back to %q", config.App.CompanyNameUniqueness, CompanyNameUniquenessLenient)
+		config.App.CompanyNameUniqueness = CompanyNameUniquenessLenient
+	}
+
+	for i, code := range config.App.AllowedCurrencies {
+		config.App.AllowedCurrencies[i] = strings.ToUpper(code)
+	}
+	if len(config.App.AllowedCurrencies) == 0 {
+		config.App.AllowedCurrencies = []string{"JPY"}
+	}
+	if len(config.App.AllowedTaxRates) == 0 {
+		config.App.AllowedTaxRates = []float64{0.08, 0.10}
+	}
+	if len(config.App.AttachmentContentTypes) == 0 {
+		config.App.AttachmentContentTypes = []string{"application/pdf", "image/png", "image/jpeg"}
 	}
 
 	return config
 }
 
+// Validate reports configuration that must not be used to serve requests, such as a JWT secret
+// too short to keep tokens from being forged or copied from the documentation
+func (c *Config) Validate() error {
+	if c.JWT.Secret == "" {
+		return fmt.Errorf("JWT_SECRET must be set")
+	}
+	if utf8.RuneCountInString(c.JWT.Secret) < MinJWTSecretLength {
+		return fmt.Errorf("JWT_SECRET must be at least %d characters", MinJWTSecretLength)
+	}
+	for _, placeholder := range placeholderJWTSecrets {
+		if c.JWT.Secret == placeholder {
+			return fmt.Errorf("JWT_SECRET must be changed from the documented example value")
+		}
+	}
+	return nil
+}
+
+// maskedSecret replaces secrets in logged configuration
+const maskedSecret = "********"
+
+// MaskSecret returns a loggable stand-in for a secret that only tells whether it is set
+func MaskSecret(secret string) string {
+	if secret == "" {
+		return "(not set)"
+	}
+	return maskedSecret
+}
+
+// LogSummary logs the effective configuration so a deployment can be checked against what was
+// intended. The database password, JWT secret and admin token are masked with MaskSecret.
+func (c *Config) LogSummary(logger *log.Logger) {
+	logger.Printf("Build: version=%s commit=%s built=%s", c.Build.Version, c.Build.Commit, c.Build.BuildTime)
+	logger.Printf("Server: address=%s trusted_proxies=%v rate_limit_per_minute=%d request_timeout=%s cors_max_age=%s cache_control=%q",
+		c.GetServerAddress(), c.Server.TrustedProxies, c.Server.RateLimitPerMinute, c.Server.RequestTimeout, c.Server.CORSMaxAge, c.Server.CacheControl)
+	logger.Printf("Database: host=%s port=%s name=%s user=%s password=%s debug=%t slow_query_threshold=%s",
+		c.Database.Host, c.Database.Port, c.Database.Name, c.Database.User, MaskSecret(c.Database.Password), c.Database.Debug, c.Database.SlowQueryThreshold)
+	logger.Printf("JWT: secret=%s expiry_hours=%d remember_me_expiry_days=%d calendar_token_expiry_days=%d read_token_expiry_hours=%d",
+		MaskSecret(c.JWT.Secret), c.JWT.ExpiryHours, c.JWT.RememberMeExpiryDays, c.JWT.CalendarTokenExpiryDays, c.JWT.ReadTokenExpiryHours)
+	logger.Printf("Auth: max_failed_logins=%d failed_login_window=%s lockout_duration=%s password_min_length=%d email_check_rate_limit_per_minute=%d",
+		c.Auth.MaxFailedLogins, c.Auth.FailedLoginWindow, c.Auth.LockoutDuration, c.Auth.PasswordPolicy.MinLength, c.Auth.EmailCheckRateLimitPerMinute)
+	logger.Printf("App: timezone=%s default_payment_term_days=%d tax_applies_to=%s allowed_tax_rates=%v allowed_currencies=%v fx_rates=%v accounting_cutoff_date=%q deleted_invoice_retention_days=%d",
+		c.GetLocation(), c.App.DefaultPaymentTermDays, c.App.TaxAppliesTo, c.App.AllowedTaxRates, c.App.AllowedCurrencies, c.App.FXRates, c.App.AccountingCutoffDate, c.App.DeletedInvoiceRetentionDays)
+	logger.Printf("Admin: token=%s", MaskSecret(c.Admin.Token))
+	logger.Printf("Scheduler: overdue_interval_minutes=%d webhook_retry_interval_minutes=%d purge_interval_minutes=%d",
+		c.Scheduler.OverdueIntervalMinutes, c.Scheduler.WebhookRetryIntervalMinutes, c.Scheduler.PurgeIntervalMinutes)
+	logger.Printf("Webhook: timeout=%s max_attempts=%d retry_backoff=%s allow_private_targets=%t",
+		c.Webhook.Timeout, c.Webhook.MaxAttempts, c.Webhook.RetryBackoff, c.Webhook.AllowPrivateTargets)
+}
+
 // GetDSN returns the database connection string
 func (c *Config) GetDSN() string {
-	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=%s",
 		c.Database.User,
 		c.Database.Password,
 		c.Database.Host,
 		c.Database.Port,
 		c.Database.Name,
+		url.QueryEscape(c.GetLocation().String()),
 	)
 }
 
+// GetLocation returns the timezone used to interpret business dates
+func (c *Config) GetLocation() *time.Location {
+	if c.App.Location == nil {
+		return time.Local
+	}
+	return c.App.Location
+}
+
 // GetServerAddress returns the server address
 func (c *Config) GetServerAddress() string {
 	return fmt.Sprintf("%s:%s", c.Server.Host, c.Server.Port)
 }
 
+// BlockedEmailDomain returns the lower-cased domain of the given email address and whether
+// that domain is blocked for registration
+func (c *Config) BlockedEmailDomain(email string) (string, bool) {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return "", false
+	}
+
+	domain := strings.ToLower(email[at+1:])
+	for _, blocked := range c.App.BlockedEmailDomains {
+		if domain == blocked {
+			return domain, true
+		}
+	}
+	return domain, false
+}
+
 // getEnv gets an environment variable with a fallback value
 func getEnv(key, fallback string) string {
 	if value, exists := os.LookupEnv(key); exists {
@@ -98,3 +451,57 @@ func getEnvAsInt(key string, fallback int) int {
 	}
 	return fallback
 }
+
+// getEnvAsBool gets an environment variable as boolean with a fallback value
+func getEnvAsBool(key string, fallback bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return fallback
+}
+
+// getEnvAsFloatList gets a comma-separated environment variable as numbers, skipping invalid entries
+func getEnvAsFloatList(key string) []float64 {
+	var values []float64
+	for _, entry := range getEnvAsList(key) {
+		value, err := strconv.ParseFloat(entry, 64)
+		if err != nil {
+			log.Printf("Invalid %s entry %q, ignoring", key, entry)
+			continue
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+// parseFXRates parses comma-separated CODE=rate pairs (e.g. "USD=0.0067,EUR=0.0062"), skipping
+// invalid entries. JPY is always included at a rate of 1.
+func parseFXRates(value string) map[string]float64 {
+	rates := map[string]float64{"JPY": 1}
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		code, rateStr, ok := strings.Cut(entry, "=")
+		rate, err := strconv.ParseFloat(strings.TrimSpace(rateStr), 64)
+		if !ok || err != nil || rate <= 0 {
+			log.Printf("Invalid FX_RATES entry %q, ignoring", entry)
+			continue
+		}
+		rates[strings.ToUpper(strings.TrimSpace(code))] = rate
+	}
+	return rates
+}
+
+// getEnvAsList gets a comma-separated environment variable as a list of trimmed, lower-cased values
+func getEnvAsList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(getEnv(key, ""), ",") {
+		if value = strings.ToLower(strings.TrimSpace(value)); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
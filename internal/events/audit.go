@@ -0,0 +1,19 @@
+package events
+
+import "log"
+
+// SubscribeAuditLog registers handlers on bus that write every invoice event to logger
+func SubscribeAuditLog(bus *Bus, logger *log.Logger) {
+	bus.Subscribe(NameInvoiceCreated, func(event Event) error {
+		e := event.(InvoiceCreated)
+		logger.Printf("Audit: %s invoice_id=%d number=%s company_id=%d status=%s amount=%.2f %s user_id=%d",
+			e.Name(), e.InvoiceID, e.InvoiceNumber, e.CompanyID, e.Status, e.InvoiceAmount, e.Currency, e.UserID)
+		return nil
+	})
+	bus.Subscribe(NameInvoiceStatusChanged, func(event Event) error {
+		e := event.(InvoiceStatusChanged)
+		logger.Printf("Audit: %s invoice_id=%d number=%s company_id=%d from=%s to=%s user_id=%d",
+			e.Name(), e.InvoiceID, e.InvoiceNumber, e.CompanyID, e.From, e.To, e.UserID)
+		return nil
+	})
+}
@@ -0,0 +1,102 @@
+package events
+
+import (
+	"log"
+	"super-payment/internal/models"
+	"sync"
+	"time"
+)
+
+// Names of the events published on a Bus
+const (
+	NameInvoiceCreated       = "invoice.created"
+	NameInvoiceStatusChanged = "invoice.status_changed"
+)
+
+// Event is a domain event published on a Bus. Events marshal to the JSON delivered to webhooks.
+type Event interface {
+	// Name identifies the kind of event subscribers register for
+	Name() string
+}
+
+// InvoiceCreated is published once a new invoice has been stored
+type InvoiceCreated struct {
+	InvoiceID     uint                 `json:"invoice_id"`
+	CompanyID     uint                 `json:"company_id"`
+	InvoiceNumber string               `json:"invoice_number"`
+	Status        models.InvoiceStatus `json:"status"`
+	InvoiceAmount float64              `json:"invoice_amount"`
+	Currency      string               `json:"currency"`
+	UserID        uint                 `json:"user_id"`
+	OccurredAt    time.Time            `json:"occurred_at"`
+}
+
+// Name implements Event
+func (InvoiceCreated) Name() string { return NameInvoiceCreated }
+
+// InvoiceStatusChanged is published once an invoice has moved from one status to another. UserID
+// is 0 when the change was made by a background job rather than a user.
+type InvoiceStatusChanged struct {
+	InvoiceID     uint                 `json:"invoice_id"`
+	CompanyID     uint                 `json:"company_id"`
+	InvoiceNumber string               `json:"invoice_number"`
+	From          models.InvoiceStatus `json:"from"`
+	To            models.InvoiceStatus `json:"to"`
+	UserID        uint                 `json:"user_id"`
+	OccurredAt    time.Time            `json:"occurred_at"`
+}
+
+// Name implements Event
+func (InvoiceStatusChanged) Name() string { return NameInvoiceStatusChanged }
+
+// Handler reacts to a published event. A returned error is logged; it never reaches the publisher.
+type Handler func(event Event) error
+
+// Bus is an in-process publish/subscribe hub. Handlers run asynchronously, each in its own
+// goroutine, so a slow or failing handler never delays or fails the code that published the event.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+	wg       sync.WaitGroup
+}
+
+// NewBus creates a new bus without subscribers
+func NewBus() *Bus {
+	return &Bus{handlers: make(map[string][]Handler)}
+}
+
+// Subscribe registers handler for the events with the given name. Subscribers are meant to be
+// registered at startup, before events are published.
+func (b *Bus) Subscribe(name string, handler Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[name] = append(b.handlers[name], handler)
+}
+
+// Publish delivers event to every handler subscribed to its name and returns without waiting for them
+func (b *Bus) Publish(event Event) {
+	b.mu.RLock()
+	handlers := b.handlers[event.Name()]
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		b.wg.Add(1)
+		go func(handler Handler) {
+			defer b.wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("Events: handler for %s panicked: %v", event.Name(), r)
+				}
+			}()
+
+			if err := handler(event); err != nil {
+				log.Printf("Events: handler for %s failed: %v", event.Name(), err)
+			}
+		}(handler)
+	}
+}
+
+// Wait blocks until every handler of the events published so far has returned
+func (b *Bus) Wait() {
+	b.wg.Wait()
+}
@@ -0,0 +1,122 @@
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	dateLayout  = "20060102"
+	stampLayout = "20060102T150405Z"
+
+	// maxLineOctets is the longest content line allowed by RFC 5545 before folding
+	maxLineOctets = 75
+)
+
+// Calendar is a minimal RFC 5545 calendar holding all-day events
+type Calendar struct {
+	prodID string
+	name   string
+	stamp  time.Time
+	events []Event
+}
+
+// Event is an all-day calendar event
+type Event struct {
+	// UID must be globally unique and stable so clients update the event rather than duplicate it
+	UID         string
+	Date        time.Time
+	Summary     string
+	Description string
+}
+
+// New creates an empty calendar identified by the given product identifier and display name
+func New(prodID, name string) *Calendar {
+	return &Calendar{prodID: prodID, name: name, stamp: time.Now().UTC()}
+}
+
+// AddEvent appends an event to the calendar
+func (c *Calendar) AddEvent(event Event) {
+	c.events = append(c.events, event)
+}
+
+// WriteTo serializes the calendar as text/calendar
+func (c *Calendar) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+
+	writeLine(bw, "BEGIN:VCALENDAR")
+	writeLine(bw, "VERSION:2.0")
+	writeLine(bw, "PRODID:"+c.prodID)
+	writeLine(bw, "CALSCALE:GREGORIAN")
+	writeLine(bw, "METHOD:PUBLISH")
+	if c.name != "" {
+		writeLine(bw, "X-WR-CALNAME:"+escapeText(c.name))
+	}
+
+	for _, event := range c.events {
+		writeLine(bw, "BEGIN:VEVENT")
+		writeLine(bw, "UID:"+escapeText(event.UID))
+		writeLine(bw, "DTSTAMP:"+c.stamp.Format(stampLayout))
+		// All-day events end on the following day (DTEND is exclusive)
+		writeLine(bw, "DTSTART;VALUE=DATE:"+event.Date.Format(dateLayout))
+		writeLine(bw, "DTEND;VALUE=DATE:"+event.Date.AddDate(0, 0, 1).Format(dateLayout))
+		writeLine(bw, "SUMMARY:"+escapeText(event.Summary))
+		if event.Description != "" {
+			writeLine(bw, "DESCRIPTION:"+escapeText(event.Description))
+		}
+		writeLine(bw, "END:VEVENT")
+	}
+
+	writeLine(bw, "END:VCALENDAR")
+
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// escapeText escapes a TEXT property value
+func escapeText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", "",
+	).Replace(s)
+}
+
+// writeLine writes a CRLF-terminated content line, folding it onto continuation lines
+// (starting with a space) so that no line exceeds 75 octets. Lines are only folded
+// between characters so multi-byte UTF-8 sequences stay intact.
+func writeLine(w *bufio.Writer, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		_, _ = w.WriteString(line[:cut])
+		_, _ = w.WriteString("\r\n ")
+		line = line[cut:]
+		// The leading space of a continuation line counts towards its length
+		limit = maxLineOctets - 1
+	}
+	_, _ = w.WriteString(line)
+	_, _ = w.WriteString("\r\n")
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,33 @@
+package ical
+
+import (
+	"fmt"
+	"super-payment/internal/models"
+)
+
+// ProdID identifies this service as the producer of its calendars
+const ProdID = "-//super-payment//Invoice Due Dates//EN"
+
+// InvoiceCalendar builds a calendar with one all-day event per invoice on its payment due date
+func InvoiceCalendar(invoices []*models.Invoice) *Calendar {
+	cal := New(ProdID, "Invoice due dates")
+	for _, invoice := range invoices {
+		cal.AddEvent(InvoiceEvent(invoice))
+	}
+	return cal
+}
+
+// InvoiceEvent describes an invoice's payment due date as a calendar event
+func InvoiceEvent(invoice *models.Invoice) Event {
+	partner := fmt.Sprintf("Business partner #%d", invoice.BusinessPartnerID)
+	if invoice.BusinessPartner != nil {
+		partner = invoice.BusinessPartner.CorporateName
+	}
+
+	return Event{
+		UID:         fmt.Sprintf("invoice-%d@super-payment", invoice.ID),
+		Date:        invoice.PaymentDueDate.Time,
+		Summary:     fmt.Sprintf("%s %.2f", partner, invoice.InvoiceAmount),
+		Description: fmt.Sprintf("Invoice #%d issued %s, due %s", invoice.ID, invoice.IssueDate.Format("2006-01-02"), invoice.PaymentDueDate.Format("2006-01-02")),
+	}
+}
@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"super-payment/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ETagMiddleware lets clients cache GET responses briefly. Successful responses get the
+// configured Cache-Control header and a weak ETag computed from the response body, and a
+// request whose If-None-Match matches that ETag is answered with 304 Not Modified and no body.
+// Responses are buffered to hash them, so routes streaming files are listed in exempt by their
+// route pattern (as returned by c.FullPath()). An empty cfg.Server.CacheControl disables it.
+func ETagMiddleware(cfg *config.Config, exempt ...string) gin.HandlerFunc {
+	cacheControl := cfg.Server.CacheControl
+	if cacheControl == "" {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	exemptRoutes := make(map[string]bool, len(exempt))
+	for _, route := range exempt {
+		exemptRoutes[route] = true
+	}
+
+	return func(c *gin.Context) {
+		// Only idempotent reads are cacheable
+		if c.Request.Method != http.MethodGet || exemptRoutes[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		original := c.Writer
+		bw := &bufferedWriter{ResponseWriter: original}
+		c.Writer = bw
+		defer func() { c.Writer = original }()
+		c.Next()
+
+		status := bw.Status()
+		if status != http.StatusOK {
+			original.WriteHeader(status)
+			_, _ = original.Write(bw.body.Bytes())
+			return
+		}
+
+		etag := weakETag(bw.body.Bytes())
+		header := original.Header()
+		header.Set("ETag", etag)
+		header.Set("Cache-Control", cacheControl)
+		// Responses depend on the authenticated user
+		header.Add("Vary", "Authorization")
+
+		if etagMatches(c.GetHeader("If-None-Match"), etag) {
+			header.Del("Content-Type")
+			header.Del("Content-Length")
+			original.WriteHeader(http.StatusNotModified)
+			original.WriteHeaderNow()
+			return
+		}
+
+		original.WriteHeader(status)
+		_, _ = original.Write(bw.body.Bytes())
+	}
+}
+
+// weakETag returns a weak entity tag derived from a hash of body
+func weakETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header matches etag, using the weak comparison
+// RFC 9110 prescribes for If-None-Match
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferedWriter holds back the handler's status and body so they can be inspected before
+// anything is sent to the client
+type bufferedWriter struct {
+	gin.ResponseWriter
+	body   bytes.Buffer
+	status int
+}
+
+func (bw *bufferedWriter) WriteHeader(code int) {
+	bw.status = code
+}
+
+func (bw *bufferedWriter) WriteHeaderNow() {}
+
+func (bw *bufferedWriter) Write(data []byte) (int, error) {
+	return bw.body.Write(data)
+}
+
+func (bw *bufferedWriter) WriteString(s string) (int, error) {
+	return bw.body.WriteString(s)
+}
+
+func (bw *bufferedWriter) Status() int {
+	if bw.status == 0 {
+		return http.StatusOK
+	}
+	return bw.status
+}
+
+func (bw *bufferedWriter) Size() int {
+	return bw.body.Len()
+}
+
+func (bw *bufferedWriter) Written() bool {
+	return bw.status != 0 || bw.body.Len() > 0
+}
+
+func (bw *bufferedWriter) Flush() {}
@@ -1,11 +1,17 @@
 package middleware
 
 import (
+	"crypto/subtle"
+	"errors"
 	"fmt"
+	"mime"
 	"net/http"
+	"strconv"
 	"strings"
 	"super-payment/internal/config"
 	"super-payment/internal/models"
+	"super-payment/internal/repository"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -17,11 +23,28 @@ type JWTClaims struct {
 	UserID    uint   `json:"user_id"`
 	CompanyID uint   `json:"company_id"`
 	Email     string `json:"email"`
+	// Scope restricts the token to a single feature; session tokens have no scope
+	Scope string `json:"scope,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// JWTMiddleware creates a JWT middleware
-func JWTMiddleware(cfg *config.Config) gin.HandlerFunc {
+// ErrJWTSecretNotConfigured is returned when tokens would be signed or verified with an empty secret
+var ErrJWTSecretNotConfigured = errors.New("JWT secret is not configured")
+
+// ScopeCalendar limits a token to reading the invoice calendar feed
+const ScopeCalendar = "calendar"
+
+// ScopeRead limits a token to read-only API requests, for external auditors
+const ScopeRead = "read"
+
+// ActiveUserChecker reports whether a user may still use the API
+type ActiveUserChecker interface {
+	IsUserActive(userID uint) (bool, error)
+}
+
+// JWTMiddleware creates a JWT middleware. When users is not nil, tokens of deactivated users
+// are rejected even before they expire.
+func JWTMiddleware(cfg *config.Config, users ActiveUserChecker) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -43,53 +66,232 @@ func JWTMiddleware(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
-		token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-			return []byte(cfg.JWT.Secret), nil
-		})
+		claims, err := ParseJWT(tokenString, cfg)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+				Error:   "unauthorized",
+				Message: err.Error(),
+			})
+			c.Abort()
+			return
+		}
 
-		if err != nil || !token.Valid {
+		switch claims.Scope {
+		case "":
+		case ScopeRead:
+			if !isReadOnlyMethod(c.Request.Method) {
+				c.JSON(http.StatusForbidden, models.ErrorResponse{
+					Error:   "insufficient_scope",
+					Message: "Token only grants read access",
+				})
+				c.Abort()
+				return
+			}
+		default:
+			// Other scoped tokens travel in URLs and must not grant general API access
 			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
 				Error:   "unauthorized",
-				Message: "Invalid token",
+				Message: "Token is not valid for this endpoint",
 			})
 			c.Abort()
 			return
 		}
 
-		claims, ok := token.Claims.(*JWTClaims)
-		if !ok {
+		if !checkUserActive(c, users, claims.UserID) {
+			return
+		}
+
+		setClaims(c, claims)
+		c.Next()
+	}
+}
+
+// QueryTokenMiddleware authenticates requests by a token carrying the given scope in the
+// "token" query parameter, for clients such as calendar applications that cannot send
+// an Authorization header
+func QueryTokenMiddleware(cfg *config.Config, users ActiveUserChecker, scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := c.Query("token")
+		if tokenString == "" {
 			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
 				Error:   "unauthorized",
-				Message: "Invalid token claims",
+				Message: "token query parameter required",
 			})
 			c.Abort()
 			return
 		}
 
-		// Set user information in context
-		c.Set("user_id", claims.UserID)
-		c.Set("company_id", claims.CompanyID)
-		c.Set("email", claims.Email)
+		claims, err := ParseJWT(tokenString, cfg)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+				Error:   "unauthorized",
+				Message: err.Error(),
+			})
+			c.Abort()
+			return
+		}
+
+		if claims.Scope != scope {
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+				Error:   "unauthorized",
+				Message: "Token is not valid for this endpoint",
+			})
+			c.Abort()
+			return
+		}
 
+		if !checkUserActive(c, users, claims.UserID) {
+			return
+		}
+
+		setClaims(c, claims)
 		c.Next()
 	}
 }
 
+// isReadOnlyMethod reports whether requests with the given method cannot change any data
+func isReadOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// checkUserActive aborts the request unless the token's user is still active
+func checkUserActive(c *gin.Context, users ActiveUserChecker, userID uint) bool {
+	if users == nil {
+		return true
+	}
+
+	active, err := users.IsUserActive(userID)
+	if err != nil {
+		if repository.IsConnectionError(err) {
+			c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{
+				Error:   "service_unavailable",
+				Message: "The service is temporarily unavailable, please retry later",
+			})
+		} else {
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+				Error:   "unauthorized",
+				Message: "User not found",
+			})
+		}
+		c.Abort()
+		return false
+	}
+	if !active {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "account_disabled",
+			Message: "This account has been deactivated",
+		})
+		c.Abort()
+		return false
+	}
+	return true
+}
+
+// setClaims stores the authenticated user's information in the gin context
+func setClaims(c *gin.Context, claims *JWTClaims) {
+	c.Set("user_id", claims.UserID)
+	c.Set("company_id", claims.CompanyID)
+	c.Set("email", claims.Email)
+	c.Set("claims", claims)
+}
+
+// AdminTokenMiddleware restricts access to operators presenting the configured X-Admin-Token
+func AdminTokenMiddleware(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.GetHeader("X-Admin-Token")
+		if cfg.Admin.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(cfg.Admin.Token)) != 1 {
+			c.JSON(http.StatusForbidden, models.ErrorResponse{
+				Error:   "forbidden",
+				Message: "Valid admin token required",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// ParseJWT validates a token string and returns its claims
+func ParseJWT(tokenString string, cfg *config.Config) (*JWTClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return signingKey(cfg)
+	})
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("Invalid token")
+	}
+
+	claims, ok := token.Claims.(*JWTClaims)
+	if !ok {
+		return nil, fmt.Errorf("Invalid token claims")
+	}
+
+	return claims, nil
+}
+
 // GenerateJWT generates a JWT token for a user
 func GenerateJWT(user *models.User, cfg *config.Config) (string, error) {
+	return GenerateJWTWithExpiry(user, time.Duration(cfg.JWT.ExpiryHours)*time.Hour, cfg)
+}
+
+// GenerateJWTWithExpiry generates a JWT token for a user valid for expiry
+func GenerateJWTWithExpiry(user *models.User, expiry time.Duration, cfg *config.Config) (string, error) {
 	claims := JWTClaims{
 		UserID:    user.ID,
 		CompanyID: user.CompanyID,
 		Email:     user.Email,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(cfg.JWT.ExpiryHours) * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiry)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
 	}
 
+	key, err := signingKey(cfg)
+	if err != nil {
+		return "", err
+	}
+
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(cfg.JWT.Secret))
+	return token.SignedString(key)
+}
+
+// GenerateScopedJWT generates a token for a user that is only accepted by endpoints
+// requiring the given scope, valid for expiry
+func GenerateScopedJWT(user *models.User, scope string, expiry time.Duration, cfg *config.Config) (string, time.Time, error) {
+	key, err := signingKey(cfg)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(expiry)
+	claims := JWTClaims{
+		UserID:    user.ID,
+		CompanyID: user.CompanyID,
+		Email:     user.Email,
+		Scope:     scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// signingKey returns the key tokens are signed and verified with. An empty secret is refused, as
+// anyone could sign tokens with it.
+func signingKey(cfg *config.Config) ([]byte, error) {
+	if cfg.JWT.Secret == "" {
+		return nil, ErrJWTSecretNotConfigured
+	}
+	return []byte(cfg.JWT.Secret), nil
 }
 
 // GetUserIDFromContext extracts user ID from gin context
@@ -107,13 +309,43 @@ func GetUserIDFromContext(c *gin.Context) (uint, error) {
 	return id, nil
 }
 
-// CORSMiddleware handles CORS
-func CORSMiddleware() gin.HandlerFunc {
+// GetClaimsFromContext extracts the validated token claims from gin context
+func GetClaimsFromContext(c *gin.Context) (*JWTClaims, error) {
+	value, exists := c.Get("claims")
+	if !exists {
+		return nil, fmt.Errorf("token claims not found in context")
+	}
+
+	claims, ok := value.(*JWTClaims)
+	if !ok {
+		return nil, fmt.Errorf("invalid token claims type")
+	}
+
+	return claims, nil
+}
+
+// CORSMiddleware handles CORS. Preflight responses may be cached by browsers for
+// cfg.Server.CORSMaxAge. Access-Control-Allow-Methods lists the methods registered for the
+// requested path, read from routes on the first request, once every route is registered.
+func CORSMiddleware(cfg *config.Config, routes func() gin.RoutesInfo) gin.HandlerFunc {
+	maxAge := ""
+	if seconds := int(cfg.Server.CORSMaxAge.Seconds()); seconds > 0 {
+		maxAge = strconv.Itoa(seconds)
+	}
+
+	var once sync.Once
+	var methods *routeMethods
+
 	return func(c *gin.Context) {
+		once.Do(func() { methods = newRouteMethods(routes()) })
+
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Credentials", "true")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Header("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, "+APIVersionHeader+", "+IdempotencyKeyHeader)
+		c.Header("Access-Control-Allow-Methods", methods.allowed(c.Request.URL.Path))
+		if maxAge != "" {
+			c.Header("Access-Control-Max-Age", maxAge)
+		}
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -124,10 +356,78 @@ func CORSMiddleware() gin.HandlerFunc {
 	}
 }
 
-// LoggingMiddleware logs HTTP requests
+// corsMethodOrder is the order methods are listed in Access-Control-Allow-Methods
+var corsMethodOrder = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+}
+
+// routeMethods looks up the methods registered for a request path
+type routeMethods struct {
+	routes []routePattern
+}
+
+// routePattern is a registered route split into path segments
+type routePattern struct {
+	method   string
+	segments []string
+}
+
+func newRouteMethods(routes gin.RoutesInfo) *routeMethods {
+	patterns := make([]routePattern, len(routes))
+	for i, route := range routes {
+		patterns[i] = routePattern{method: route.Method, segments: strings.Split(strings.Trim(route.Path, "/"), "/")}
+	}
+	return &routeMethods{routes: patterns}
+}
+
+// allowed returns the methods registered for path, followed by OPTIONS, which every path answers
+func (m *routeMethods) allowed(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	registered := make(map[string]bool)
+	for _, route := range m.routes {
+		if route.matches(segments) {
+			registered[route.method] = true
+		}
+	}
+
+	var methods []string
+	for _, method := range corsMethodOrder {
+		if registered[method] {
+			methods = append(methods, method)
+		}
+	}
+	return strings.Join(append(methods, http.MethodOptions), ", ")
+}
+
+// matches reports whether a request path, split into segments, is served by the route. A :param
+// segment matches any single segment and a *wildcard segment matches the rest of the path.
+func (p routePattern) matches(segments []string) bool {
+	for i, segment := range p.segments {
+		switch {
+		case strings.HasPrefix(segment, "*"):
+			return true
+		case i >= len(segments):
+			return false
+		case strings.HasPrefix(segment, ":"):
+			if segments[i] == "" {
+				return false
+			}
+		case segment != segments[i]:
+			return false
+		}
+	}
+	return len(segments) == len(p.segments)
+}
+
+// IdempotencyKeyHeader is the request header clients tag retries of the same request with
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// LoggingMiddleware logs HTTP requests. The logged client IP is c.ClientIP(), which honours
+// X-Forwarded-For only from the router's trusted proxies. Requests carrying an
+// IdempotencyKeyHeader get an idempotency_key field so client retries can be traced in the logs.
 func LoggingMiddleware() gin.HandlerFunc {
 	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
-		return fmt.Sprintf("%s - [%s] \"%s %s %s %d %s \"%s\" %s\"\n",
+		line := fmt.Sprintf("%s - [%s] \"%s %s %s %d %s \"%s\" %s\"",
 			param.ClientIP,
 			param.TimeStamp.Format(time.RFC1123),
 			param.Method,
@@ -138,9 +438,108 @@ func LoggingMiddleware() gin.HandlerFunc {
 			param.Request.UserAgent(),
 			param.ErrorMessage,
 		)
+		if key := param.Request.Header.Get(IdempotencyKeyHeader); key != "" {
+			// Quoted so a client-supplied key cannot forge log lines
+			line += fmt.Sprintf(" idempotency_key=%q", key)
+		}
+		return line + "\n"
 	})
 }
 
+// IdempotencyKeyMiddleware echoes the request's IdempotencyKeyHeader on the response, error
+// responses included, so a client can match each outcome to the retry that produced it
+func IdempotencyKeyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key := c.GetHeader(IdempotencyKeyHeader); key != "" {
+			c.Header(IdempotencyKeyHeader, key)
+		}
+		c.Next()
+	}
+}
+
+// RateLimitMiddleware limits each client IP, as resolved by c.ClientIP(), to
+// cfg.Server.RateLimitPerMinute requests per minute
+func RateLimitMiddleware(cfg *config.Config) gin.HandlerFunc {
+	return RateLimit(cfg.Server.RateLimitPerMinute)
+}
+
+// RateLimit limits each client IP to limit requests per minute (0 disables it). Every call keeps
+// its own counters, so it can add a stricter limit to individual routes.
+func RateLimit(limit int) gin.HandlerFunc {
+	if limit <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	var (
+		mu          sync.Mutex
+		windowStart time.Time
+		counts      = make(map[string]int)
+	)
+
+	return func(c *gin.Context) {
+		now := time.Now().Truncate(time.Minute)
+		ip := c.ClientIP()
+
+		mu.Lock()
+		if !now.Equal(windowStart) {
+			windowStart = now
+			counts = make(map[string]int)
+		}
+		counts[ip]++
+		exceeded := counts[ip] > limit
+		mu.Unlock()
+
+		if exceeded {
+			c.Header("Retry-After", fmt.Sprintf("%d", int(time.Until(now.Add(time.Minute)).Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, models.ErrorResponse{
+				Error:   "rate_limited",
+				Message: "Too many requests, please retry later",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// JSONContentTypeMiddleware rejects POST, PUT and PATCH requests with a body unless it is sent
+// as application/json. Routes exchanging files are listed in exempt by their route pattern
+// (as returned by c.FullPath()).
+func JSONContentTypeMiddleware(exempt ...string) gin.HandlerFunc {
+	exemptRoutes := make(map[string]bool, len(exempt))
+	for _, route := range exempt {
+		exemptRoutes[route] = true
+	}
+
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+		default:
+			c.Next()
+			return
+		}
+
+		// Bodyless actions such as issuing an invoice need no content type
+		if c.Request.ContentLength == 0 || exemptRoutes[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		mediaType, _, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+		if err != nil || mediaType != "application/json" {
+			c.JSON(http.StatusUnsupportedMediaType, models.ErrorResponse{
+				Error:   "unsupported_media_type",
+				Message: "Content-Type must be application/json",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
 // ErrorHandlingMiddleware handles panics and errors
 func ErrorHandlingMiddleware() gin.HandlerFunc {
 	return gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
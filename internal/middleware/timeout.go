@@ -0,0 +1,210 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"super-payment/internal/config"
+	"super-payment/internal/models"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TimeoutMiddleware gives each request a deadline of cfg.Server.RequestTimeout. The remaining
+// handlers run with the deadline on c.Request.Context(); when it passes before they finish, the
+// client receives 503 request_timeout (unless the handler already started its response) and
+// anything the handler writes afterwards is discarded.
+func TimeoutMiddleware(cfg *config.Config) gin.HandlerFunc {
+	timeout := cfg.Server.RequestTimeout
+	if timeout <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		original := c.Writer
+		tw := newTimeoutWriter(ctx, original)
+		c.Writer = tw
+		defer func() { c.Writer = original }()
+
+		done := make(chan struct{})
+		panicked := make(chan interface{}, 1)
+		go func() {
+			defer close(done)
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- p
+				}
+			}()
+			c.Next()
+		}()
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			tw.timeout()
+			// The gin context is recycled once this middleware returns, so wait for the handler
+			// to observe the cancelled context and unwind before releasing it
+			<-done
+		}
+
+		// Re-raise handler panics on this goroutine so the recovery middleware handles them
+		select {
+		case p := <-panicked:
+			panic(p)
+		default:
+		}
+
+		tw.WriteHeaderNow()
+	}
+}
+
+// timeoutWriter holds back the handler's status and headers until the first write so that a
+// timeout response can still be sent, and drops all writes once the request has timed out
+type timeoutWriter struct {
+	gin.ResponseWriter
+	ctx context.Context
+
+	mu        sync.Mutex
+	header    http.Header
+	status    int
+	committed bool
+	timedOut  bool
+}
+
+func newTimeoutWriter(ctx context.Context, w gin.ResponseWriter) *timeoutWriter {
+	return &timeoutWriter{
+		ResponseWriter: w,
+		ctx:            ctx,
+		header:         w.Header().Clone(),
+		status:         http.StatusOK,
+	}
+}
+
+// timeout sends the timeout response if the handler has not started its response yet
+func (tw *timeoutWriter) timeout() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	tw.timeoutLocked()
+}
+
+// checkTimeout handles a deadline that passed before the middleware noticed, so that a handler
+// reacting to the cancelled context cannot slip its response in first; tw.mu must be held
+func (tw *timeoutWriter) checkTimeout() bool {
+	if !tw.timedOut && errors.Is(tw.ctx.Err(), context.DeadlineExceeded) {
+		tw.timeoutLocked()
+	}
+	return tw.timedOut
+}
+
+// timeoutLocked is timeout with tw.mu held
+func (tw *timeoutWriter) timeoutLocked() {
+	tw.timedOut = true
+	if tw.committed {
+		return
+	}
+	tw.committed = true
+
+	body, _ := json.Marshal(models.ErrorResponse{
+		Error:   "request_timeout",
+		Message: "The request took too long to process",
+	})
+	tw.ResponseWriter.Header().Set("Content-Type", "application/json; charset=utf-8")
+	tw.ResponseWriter.WriteHeader(http.StatusServiceUnavailable)
+	_, _ = tw.ResponseWriter.Write(body)
+	// Send the response now rather than when the handler eventually returns
+	tw.ResponseWriter.Flush()
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if !tw.committed && code > 0 {
+		tw.status = code
+	}
+}
+
+// WriteHeaderNow sends the held-back status and headers
+func (tw *timeoutWriter) WriteHeaderNow() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.checkTimeout() {
+		return
+	}
+	tw.commit()
+}
+
+func (tw *timeoutWriter) Write(data []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.checkTimeout() {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.commit()
+	return tw.ResponseWriter.Write(data)
+}
+
+func (tw *timeoutWriter) WriteString(s string) (int, error) {
+	return tw.Write([]byte(s))
+}
+
+func (tw *timeoutWriter) Status() int {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if !tw.committed {
+		return tw.status
+	}
+	return tw.ResponseWriter.Status()
+}
+
+func (tw *timeoutWriter) Written() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	return tw.committed
+}
+
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.checkTimeout() {
+		return
+	}
+	tw.commit()
+	tw.ResponseWriter.Flush()
+}
+
+// commit copies the held-back headers and status to the underlying writer; tw.mu must be held
+func (tw *timeoutWriter) commit() {
+	if tw.committed {
+		return
+	}
+	tw.committed = true
+
+	header := tw.ResponseWriter.Header()
+	for key := range header {
+		if _, ok := tw.header[key]; !ok {
+			header.Del(key)
+		}
+	}
+	for key, values := range tw.header {
+		header[key] = values
+	}
+	tw.ResponseWriter.WriteHeader(tw.status)
+	tw.ResponseWriter.WriteHeaderNow()
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"super-payment/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIVersionHeader is the request header clients select the shape of API responses with. It is
+// echoed on every response with the version that was served.
+const APIVersionHeader = "X-Api-Version"
+
+// API versions accepted in APIVersionHeader
+const (
+	// APIVersion1 lists invoices as a bare array of the requested page
+	APIVersion1 = "v1"
+	// APIVersion2 lists invoices in a paginated envelope with the total number of matches
+	APIVersion2 = "v2"
+	// LatestAPIVersion is served to requests that do not ask for a version
+	LatestAPIVersion = APIVersion2
+)
+
+var apiVersions = []string{APIVersion1, APIVersion2}
+
+// apiVersionKey is the gin context key of the negotiated API version
+const apiVersionKey = "api_version"
+
+// APIVersionMiddleware negotiates the API version of each request from APIVersionHeader, accepting
+// "v2" as well as "2", and answers versions it does not know with 400 unsupported_api_version.
+// Handlers read the result with GetAPIVersion.
+func APIVersionMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		version := LatestAPIVersion
+		if requested := strings.ToLower(strings.TrimSpace(c.GetHeader(APIVersionHeader))); requested != "" {
+			if !strings.HasPrefix(requested, "v") {
+				requested = "v" + requested
+			}
+			if !isAPIVersion(requested) {
+				c.JSON(http.StatusBadRequest, models.ErrorResponse{
+					Error:   "unsupported_api_version",
+					Message: fmt.Sprintf("Unsupported %s %q: must be one of %s", APIVersionHeader, c.GetHeader(APIVersionHeader), strings.Join(apiVersions, ", ")),
+				})
+				c.Abort()
+				return
+			}
+			version = requested
+		}
+
+		c.Set(apiVersionKey, version)
+		c.Header(APIVersionHeader, version)
+		// Responses depend on the requested version
+		c.Writer.Header().Add("Vary", APIVersionHeader)
+		c.Next()
+	}
+}
+
+// GetAPIVersion returns the API version negotiated for the request, or LatestAPIVersion when
+// APIVersionMiddleware did not run
+func GetAPIVersion(c *gin.Context) string {
+	if version, ok := c.Get(apiVersionKey); ok {
+		if v, ok := version.(string); ok {
+			return v
+		}
+	}
+	return LatestAPIVersion
+}
+
+func isAPIVersion(version string) bool {
+	for _, v := range apiVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
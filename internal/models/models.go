@@ -1,8 +1,13 @@
 package models
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
+	"mime"
+	"net/url"
 	"regexp"
+	"strings"
 	"time"
 )
 
@@ -14,8 +19,8 @@ type Company struct {
 	PhoneNumber    string    `json:"phone_number" db:"phone_number" binding:"required"`
 	PostalCode     string    `json:"postal_code" db:"postal_code" binding:"required"`
 	Address        string    `json:"address" db:"address" binding:"required"`
-	CreatedAt      time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+	CreatedAt      Timestamp `json:"created_at" db:"created_at"`
+	UpdatedAt      Timestamp `json:"updated_at" db:"updated_at"`
 }
 
 // User represents a user entity linked to a company
@@ -25,11 +30,28 @@ type User struct {
 	FullName  string    `json:"full_name" db:"full_name" binding:"required"`
 	Email     string    `json:"email" db:"email" binding:"required,email"`
 	Password  string    `json:"-" db:"password" binding:"required,min=8"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	Role      UserRole  `json:"role" db:"role"`
+	IsActive  bool      `json:"is_active" db:"is_active"`
+	CreatedAt Timestamp `json:"created_at" db:"created_at"`
+	UpdatedAt Timestamp `json:"updated_at" db:"updated_at"`
 	Company   *Company  `json:"company,omitempty"`
 }
 
+// UserRole represents a user's role within their company
+type UserRole string
+
+const (
+	// UserRoleAdmin manages the company's data; the user who registers a company is its admin
+	UserRoleAdmin UserRole = "admin"
+	// UserRoleMember is a regular user of the company
+	UserRoleMember UserRole = "member"
+)
+
+// IsAdmin reports whether the user is an admin of their company
+func (u *User) IsAdmin() bool {
+	return u.Role == UserRoleAdmin
+}
+
 // BusinessPartner represents a business partner entity linked to a company
 type BusinessPartner struct {
 	ID             uint      `json:"id" db:"id"`
@@ -39,66 +61,494 @@ type BusinessPartner struct {
 	PhoneNumber    string    `json:"phone_number" db:"phone_number" binding:"required"`
 	PostalCode     string    `json:"postal_code" db:"postal_code" binding:"required"`
 	Address        string    `json:"address" db:"address" binding:"required"`
-	CreatedAt      time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+	CreatedAt      Timestamp `json:"created_at" db:"created_at"`
+	UpdatedAt      Timestamp `json:"updated_at" db:"updated_at"`
+}
+
+// BusinessPartnerStats represents aggregated invoice figures for a business partner
+type BusinessPartnerStats struct {
+	BusinessPartnerID uint       `json:"business_partner_id"`
+	InvoiceCount      int        `json:"invoice_count"`
+	TotalInvoiced     float64    `json:"total_invoiced"`
+	TotalPaid         float64    `json:"total_paid"`
+	LastIssueDate     *time.Time `json:"last_issue_date"`
+}
+
+// BusinessPartnerStatement lists the invoices issued to a business partner in one month, in issue
+// date order
+type BusinessPartnerStatement struct {
+	Company         *Company
+	BusinessPartner *BusinessPartner
+	Year            int
+	Month           time.Month
+	Invoices        []*Invoice
+}
+
+// BusinessPartnerMergeResult describes the outcome of merging one business partner into another
+type BusinessPartnerMergeResult struct {
+	SourceID           uint  `json:"source_id"`
+	TargetID           uint  `json:"target_id"`
+	ReassignedInvoices int64 `json:"reassigned_invoices"`
+	SourceDeleted      bool  `json:"source_deleted"`
+}
+
+// MonthlyInvoiceTotal represents the invoice count and total for one month of a year
+type MonthlyInvoiceTotal struct {
+	Month        int     `json:"month"`
+	InvoiceCount int     `json:"invoice_count"`
+	TotalAmount  float64 `json:"total_amount"`
+}
+
+// Aging buckets group unpaid invoices by the number of days past their payment due date
+const (
+	AgingBucketCurrent = "current"
+	AgingBucket1To30   = "1-30"
+	AgingBucket31To60  = "31-60"
+	AgingBucket61To90  = "61-90"
+	AgingBucketOver90  = "90+"
+)
+
+// AgingBuckets lists every aging bucket from least to most overdue
+var AgingBuckets = []string{AgingBucketCurrent, AgingBucket1To30, AgingBucket31To60, AgingBucket61To90, AgingBucketOver90}
+
+// AgingBucketTotal represents the number of unpaid invoices in one aging bucket and currency and
+// their outstanding amount
+type AgingBucketTotal struct {
+	Bucket       string  `json:"bucket"`
+	Currency     string  `json:"currency"`
+	InvoiceCount int     `json:"invoice_count"`
+	TotalAmount  float64 `json:"total_amount"`
+}
+
+// FeeRevenueTotal represents the fees and consumption tax collected on the paid invoices of all
+// companies issued in one month, per invoice currency
+type FeeRevenueTotal struct {
+	Year           int     `json:"year"`
+	Month          int     `json:"month"`
+	Currency       string  `json:"currency"`
+	InvoiceCount   int     `json:"invoice_count"`
+	Fee            float64 `json:"fee"`
+	ConsumptionTax float64 `json:"consumption_tax"`
+	TotalRevenue   float64 `json:"total_revenue"`
+}
+
+// InvoiceStatusCount represents the number of invoices in one status
+type InvoiceStatusCount struct {
+	Status InvoiceStatus `json:"status"`
+	Count  int           `json:"count"`
+}
+
+// StatusSummary represents the number and totals of a company's invoices in one status and currency
+type StatusSummary struct {
+	Status        InvoiceStatus `json:"status"`
+	Currency      string        `json:"currency"`
+	Count         int           `json:"count"`
+	InvoiceAmount float64       `json:"invoice_amount"`
+	// TotalFee and TotalTax are the platform's share of InvoiceAmount
+	TotalFee float64 `json:"total_fee"`
+	TotalTax float64 `json:"total_tax"`
 }
 
 // BusinessPartnerBankAccount represents bank account information for a business partner
 type BusinessPartnerBankAccount struct {
-	ID                uint      `json:"id" db:"id"`
-	BusinessPartnerID uint      `json:"business_partner_id" db:"business_partner_id" binding:"required"`
-	BankName          string    `json:"bank_name" db:"bank_name" binding:"required"`
-	BranchName        string    `json:"branch_name" db:"branch_name" binding:"required"`
-	AccountNumber     string    `json:"account_number" db:"account_number" binding:"required"`
-	AccountName       string    `json:"account_name" db:"account_name" binding:"required"`
-	CreatedAt         time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt         time.Time `json:"updated_at" db:"updated_at"`
+	ID                uint   `json:"id" db:"id"`
+	BusinessPartnerID uint   `json:"business_partner_id" db:"business_partner_id" binding:"required"`
+	BankName          string `json:"bank_name" db:"bank_name" binding:"required"`
+	BranchName        string `json:"branch_name" db:"branch_name" binding:"required"`
+	AccountNumber     string `json:"account_number" db:"account_number" binding:"required"`
+	AccountName       string `json:"account_name" db:"account_name" binding:"required"`
+	// IsPrimary marks the account payments to the business partner are made to
+	IsPrimary bool      `json:"is_primary" db:"is_primary"`
+	CreatedAt Timestamp `json:"created_at" db:"created_at"`
+	UpdatedAt Timestamp `json:"updated_at" db:"updated_at"`
 }
 
 // InvoiceStatus represents the status of an invoice
 type InvoiceStatus string
 
 const (
+	InvoiceStatusDraft       InvoiceStatus = "draft"
 	InvoiceStatusUnprocessed InvoiceStatus = "unprocessed"
 	InvoiceStatusProcessing  InvoiceStatus = "processing"
 	InvoiceStatusPaid        InvoiceStatus = "paid"
 	InvoiceStatusError       InvoiceStatus = "error"
+	// InvoiceStatusPartiallyPaid is entered by recording a payment that leaves part of the
+	// invoice amount outstanding
+	InvoiceStatusPartiallyPaid InvoiceStatus = "partially_paid"
+)
+
+// InvoiceStatuses lists every invoice status in lifecycle order
+var InvoiceStatuses = []InvoiceStatus{
+	InvoiceStatusDraft,
+	InvoiceStatusUnprocessed,
+	InvoiceStatusProcessing,
+	InvoiceStatusPartiallyPaid,
+	InvoiceStatusPaid,
+	InvoiceStatusError,
+}
+
+// invoiceStatusTransitions is the invoice lifecycle: the statuses each status may move to.
+// Drafts are only left by issuing them, and no invoice returns to draft. Issued invoices that are
+// not yet paid move to partially paid by recording a payment. Further payments on a partially paid
+// invoice keep it partially paid until they add up to its amount; staying in a status is not a
+// transition, so it is not part of the graph.
+var invoiceStatusTransitions = map[InvoiceStatus][]InvoiceStatus{
+	InvoiceStatusDraft:       {InvoiceStatusUnprocessed},
+	InvoiceStatusUnprocessed: {InvoiceStatusProcessing, InvoiceStatusPartiallyPaid, InvoiceStatusPaid, InvoiceStatusError},
+	InvoiceStatusProcessing:  {InvoiceStatusUnprocessed, InvoiceStatusPartiallyPaid, InvoiceStatusPaid, InvoiceStatusError},
+	InvoiceStatusPaid:        {InvoiceStatusUnprocessed, InvoiceStatusProcessing, InvoiceStatusError},
+	InvoiceStatusError:       {InvoiceStatusUnprocessed, InvoiceStatusProcessing, InvoiceStatusPartiallyPaid, InvoiceStatusPaid},
+	// Partially paid invoices may also be settled or written off by hand
+	InvoiceStatusPartiallyPaid: {InvoiceStatusPaid, InvoiceStatusError},
+}
+
+// ValidTransitions returns the statuses an invoice in this status may move to, in lifecycle order
+func (s InvoiceStatus) ValidTransitions() []InvoiceStatus {
+	return append([]InvoiceStatus(nil), invoiceStatusTransitions[s]...)
+}
+
+// CanTransitionTo reports whether an invoice in this status may move to the target status
+func (s InvoiceStatus) CanTransitionTo(target InvoiceStatus) bool {
+	for _, status := range invoiceStatusTransitions[s] {
+		if status == target {
+			return true
+		}
+	}
+	return false
+}
+
+// Deletable reports whether an invoice in this status may be deleted: drafts, and issued invoices
+// nothing has happened to yet
+func (s InvoiceStatus) Deletable() bool {
+	return s == InvoiceStatusDraft || s == InvoiceStatusUnprocessed
+}
+
+// InvoiceStatusHistory represents an audit entry for an invoice status transition
+type InvoiceStatusHistory struct {
+	ID         uint          `json:"id" db:"id"`
+	InvoiceID  uint          `json:"invoice_id" db:"invoice_id"`
+	FromStatus InvoiceStatus `json:"from_status" db:"from_status"`
+	ToStatus   InvoiceStatus `json:"to_status" db:"to_status"`
+	Action     string        `json:"action" db:"action"`
+	UserID     *uint         `json:"user_id,omitempty" db:"user_id"`
+	CreatedAt  Timestamp     `json:"created_at" db:"created_at"`
+}
+
+// Invoice history actions
+const (
+	InvoiceActionMarkedOverdue   = "marked_overdue"
+	InvoiceActionIssued          = "issued"
+	InvoiceActionStatusUpdated   = "status_updated"
+	InvoiceActionPaymentRecorded = "payment_recorded"
 )
 
+// InvoiceActions lists every action recorded in invoice history
+var InvoiceActions = []string{InvoiceActionMarkedOverdue, InvoiceActionIssued, InvoiceActionStatusUpdated, InvoiceActionPaymentRecorded}
+
+// IsInvoiceAction reports whether action is one of InvoiceActions
+func IsInvoiceAction(action string) bool {
+	for _, known := range InvoiceActions {
+		if action == known {
+			return true
+		}
+	}
+	return false
+}
+
 // Invoice represents invoice data linked to a company and business partner
 type Invoice struct {
 	ID                 uint             `json:"id" db:"id"`
+	InvoiceNumber      string           `json:"invoice_number" db:"invoice_number"`
 	CompanyID          uint             `json:"company_id" db:"company_id" binding:"required"`
 	BusinessPartnerID  uint             `json:"business_partner_id" db:"business_partner_id" binding:"required"`
-	IssueDate          time.Time        `json:"issue_date" db:"issue_date" binding:"required"`
+	IssueDate          Timestamp        `json:"issue_date" db:"issue_date" binding:"required"`
 	PaymentAmount      float64          `json:"payment_amount" db:"payment_amount" binding:"required,gt=0"`
 	Fee                float64          `json:"fee" db:"fee"`
 	FeeRate            float64          `json:"fee_rate" db:"fee_rate"`
 	ConsumptionTax     float64          `json:"consumption_tax" db:"consumption_tax"`
 	ConsumptionTaxRate float64          `json:"consumption_tax_rate" db:"consumption_tax_rate"`
 	InvoiceAmount      float64          `json:"invoice_amount" db:"invoice_amount"`
-	PaymentDueDate     time.Time        `json:"payment_due_date" db:"payment_due_date" binding:"required"`
+	PaidAmount         float64          `json:"paid_amount" db:"paid_amount"`
+	PaymentDueDate     Timestamp        `json:"payment_due_date" db:"payment_due_date" binding:"required"`
 	Status             InvoiceStatus    `json:"status" db:"status"`
-	CreatedAt          time.Time        `json:"created_at" db:"created_at"`
-	UpdatedAt          time.Time        `json:"updated_at" db:"updated_at"`
+	Memo               *string          `json:"memo" db:"memo"`
+	Currency           string           `json:"currency" db:"currency"`
+	CreatedByUserID    *uint            `json:"created_by_user_id" db:"created_by_user_id"`
+	CreatedByName      *string          `json:"created_by_name,omitempty"`
+	CreatedAt          Timestamp        `json:"created_at" db:"created_at"`
+	UpdatedAt          Timestamp        `json:"updated_at" db:"updated_at"`
 	Company            *Company         `json:"company,omitempty"`
 	BusinessPartner    *BusinessPartner `json:"business_partner,omitempty"`
+	LineItems          []InvoiceItem    `json:"line_items,omitempty"`
+	// BankAccount is the business partner's primary bank account, included on request
+	BankAccount *BusinessPartnerBankAccount `json:"bank_account,omitempty"`
+	// DisplayAmount is the invoice amount converted to DisplayCurrency, included on request
+	DisplayAmount   *float64 `json:"display_amount,omitempty"`
+	DisplayCurrency string   `json:"display_currency,omitempty"`
+}
+
+// InvoiceItem represents a single line item on an invoice
+type InvoiceItem struct {
+	ID          uint      `json:"id" db:"id"`
+	InvoiceID   uint      `json:"invoice_id" db:"invoice_id"`
+	Description string    `json:"description" db:"description"`
+	Quantity    int       `json:"quantity" db:"quantity"`
+	UnitPrice   float64   `json:"unit_price" db:"unit_price"`
+	Amount      float64   `json:"amount" db:"amount"`
+	CreatedAt   Timestamp `json:"created_at" db:"created_at"`
+	UpdatedAt   Timestamp `json:"updated_at" db:"updated_at"`
+}
+
+// InvoicePayment represents a single payment received against an invoice
+type InvoicePayment struct {
+	ID        uint      `json:"id" db:"id"`
+	InvoiceID uint      `json:"invoice_id" db:"invoice_id"`
+	Amount    float64   `json:"amount" db:"amount"`
+	UserID    *uint     `json:"user_id,omitempty" db:"user_id"`
+	CreatedAt Timestamp `json:"created_at" db:"created_at"`
+}
+
+// CreateInvoiceItemRequest represents a line item in the invoice creation request
+type CreateInvoiceItemRequest struct {
+	Description string  `json:"description" binding:"required"`
+	Quantity    int     `json:"quantity" binding:"required,gt=0"`
+	UnitPrice   float64 `json:"unit_price" binding:"required,gt=0"`
 }
 
 // CreateInvoiceRequest represents the request structure for creating an invoice
 type CreateInvoiceRequest struct {
-	BusinessPartnerID uint      `json:"business_partner_id" binding:"required"`
-	PaymentAmount     float64   `json:"payment_amount" binding:"required,gt=0"`
-	PaymentDueDate    time.Time `json:"payment_due_date" binding:"required"`
+	BusinessPartnerID uint                       `json:"business_partner_id" binding:"required"`
+	PaymentAmount     float64                    `json:"payment_amount" binding:"omitempty,gt=0"`
+	PaymentDueDate    time.Time                  `json:"payment_due_date"` // optional; defaults to the configured payment terms
+	LineItems         []CreateInvoiceItemRequest `json:"line_items" binding:"omitempty,dive"`
+	Memo              *string                    `json:"memo" binding:"omitempty,max=500"`
+	Currency          string                     `json:"currency"` // ISO 4217 code; defaults to DefaultCurrency
+	Draft             bool                       `json:"-"`        // set from the draft query parameter
+}
+
+// UnmarshalJSON decodes the request, rejecting a payment_amount that is not written as a plain
+// decimal number (e.g. 1e4) so that the amount in raw request logs is the amount invoiced
+func (req *CreateInvoiceRequest) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		PaymentAmount json.RawMessage `json:"payment_amount"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if err := ValidateDecimalLiteral("payment_amount", raw.PaymentAmount); err != nil {
+		return err
+	}
+
+	type plainRequest CreateInvoiceRequest
+	return json.Unmarshal(data, (*plainRequest)(req))
+}
+
+// ToInvoiceItems converts the requested line items to InvoiceItem models with computed amounts
+func (req *CreateInvoiceRequest) ToInvoiceItems() []InvoiceItem {
+	items := make([]InvoiceItem, 0, len(req.LineItems))
+	for _, item := range req.LineItems {
+		items = append(items, InvoiceItem{
+			Description: item.Description,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.UnitPrice,
+			Amount:      math.Round(float64(item.Quantity)*item.UnitPrice*100) / 100,
+		})
+	}
+	return items
+}
+
+// LineItemsTotal returns the sum of all line item amounts
+func (req *CreateInvoiceRequest) LineItemsTotal() float64 {
+	total := 0.0
+	for _, item := range req.ToInvoiceItems() {
+		total += item.Amount
+	}
+	return math.Round(total*100) / 100
+}
+
+// InvoiceAttachment describes a document linked to an invoice. The file itself lives in
+// external storage; only its metadata is kept.
+type InvoiceAttachment struct {
+	ID          uint      `json:"id" db:"id"`
+	InvoiceID   uint      `json:"invoice_id" db:"invoice_id"`
+	Filename    string    `json:"filename" db:"filename"`
+	ContentType string    `json:"content_type" db:"content_type"`
+	StorageURL  string    `json:"storage_url" db:"storage_url"`
+	Size        int64     `json:"size" db:"size"`
+	CreatedAt   Timestamp `json:"created_at" db:"created_at"`
+}
+
+// CreateInvoiceAttachmentRequest represents the request structure for linking a stored document to an invoice
+type CreateInvoiceAttachmentRequest struct {
+	Filename    string `json:"filename" binding:"required,max=255"`
+	ContentType string `json:"content_type" binding:"required,max=100"`
+	StorageURL  string `json:"storage_url" binding:"required,max=2048"`
+	Size        int64  `json:"size" binding:"required,gt=0"`
+}
+
+// CloneInvoiceRequest represents the optional request body for cloning an invoice
+type CloneInvoiceRequest struct {
+	PaymentDueDate time.Time `json:"payment_due_date"` // optional; defaults to the configured payment terms
+}
+
+// BankAccountRequest represents the request structure for adding or updating a business partner's bank account
+type BankAccountRequest struct {
+	BankName      string `json:"bank_name" binding:"required,max=255"`
+	BranchName    string `json:"branch_name" binding:"required,max=255"`
+	AccountNumber string `json:"account_number" binding:"required,max=20"`
+	AccountName   string `json:"account_name" binding:"required,max=255"`
+}
+
+// CompanyRates holds the rates, payment terms and initial invoice status a company has configured
+// for itself; nil fields use the application defaults
+type CompanyRates struct {
+	FeeRate              *float64
+	ConsumptionTaxRate   *float64
+	PaymentTermDays      *int
+	InitialInvoiceStatus *InvoiceStatus
+}
+
+// CompanySettings represents the rates, payment terms and initial status applied to a company's
+// new invoices
+type CompanySettings struct {
+	FeeRate              float64       `json:"fee_rate"`
+	ConsumptionTaxRate   float64       `json:"consumption_tax_rate"`
+	PaymentTermDays      int           `json:"payment_term_days"`
+	InitialInvoiceStatus InvoiceStatus `json:"initial_invoice_status"`
+}
+
+// UpdateCompanySettingsRequest represents the request structure for updating a company's settings;
+// a null or omitted field reverts it to the application default. The fee rate is negotiated with
+// the platform and can only be set through the admin API.
+type UpdateCompanySettingsRequest struct {
+	ConsumptionTaxRate *float64 `json:"consumption_tax_rate"` // one of the configured tax rates
+	PaymentTermDays    *int     `json:"payment_term_days" binding:"omitempty,min=1,max=365"`
+	// InitialInvoiceStatus is the status new invoices start in; omitted starts them unprocessed
+	InitialInvoiceStatus *InvoiceStatus `json:"initial_invoice_status" binding:"omitempty,oneof=unprocessed processing"`
+}
+
+// PreviewRateChangeRequest represents a proposed change of the company's rates; an omitted rate
+// keeps its current value
+type PreviewRateChangeRequest struct {
+	FeeRate            *float64 `json:"fee_rate" binding:"omitempty,gte=0,lte=0.2"`
+	ConsumptionTaxRate *float64 `json:"consumption_tax_rate"` // one of the configured tax rates
+}
+
+// RateChangePreview compares the fees of the company's unprocessed invoices under its current
+// rates with the fees they would have under the proposed rates, totalled per currency
+type RateChangePreview struct {
+	FeeRate            float64           `json:"fee_rate"`
+	ConsumptionTaxRate float64           `json:"consumption_tax_rate"`
+	InvoiceCount       int               `json:"invoice_count"`
+	Totals             []RateChangeTotal `json:"totals"`
+}
+
+// RateChangeTotal holds the current and projected fee totals of the invoices in one currency
+type RateChangeTotal struct {
+	Currency                string  `json:"currency"`
+	InvoiceCount            int     `json:"invoice_count"`
+	CurrentFees             float64 `json:"current_fees"`
+	ProjectedFees           float64 `json:"projected_fees"`
+	FeeDelta                float64 `json:"fee_delta"`
+	CurrentConsumptionTax   float64 `json:"current_consumption_tax"`
+	ProjectedConsumptionTax float64 `json:"projected_consumption_tax"`
+}
+
+// UpdateCompanyFeeRateRequest represents the request structure for setting a company's negotiated
+// fee rate; a null fee_rate reverts the company to the default rate
+type UpdateCompanyFeeRateRequest struct {
+	FeeRate *float64 `json:"fee_rate" binding:"omitempty,gte=0,lt=1"`
+}
+
+// CalculateBatchRequest represents the request structure for quoting several payment amounts.
+// Omitted rates default to the company's fee rate and the standard consumption tax rate.
+type CalculateBatchRequest struct {
+	Amounts []float64 `json:"amounts" binding:"required,min=1,max=1000,dive,gt=0"`
+	FeeRate *float64  `json:"fee_rate" binding:"omitempty,gte=0,lt=1"`
+	TaxRate *float64  `json:"tax_rate" binding:"omitempty,gte=0,lt=1"`
+}
+
+// InvoiceBreakdown represents the fee, consumption tax and invoice amount for a payment amount
+type InvoiceBreakdown struct {
+	PaymentAmount      float64 `json:"payment_amount"`
+	FeeRate            float64 `json:"fee_rate"`
+	Fee                float64 `json:"fee"`
+	ConsumptionTaxRate float64 `json:"consumption_tax_rate"`
+	ConsumptionTax     float64 `json:"consumption_tax"`
+	InvoiceAmount      float64 `json:"invoice_amount"`
+}
+
+// RecordPaymentRequest represents the request structure for recording a payment on an invoice
+type RecordPaymentRequest struct {
+	Amount float64 `json:"amount" binding:"required,gt=0"`
+}
+
+// BulkDeleteInvoicesRequest represents the request structure for deleting several invoices at once
+type BulkDeleteInvoicesRequest struct {
+	IDs []uint `json:"ids" binding:"required,min=1,max=100,dive,gt=0"`
+}
+
+// BulkDeleteInvoiceResult reports the outcome of deleting one invoice of a bulk delete
+type BulkDeleteInvoiceResult struct {
+	ID      uint   `json:"id"`
+	Deleted bool   `json:"deleted"`
+	Error   string `json:"error,omitempty"`
+}
+
+// UpdateInvoiceStatusRequest represents the request structure for updating an invoice's status
+type UpdateInvoiceStatusRequest struct {
+	Status InvoiceStatus `json:"status" binding:"required,oneof=unprocessed processing paid error"`
 }
 
 // GetInvoicesRequest represents the query parameters for retrieving invoices
 type GetInvoicesRequest struct {
-	StartDate *time.Time `form:"start_date"`
-	EndDate   *time.Time `form:"end_date"`
-	Status    *string    `form:"status"`
-	Page      int        `form:"page,default=1"`
-	Limit     int        `form:"limit,default=20"`
+	StartDate         *time.Time `form:"start_date"`
+	EndDate           *time.Time `form:"end_date"`
+	Status            *string    `form:"status"`
+	MinAmount         *float64   `form:"min_amount"`
+	MaxAmount         *float64   `form:"max_amount"`
+	BusinessPartnerID *uint      `form:"-"`
+	// IssuedFrom and IssuedBefore limit the listing to invoices issued in [IssuedFrom, IssuedBefore);
+	// StartDate and EndDate bound the due date
+	IssuedFrom   *time.Time `form:"-"`
+	IssuedBefore *time.Time `form:"-"`
+	// Search matches invoices whose memo contains the given text
+	Search *string `form:"search"`
+	// PartnerName matches business partners whose corporate name contains the given text
+	PartnerName *string `form:"partner_name"`
+	// CreatedByUserID matches invoices created by the given user
+	CreatedByUserID *uint `form:"-"`
+	Page            int   `form:"page,default=1"`
+	Limit           int   `form:"limit,default=20"`
+	// After switches the listing to keyset pagination ordered by ID (newest first),
+	// returning invoices with an ID lower than the given cursor. Zero starts from the newest invoice.
+	After *uint `form:"after"`
+	// DisplayCurrency adds the invoice amount converted to this currency to each invoice
+	DisplayCurrency *string `form:"-"`
+}
+
+// GetInvoiceHistoryRequest represents the query parameters for listing the status history of an
+// invoice. A zero Limit returns every entry.
+type GetInvoiceHistoryRequest struct {
+	Action *string `form:"action"`
+	Page   int     `form:"page,default=1" binding:"min=1"`
+	Limit  int     `form:"limit,default=20" binding:"min=1,max=100"`
+}
+
+// GetUsersRequest represents the query parameters for listing the users of a company
+type GetUsersRequest struct {
+	// Search matches users whose full name or email contains the given text
+	Search *string `form:"search"`
+	Page   int     `form:"page,default=1" binding:"min=1"`
+	Limit  int     `form:"limit,default=20" binding:"min=1,max=100"`
+}
+
+// GetBusinessPartnersRequest represents the query parameters for listing the business partners of
+// a company
+type GetBusinessPartnersRequest struct {
+	// HasBankAccount matches partners that have at least one bank account on file when true, and
+	// partners that have none when false
+	HasBankAccount *bool `form:"has_bank_account"`
 }
 
 // AuthResponse represents authentication response
@@ -107,16 +557,86 @@ type AuthResponse struct {
 	User  User   `json:"user"`
 }
 
+// TokenIntrospectionResponse describes the validity and remaining lifetime of an auth token
+type TokenIntrospectionResponse struct {
+	Valid            bool      `json:"valid"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	SecondsRemaining int64     `json:"seconds_remaining"`
+	UserID           uint      `json:"user_id"`
+	CompanyID        uint      `json:"company_id"`
+}
+
+// HealthDetails describes the state of the service and its dependencies for operators
+type HealthDetails struct {
+	Status        string         `json:"status"`
+	Timestamp     time.Time      `json:"timestamp"`
+	StartedAt     time.Time      `json:"started_at"`
+	UptimeSeconds int64          `json:"uptime_seconds"`
+	Database      DatabaseHealth `json:"database"`
+}
+
+// DatabaseHealth describes database connectivity and connection pool usage
+type DatabaseHealth struct {
+	Connected bool   `json:"connected"`
+	Error     string `json:"error,omitempty"`
+	// LatencyMs is the round trip time of the connectivity check
+	LatencyMs int64             `json:"latency_ms"`
+	Pool      DatabasePoolStats `json:"pool"`
+}
+
+// DatabasePoolStats mirrors the connection pool statistics reported by database/sql
+type DatabasePoolStats struct {
+	MaxOpen        int   `json:"max_open"`
+	Open           int   `json:"open"`
+	InUse          int   `json:"in_use"`
+	Idle           int   `json:"idle"`
+	WaitCount      int64 `json:"wait_count"`
+	WaitDurationMs int64 `json:"wait_duration_ms"`
+}
+
+// CalendarTokenResponse holds a token for subscribing to the invoice due date calendar feed
+type CalendarTokenResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	FeedPath  string    `json:"feed_path"`
+}
+
+// ReadTokenResponse holds a read-only token for an external auditor
+type ReadTokenResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// EmailAvailabilityRequest represents the query of an email availability check
+type EmailAvailabilityRequest struct {
+	Email string `form:"email" binding:"required,email"`
+}
+
+// EmailAvailabilityResponse reports whether an email can still be used to register
+type EmailAvailabilityResponse struct {
+	Available bool `json:"available"`
+}
+
 // LoginRequest represents login request
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
+	// RememberMe asks for a token valid for the longer remember-me lifetime
+	RememberMe bool `json:"remember_me"`
 }
 
 // ErrorResponse represents error response
 type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message,omitempty"`
+	Error   string       `json:"error"`
+	Message string       `json:"message,omitempty"`
+	Fields  []FieldError `json:"fields,omitempty"`
+	Data    interface{}  `json:"data,omitempty"`
+}
+
+// FieldError describes a single request field that failed validation
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
 }
 
 // SuccessResponse represents success response
@@ -125,11 +645,221 @@ type SuccessResponse struct {
 	Data    interface{} `json:"data,omitempty"`
 }
 
+// InvoiceListResponse represents an invoice list response with an optional keyset pagination cursor
+type InvoiceListResponse struct {
+	Message    string     `json:"message"`
+	Data       []*Invoice `json:"data"`
+	NextCursor *uint      `json:"next_cursor,omitempty"`
+}
+
+// InvoicePageResponse represents one page of invoices, in the nested or flat shape, along with
+// the total number of invoices matching the filters
+type InvoicePageResponse struct {
+	Message    string      `json:"message"`
+	Data       interface{} `json:"data"`
+	Page       int         `json:"page"`
+	Limit      int         `json:"limit"`
+	Total      int         `json:"total"`
+	TotalPages int         `json:"total_pages"`
+}
+
+// InvoiceHistoryResponse represents one page of the status history of an invoice
+type InvoiceHistoryResponse struct {
+	Message    string                  `json:"message"`
+	Data       []*InvoiceStatusHistory `json:"data"`
+	Page       int                     `json:"page"`
+	Limit      int                     `json:"limit"`
+	Total      int                     `json:"total"`
+	TotalPages int                     `json:"total_pages"`
+}
+
+// UserListResponse represents one page of the users of a company
+type UserListResponse struct {
+	Message    string  `json:"message"`
+	Data       []*User `json:"data"`
+	Page       int     `json:"page"`
+	Limit      int     `json:"limit"`
+	Total      int     `json:"total"`
+	TotalPages int     `json:"total_pages"`
+}
+
+// FlatInvoiceListResponse is InvoiceListResponse with invoices in the flat shape
+type FlatInvoiceListResponse struct {
+	Message    string        `json:"message"`
+	Data       []FlatInvoice `json:"data"`
+	NextCursor *uint         `json:"next_cursor,omitempty"`
+}
+
+// SparseInvoiceListResponse represents a keyset-paginated list of invoices reduced to the fields
+// requested by the client
+type SparseInvoiceListResponse struct {
+	Message    string                   `json:"message"`
+	Data       []map[string]interface{} `json:"data"`
+	NextCursor *uint                    `json:"next_cursor,omitempty"`
+}
+
+// FlatInvoice is an invoice without nested objects, for BI tools that expect one flat record per
+// invoice. The company and business partner are reduced to their corporate names.
+type FlatInvoice struct {
+	ID                  uint          `json:"id"`
+	InvoiceNumber       string        `json:"invoice_number"`
+	CompanyID           uint          `json:"company_id"`
+	CompanyName         string        `json:"company_name"`
+	BusinessPartnerID   uint          `json:"business_partner_id"`
+	BusinessPartnerName string        `json:"business_partner_name"`
+	IssueDate           Timestamp     `json:"issue_date"`
+	PaymentAmount       float64       `json:"payment_amount"`
+	Fee                 float64       `json:"fee"`
+	FeeRate             float64       `json:"fee_rate"`
+	ConsumptionTax      float64       `json:"consumption_tax"`
+	ConsumptionTaxRate  float64       `json:"consumption_tax_rate"`
+	InvoiceAmount       float64       `json:"invoice_amount"`
+	PaidAmount          float64       `json:"paid_amount"`
+	PaymentDueDate      Timestamp     `json:"payment_due_date"`
+	Status              InvoiceStatus `json:"status"`
+	Memo                *string       `json:"memo"`
+	Currency            string        `json:"currency"`
+	DisplayAmount       *float64      `json:"display_amount,omitempty"`
+	DisplayCurrency     string        `json:"display_currency,omitempty"`
+	CreatedByUserID     *uint         `json:"created_by_user_id"`
+	CreatedByName       *string       `json:"created_by_name"`
+	CreatedAt           Timestamp     `json:"created_at"`
+	UpdatedAt           Timestamp     `json:"updated_at"`
+}
+
+// NewFlatInvoice maps an invoice to its flat shape
+func NewFlatInvoice(invoice *Invoice) FlatInvoice {
+	flat := FlatInvoice{
+		ID:                 invoice.ID,
+		InvoiceNumber:      invoice.InvoiceNumber,
+		CompanyID:          invoice.CompanyID,
+		BusinessPartnerID:  invoice.BusinessPartnerID,
+		IssueDate:          invoice.IssueDate,
+		PaymentAmount:      invoice.PaymentAmount,
+		Fee:                invoice.Fee,
+		FeeRate:            invoice.FeeRate,
+		ConsumptionTax:     invoice.ConsumptionTax,
+		ConsumptionTaxRate: invoice.ConsumptionTaxRate,
+		InvoiceAmount:      invoice.InvoiceAmount,
+		PaidAmount:         invoice.PaidAmount,
+		PaymentDueDate:     invoice.PaymentDueDate,
+		Status:             invoice.Status,
+		Memo:               invoice.Memo,
+		Currency:           invoice.Currency,
+		DisplayAmount:      invoice.DisplayAmount,
+		DisplayCurrency:    invoice.DisplayCurrency,
+		CreatedByUserID:    invoice.CreatedByUserID,
+		CreatedByName:      invoice.CreatedByName,
+		CreatedAt:          invoice.CreatedAt,
+		UpdatedAt:          invoice.UpdatedAt,
+	}
+	if invoice.Company != nil {
+		flat.CompanyName = invoice.Company.CorporateName
+	}
+	if invoice.BusinessPartner != nil {
+		flat.BusinessPartnerName = invoice.BusinessPartner.CorporateName
+	}
+	return flat
+}
+
+// FlattenInvoices maps invoices to their flat shape
+func FlattenInvoices(invoices []*Invoice) []FlatInvoice {
+	flat := make([]FlatInvoice, len(invoices))
+	for i, invoice := range invoices {
+		flat[i] = NewFlatInvoice(invoice)
+	}
+	return flat
+}
+
 // UserRegistrationRequest represents the request structure for user registration
 type UserRegistrationRequest struct {
 	FullName string `json:"full_name" binding:"required"`
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=8"`
+	Password string `json:"password" binding:"required"` // checked against the configured password policy
+}
+
+// UpdateProfileRequest represents the request structure for users updating their own profile;
+// omitted (null) fields keep their current value
+type UpdateProfileRequest struct {
+	FullName *string `json:"full_name"`
+	Email    *string `json:"email" binding:"omitempty,email"`
+}
+
+// Validate validates the fields present in the UpdateProfileRequest
+func (req *UpdateProfileRequest) Validate() error {
+	if req.FullName == nil && req.Email == nil {
+		return fmt.Errorf("at least one of full_name and email must be given")
+	}
+	if req.FullName != nil && strings.TrimSpace(*req.FullName) == "" {
+		return fmt.Errorf("full_name must not be blank")
+	}
+	return nil
+}
+
+// WebhookSecretPrefix starts every generated webhook signing secret, so the query log can recognize
+// and mask secrets passed as statement arguments
+const WebhookSecretPrefix = "whsec_"
+
+// WebhookSettings holds the endpoint invoice events of a company are delivered to; a nil URL
+// disables webhooks. Deliveries are signed with Secret, which is only returned when the endpoint
+// is set.
+type WebhookSettings struct {
+	URL    *string `json:"url"`
+	Secret *string `json:"secret,omitempty"`
+}
+
+// UpdateWebhookSettingsRequest represents the request structure for setting a company's webhook
+// endpoint; a null or omitted URL disables webhooks
+type UpdateWebhookSettingsRequest struct {
+	URL *string `json:"url" binding:"omitempty,max=2048"`
+}
+
+// Validate validates the UpdateWebhookSettingsRequest
+func (req *UpdateWebhookSettingsRequest) Validate() error {
+	if req.URL == nil {
+		return nil
+	}
+	webhookURL, err := url.Parse(*req.URL)
+	if err != nil || (webhookURL.Scheme != "https" && webhookURL.Scheme != "http") || webhookURL.Host == "" {
+		return fmt.Errorf("url must be an absolute http or https URL")
+	}
+	return nil
+}
+
+// WebhookDeliveryStatus represents the state of a failed webhook delivery
+type WebhookDeliveryStatus string
+
+const (
+	// WebhookDeliveryStatusPending is a delivery waiting for its next retry
+	WebhookDeliveryStatusPending WebhookDeliveryStatus = "pending"
+	// WebhookDeliveryStatusDelivered is a delivery that succeeded on a retry
+	WebhookDeliveryStatusDelivered WebhookDeliveryStatus = "delivered"
+	// WebhookDeliveryStatusDead is a delivery that failed on every attempt and is no longer retried
+	WebhookDeliveryStatusDead WebhookDeliveryStatus = "dead"
+)
+
+// WebhookDelivery is an event delivery to a company's webhook endpoint that failed on its first
+// attempt and is retried from the queue
+type WebhookDelivery struct {
+	ID          uint                  `json:"id" db:"id"`
+	CompanyID   uint                  `json:"company_id" db:"company_id"`
+	Event       string                `json:"event" db:"event"`
+	URL         string                `json:"url" db:"url"`
+	Payload     json.RawMessage       `json:"payload" db:"payload"`
+	Status      WebhookDeliveryStatus `json:"status" db:"status"`
+	Attempts    int                   `json:"attempts" db:"attempts"`
+	LastError   *string               `json:"last_error" db:"last_error"`
+	NextRetryAt *Timestamp            `json:"next_retry_at" db:"next_retry_at"`
+	DeliveredAt *Timestamp            `json:"delivered_at" db:"delivered_at"`
+	CreatedAt   Timestamp             `json:"created_at" db:"created_at"`
+	UpdatedAt   Timestamp             `json:"updated_at" db:"updated_at"`
+}
+
+// GetWebhookDeliveriesRequest represents the query parameters for listing the webhook deliveries
+// of a company, newest first
+type GetWebhookDeliveriesRequest struct {
+	Status *string `form:"status" binding:"omitempty,oneof=pending delivered dead"`
+	Limit  int     `form:"limit,default=50" binding:"min=1,max=100"`
 }
 
 // BusinessPartnerCreateRequest represents the request structure for creating a business partner
@@ -141,6 +871,50 @@ type BusinessPartnerCreateRequest struct {
 	Address        string `json:"address" binding:"required"`
 }
 
+// BusinessPartnerPatchRequest represents the request structure for partially updating a business
+// partner; omitted (null) fields keep their current value
+type BusinessPartnerPatchRequest struct {
+	CorporateName  *string `json:"corporate_name"`
+	Representative *string `json:"representative"`
+	PhoneNumber    *string `json:"phone_number"`
+	PostalCode     *string `json:"postal_code"`
+	Address        *string `json:"address"`
+}
+
+// BusinessPartnerImportColumns is the header expected on business partner CSV imports
+var BusinessPartnerImportColumns = []string{"corporate_name", "representative", "phone_number", "postal_code", "address"}
+
+// BusinessPartnerImportResult reports the outcome of importing one CSV row
+type BusinessPartnerImportResult struct {
+	// Row is the CSV record number, the header being row 1
+	Row               int    `json:"row"`
+	Success           bool   `json:"success"`
+	BusinessPartnerID uint   `json:"business_partner_id,omitempty"`
+	Error             string `json:"error,omitempty"`
+}
+
+// BusinessPartnerImportResponse summarizes a business partner CSV import
+type BusinessPartnerImportResponse struct {
+	Imported int                           `json:"imported"`
+	Failed   int                           `json:"failed"`
+	Results  []BusinessPartnerImportResult `json:"results"`
+}
+
+// BusinessPartnerValidationResult reports whether one record of a validation batch would be accepted
+type BusinessPartnerValidationResult struct {
+	// Index is the position of the record in the request array, starting at 0
+	Index  int      `json:"index"`
+	Valid  bool     `json:"valid"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// BusinessPartnerValidationResponse summarizes the validation of a batch of business partner records
+type BusinessPartnerValidationResponse struct {
+	Valid   int                               `json:"valid"`
+	Invalid int                               `json:"invalid"`
+	Results []BusinessPartnerValidationResult `json:"results"`
+}
+
 // ToBusinessPartner converts the request to a BusinessPartner model
 func (req *BusinessPartnerCreateRequest) ToBusinessPartner() *BusinessPartner {
 	return &BusinessPartner{
@@ -154,15 +928,22 @@ func (req *BusinessPartnerCreateRequest) ToBusinessPartner() *BusinessPartner {
 
 // Validation functions
 var (
-	// Japanese phone number pattern: XXX-XXXX-XXXX format
-	phoneRegex = regexp.MustCompile(`^0\d{1,4}-\d{1,4}-\d{4}$`)
+	// Japanese phone number patterns: 10-digit landlines, whose area code and exchange total
+	// 6 digits (03-XXXX-XXXX, 052-XXX-XXXX, 0466-XX-XXXX, 01267-X-XXXX), and 11-digit
+	// mobile and IP phone numbers (090-XXXX-XXXX)
+	landlinePhoneRegex = regexp.MustCompile(`^(0\d-\d{4}|0\d{2}-\d{3}|0\d{3}-\d{2}|0\d{4}-\d)-\d{4}$`)
+	mobilePhoneRegex   = regexp.MustCompile(`^0[2-9]0-\d{4}-\d{4}$`)
 	// Japanese postal code pattern: XXX-XXXX format
 	postalCodeRegex = regexp.MustCompile(`^\d{3}-\d{4}$`)
+	// Bank account number pattern: digits only
+	accountNumberRegex = regexp.MustCompile(`^\d+$`)
+	// Plain decimal number: optional sign and fraction, no exponent
+	decimalLiteralRegex = regexp.MustCompile(`^-?\d+(\.\d+)?$`)
 )
 
 // ValidatePhoneNumber validates Japanese phone number format
 func ValidatePhoneNumber(phone string) error {
-	if !phoneRegex.MatchString(phone) {
+	if !landlinePhoneRegex.MatchString(phone) && !mobilePhoneRegex.MatchString(phone) {
 		return fmt.Errorf("invalid phone number format. Expected format: XXX-XXXX-XXXX")
 	}
 	return nil
@@ -176,29 +957,262 @@ func ValidatePostalCode(postalCode string) error {
 	return nil
 }
 
-// ValidatePaymentDueDate validates that the payment due date is in the future
-func ValidatePaymentDueDate(dueDate time.Time) error {
-	if dueDate.Before(time.Now()) {
+// ValidateContactInfo validates the phone number and postal code of a company or business partner.
+// Every path creating or updating one runs it.
+func ValidateContactInfo(phone, postalCode string) error {
+	if err := ValidatePhoneNumber(phone); err != nil {
+		return err
+	}
+	return ValidatePostalCode(postalCode)
+}
+
+// ValidateAccountNumber validates that a bank account number consists of digits only
+func ValidateAccountNumber(accountNumber string) error {
+	if !accountNumberRegex.MatchString(accountNumber) {
+		return fmt.Errorf("invalid account number format. Expected digits only")
+	}
+	return nil
+}
+
+// ValidateDecimalLiteral validates that a raw JSON value, when present, is a number written in
+// plain decimal notation, rejecting scientific notation and non-numeric values such as NaN
+func ValidateDecimalLiteral(field string, raw json.RawMessage) error {
+	value := strings.TrimSpace(string(raw))
+	if value == "" || value == "null" {
+		return nil
+	}
+	if !decimalLiteralRegex.MatchString(value) {
+		return fmt.Errorf("%s must be a plain decimal number (e.g. 10000.00), got %s", field, value)
+	}
+	return nil
+}
+
+// DefaultCurrency is the ISO 4217 currency of invoices created without one
+const DefaultCurrency = "JPY"
+
+// currencyDecimals lists the ISO 4217 currencies whose minor unit is not 1/100
+var currencyDecimals = map[string]int{
+	"JPY": 0, "KRW": 0, "VND": 0, "CLP": 0, "ISK": 0, "PYG": 0, "UGX": 0, "XAF": 0, "XOF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// CurrencyDecimals returns the number of decimal places of a currency's minor unit
+// (e.g. 0 for JPY, 2 for USD)
+func CurrencyDecimals(currency string) int {
+	if decimals, ok := currencyDecimals[currency]; ok {
+		return decimals
+	}
+	return 2
+}
+
+// RoundToCurrency rounds an amount to the minor unit of a currency
+func RoundToCurrency(amount float64, currency string) float64 {
+	scale := math.Pow10(CurrencyDecimals(currency))
+	return math.Round(amount*scale) / scale
+}
+
+// ConvertCurrency converts an amount between currencies using exchange rates given as units of
+// each currency per unit of a common base currency, rounding to the minor unit of the target
+// currency. ok is false when either currency has no rate.
+func ConvertCurrency(amount float64, from, to string, rates map[string]float64) (converted float64, ok bool) {
+	fromRate, fromOK := rates[from]
+	toRate, toOK := rates[to]
+	if !fromOK || !toOK {
+		return 0, false
+	}
+	return RoundToCurrency(amount/fromRate*toRate, to), true
+}
+
+// ValidateCurrencyAmount validates that an amount has no more decimal places than the minor unit
+// of its currency allows, e.g. no fractional yen
+func ValidateCurrencyAmount(field string, amount float64, currency string) error {
+	if math.Abs(RoundToCurrency(amount, currency)-amount) > 1e-9 {
+		return fmt.Errorf("%s must have at most %d decimal places for %s", field, CurrencyDecimals(currency), currency)
+	}
+	return nil
+}
+
+// ValidatePaymentDueDate validates that the payment due date is not before today in the given location
+func ValidatePaymentDueDate(dueDate, now time.Time, loc *time.Location) error {
+	if DateOnly(dueDate, loc).Before(DateOnly(now, loc)) {
 		return fmt.Errorf("payment due date must be in the future")
 	}
 	return nil
 }
 
+// DateOnly truncates t to midnight of its calendar date in the given location
+func DateOnly(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
 // Validate validates the BusinessPartnerCreateRequest
 func (req *BusinessPartnerCreateRequest) Validate() error {
-	if err := ValidatePhoneNumber(req.PhoneNumber); err != nil {
-		return err
+	return ValidateContactInfo(req.PhoneNumber, req.PostalCode)
+}
+
+// Validate validates the fields present in the BusinessPartnerPatchRequest
+func (req *BusinessPartnerPatchRequest) Validate() error {
+	if req.CorporateName != nil && strings.TrimSpace(*req.CorporateName) == "" {
+		return fmt.Errorf("corporate_name must not be blank")
+	}
+	if req.Representative != nil && strings.TrimSpace(*req.Representative) == "" {
+		return fmt.Errorf("representative must not be blank")
+	}
+	if req.Address != nil && strings.TrimSpace(*req.Address) == "" {
+		return fmt.Errorf("address must not be blank")
+	}
+	if req.PhoneNumber != nil {
+		if err := ValidatePhoneNumber(*req.PhoneNumber); err != nil {
+			return err
+		}
+	}
+	if req.PostalCode != nil {
+		if err := ValidatePostalCode(*req.PostalCode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Apply merges the fields present in the request into partner
+func (req *BusinessPartnerPatchRequest) Apply(partner *BusinessPartner) {
+	if req.CorporateName != nil {
+		partner.CorporateName = *req.CorporateName
+	}
+	if req.Representative != nil {
+		partner.Representative = *req.Representative
+	}
+	if req.PhoneNumber != nil {
+		partner.PhoneNumber = *req.PhoneNumber
+	}
+	if req.PostalCode != nil {
+		partner.PostalCode = *req.PostalCode
+	}
+	if req.Address != nil {
+		partner.Address = *req.Address
+	}
+}
+
+// Validate validates that the consumption tax rate is one of the allowed rates
+func (req *UpdateCompanySettingsRequest) Validate(allowedTaxRates []float64) error {
+	if req.ConsumptionTaxRate == nil {
+		return nil
 	}
-	if err := ValidatePostalCode(req.PostalCode); err != nil {
+	for _, rate := range allowedTaxRates {
+		if math.Abs(*req.ConsumptionTaxRate-rate) < 1e-9 {
+			return nil
+		}
+	}
+	return fmt.Errorf("consumption_tax_rate must be one of %v", allowedTaxRates)
+}
+
+// Validate checks that a proposed consumption tax rate is one of allowedTaxRates
+func (req *PreviewRateChangeRequest) Validate(allowedTaxRates []float64) error {
+	if req.ConsumptionTaxRate == nil {
+		return nil
+	}
+	for _, rate := range allowedTaxRates {
+		if math.Abs(*req.ConsumptionTaxRate-rate) < 1e-9 {
+			return nil
+		}
+	}
+	return fmt.Errorf("consumption_tax_rate must be one of %v", allowedTaxRates)
+}
+
+// Validate validates the BankAccountRequest
+func (req *BankAccountRequest) Validate() error {
+	if err := ValidateAccountNumber(req.AccountNumber); err != nil {
 		return err
 	}
+	if strings.TrimSpace(req.BankName) == "" {
+		return fmt.Errorf("bank_name must not be blank")
+	}
+	if strings.TrimSpace(req.BranchName) == "" {
+		return fmt.Errorf("branch_name must not be blank")
+	}
+	if strings.TrimSpace(req.AccountName) == "" {
+		return fmt.Errorf("account_name must not be blank")
+	}
+	return nil
+}
+
+// Validate validates the CreateInvoiceRequest, interpreting dates in the given location
+func (req *CreateInvoiceRequest) Validate(loc *time.Location) error {
+	if len(req.LineItems) == 0 && req.PaymentAmount <= 0 {
+		return fmt.Errorf("payment_amount is required when line_items are not provided")
+	}
+	if len(req.LineItems) > 0 && req.PaymentAmount > 0 && req.PaymentAmount != req.LineItemsTotal() {
+		return fmt.Errorf("payment_amount does not match the sum of line_items")
+	}
+	if !req.PaymentDueDate.IsZero() {
+		if err := ValidatePaymentDueDate(req.PaymentDueDate, time.Now(), loc); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// Validate validates the CreateInvoiceRequest
-func (req *CreateInvoiceRequest) Validate() error {
-	if err := ValidatePaymentDueDate(req.PaymentDueDate); err != nil {
+// Validate normalizes the content type and validates the attachment against the allowed
+// content types (lower-cased media types without parameters)
+func (req *CreateInvoiceAttachmentRequest) Validate(allowedContentTypes []string) error {
+	req.Filename = strings.TrimSpace(req.Filename)
+	if req.Filename == "" {
+		return fmt.Errorf("filename must not be blank")
+	}
+	if strings.ContainsAny(req.Filename, `/\`) {
+		return fmt.Errorf("filename must not contain path separators")
+	}
+
+	mediaType, _, err := mime.ParseMediaType(req.ContentType)
+	if err != nil {
+		return fmt.Errorf("content_type %q is not a valid media type", req.ContentType)
+	}
+	req.ContentType = mediaType
+	allowed := false
+	for _, contentType := range allowedContentTypes {
+		if contentType == mediaType {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("content_type %q is not allowed, must be one of: %s", mediaType, strings.Join(allowedContentTypes, ", "))
+	}
+
+	storageURL, err := url.Parse(req.StorageURL)
+	if err != nil || (storageURL.Scheme != "https" && storageURL.Scheme != "http") || storageURL.Host == "" {
+		return fmt.Errorf("storage_url must be an absolute http or https URL")
+	}
+	return nil
+}
+
+// ValidateCurrency normalizes the requested currency, defaulting to DefaultCurrency, and validates
+// it against the allowed ISO 4217 codes and the amounts against its minor unit
+func (req *CreateInvoiceRequest) ValidateCurrency(allowed []string) error {
+	req.Currency = strings.ToUpper(strings.TrimSpace(req.Currency))
+	if req.Currency == "" {
+		req.Currency = DefaultCurrency
+	}
+
+	supported := false
+	for _, code := range allowed {
+		if code == req.Currency {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return fmt.Errorf("currency %q is not supported, must be one of: %s", req.Currency, strings.Join(allowed, ", "))
+	}
+
+	if err := ValidateCurrencyAmount("payment_amount", req.PaymentAmount, req.Currency); err != nil {
 		return err
 	}
+	for i, item := range req.LineItems {
+		if err := ValidateCurrencyAmount(fmt.Sprintf("line_items[%d].unit_price", i), item.UnitPrice, req.Currency); err != nil {
+			return err
+		}
+	}
 	return nil
 }
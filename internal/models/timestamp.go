@@ -0,0 +1,82 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimestampLayout is the format of timestamps in JSON responses: RFC 3339 with second precision
+// and the UTC offset of the configured timezone
+const TimestampLayout = time.RFC3339
+
+// timestampLocation is the timezone timestamps are rendered in
+var timestampLocation = time.UTC
+
+// SetTimestampLocation sets the timezone timestamps are rendered in. It is meant to be called once
+// at startup, before any response is written.
+func SetTimestampLocation(loc *time.Location) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	timestampLocation = loc
+}
+
+// Timestamp is a time.Time that marshals to JSON in TimestampLayout in the configured timezone,
+// dropping the sub-second part. It reads RFC 3339 timestamps of any precision as well as plain
+// dates, and can be stored and scanned by database/sql.
+type Timestamp struct {
+	time.Time
+}
+
+// NewTimestamp returns t as a Timestamp
+func NewTimestamp(t time.Time) Timestamp {
+	return Timestamp{Time: t}
+}
+
+// MarshalJSON implements json.Marshaler
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + t.In(timestampLocation).Truncate(time.Second).Format(TimestampLayout) + `"`), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		t.Time = time.Time{}
+		return nil
+	}
+	if !strings.HasPrefix(s, `"`) || !strings.HasSuffix(s, `"`) || len(s) < 2 {
+		return fmt.Errorf("invalid timestamp %s", s)
+	}
+	s = s[1 : len(s)-1]
+
+	parsed, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		parsed, err = time.ParseInLocation("2006-01-02", s, timestampLocation)
+		if err != nil {
+			return fmt.Errorf("invalid timestamp %q: must be RFC 3339 or YYYY-MM-DD", s)
+		}
+	}
+	t.Time = parsed
+	return nil
+}
+
+// Scan implements sql.Scanner
+func (t *Timestamp) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case time.Time:
+		t.Time = v
+	case nil:
+		t.Time = time.Time{}
+	default:
+		return fmt.Errorf("cannot scan %T into Timestamp", value)
+	}
+	return nil
+}
+
+// Value implements driver.Valuer
+func (t Timestamp) Value() (driver.Value, error) {
+	return t.Time, nil
+}
@@ -0,0 +1,155 @@
+package pdf
+
+import (
+	"fmt"
+	"sort"
+	"super-payment/internal/models"
+)
+
+const (
+	dateLayout     = "2006-01-02"
+	dateTimeLayout = "2006-01-02 15:04:05"
+)
+
+// TemplateFunc renders an invoice into a document
+type TemplateFunc func(invoice *models.Invoice) *Document
+
+// DefaultTemplate is the template used when none is requested
+const DefaultTemplate = "standard"
+
+// templates holds the registered invoice layouts by name
+var templates = map[string]TemplateFunc{
+	"standard": InvoiceDocument,
+	"detailed": DetailedInvoiceDocument,
+}
+
+// Template returns the invoice template registered under name
+func Template(name string) (TemplateFunc, bool) {
+	render, ok := templates[name]
+	return render, ok
+}
+
+// TemplateNames returns the names of all registered templates in sorted order
+func TemplateNames() []string {
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// InvoiceDocument lays out an invoice as a single-page document (the standard template)
+func InvoiceDocument(invoice *models.Invoice) *Document {
+	lines := []string{
+		fmt.Sprintf("INVOICE #%d", invoice.ID),
+		"",
+	}
+
+	if invoice.Company != nil {
+		lines = append(lines, "From: "+invoice.Company.CorporateName)
+	}
+	if invoice.BusinessPartner != nil {
+		lines = append(lines, "To:   "+invoice.BusinessPartner.CorporateName)
+	}
+
+	lines = append(lines,
+		"",
+		"Issue date:       "+invoice.IssueDate.Format(dateLayout),
+		"Payment due date: "+invoice.PaymentDueDate.Format(dateLayout),
+		"Status:           "+string(invoice.Status),
+		"",
+	)
+
+	if len(invoice.LineItems) > 0 {
+		lines = append(lines, "Items:")
+		for _, item := range invoice.LineItems {
+			lines = append(lines, fmt.Sprintf("  %s  %d x %.2f = %.2f", item.Description, item.Quantity, item.UnitPrice, item.Amount))
+		}
+		lines = append(lines, "")
+	}
+
+	lines = append(lines,
+		fmt.Sprintf("Payment amount:   %.2f", invoice.PaymentAmount),
+		fmt.Sprintf("Fee (%.2f%%):      %.2f", invoice.FeeRate*100, invoice.Fee),
+		fmt.Sprintf("Consumption tax (%.2f%%): %.2f", invoice.ConsumptionTaxRate*100, invoice.ConsumptionTax),
+		fmt.Sprintf("Invoice amount:   %.2f", invoice.InvoiceAmount),
+	)
+
+	doc := New()
+	doc.AddPage(lines...)
+	return doc
+}
+
+// DetailedInvoiceDocument lays out an invoice with the full contact details of both parties,
+// the memo and the record timestamps in addition to the standard content
+func DetailedInvoiceDocument(invoice *models.Invoice) *Document {
+	lines := []string{
+		fmt.Sprintf("INVOICE #%d (DETAILED)", invoice.ID),
+		"",
+	}
+
+	if invoice.Company != nil {
+		company := invoice.Company
+		lines = append(lines, partyLines("From", company.CorporateName, company.Representative,
+			company.PostalCode, company.Address, company.PhoneNumber)...)
+		lines = append(lines, "")
+	}
+	if invoice.BusinessPartner != nil {
+		partner := invoice.BusinessPartner
+		lines = append(lines, partyLines("To", partner.CorporateName, partner.Representative,
+			partner.PostalCode, partner.Address, partner.PhoneNumber)...)
+		lines = append(lines, "")
+	}
+
+	lines = append(lines,
+		"Issue date:       "+invoice.IssueDate.Format(dateLayout),
+		"Payment due date: "+invoice.PaymentDueDate.Format(dateLayout),
+		"Status:           "+string(invoice.Status),
+		"",
+	)
+
+	if len(invoice.LineItems) > 0 {
+		lines = append(lines, "Items:")
+		for i, item := range invoice.LineItems {
+			lines = append(lines,
+				fmt.Sprintf("  %d. %s", i+1, item.Description),
+				fmt.Sprintf("     Quantity: %d  Unit price: %.2f  Amount: %.2f", item.Quantity, item.UnitPrice, item.Amount),
+			)
+		}
+		lines = append(lines, "")
+	}
+
+	lines = append(lines,
+		fmt.Sprintf("Payment amount:          %.2f", invoice.PaymentAmount),
+		fmt.Sprintf("Fee rate:                %.2f%%", invoice.FeeRate*100),
+		fmt.Sprintf("Fee:                     %.2f", invoice.Fee),
+		fmt.Sprintf("Consumption tax rate:    %.2f%%", invoice.ConsumptionTaxRate*100),
+		fmt.Sprintf("Consumption tax:         %.2f", invoice.ConsumptionTax),
+		fmt.Sprintf("Invoice amount:          %.2f", invoice.InvoiceAmount),
+	)
+
+	if invoice.Memo != nil && *invoice.Memo != "" {
+		lines = append(lines, "", "Memo: "+*invoice.Memo)
+	}
+
+	lines = append(lines,
+		"",
+		"Created: "+invoice.CreatedAt.Format(dateTimeLayout),
+		"Updated: "+invoice.UpdatedAt.Format(dateTimeLayout),
+	)
+
+	doc := New()
+	doc.AddPage(lines...)
+	return doc
+}
+
+// partyLines lists one party's contact details under the given label
+func partyLines(label, name, representative, postalCode, address, phone string) []string {
+	return []string{
+		label + ": " + name,
+		"  Representative: " + representative,
+		"  Address:        " + postalCode + " " + address,
+		"  Phone:          " + phone,
+	}
+}
@@ -0,0 +1,149 @@
+package pdf
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	pageWidth  = 595 // A4 width in points
+	pageHeight = 842 // A4 height in points
+	margin     = 50
+	fontSize   = 11
+	lineHeight = 16
+)
+
+// LinesPerPage is the number of text lines that fit on a single page
+const LinesPerPage = (pageHeight - 2*margin) / lineHeight
+
+// Document is a minimal text-only PDF document using the built-in Helvetica font.
+// Characters outside Latin-1 cannot be rendered by the built-in font and are replaced with '?'.
+type Document struct {
+	pages [][]string
+}
+
+// New creates an empty document
+func New() *Document {
+	return &Document{}
+}
+
+// AddPage appends a page with the given lines, continuing onto further pages when
+// the lines do not fit on one page
+func (d *Document) AddPage(lines ...string) {
+	for len(lines) > LinesPerPage {
+		d.pages = append(d.pages, lines[:LinesPerPage])
+		lines = lines[LinesPerPage:]
+	}
+	d.pages = append(d.pages, lines)
+}
+
+// WriteTo writes the document in PDF format to w
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	pages := d.pages
+	if len(pages) == 0 {
+		pages = [][]string{{}}
+	}
+
+	bw := bufio.NewWriter(w)
+	cw := &countingWriter{w: bw}
+	var offsets []int64
+
+	beginObject := func() int {
+		offsets = append(offsets, cw.n)
+		id := len(offsets)
+		fmt.Fprintf(cw, "%d 0 obj\n", id)
+		return id
+	}
+
+	fmt.Fprint(cw, "%PDF-1.4\n")
+
+	// Objects 1-3 are the catalog, page tree and font; each page then takes two objects
+	beginObject()
+	fmt.Fprint(cw, "<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+
+	beginObject()
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+2*i)
+	}
+	fmt.Fprintf(cw, "<< /Type /Pages /Kids [%s] /Count %d >>\nendobj\n", strings.Join(kids, " "), len(pages))
+
+	beginObject()
+	fmt.Fprint(cw, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>\nendobj\n")
+
+	for _, lines := range pages {
+		pageID := beginObject()
+		fmt.Fprintf(cw, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>\nendobj\n",
+			pageWidth, pageHeight, pageID+1)
+
+		content := pageContent(lines)
+		beginObject()
+		fmt.Fprintf(cw, "<< /Length %d >>\nstream\n", len(content))
+		_, _ = cw.Write(content)
+		fmt.Fprint(cw, "\nendstream\nendobj\n")
+	}
+
+	xrefOffset := cw.n
+	fmt.Fprintf(cw, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(cw, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(cw, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xrefOffset)
+
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	return cw.n, bw.Flush()
+}
+
+// pageContent builds the content stream drawing lines top to bottom
+func pageContent(lines []string) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "BT\n/F1 %d Tf\n%d TL\n%d %d Td\n", fontSize, lineHeight, margin, pageHeight-margin)
+	for _, line := range lines {
+		fmt.Fprintf(&buf, "(%s) '\n", escapeText(line))
+	}
+	buf.WriteString("ET")
+	return buf.Bytes()
+}
+
+// escapeText converts s to a Latin-1 PDF string literal body
+func escapeText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20:
+			b.WriteByte(' ')
+		case r < 0x80:
+			b.WriteRune(r)
+		case r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// countingWriter tracks the number of bytes written so object offsets can be recorded
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.err = err
+	return n, err
+}
@@ -0,0 +1,55 @@
+package pdf
+
+import (
+	"fmt"
+	"sort"
+	"super-payment/internal/models"
+)
+
+// StatementDocument lays out a business partner's monthly statement: one line per invoice with
+// the running total of its currency, followed by the total of each currency
+func StatementDocument(statement *models.BusinessPartnerStatement) *Document {
+	lines := []string{
+		fmt.Sprintf("STATEMENT %04d-%02d", statement.Year, int(statement.Month)),
+		"",
+	}
+
+	if statement.Company != nil {
+		lines = append(lines, "From: "+statement.Company.CorporateName)
+	}
+	if partner := statement.BusinessPartner; partner != nil {
+		lines = append(lines, partyLines("To", partner.CorporateName, partner.Representative,
+			partner.PostalCode, partner.Address, partner.PhoneNumber)...)
+	}
+	lines = append(lines, "")
+
+	if len(statement.Invoices) == 0 {
+		lines = append(lines, "No invoices were issued this month.")
+		doc := New()
+		doc.AddPage(lines...)
+		return doc
+	}
+
+	lines = append(lines, "Invoices:")
+	totals := make(map[string]float64)
+	for _, invoice := range statement.Invoices {
+		totals[invoice.Currency] = models.RoundToCurrency(totals[invoice.Currency]+invoice.InvoiceAmount, invoice.Currency)
+		lines = append(lines, fmt.Sprintf("  %s  %s  %s  %s %.2f  Running total: %s %.2f",
+			invoice.IssueDate.Format(dateLayout), invoice.InvoiceNumber, invoice.Status,
+			invoice.Currency, invoice.InvoiceAmount, invoice.Currency, totals[invoice.Currency]))
+	}
+	lines = append(lines, "")
+
+	currencies := make([]string, 0, len(totals))
+	for currency := range totals {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	for _, currency := range currencies {
+		lines = append(lines, fmt.Sprintf("Total (%s): %.2f", currency, totals[currency]))
+	}
+
+	doc := New()
+	doc.AddPage(lines...)
+	return doc
+}
@@ -0,0 +1,233 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"super-payment/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// maskedArg replaces logged argument values that must not appear in logs
+const maskedArg = "***"
+
+// queryLogConnector wraps a driver connector so that the statements executed on its connections,
+// including those inside transactions, are logged with their args and duration
+type queryLogConnector struct {
+	driver.Connector
+	logger *queryLogger
+}
+
+func (c *queryLogConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &queryLogConn{Conn: conn, logger: c.logger}, nil
+}
+
+// queryLogConn logs the statements executed directly on a connection and wraps the
+// statements it prepares. Optional driver interfaces are forwarded to the wrapped connection.
+type queryLogConn struct {
+	driver.Conn
+	logger *queryLogger
+}
+
+func (c *queryLogConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	start := time.Now()
+	result, err := execer.ExecContext(ctx, query, args)
+	// ErrSkip makes database/sql retry through a prepared statement, which is logged instead
+	if err != driver.ErrSkip {
+		c.logger.log(query, args, time.Since(start), err)
+	}
+	return result, err
+}
+
+func (c *queryLogConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	start := time.Now()
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		c.logger.log(query, args, time.Since(start), err)
+	}
+	return rows, err
+}
+
+func (c *queryLogConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *queryLogConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &queryLogStmt{Stmt: stmt, query: query, logger: c.logger}, nil
+}
+
+func (c *queryLogConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *queryLogConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *queryLogConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *queryLogConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *queryLogConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// queryLogStmt logs each execution of a prepared statement
+type queryLogStmt struct {
+	driver.Stmt
+	query  string
+	logger *queryLogger
+}
+
+func (s *queryLogStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var result driver.Result
+	var err error
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		result, err = execer.ExecContext(ctx, args)
+	} else {
+		result, err = s.Stmt.Exec(namedValues(args))
+	}
+	s.logger.log(s.query, args, time.Since(start), err)
+	return result, err
+}
+
+func (s *queryLogStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var rows driver.Rows
+	var err error
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		rows, err = s.Stmt.Query(namedValues(args))
+	}
+	s.logger.log(s.query, args, time.Since(start), err)
+	return rows, err
+}
+
+// namedValues converts named args to the positional values of the legacy driver interfaces
+func namedValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return values
+}
+
+// queryLogger logs executed statements: every statement to queryLog, and statements taking at
+// least slowThreshold to slowLog as a warning. A nil queryLog or zero slowThreshold disables
+// the respective log.
+type queryLogger struct {
+	queryLog      *log.Logger
+	slowLog       *log.Logger
+	slowThreshold time.Duration
+}
+
+// log logs a statement on a single line with its args and duration
+func (l *queryLogger) log(query string, args []driver.NamedValue, duration time.Duration, err error) {
+	slow := l.slowThreshold > 0 && duration >= l.slowThreshold
+	if l.queryLog == nil && !slow {
+		return
+	}
+
+	values := make([]string, len(args))
+	for i, arg := range args {
+		values[i] = formatArg(arg.Value)
+	}
+
+	line := fmt.Sprintf("SQL %s [%s] (%s)", strings.Join(strings.Fields(query), " "), strings.Join(values, ", "), duration)
+	if err != nil {
+		line += " error: " + err.Error()
+	}
+	if l.queryLog != nil {
+		l.queryLog.Print(line)
+	}
+	if slow {
+		l.slowLog.Printf("WARNING: slow query over %s: %s", l.slowThreshold, line)
+	}
+}
+
+// formatArg formats a statement argument for the query log, masking password hashes and
+// webhook secrets
+func formatArg(value driver.Value) string {
+	switch v := value.(type) {
+	case string:
+		if isSecret(v) {
+			return maskedArg
+		}
+		return fmt.Sprintf("%q", v)
+	case []byte:
+		if isSecret(string(v)) {
+			return maskedArg
+		}
+		return fmt.Sprintf("%q", v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case nil:
+		return "NULL"
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// isSecret reports whether s is a value that must never be written to the query log
+func isSecret(s string) bool {
+	return isPasswordHash(s) || strings.HasPrefix(s, models.WebhookSecretPrefix)
+}
+
+// isPasswordHash reports whether s is a bcrypt hash, the form in which user passwords are stored
+func isPasswordHash(s string) bool {
+	if !strings.HasPrefix(s, "$2") {
+		return false
+	}
+	_, err := bcrypt.Cost([]byte(s))
+	return err == nil
+}
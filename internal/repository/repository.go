@@ -1,12 +1,18 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"log"
+	"net"
+	"strings"
 	"super-payment/internal/models"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 )
 
 // Repository interface defines the contract for data access
@@ -15,21 +21,73 @@ type Repository interface {
 	CreateUser(user *models.User) error
 	GetUserByEmail(email string) (*models.User, error)
 	GetUserByID(id uint) (*models.User, error)
+	SetUserActive(id uint, active bool) error
+	UpdateUser(user *models.User) error
+	GetUsersByCompanyID(companyID uint, req *models.GetUsersRequest) ([]*models.User, error)
+	CountUsersByCompanyID(companyID uint, search *string) (int, error)
 
 	// Company operations
 	CreateCompany(company *models.Company) error
 	GetCompanyByID(id uint) (*models.Company, error)
+	CompanyNameExists(normalizedName string) (bool, error)
+	SetCompanyFeeRate(companyID uint, feeRate *float64) error
+	GetCompanyRates(companyID uint) (*models.CompanyRates, error)
+	UpdateCompanyRates(companyID uint, rates *models.CompanyRates) error
 
 	// Business Partner operations
 	CreateBusinessPartner(partner *models.BusinessPartner) error
+	CreateBusinessPartners(partners []*models.BusinessPartner) error
 	GetBusinessPartnerByID(id uint) (*models.BusinessPartner, error)
-	GetBusinessPartnersByCompanyID(companyID uint) ([]*models.BusinessPartner, error)
+	FindBusinessPartnerByNormalizedName(companyID uint, normalizedName string) (*models.BusinessPartner, error)
+	UpdateBusinessPartner(partner *models.BusinessPartner) error
+	MergeBusinessPartners(sourceID, targetID uint, deleteSource bool, closedBefore *time.Time) (int64, error)
+	GetBusinessPartnersByCompanyID(companyID uint, req *models.GetBusinessPartnersRequest) ([]*models.BusinessPartner, error)
+	GetBusinessPartnerStats(companyID, partnerID uint) (*models.BusinessPartnerStats, error)
+	CreateBankAccount(account *models.BusinessPartnerBankAccount) error
+	GetBankAccountByID(id uint) (*models.BusinessPartnerBankAccount, error)
+	UpdateBankAccount(account *models.BusinessPartnerBankAccount) error
+	GetPrimaryBankAccount(partnerID uint) (*models.BusinessPartnerBankAccount, error)
+	SetPrimaryBankAccount(partnerID, accountID uint) error
 
 	// Invoice operations
 	CreateInvoice(invoice *models.Invoice) error
 	GetInvoiceByID(id uint) (*models.Invoice, error)
+	GetInvoiceByIDContext(ctx context.Context, id uint) (*models.Invoice, error)
+	GetInvoiceByNumber(companyID uint, number string) (*models.Invoice, error)
+	GetInvoiceByNumberContext(ctx context.Context, companyID uint, number string) (*models.Invoice, error)
 	GetInvoicesByCompanyID(companyID uint, req *models.GetInvoicesRequest) ([]*models.Invoice, error)
-	UpdateInvoiceStatus(id uint, status models.InvoiceStatus) error
+	GetInvoicesByCompanyIDContext(ctx context.Context, companyID uint, req *models.GetInvoicesRequest) ([]*models.Invoice, error)
+	CountInvoicesByCompanyID(companyID uint, req *models.GetInvoicesRequest) (int, error)
+	CountInvoicesByCompanyIDContext(ctx context.Context, companyID uint, req *models.GetInvoicesRequest) (int, error)
+	CountInvoicesByStatus(companyID uint) ([]*models.InvoiceStatusCount, error)
+	GetInvoiceStatusSummaries(companyID uint) ([]*models.StatusSummary, error)
+	FindDuplicateInvoice(invoice *models.Invoice) (*models.Invoice, error)
+	UpdateInvoiceStatus(id uint, from, to models.InvoiceStatus, userID uint) error
+	RecordInvoicePayment(payment *models.InvoicePayment, from, to models.InvoiceStatus, paidBefore float64) error
+	DeleteInvoices(companyID uint, ids []uint, closedBefore *time.Time) ([]models.BulkDeleteInvoiceResult, error)
+	MarkOverdueInvoices(dueBefore time.Time, closedBefore *time.Time) ([]*models.Invoice, error)
+	PurgeDeletedInvoices(deletedBefore time.Time, closedBefore *time.Time) (int64, error)
+	IssueInvoice(id uint, status models.InvoiceStatus, issueDate time.Time, userID uint) error
+	UpdateInvoiceAmounts(invoice *models.Invoice) error
+	GetMonthlyInvoiceTotals(companyID uint, year int) ([]*models.MonthlyInvoiceTotal, error)
+	GetInvoiceAgingTotals(companyID uint, today time.Time) ([]*models.AgingBucketTotal, error)
+	GetFeeRevenueTotals(start, end time.Time) ([]*models.FeeRevenueTotal, error)
+	GetInvoiceStatusHistory(invoiceID uint) ([]*models.InvoiceStatusHistory, error)
+	GetInvoiceStatusHistoryPage(invoiceID uint, req *models.GetInvoiceHistoryRequest) ([]*models.InvoiceStatusHistory, error)
+	CountInvoiceStatusHistory(invoiceID uint, action *string) (int, error)
+	CreateInvoiceAttachment(attachment *models.InvoiceAttachment) error
+	GetInvoiceAttachments(invoiceID uint) ([]*models.InvoiceAttachment, error)
+
+	// Webhook operations
+	GetCompanyWebhook(companyID uint) (*models.WebhookSettings, error)
+	SetCompanyWebhookURL(companyID uint, url *string, secret string) error
+	CreateWebhookDelivery(delivery *models.WebhookDelivery) error
+	UpdateWebhookDelivery(delivery *models.WebhookDelivery) error
+	GetDueWebhookDeliveries(now time.Time, limit int) ([]*models.WebhookDelivery, error)
+	GetWebhookDeliveriesByCompanyID(companyID uint, req *models.GetWebhookDeliveriesRequest) ([]*models.WebhookDelivery, error)
+
+	// Health operations
+	CheckHealth() *models.DatabaseHealth
 }
 
 // MySQLRepository implements Repository interface
@@ -39,10 +97,49 @@ type MySQLRepository struct {
 
 // NewMySQLRepository creates a new MySQL repository
 func NewMySQLRepository(dsn string) (*MySQLRepository, error) {
-	db, err := sql.Open("mysql", dsn)
+	return NewMySQLRepositoryWithLogging(dsn, LogOptions{})
+}
+
+// NewMySQLRepositoryWithQueryLog creates a new MySQL repository that logs every executed
+// statement with its args and duration to queryLog. A nil queryLog disables the logging.
+func NewMySQLRepositoryWithQueryLog(dsn string, queryLog *log.Logger) (*MySQLRepository, error) {
+	return NewMySQLRepositoryWithLogging(dsn, LogOptions{QueryLog: queryLog})
+}
+
+// LogOptions configures which executed statements a MySQLRepository logs
+type LogOptions struct {
+	// QueryLog receives every statement with its args and duration; nil disables it
+	QueryLog *log.Logger
+	// SlowQueryThreshold is the duration from which a statement is logged as a warning to
+	// SlowQueryLog; 0 disables the warning
+	SlowQueryThreshold time.Duration
+	// SlowQueryLog receives the slow query warnings; nil uses the standard logger
+	SlowQueryLog *log.Logger
+}
+
+// NewMySQLRepositoryWithLogging creates a new MySQL repository that logs executed statements as
+// configured by opts
+func NewMySQLRepositoryWithLogging(dsn string, opts LogOptions) (*MySQLRepository, error) {
+	mysqlConfig, err := mysql.ParseDSN(dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
+	connector, err := mysql.NewConnector(mysqlConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	if opts.QueryLog != nil || opts.SlowQueryThreshold > 0 {
+		slowLog := opts.SlowQueryLog
+		if slowLog == nil {
+			slowLog = log.Default()
+		}
+		connector = &queryLogConnector{Connector: connector, logger: &queryLogger{
+			queryLog:      opts.QueryLog,
+			slowLog:       slowLog,
+			slowThreshold: opts.SlowQueryThreshold,
+		}}
+	}
+	db := sql.OpenDB(connector)
 
 	if err := db.Ping(); err != nil {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
@@ -51,6 +148,79 @@ func NewMySQLRepository(dsn string) (*MySQLRepository, error) {
 	return &MySQLRepository{db: db}, nil
 }
 
+var (
+	// ErrDuplicateKey is returned when a write violates a unique constraint
+	ErrDuplicateKey = errors.New("duplicate key")
+	// ErrUserNotFound is returned when no user has the requested email
+	ErrUserNotFound = errors.New("user not found")
+	// ErrInvoiceChanged is returned when an invoice no longer has the status or paid amount an
+	// update was based on
+	ErrInvoiceChanged = errors.New("invoice has changed")
+	// ErrPeriodClosed is returned when a change would reach invoices issued in a closed accounting
+	// period
+	ErrPeriodClosed = errors.New("accounting period is closed")
+)
+
+// mysqlDuplicateEntry is the MySQL error number of a unique constraint violation
+const mysqlDuplicateEntry = 1062
+
+// duplicateKeyError wraps err with ErrDuplicateKey when it is a unique constraint violation
+func duplicateKeyError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDuplicateEntry {
+		return fmt.Errorf("%w: %v", ErrDuplicateKey, err)
+	}
+	return err
+}
+
+// IsConnectionError reports whether err was caused by a lost or unusable database connection,
+// in which case the operation may succeed when retried
+func IsConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) || errors.Is(err, sql.ErrConnDone) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	// database/sql does not export the error returned once the pool has been closed
+	return strings.Contains(err.Error(), "sql: database is closed")
+}
+
+// healthCheckTimeout bounds how long a health check waits for the database to answer
+const healthCheckTimeout = 2 * time.Second
+
+// CheckHealth pings the database and reports the connection pool statistics
+func (r *MySQLRepository) CheckHealth() *models.DatabaseHealth {
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+
+	health := &models.DatabaseHealth{Connected: true}
+	start := time.Now()
+	if err := r.db.PingContext(ctx); err != nil {
+		health.Connected = false
+		health.Error = err.Error()
+	}
+	health.LatencyMs = time.Since(start).Milliseconds()
+
+	stats := r.db.Stats()
+	health.Pool = models.DatabasePoolStats{
+		MaxOpen:        stats.MaxOpenConnections,
+		Open:           stats.OpenConnections,
+		InUse:          stats.InUse,
+		Idle:           stats.Idle,
+		WaitCount:      stats.WaitCount,
+		WaitDurationMs: stats.WaitDuration.Milliseconds(),
+	}
+	return health
+}
+
 // Close closes the database connection
 func (r *MySQLRepository) Close() error {
 	return r.db.Close()
@@ -59,11 +229,11 @@ func (r *MySQLRepository) Close() error {
 // CreateUser creates a new user
 func (r *MySQLRepository) CreateUser(user *models.User) error {
 	query := `
-		INSERT INTO users (company_id, full_name, email, password, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?)
+		INSERT INTO users (company_id, full_name, email, password, role, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
 	`
 	now := time.Now()
-	result, err := r.db.Exec(query, user.CompanyID, user.FullName, user.Email, user.Password, now, now)
+	result, err := r.db.Exec(query, user.CompanyID, user.FullName, user.Email, user.Password, user.Role, now, now)
 	if err != nil {
 		return fmt.Errorf("failed to create user: %w", err)
 	}
@@ -74,15 +244,16 @@ func (r *MySQLRepository) CreateUser(user *models.User) error {
 	}
 
 	user.ID = uint(id)
-	user.CreatedAt = now
-	user.UpdatedAt = now
+	user.IsActive = true
+	user.CreatedAt = models.NewTimestamp(now)
+	user.UpdatedAt = models.NewTimestamp(now)
 	return nil
 }
 
-// GetUserByEmail gets a user by email
+// GetUserByEmail gets a user by email, failing with ErrUserNotFound when there is none
 func (r *MySQLRepository) GetUserByEmail(email string) (*models.User, error) {
 	query := `
-		SELECT u.id, u.company_id, u.full_name, u.email, u.password, u.created_at, u.updated_at,
+		SELECT u.id, u.company_id, u.full_name, u.email, u.password, u.role, u.is_active, u.created_at, u.updated_at,
 		       c.id, c.corporate_name, c.representative, c.phone_number, c.postal_code, c.address, c.created_at, c.updated_at
 		FROM users u
 		JOIN companies c ON u.company_id = c.id
@@ -92,13 +263,14 @@ func (r *MySQLRepository) GetUserByEmail(email string) (*models.User, error) {
 
 	user := &models.User{Company: &models.Company{}}
 	err := row.Scan(
-		&user.ID, &user.CompanyID, &user.FullName, &user.Email, &user.Password, &user.CreatedAt, &user.UpdatedAt,
-		&user.Company.ID, &user.Company.CorporateName, &user.Company.Representative, &user.Company.PhoneNumber,
-		&user.Company.PostalCode, &user.Company.Address, &user.Company.CreatedAt, &user.Company.UpdatedAt,
+		&user.ID, &user.CompanyID, &user.FullName, &user.Email, &user.Password, &user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt,
+		&user.Company.ID, &user.Company.CorporateName, nullableString(&user.Company.Representative),
+		nullableString(&user.Company.PhoneNumber), nullableString(&user.Company.PostalCode), nullableString(&user.Company.Address),
+		&user.Company.CreatedAt, &user.Company.UpdatedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
@@ -109,7 +281,7 @@ func (r *MySQLRepository) GetUserByEmail(email string) (*models.User, error) {
 // GetUserByID gets a user by ID
 func (r *MySQLRepository) GetUserByID(id uint) (*models.User, error) {
 	query := `
-		SELECT u.id, u.company_id, u.full_name, u.email, u.password, u.created_at, u.updated_at,
+		SELECT u.id, u.company_id, u.full_name, u.email, u.password, u.role, u.is_active, u.created_at, u.updated_at,
 		       c.id, c.corporate_name, c.representative, c.phone_number, c.postal_code, c.address, c.created_at, c.updated_at
 		FROM users u
 		JOIN companies c ON u.company_id = c.id
@@ -119,9 +291,10 @@ func (r *MySQLRepository) GetUserByID(id uint) (*models.User, error) {
 
 	user := &models.User{Company: &models.Company{}}
 	err := row.Scan(
-		&user.ID, &user.CompanyID, &user.FullName, &user.Email, &user.Password, &user.CreatedAt, &user.UpdatedAt,
-		&user.Company.ID, &user.Company.CorporateName, &user.Company.Representative, &user.Company.PhoneNumber,
-		&user.Company.PostalCode, &user.Company.Address, &user.Company.CreatedAt, &user.Company.UpdatedAt,
+		&user.ID, &user.CompanyID, &user.FullName, &user.Email, &user.Password, &user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt,
+		&user.Company.ID, &user.Company.CorporateName, nullableString(&user.Company.Representative),
+		nullableString(&user.Company.PhoneNumber), nullableString(&user.Company.PostalCode), nullableString(&user.Company.Address),
+		&user.Company.CreatedAt, &user.Company.UpdatedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -133,6 +306,85 @@ func (r *MySQLRepository) GetUserByID(id uint) (*models.User, error) {
 	return user, nil
 }
 
+// SetUserActive activates or deactivates a user
+func (r *MySQLRepository) SetUserActive(id uint, active bool) error {
+	_, err := r.db.Exec(`UPDATE users SET is_active = ?, updated_at = ? WHERE id = ?`, active, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	return nil
+}
+
+// UpdateUser updates the full name and email of a user. It fails with ErrDuplicateKey when
+// another user already has the email.
+func (r *MySQLRepository) UpdateUser(user *models.User) error {
+	now := time.Now()
+	_, err := r.db.Exec(`UPDATE users SET full_name = ?, email = ?, updated_at = ? WHERE id = ?`,
+		user.FullName, user.Email, now, user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", duplicateKeyError(err))
+	}
+
+	user.UpdatedAt = models.NewTimestamp(now)
+	return nil
+}
+
+// GetUsersByCompanyID gets one page of the users of a company ordered by name, optionally limited
+// to those whose full name or email contains req.Search. Users are returned without their company.
+func (r *MySQLRepository) GetUsersByCompanyID(companyID uint, req *models.GetUsersRequest) ([]*models.User, error) {
+	query := `
+		SELECT id, company_id, full_name, email, password, role, is_active, created_at, updated_at
+		FROM users
+		WHERE company_id = ?
+	`
+	where, args := userSearchFilter(req.Search)
+	query += where + " ORDER BY full_name, id LIMIT ? OFFSET ?"
+	args = append([]interface{}{companyID}, args...)
+	args = append(args, req.Limit, (req.Page-1)*req.Limit)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		user := &models.User{}
+		err := rows.Scan(&user.ID, &user.CompanyID, &user.FullName, &user.Email, &user.Password,
+			&user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
+// CountUsersByCompanyID counts the users of a company, optionally limited to those whose full name
+// or email contains search
+func (r *MySQLRepository) CountUsersByCompanyID(companyID uint, search *string) (int, error) {
+	where, args := userSearchFilter(search)
+	args = append([]interface{}{companyID}, args...)
+
+	var count int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM users WHERE company_id = ?`+where, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	return count, nil
+}
+
+// userSearchFilter builds the condition matching users whose full name or email contains search
+func userSearchFilter(search *string) (string, []interface{}) {
+	if search == nil {
+		return "", nil
+	}
+	pattern := "%" + escapeLike(*search) + "%"
+	return " AND (full_name LIKE ? OR email LIKE ?)", []interface{}{pattern, pattern}
+}
+
 // CreateCompany creates a new company
 func (r *MySQLRepository) CreateCompany(company *models.Company) error {
 	query := `
@@ -152,11 +404,28 @@ func (r *MySQLRepository) CreateCompany(company *models.Company) error {
 	}
 
 	company.ID = uint(id)
-	company.CreatedAt = now
-	company.UpdatedAt = now
+	company.CreatedAt = models.NewTimestamp(now)
+	company.UpdatedAt = models.NewTimestamp(now)
 	return nil
 }
 
+// CompanyNameExists checks whether a company exists whose corporate name, with surrounding
+// whitespace trimmed and inner whitespace collapsed, equals normalizedName
+func (r *MySQLRepository) CompanyNameExists(normalizedName string) (bool, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM companies
+		WHERE REGEXP_REPLACE(TRIM(corporate_name), '[[:space:]]+', ' ') = ?
+	`
+
+	var count int
+	if err := r.db.QueryRow(query, normalizedName).Scan(&count); err != nil {
+		return false, fmt.Errorf("failed to check company name: %w", err)
+	}
+
+	return count > 0, nil
+}
+
 // GetCompanyByID gets a company by ID
 func (r *MySQLRepository) GetCompanyByID(id uint) (*models.Company, error) {
 	query := `
@@ -167,8 +436,9 @@ func (r *MySQLRepository) GetCompanyByID(id uint) (*models.Company, error) {
 	row := r.db.QueryRow(query, id)
 
 	company := &models.Company{}
-	err := row.Scan(&company.ID, &company.CorporateName, &company.Representative, &company.PhoneNumber,
-		&company.PostalCode, &company.Address, &company.CreatedAt, &company.UpdatedAt)
+	err := row.Scan(&company.ID, &company.CorporateName, nullableString(&company.Representative),
+		nullableString(&company.PhoneNumber), nullableString(&company.PostalCode), nullableString(&company.Address),
+		&company.CreatedAt, &company.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("company not found")
@@ -179,14 +449,121 @@ func (r *MySQLRepository) GetCompanyByID(id uint) (*models.Company, error) {
 	return company, nil
 }
 
+// GetCompanyRates gets the rates, payment terms and initial invoice status configured by a company;
+// unset values are nil
+func (r *MySQLRepository) GetCompanyRates(companyID uint) (*models.CompanyRates, error) {
+	var feeRate, taxRate sql.NullFloat64
+	var termDays sql.NullInt32
+	var initialStatus sql.NullString
+	err := r.db.QueryRow(`SELECT fee_rate, consumption_tax_rate, payment_term_days, initial_invoice_status FROM companies WHERE id = ?`, companyID).
+		Scan(&feeRate, &taxRate, &termDays, &initialStatus)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("company not found")
+		}
+		return nil, fmt.Errorf("failed to get company rates: %w", err)
+	}
+
+	rates := &models.CompanyRates{}
+	if feeRate.Valid {
+		rates.FeeRate = &feeRate.Float64
+	}
+	if taxRate.Valid {
+		rates.ConsumptionTaxRate = &taxRate.Float64
+	}
+	if termDays.Valid {
+		days := int(termDays.Int32)
+		rates.PaymentTermDays = &days
+	}
+	if initialStatus.Valid {
+		status := models.InvoiceStatus(initialStatus.String)
+		rates.InitialInvoiceStatus = &status
+	}
+	return rates, nil
+}
+
+// UpdateCompanyRates sets the consumption tax rate, payment terms and initial invoice status of a
+// company; nil values revert to the defaults. The fee rate is left to SetCompanyFeeRate.
+func (r *MySQLRepository) UpdateCompanyRates(companyID uint, rates *models.CompanyRates) error {
+	query := `
+		UPDATE companies
+		SET consumption_tax_rate = ?, payment_term_days = ?, initial_invoice_status = ?, updated_at = ?
+		WHERE id = ?
+	`
+	if _, err := r.db.Exec(query, rates.ConsumptionTaxRate, rates.PaymentTermDays, rates.InitialInvoiceStatus, time.Now(), companyID); err != nil {
+		return fmt.Errorf("failed to update company rates: %w", err)
+	}
+	return nil
+}
+
+// SetCompanyFeeRate sets the negotiated fee rate of a company; nil reverts it to the default rate
+func (r *MySQLRepository) SetCompanyFeeRate(companyID uint, feeRate *float64) error {
+	if _, err := r.db.Exec(`UPDATE companies SET fee_rate = ?, updated_at = ? WHERE id = ?`, feeRate, time.Now(), companyID); err != nil {
+		return fmt.Errorf("failed to set company fee rate: %w", err)
+	}
+	return nil
+}
+
 // CreateBusinessPartner creates a new business partner
 func (r *MySQLRepository) CreateBusinessPartner(partner *models.BusinessPartner) error {
+	return insertBusinessPartner(r.db, partner, time.Now())
+}
+
+// CreateBusinessPartners creates several business partners in a single transaction, so that
+// either all of them are created or none
+func (r *MySQLRepository) CreateBusinessPartners(partners []*models.BusinessPartner) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now()
+	for _, partner := range partners {
+		if err := insertBusinessPartner(tx, partner, now); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit business partners: %w", err)
+	}
+
+	return nil
+}
+
+// nullableString scans a text column into dest, reading NULL as an empty string. Contact details
+// of companies and business partners are read through it so that legacy rows missing them can
+// still be loaded.
+func nullableString(dest *string) sql.Scanner {
+	return nullStringScanner{dest: dest}
+}
+
+type nullStringScanner struct {
+	dest *string
+}
+
+func (s nullStringScanner) Scan(value interface{}) error {
+	var ns sql.NullString
+	if err := ns.Scan(value); err != nil {
+		return err
+	}
+	*s.dest = ns.String
+	return nil
+}
+
+// execer is implemented by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// insertBusinessPartner inserts a business partner and sets its ID and timestamps
+func insertBusinessPartner(db execer, partner *models.BusinessPartner, now time.Time) error {
 	query := `
 		INSERT INTO business_partners (company_id, corporate_name, representative, phone_number, postal_code, address, created_at, updated_at)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 	`
-	now := time.Now()
-	result, err := r.db.Exec(query, partner.CompanyID, partner.CorporateName, partner.Representative,
+	result, err := db.Exec(query, partner.CompanyID, partner.CorporateName, partner.Representative,
 		partner.PhoneNumber, partner.PostalCode, partner.Address, now, now)
 	if err != nil {
 		return fmt.Errorf("failed to create business partner: %w", err)
@@ -198,8 +575,8 @@ func (r *MySQLRepository) CreateBusinessPartner(partner *models.BusinessPartner)
 	}
 
 	partner.ID = uint(id)
-	partner.CreatedAt = now
-	partner.UpdatedAt = now
+	partner.CreatedAt = models.NewTimestamp(now)
+	partner.UpdatedAt = models.NewTimestamp(now)
 	return nil
 }
 
@@ -213,8 +590,9 @@ func (r *MySQLRepository) GetBusinessPartnerByID(id uint) (*models.BusinessPartn
 	row := r.db.QueryRow(query, id)
 
 	partner := &models.BusinessPartner{}
-	err := row.Scan(&partner.ID, &partner.CompanyID, &partner.CorporateName, &partner.Representative,
-		&partner.PhoneNumber, &partner.PostalCode, &partner.Address, &partner.CreatedAt, &partner.UpdatedAt)
+	err := row.Scan(&partner.ID, &partner.CompanyID, &partner.CorporateName, nullableString(&partner.Representative),
+		nullableString(&partner.PhoneNumber), nullableString(&partner.PostalCode), nullableString(&partner.Address),
+		&partner.CreatedAt, &partner.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("business partner not found")
@@ -225,13 +603,106 @@ func (r *MySQLRepository) GetBusinessPartnerByID(id uint) (*models.BusinessPartn
 	return partner, nil
 }
 
-// GetBusinessPartnersByCompanyID gets business partners by company ID
-func (r *MySQLRepository) GetBusinessPartnersByCompanyID(companyID uint) ([]*models.BusinessPartner, error) {
+// FindBusinessPartnerByNormalizedName finds the oldest business partner of a company whose
+// corporate name, lower-cased with surrounding whitespace trimmed and inner whitespace collapsed,
+// equals normalizedName. It returns nil when there is no match.
+func (r *MySQLRepository) FindBusinessPartnerByNormalizedName(companyID uint, normalizedName string) (*models.BusinessPartner, error) {
 	query := `
-		SELECT id, company_id, corporate_name, representative, phone_number, postal_code, address, created_at, updated_at
+		SELECT id
 		FROM business_partners
-		WHERE company_id = ?
+		WHERE company_id = ? AND LOWER(REGEXP_REPLACE(TRIM(corporate_name), '[[:space:]]+', ' ')) = ?
+		ORDER BY id
+		LIMIT 1
+	`
+
+	var id uint
+	if err := r.db.QueryRow(query, companyID, normalizedName).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find business partner by name: %w", err)
+	}
+
+	return r.GetBusinessPartnerByID(id)
+}
+
+// UpdateBusinessPartner updates the name, representative and contact details of a business partner
+func (r *MySQLRepository) UpdateBusinessPartner(partner *models.BusinessPartner) error {
+	query := `
+		UPDATE business_partners
+		SET corporate_name = ?, representative = ?, phone_number = ?, postal_code = ?, address = ?, updated_at = ?
+		WHERE id = ?
+	`
+	now := time.Now()
+	if _, err := r.db.Exec(query, partner.CorporateName, partner.Representative, partner.PhoneNumber,
+		partner.PostalCode, partner.Address, now, partner.ID); err != nil {
+		return fmt.Errorf("failed to update business partner: %w", err)
+	}
+
+	partner.UpdatedAt = models.NewTimestamp(now)
+	return nil
+}
+
+// MergeBusinessPartners reassigns every invoice of the source business partner to the target in a
+// single transaction and returns how many were moved. When deleteSource is set the source partner
+// is deleted as well, together with its bank accounts. When closedBefore is set and any invoice of
+// the source was issued before it, nothing is merged and ErrPeriodClosed is returned.
+func (r *MySQLRepository) MergeBusinessPartners(sourceID, targetID uint, deleteSource bool, closedBefore *time.Time) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if closedBefore != nil {
+		var closed int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM invoices WHERE business_partner_id = ? AND issue_date < ?`,
+			sourceID, *closedBefore).Scan(&closed); err != nil {
+			return 0, fmt.Errorf("failed to check for invoices in a closed period: %w", err)
+		}
+		if closed > 0 {
+			return 0, fmt.Errorf("%w: %d invoices of business partner %d were issued in it", ErrPeriodClosed, closed, sourceID)
+		}
+	}
+
+	result, err := tx.Exec(`UPDATE invoices SET business_partner_id = ?, updated_at = ? WHERE business_partner_id = ?`,
+		targetID, time.Now(), sourceID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reassign invoices: %w", err)
+	}
+	reassigned, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if deleteSource {
+		if _, err := tx.Exec(`DELETE FROM business_partners WHERE id = ?`, sourceID); err != nil {
+			return 0, fmt.Errorf("failed to delete business partner: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return reassigned, nil
+}
+
+// GetBusinessPartnersByCompanyID gets business partners by company ID, filtered by req when it is
+// not nil
+func (r *MySQLRepository) GetBusinessPartnersByCompanyID(companyID uint, req *models.GetBusinessPartnersRequest) ([]*models.BusinessPartner, error) {
+	query := `
+		SELECT bp.id, bp.company_id, bp.corporate_name, bp.representative, bp.phone_number, bp.postal_code, bp.address, bp.created_at, bp.updated_at
+		FROM business_partners bp
+		WHERE bp.company_id = ?
 	`
+	if req != nil && req.HasBankAccount != nil {
+		exists := `EXISTS (SELECT 1 FROM business_partner_bank_accounts ba WHERE ba.business_partner_id = bp.id)`
+		if *req.HasBankAccount {
+			query += ` AND ` + exists
+		} else {
+			query += ` AND NOT ` + exists
+		}
+	}
 	rows, err := r.db.Query(query, companyID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get business partners: %w", err)
@@ -241,8 +712,9 @@ func (r *MySQLRepository) GetBusinessPartnersByCompanyID(companyID uint) ([]*mod
 	var partners []*models.BusinessPartner
 	for rows.Next() {
 		partner := &models.BusinessPartner{}
-		err := rows.Scan(&partner.ID, &partner.CompanyID, &partner.CorporateName, &partner.Representative,
-			&partner.PhoneNumber, &partner.PostalCode, &partner.Address, &partner.CreatedAt, &partner.UpdatedAt)
+		err := rows.Scan(&partner.ID, &partner.CompanyID, &partner.CorporateName, nullableString(&partner.Representative),
+			nullableString(&partner.PhoneNumber), nullableString(&partner.PostalCode), nullableString(&partner.Address),
+			&partner.CreatedAt, &partner.UpdatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan business partner: %w", err)
 		}
@@ -252,19 +724,18 @@ func (r *MySQLRepository) GetBusinessPartnersByCompanyID(companyID uint) ([]*mod
 	return partners, nil
 }
 
-// CreateInvoice creates a new invoice
-func (r *MySQLRepository) CreateInvoice(invoice *models.Invoice) error {
+// CreateBankAccount creates a new bank account for a business partner. It fails with
+// ErrDuplicateKey when the partner already has an account with the same bank, branch and number.
+func (r *MySQLRepository) CreateBankAccount(account *models.BusinessPartnerBankAccount) error {
 	query := `
-		INSERT INTO invoices (company_id, business_partner_id, issue_date, payment_amount, fee, fee_rate, 
-		                     consumption_tax, consumption_tax_rate, invoice_amount, payment_due_date, status, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO business_partner_bank_accounts (business_partner_id, bank_name, branch_name, account_number, account_name, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
 	`
 	now := time.Now()
-	result, err := r.db.Exec(query, invoice.CompanyID, invoice.BusinessPartnerID, invoice.IssueDate,
-		invoice.PaymentAmount, invoice.Fee, invoice.FeeRate, invoice.ConsumptionTax, invoice.ConsumptionTaxRate,
-		invoice.InvoiceAmount, invoice.PaymentDueDate, invoice.Status, now, now)
+	result, err := r.db.Exec(query, account.BusinessPartnerID, account.BankName, account.BranchName,
+		account.AccountNumber, account.AccountName, now, now)
 	if err != nil {
-		return fmt.Errorf("failed to create invoice: %w", err)
+		return fmt.Errorf("failed to create bank account: %w", duplicateKeyError(err))
 	}
 
 	id, err := result.LastInsertId()
@@ -272,123 +743,1296 @@ func (r *MySQLRepository) CreateInvoice(invoice *models.Invoice) error {
 		return fmt.Errorf("failed to get last insert id: %w", err)
 	}
 
-	invoice.ID = uint(id)
-	invoice.CreatedAt = now
-	invoice.UpdatedAt = now
+	account.ID = uint(id)
+	account.CreatedAt = models.NewTimestamp(now)
+	account.UpdatedAt = models.NewTimestamp(now)
 	return nil
 }
 
-// GetInvoiceByID gets an invoice by ID
-func (r *MySQLRepository) GetInvoiceByID(id uint) (*models.Invoice, error) {
+// GetBankAccountByID gets a business partner bank account by ID
+func (r *MySQLRepository) GetBankAccountByID(id uint) (*models.BusinessPartnerBankAccount, error) {
 	query := `
-		SELECT i.id, i.company_id, i.business_partner_id, i.issue_date, i.payment_amount, i.fee, i.fee_rate,
-		       i.consumption_tax, i.consumption_tax_rate, i.invoice_amount, i.payment_due_date, i.status, i.created_at, i.updated_at,
-		       c.id, c.corporate_name, c.representative, c.phone_number, c.postal_code, c.address, c.created_at, c.updated_at,
-		       bp.id, bp.company_id, bp.corporate_name, bp.representative, bp.phone_number, bp.postal_code, bp.address, bp.created_at, bp.updated_at
-		FROM invoices i
-		JOIN companies c ON i.company_id = c.id
-		JOIN business_partners bp ON i.business_partner_id = bp.id
-		WHERE i.id = ?
+		SELECT id, business_partner_id, bank_name, branch_name, account_number, account_name, is_primary, created_at, updated_at
+		FROM business_partner_bank_accounts
+		WHERE id = ?
 	`
 	row := r.db.QueryRow(query, id)
 
-	invoice := &models.Invoice{Company: &models.Company{}, BusinessPartner: &models.BusinessPartner{}}
-	err := row.Scan(
-		&invoice.ID, &invoice.CompanyID, &invoice.BusinessPartnerID, &invoice.IssueDate, &invoice.PaymentAmount,
-		&invoice.Fee, &invoice.FeeRate, &invoice.ConsumptionTax, &invoice.ConsumptionTaxRate, &invoice.InvoiceAmount,
-		&invoice.PaymentDueDate, &invoice.Status, &invoice.CreatedAt, &invoice.UpdatedAt,
-		&invoice.Company.ID, &invoice.Company.CorporateName, &invoice.Company.Representative, &invoice.Company.PhoneNumber,
-		&invoice.Company.PostalCode, &invoice.Company.Address, &invoice.Company.CreatedAt, &invoice.Company.UpdatedAt,
-		&invoice.BusinessPartner.ID, &invoice.BusinessPartner.CompanyID, &invoice.BusinessPartner.CorporateName,
-		&invoice.BusinessPartner.Representative, &invoice.BusinessPartner.PhoneNumber, &invoice.BusinessPartner.PostalCode,
-		&invoice.BusinessPartner.Address, &invoice.BusinessPartner.CreatedAt, &invoice.BusinessPartner.UpdatedAt,
-	)
+	account := &models.BusinessPartnerBankAccount{}
+	err := row.Scan(&account.ID, &account.BusinessPartnerID, &account.BankName, &account.BranchName,
+		&account.AccountNumber, &account.AccountName, &account.IsPrimary, &account.CreatedAt, &account.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("invoice not found")
+			return nil, fmt.Errorf("bank account not found")
 		}
-		return nil, fmt.Errorf("failed to get invoice: %w", err)
+		return nil, fmt.Errorf("failed to get bank account: %w", err)
 	}
 
-	return invoice, nil
+	return account, nil
 }
 
-// GetInvoicesByCompanyID gets invoices by company ID with optional filters
-func (r *MySQLRepository) GetInvoicesByCompanyID(companyID uint, req *models.GetInvoicesRequest) ([]*models.Invoice, error) {
+// UpdateBankAccount updates the bank, branch, number and name of a bank account. Like
+// CreateBankAccount it fails with ErrDuplicateKey when that would duplicate another account.
+func (r *MySQLRepository) UpdateBankAccount(account *models.BusinessPartnerBankAccount) error {
 	query := `
-		SELECT i.id, i.company_id, i.business_partner_id, i.issue_date, i.payment_amount, i.fee, i.fee_rate,
-		       i.consumption_tax, i.consumption_tax_rate, i.invoice_amount, i.payment_due_date, i.status, i.created_at, i.updated_at,
-		       c.id, c.corporate_name, c.representative, c.phone_number, c.postal_code, c.address, c.created_at, c.updated_at,
-		       bp.id, bp.company_id, bp.corporate_name, bp.representative, bp.phone_number, bp.postal_code, bp.address, bp.created_at, bp.updated_at
-		FROM invoices i
-		JOIN companies c ON i.company_id = c.id
-		JOIN business_partners bp ON i.business_partner_id = bp.id
-		WHERE i.company_id = ?
+		UPDATE business_partner_bank_accounts
+		SET bank_name = ?, branch_name = ?, account_number = ?, account_name = ?, updated_at = ?
+		WHERE id = ?
 	`
+	now := time.Now()
+	if _, err := r.db.Exec(query, account.BankName, account.BranchName, account.AccountNumber,
+		account.AccountName, now, account.ID); err != nil {
+		return fmt.Errorf("failed to update bank account: %w", duplicateKeyError(err))
+	}
 
-	args := []interface{}{companyID}
+	account.UpdatedAt = models.NewTimestamp(now)
+	return nil
+}
 
-	if req.StartDate != nil {
-		query += " AND i.payment_due_date >= ?"
-		args = append(args, *req.StartDate)
-	}
+// GetPrimaryBankAccount gets the primary bank account of a business partner, or nil when none is marked
+func (r *MySQLRepository) GetPrimaryBankAccount(partnerID uint) (*models.BusinessPartnerBankAccount, error) {
+	query := `
+		SELECT id, business_partner_id, bank_name, branch_name, account_number, account_name, is_primary, created_at, updated_at
+		FROM business_partner_bank_accounts
+		WHERE business_partner_id = ? AND is_primary = TRUE
+		ORDER BY id
+		LIMIT 1
+	`
+	row := r.db.QueryRow(query, partnerID)
 
-	if req.EndDate != nil {
-		query += " AND i.payment_due_date <= ?"
-		args = append(args, *req.EndDate)
+	account := &models.BusinessPartnerBankAccount{}
+	err := row.Scan(&account.ID, &account.BusinessPartnerID, &account.BankName, &account.BranchName,
+		&account.AccountNumber, &account.AccountName, &account.IsPrimary, &account.CreatedAt, &account.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get primary bank account: %w", err)
 	}
 
-	if req.Status != nil {
-		query += " AND i.status = ?"
-		args = append(args, *req.Status)
-	}
+	return account, nil
+}
 
-	query += " ORDER BY i.payment_due_date DESC"
+// SetPrimaryBankAccount marks a bank account as its business partner's primary account,
+// unmarking the partner's other accounts
+func (r *MySQLRepository) SetPrimaryBankAccount(partnerID, accountID uint) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
 
-	if req.Limit > 0 {
-		query += " LIMIT ?"
-		args = append(args, req.Limit)
+	now := time.Now()
+	if _, err := tx.Exec(`UPDATE business_partner_bank_accounts SET is_primary = FALSE, updated_at = ? WHERE business_partner_id = ? AND is_primary = TRUE AND id <> ?`,
+		now, partnerID, accountID); err != nil {
+		return fmt.Errorf("failed to unset primary bank account: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE business_partner_bank_accounts SET is_primary = TRUE, updated_at = ? WHERE id = ? AND business_partner_id = ?`,
+		now, accountID, partnerID); err != nil {
+		return fmt.Errorf("failed to set primary bank account: %w", err)
+	}
 
-		if req.Page > 1 {
-			query += " OFFSET ?"
-			args = append(args, (req.Page-1)*req.Limit)
-		}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	return nil
+}
 
-	rows, err := r.db.Query(query, args...)
+// GetBusinessPartnerStats aggregates a business partner's invoices within a company
+func (r *MySQLRepository) GetBusinessPartnerStats(companyID, partnerID uint) (*models.BusinessPartnerStats, error) {
+	query := `
+		SELECT COUNT(i.id),
+		       COALESCE(SUM(i.invoice_amount), 0),
+		       COALESCE(SUM(i.paid_amount), 0),
+		       MAX(i.issue_date)
+		FROM business_partners bp
+		LEFT JOIN invoices i ON i.business_partner_id = bp.id AND i.company_id = bp.company_id AND i.deleted_at IS NULL
+		WHERE bp.id = ? AND bp.company_id = ?
+	`
+
+	stats := &models.BusinessPartnerStats{BusinessPartnerID: partnerID}
+	var lastIssueDate sql.NullTime
+	err := r.db.QueryRow(query, partnerID, companyID).Scan(
+		&stats.InvoiceCount, &stats.TotalInvoiced, &stats.TotalPaid, &lastIssueDate)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get invoices: %w", err)
+		return nil, fmt.Errorf("failed to get business partner stats: %w", err)
 	}
-	defer rows.Close()
 
-	var invoices []*models.Invoice
-	for rows.Next() {
-		invoice := &models.Invoice{Company: &models.Company{}, BusinessPartner: &models.BusinessPartner{}}
-		err := rows.Scan(
-			&invoice.ID, &invoice.CompanyID, &invoice.BusinessPartnerID, &invoice.IssueDate, &invoice.PaymentAmount,
-			&invoice.Fee, &invoice.FeeRate, &invoice.ConsumptionTax, &invoice.ConsumptionTaxRate, &invoice.InvoiceAmount,
-			&invoice.PaymentDueDate, &invoice.Status, &invoice.CreatedAt, &invoice.UpdatedAt,
-			&invoice.Company.ID, &invoice.Company.CorporateName, &invoice.Company.Representative, &invoice.Company.PhoneNumber,
-			&invoice.Company.PostalCode, &invoice.Company.Address, &invoice.Company.CreatedAt, &invoice.Company.UpdatedAt,
-			&invoice.BusinessPartner.ID, &invoice.BusinessPartner.CompanyID, &invoice.BusinessPartner.CorporateName,
-			&invoice.BusinessPartner.Representative, &invoice.BusinessPartner.PhoneNumber, &invoice.BusinessPartner.PostalCode,
-			&invoice.BusinessPartner.Address, &invoice.BusinessPartner.CreatedAt, &invoice.BusinessPartner.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan invoice: %w", err)
-		}
-		invoices = append(invoices, invoice)
+	if lastIssueDate.Valid {
+		stats.LastIssueDate = &lastIssueDate.Time
 	}
 
-	return invoices, nil
+	return stats, nil
 }
 
-// UpdateInvoiceStatus updates the status of an invoice
-func (r *MySQLRepository) UpdateInvoiceStatus(id uint, status models.InvoiceStatus) error {
-	query := `UPDATE invoices SET status = ?, updated_at = ? WHERE id = ?`
-	_, err := r.db.Exec(query, status, time.Now(), id)
+// CreateInvoice creates a new invoice along with its line items in a single transaction, numbered
+// from its company's invoice sequence
+func (r *MySQLRepository) CreateInvoice(invoice *models.Invoice) error {
+	ctx := context.Background()
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	unlock, err := lockInvoiceSequence(ctx, conn, invoice.CompanyID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	number, err := nextInvoiceNumber(tx, invoice.CompanyID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO invoices (company_id, business_partner_id, issue_date, payment_amount, fee, fee_rate, 
+		                     consumption_tax, consumption_tax_rate, invoice_amount, paid_amount, payment_due_date, invoice_number, status, memo, currency, created_by_user_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	now := time.Now()
+	result, err := tx.Exec(query, invoice.CompanyID, invoice.BusinessPartnerID, invoice.IssueDate,
+		invoice.PaymentAmount, invoice.Fee, invoice.FeeRate, invoice.ConsumptionTax, invoice.ConsumptionTaxRate,
+		invoice.InvoiceAmount, invoice.PaidAmount, invoice.PaymentDueDate, number, invoice.Status, invoice.Memo, invoice.Currency, invoice.CreatedByUserID, now, now)
+	if err != nil {
+		return fmt.Errorf("failed to create invoice: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	itemQuery := `
+		INSERT INTO invoice_items (invoice_id, description, quantity, unit_price, amount, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+	for i := range invoice.LineItems {
+		item := &invoice.LineItems[i]
+		itemResult, err := tx.Exec(itemQuery, id, item.Description, item.Quantity, item.UnitPrice, item.Amount, now, now)
+		if err != nil {
+			return fmt.Errorf("failed to create invoice item: %w", err)
+		}
+
+		itemID, err := itemResult.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get last insert id: %w", err)
+		}
+
+		item.ID = uint(itemID)
+		item.InvoiceID = uint(id)
+		item.CreatedAt = models.NewTimestamp(now)
+		item.UpdatedAt = models.NewTimestamp(now)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit invoice: %w", err)
+	}
+
+	invoice.ID = uint(id)
+	invoice.InvoiceNumber = number
+	invoice.CreatedAt = models.NewTimestamp(now)
+	invoice.UpdatedAt = models.NewTimestamp(now)
+	return nil
+}
+
+// GetInvoiceByID gets an invoice by ID
+func (r *MySQLRepository) GetInvoiceByID(id uint) (*models.Invoice, error) {
+	return r.GetInvoiceByIDContext(context.Background(), id)
+}
+
+// GetInvoiceByIDContext is GetInvoiceByID with its queries cancelled once ctx is done
+func (r *MySQLRepository) GetInvoiceByIDContext(ctx context.Context, id uint) (*models.Invoice, error) {
+	query := `
+		SELECT i.id, i.company_id, i.business_partner_id, i.issue_date, i.payment_amount, i.fee, i.fee_rate,
+		       i.consumption_tax, i.consumption_tax_rate, i.invoice_amount, i.paid_amount, i.payment_due_date, i.invoice_number, i.status, i.memo, i.currency, i.created_at, i.updated_at,
+		       i.created_by_user_id, u.full_name,
+		       c.id, c.corporate_name, c.representative, c.phone_number, c.postal_code, c.address, c.created_at, c.updated_at,
+		       bp.id, bp.company_id, bp.corporate_name, bp.representative, bp.phone_number, bp.postal_code, bp.address, bp.created_at, bp.updated_at
+		FROM invoices i
+		JOIN companies c ON i.company_id = c.id
+		JOIN business_partners bp ON i.business_partner_id = bp.id
+		LEFT JOIN users u ON i.created_by_user_id = u.id
+		WHERE i.id = ? AND i.deleted_at IS NULL
+	`
+	row := r.db.QueryRowContext(ctx, query, id)
+
+	invoice := &models.Invoice{Company: &models.Company{}, BusinessPartner: &models.BusinessPartner{}}
+	err := row.Scan(
+		&invoice.ID, &invoice.CompanyID, &invoice.BusinessPartnerID, &invoice.IssueDate, &invoice.PaymentAmount,
+		&invoice.Fee, &invoice.FeeRate, &invoice.ConsumptionTax, &invoice.ConsumptionTaxRate, &invoice.InvoiceAmount,
+		&invoice.PaidAmount, &invoice.PaymentDueDate, &invoice.InvoiceNumber, &invoice.Status, &invoice.Memo, &invoice.Currency, &invoice.CreatedAt, &invoice.UpdatedAt,
+		&invoice.CreatedByUserID, &invoice.CreatedByName,
+		&invoice.Company.ID, &invoice.Company.CorporateName, nullableString(&invoice.Company.Representative),
+		nullableString(&invoice.Company.PhoneNumber), nullableString(&invoice.Company.PostalCode), nullableString(&invoice.Company.Address),
+		&invoice.Company.CreatedAt, &invoice.Company.UpdatedAt,
+		&invoice.BusinessPartner.ID, &invoice.BusinessPartner.CompanyID, &invoice.BusinessPartner.CorporateName,
+		nullableString(&invoice.BusinessPartner.Representative), nullableString(&invoice.BusinessPartner.PhoneNumber),
+		nullableString(&invoice.BusinessPartner.PostalCode), nullableString(&invoice.BusinessPartner.Address),
+		&invoice.BusinessPartner.CreatedAt, &invoice.BusinessPartner.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("invoice not found")
+		}
+		return nil, fmt.Errorf("failed to get invoice: %w", err)
+	}
+
+	items, err := r.getInvoiceItems(ctx, invoice.ID)
+	if err != nil {
+		return nil, err
+	}
+	invoice.LineItems = items
+
+	return invoice, nil
+}
+
+// GetInvoiceByNumber gets a company's invoice by its invoice number. It returns nil when the
+// company has no such invoice.
+func (r *MySQLRepository) GetInvoiceByNumber(companyID uint, number string) (*models.Invoice, error) {
+	return r.GetInvoiceByNumberContext(context.Background(), companyID, number)
+}
+
+// GetInvoiceByNumberContext is GetInvoiceByNumber with its queries cancelled once ctx is done
+func (r *MySQLRepository) GetInvoiceByNumberContext(ctx context.Context, companyID uint, number string) (*models.Invoice, error) {
+	var id uint
+	err := r.db.QueryRowContext(ctx, `SELECT id FROM invoices WHERE company_id = ? AND invoice_number = ? AND deleted_at IS NULL`, companyID, number).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get invoice: %w", err)
+	}
+
+	return r.GetInvoiceByIDContext(ctx, id)
+}
+
+// invoiceSequenceLockTimeout is how many seconds a creation waits for its company's invoice
+// sequence lock before failing
+const invoiceSequenceLockTimeout = 10
+
+// lockInvoiceSequence takes the named lock serializing the invoice creations of a company on conn.
+// It is taken before the creation's transaction begins and released by the returned function after
+// it ends, so each creation starts from the sequence and invoices committed by the one before it.
+func lockInvoiceSequence(ctx context.Context, conn *sql.Conn, companyID uint) (func(), error) {
+	name := fmt.Sprintf("invoice_sequence_%d", companyID)
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, ?)`, name, invoiceSequenceLockTimeout).Scan(&acquired); err != nil {
+		return nil, fmt.Errorf("failed to lock invoice sequence: %w", err)
+	}
+	if acquired.Int64 != 1 {
+		return nil, fmt.Errorf("timed out waiting for the invoice sequence of company %d", companyID)
+	}
+
+	return func() { _, _ = conn.ExecContext(ctx, `SELECT RELEASE_LOCK(?)`, name) }, nil
+}
+
+// nextInvoiceNumber draws the next number from a company's invoice sequence. The sequence row is
+// read FOR UPDATE, so it stays locked until tx ends and a rolled back creation gives its number back.
+func nextInvoiceNumber(tx *sql.Tx, companyID uint) (string, error) {
+	if _, err := tx.Exec(`INSERT INTO invoice_sequences (company_id, seq) VALUES (?, 0) ON DUPLICATE KEY UPDATE seq = seq`, companyID); err != nil {
+		return "", fmt.Errorf("failed to start invoice sequence: %w", err)
+	}
+
+	var seq uint
+	if err := tx.QueryRow(`SELECT seq FROM invoice_sequences WHERE company_id = ? FOR UPDATE`, companyID).Scan(&seq); err != nil {
+		return "", fmt.Errorf("failed to read invoice sequence: %w", err)
+	}
+	seq++
+	if _, err := tx.Exec(`UPDATE invoice_sequences SET seq = ? WHERE company_id = ?`, seq, companyID); err != nil {
+		return "", fmt.Errorf("failed to advance invoice sequence: %w", err)
+	}
+	return invoiceNumber(seq), nil
+}
+
+// invoiceNumber formats the invoice number of the given position in a company's sequence
+func invoiceNumber(seq uint) string {
+	return fmt.Sprintf("INV-%06d", seq)
+}
+
+// getInvoiceItems gets the line items of an invoice
+func (r *MySQLRepository) getInvoiceItems(ctx context.Context, invoiceID uint) ([]models.InvoiceItem, error) {
+	query := `
+		SELECT id, invoice_id, description, quantity, unit_price, amount, created_at, updated_at
+		FROM invoice_items
+		WHERE invoice_id = ?
+		ORDER BY id
+	`
+	rows, err := r.db.QueryContext(ctx, query, invoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoice items: %w", err)
+	}
+	defer rows.Close()
+
+	var items []models.InvoiceItem
+	for rows.Next() {
+		var item models.InvoiceItem
+		err := rows.Scan(&item.ID, &item.InvoiceID, &item.Description, &item.Quantity, &item.UnitPrice,
+			&item.Amount, &item.CreatedAt, &item.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan invoice item: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get invoice items: %w", err)
+	}
+
+	return items, nil
+}
+
+// GetInvoicesByCompanyID gets invoices by company ID with optional filters
+func (r *MySQLRepository) GetInvoicesByCompanyID(companyID uint, req *models.GetInvoicesRequest) ([]*models.Invoice, error) {
+	return r.GetInvoicesByCompanyIDContext(context.Background(), companyID, req)
+}
+
+// GetInvoicesByCompanyIDContext is GetInvoicesByCompanyID with its query cancelled once ctx is done
+func (r *MySQLRepository) GetInvoicesByCompanyIDContext(ctx context.Context, companyID uint, req *models.GetInvoicesRequest) ([]*models.Invoice, error) {
+	query := `
+		SELECT i.id, i.company_id, i.business_partner_id, i.issue_date, i.payment_amount, i.fee, i.fee_rate,
+		       i.consumption_tax, i.consumption_tax_rate, i.invoice_amount, i.paid_amount, i.payment_due_date, i.invoice_number, i.status, i.memo, i.currency, i.created_at, i.updated_at,
+		       i.created_by_user_id, u.full_name,
+		       c.id, c.corporate_name, c.representative, c.phone_number, c.postal_code, c.address, c.created_at, c.updated_at,
+		       bp.id, bp.company_id, bp.corporate_name, bp.representative, bp.phone_number, bp.postal_code, bp.address, bp.created_at, bp.updated_at
+		FROM invoices i
+		JOIN companies c ON i.company_id = c.id
+		JOIN business_partners bp ON i.business_partner_id = bp.id
+		LEFT JOIN users u ON i.created_by_user_id = u.id
+		WHERE i.company_id = ? AND i.deleted_at IS NULL
+	`
+
+	filters, args := buildInvoiceFilters(companyID, req)
+	query += filters
+
+	if req.After != nil {
+		// Keyset pagination: stable under concurrent inserts, unlike OFFSET
+		if *req.After > 0 {
+			query += " AND i.id < ?"
+			args = append(args, *req.After)
+		}
+		query += " ORDER BY i.id DESC"
+	} else {
+		// Filtered by status, this reads idx_invoices_company_status_due in order, without a sort
+		query += " ORDER BY i.payment_due_date DESC"
+	}
+
+	if req.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, req.Limit)
+
+		if req.After == nil && req.Page > 1 {
+			query += " OFFSET ?"
+			args = append(args, (req.Page-1)*req.Limit)
+		}
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoices: %w", err)
+	}
+	defer rows.Close()
+
+	// Every row carries the same company, so all invoices share one Company, scanned through the
+	// same scanners on each row
+	company := &models.Company{}
+	representative, phoneNumber := nullableString(&company.Representative), nullableString(&company.PhoneNumber)
+	postalCode, address := nullableString(&company.PostalCode), nullableString(&company.Address)
+
+	var invoices []*models.Invoice
+	for rows.Next() {
+		if invoices == nil {
+			// Sized for a full page, and only once there is a row so that no invoices still lists as nil
+			invoices = make([]*models.Invoice, 0, req.Limit)
+		}
+
+		invoice := &models.Invoice{Company: company, BusinessPartner: &models.BusinessPartner{}}
+		err := rows.Scan(
+			&invoice.ID, &invoice.CompanyID, &invoice.BusinessPartnerID, &invoice.IssueDate, &invoice.PaymentAmount,
+			&invoice.Fee, &invoice.FeeRate, &invoice.ConsumptionTax, &invoice.ConsumptionTaxRate, &invoice.InvoiceAmount,
+			&invoice.PaidAmount, &invoice.PaymentDueDate, &invoice.InvoiceNumber, &invoice.Status, &invoice.Memo, &invoice.Currency, &invoice.CreatedAt, &invoice.UpdatedAt,
+			&invoice.CreatedByUserID, &invoice.CreatedByName,
+			&company.ID, &company.CorporateName, representative, phoneNumber, postalCode, address,
+			&company.CreatedAt, &company.UpdatedAt,
+			&invoice.BusinessPartner.ID, &invoice.BusinessPartner.CompanyID, &invoice.BusinessPartner.CorporateName,
+			nullableString(&invoice.BusinessPartner.Representative), nullableString(&invoice.BusinessPartner.PhoneNumber),
+			nullableString(&invoice.BusinessPartner.PostalCode), nullableString(&invoice.BusinessPartner.Address),
+			&invoice.BusinessPartner.CreatedAt, &invoice.BusinessPartner.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan invoice: %w", err)
+		}
+		invoices = append(invoices, invoice)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get invoices: %w", err)
+	}
+
+	return invoices, nil
+}
+
+// CountInvoicesByCompanyID counts invoices by company ID with the same optional filters as the listing
+func (r *MySQLRepository) CountInvoicesByCompanyID(companyID uint, req *models.GetInvoicesRequest) (int, error) {
+	return r.CountInvoicesByCompanyIDContext(context.Background(), companyID, req)
+}
+
+// CountInvoicesByCompanyIDContext is CountInvoicesByCompanyID with its query cancelled once ctx is done
+func (r *MySQLRepository) CountInvoicesByCompanyIDContext(ctx context.Context, companyID uint, req *models.GetInvoicesRequest) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM invoices i
+		JOIN business_partners bp ON i.business_partner_id = bp.id
+		WHERE i.company_id = ? AND i.deleted_at IS NULL
+	`
+
+	filters, args := buildInvoiceFilters(companyID, req)
+	query += filters
+
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count invoices: %w", err)
+	}
+
+	return count, nil
+}
+
+// CountInvoicesByStatus counts a company's invoices per status. Statuses without invoices are omitted.
+func (r *MySQLRepository) CountInvoicesByStatus(companyID uint) ([]*models.InvoiceStatusCount, error) {
+	query := `
+		SELECT status, COUNT(*)
+		FROM invoices
+		WHERE company_id = ? AND deleted_at IS NULL
+		GROUP BY status
+	`
+	rows, err := r.db.Query(query, companyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count invoices by status: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []*models.InvoiceStatusCount
+	for rows.Next() {
+		count := &models.InvoiceStatusCount{}
+		if err := rows.Scan(&count.Status, &count.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan invoice status count: %w", err)
+		}
+		counts = append(counts, count)
+	}
+
+	return counts, nil
+}
+
+// GetInvoiceStatusSummaries gets the count, invoice amount, fee and consumption tax totals of a
+// company's invoices per status and currency. Statuses without invoices are omitted.
+func (r *MySQLRepository) GetInvoiceStatusSummaries(companyID uint) ([]*models.StatusSummary, error) {
+	query := `
+		SELECT status, currency, COUNT(*), COALESCE(SUM(invoice_amount), 0), COALESCE(SUM(fee), 0), COALESCE(SUM(consumption_tax), 0)
+		FROM invoices
+		WHERE company_id = ? AND deleted_at IS NULL
+		GROUP BY status, currency
+		ORDER BY status, currency
+	`
+	rows, err := r.db.Query(query, companyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoice status summaries: %w", err)
+	}
+	defer rows.Close()
+
+	var summaries []*models.StatusSummary
+	for rows.Next() {
+		summary := &models.StatusSummary{}
+		err := rows.Scan(&summary.Status, &summary.Currency, &summary.Count, &summary.InvoiceAmount, &summary.TotalFee, &summary.TotalTax)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan invoice status summary: %w", err)
+		}
+		summaries = append(summaries, summary)
+	}
+
+	return summaries, nil
+}
+
+// FindDuplicateInvoice finds an invoice in the given invoice's status issued on the same day for
+// the same business partner, payment amount and due date. It returns nil when there is no match.
+func (r *MySQLRepository) FindDuplicateInvoice(invoice *models.Invoice) (*models.Invoice, error) {
+	query := `
+		SELECT id
+		FROM invoices
+		WHERE company_id = ? AND business_partner_id = ? AND payment_amount = ? AND currency = ?
+		  AND payment_due_date = ? AND issue_date = ? AND status = ? AND deleted_at IS NULL
+		ORDER BY id DESC
+		LIMIT 1
+	`
+
+	var id uint
+	err := r.db.QueryRow(query, invoice.CompanyID, invoice.BusinessPartnerID, invoice.PaymentAmount, invoice.Currency,
+		invoice.PaymentDueDate, invoice.IssueDate, invoice.Status).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find duplicate invoice: %w", err)
+	}
+
+	return r.GetInvoiceByID(id)
+}
+
+// buildInvoiceFilters builds the optional WHERE conditions shared by invoice listing and counting.
+// The returned args start with the company ID.
+func buildInvoiceFilters(companyID uint, req *models.GetInvoicesRequest) (string, []interface{}) {
+	var query string
+	args := []interface{}{companyID}
+
+	if req.StartDate != nil {
+		query += " AND i.payment_due_date >= ?"
+		args = append(args, *req.StartDate)
+	}
+
+	if req.EndDate != nil {
+		query += " AND i.payment_due_date <= ?"
+		args = append(args, *req.EndDate)
+	}
+
+	if req.IssuedFrom != nil {
+		query += " AND i.issue_date >= ?"
+		args = append(args, *req.IssuedFrom)
+	}
+
+	if req.IssuedBefore != nil {
+		query += " AND i.issue_date < ?"
+		args = append(args, *req.IssuedBefore)
+	}
+
+	if req.Status != nil {
+		query += " AND i.status = ?"
+		args = append(args, *req.Status)
+	}
+
+	if req.MinAmount != nil {
+		query += " AND i.invoice_amount >= ?"
+		args = append(args, *req.MinAmount)
+	}
+
+	if req.MaxAmount != nil {
+		query += " AND i.invoice_amount <= ?"
+		args = append(args, *req.MaxAmount)
+	}
+
+	if req.BusinessPartnerID != nil {
+		query += " AND i.business_partner_id = ?"
+		args = append(args, *req.BusinessPartnerID)
+	}
+
+	if req.Search != nil {
+		query += " AND i.memo LIKE ?"
+		args = append(args, "%"+escapeLike(*req.Search)+"%")
+	}
+
+	if req.PartnerName != nil {
+		query += " AND bp.corporate_name LIKE ?"
+		args = append(args, "%"+escapeLike(*req.PartnerName)+"%")
+	}
+
+	if req.CreatedByUserID != nil {
+		query += " AND i.created_by_user_id = ?"
+		args = append(args, *req.CreatedByUserID)
+	}
+
+	return query, args
+}
+
+// likeEscaper escapes the LIKE wildcards and the escape character itself
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// escapeLike escapes s so it matches literally inside a LIKE pattern
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// UpdateInvoiceStatus changes the status of an invoice from the given status, recording the
+// transition in the status history. The paid amount is recomputed from the recorded payments, so
+// marking an invoice paid by hand records no money and reopening it keeps its payments.
+// It fails with ErrInvoiceChanged if the invoice no longer has the from status.
+func (r *MySQLRepository) UpdateInvoiceStatus(id uint, from, to models.InvoiceStatus, userID uint) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var paidAmount float64
+	if err := tx.QueryRow(`SELECT COALESCE(SUM(amount), 0) FROM invoice_payments WHERE invoice_id = ?`, id).Scan(&paidAmount); err != nil {
+		return fmt.Errorf("failed to sum invoice payments: %w", err)
+	}
+
+	now := time.Now()
+	result, err := tx.Exec(`UPDATE invoices SET status = ?, paid_amount = ?, updated_at = ? WHERE id = ? AND status = ?`, to, paidAmount, now, id, from)
+	if err != nil {
+		return fmt.Errorf("failed to update invoice status: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrInvoiceChanged
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO invoice_status_histories (invoice_id, from_status, to_status, action, user_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		id, from, to, models.InvoiceActionStatusUpdated, userID, now); err != nil {
+		return fmt.Errorf("failed to create invoice status history: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit invoice status update: %w", err)
+	}
+
+	return nil
+}
+
+// RecordInvoicePayment records a payment on an invoice, adding it to the invoice's paid amount and
+// moving the invoice from the given status to the given status, in a single transaction. It fails
+// with ErrInvoiceChanged if the invoice's status or paid amount changed since they were read.
+func (r *MySQLRepository) RecordInvoicePayment(payment *models.InvoicePayment, from, to models.InvoiceStatus, paidBefore float64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now()
+	result, err := tx.Exec(`
+		UPDATE invoices SET paid_amount = paid_amount + ?, status = ?, updated_at = ?
+		WHERE id = ? AND status = ? AND paid_amount = ?`,
+		payment.Amount, to, now, payment.InvoiceID, from, paidBefore)
+	if err != nil {
+		return fmt.Errorf("failed to update invoice paid amount: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrInvoiceChanged
+	}
+
+	result, err = tx.Exec(`INSERT INTO invoice_payments (invoice_id, amount, user_id, created_at) VALUES (?, ?, ?, ?)`,
+		payment.InvoiceID, payment.Amount, payment.UserID, now)
+	if err != nil {
+		return fmt.Errorf("failed to create invoice payment: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO invoice_status_histories (invoice_id, from_status, to_status, action, user_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		payment.InvoiceID, from, to, models.InvoiceActionPaymentRecorded, payment.UserID, now); err != nil {
+		return fmt.Errorf("failed to create invoice status history: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit invoice payment: %w", err)
+	}
+
+	payment.ID = uint(id)
+	payment.CreatedAt = models.NewTimestamp(now)
+	return nil
+}
+
+// DeleteInvoices soft-deletes a company's invoices in a single transaction, provided every one of
+// them exists, is in a deletable status and, when closedBefore is set, was issued no earlier than
+// closedBefore. It returns the outcome for each ID in order; when any invoice cannot be deleted,
+// none is.
+func (r *MySQLRepository) DeleteInvoices(companyID uint, ids []uint, closedBefore *time.Time) ([]models.BulkDeleteInvoiceResult, error) {
+	tx, err := r.db.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to update invoice status: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	args := make([]interface{}, 0, len(ids)+1)
+	args = append(args, companyID)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	rows, err := tx.Query(`SELECT id, status, issue_date FROM invoices WHERE company_id = ? AND deleted_at IS NULL AND id IN (`+placeholders+`) FOR UPDATE`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoices: %w", err)
+	}
+	statuses := make(map[uint]models.InvoiceStatus, len(ids))
+	issueDates := make(map[uint]time.Time, len(ids))
+	for rows.Next() {
+		var id uint
+		var status models.InvoiceStatus
+		var issueDate time.Time
+		if err := rows.Scan(&id, &status, &issueDate); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan invoice: %w", err)
+		}
+		statuses[id] = status
+		issueDates[id] = issueDate
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get invoices: %w", err)
+	}
+
+	results := make([]models.BulkDeleteInvoiceResult, len(ids))
+	deletable := true
+	for i, id := range ids {
+		results[i].ID = id
+		status, ok := statuses[id]
+		switch {
+		case !ok:
+			results[i].Error = "invoice not found"
+			deletable = false
+		case !status.Deletable():
+			results[i].Error = fmt.Sprintf("%s invoices cannot be deleted", status)
+			deletable = false
+		case closedBefore != nil && issueDates[id].Before(*closedBefore):
+			results[i].Error = "invoices issued in a closed accounting period cannot be deleted"
+			deletable = false
+		}
+	}
+	if !deletable {
+		return results, nil
+	}
+
+	if _, err := tx.Exec(`UPDATE invoices SET deleted_at = ? WHERE id IN (`+placeholders+`)`, append([]interface{}{time.Now()}, args[1:]...)...); err != nil {
+		return nil, fmt.Errorf("failed to delete invoices: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit invoice deletion: %w", err)
+	}
+
+	for i := range results {
+		results[i].Deleted = true
+	}
+	return results, nil
+}
+
+// PurgeDeletedInvoices permanently removes the invoices soft-deleted before deletedBefore, together
+// with their items, payments, attachments and status history, and returns how many were removed.
+// When closedBefore is set, invoices issued before it are kept.
+func (r *MySQLRepository) PurgeDeletedInvoices(deletedBefore time.Time, closedBefore *time.Time) (int64, error) {
+	query := `DELETE FROM invoices WHERE deleted_at IS NOT NULL AND deleted_at < ?`
+	args := []interface{}{deletedBefore}
+	if closedBefore != nil {
+		query += " AND issue_date >= ?"
+		args = append(args, *closedBefore)
+	}
+
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted invoices: %w", err)
+	}
+
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return purged, nil
+}
+
+// MarkOverdueInvoices transitions unprocessed invoices due before the given date to error status,
+// recording an audit entry for each, in a single transaction. When closedBefore is set, invoices
+// issued before it are left alone. It returns the invoices updated, with their company, invoice
+// number and new status.
+func (r *MySQLRepository) MarkOverdueInvoices(dueBefore time.Time, closedBefore *time.Time) ([]*models.Invoice, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	query := `SELECT id, company_id, invoice_number FROM invoices WHERE status = ? AND payment_due_date < ? AND deleted_at IS NULL`
+	args := []interface{}{models.InvoiceStatusUnprocessed, dueBefore}
+	if closedBefore != nil {
+		query += " AND issue_date >= ?"
+		args = append(args, *closedBefore)
+	}
+
+	rows, err := tx.Query(query+" FOR UPDATE", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get overdue invoices: %w", err)
+	}
+
+	var invoices []*models.Invoice
+	for rows.Next() {
+		invoice := &models.Invoice{Status: models.InvoiceStatusError}
+		if err := rows.Scan(&invoice.ID, &invoice.CompanyID, &invoice.InvoiceNumber); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan overdue invoice: %w", err)
+		}
+		invoices = append(invoices, invoice)
+	}
+	rows.Close()
+
+	now := time.Now()
+	for _, invoice := range invoices {
+		if _, err := tx.Exec(`UPDATE invoices SET status = ?, updated_at = ? WHERE id = ?`,
+			models.InvoiceStatusError, now, invoice.ID); err != nil {
+			return nil, fmt.Errorf("failed to update invoice status: %w", err)
+		}
+
+		if _, err := tx.Exec(`
+			INSERT INTO invoice_status_histories (invoice_id, from_status, to_status, action, created_at)
+			VALUES (?, ?, ?, ?, ?)`,
+			invoice.ID, models.InvoiceStatusUnprocessed, models.InvoiceStatusError, models.InvoiceActionMarkedOverdue, now); err != nil {
+			return nil, fmt.Errorf("failed to create invoice status history: %w", err)
+		}
+		invoice.UpdatedAt = models.NewTimestamp(now)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit overdue invoices: %w", err)
+	}
+
+	return invoices, nil
+}
+
+// IssueInvoice transitions a draft invoice to status, the initial status of its company's invoices,
+// setting its issue date and recording the transition in the status history. It fails with
+// ErrInvoiceChanged if the invoice is no longer a draft.
+func (r *MySQLRepository) IssueInvoice(id uint, status models.InvoiceStatus, issueDate time.Time, userID uint) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now()
+	result, err := tx.Exec(`UPDATE invoices SET status = ?, issue_date = ?, updated_at = ? WHERE id = ? AND status = ?`,
+		status, issueDate, now, id, models.InvoiceStatusDraft)
+	if err != nil {
+		return fmt.Errorf("failed to issue invoice: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrInvoiceChanged
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO invoice_status_histories (invoice_id, from_status, to_status, action, user_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		id, models.InvoiceStatusDraft, status, models.InvoiceActionIssued, userID, now); err != nil {
+		return fmt.Errorf("failed to create invoice status history: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit invoice issue: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateInvoiceAmounts stores recalculated rates and amounts of an invoice. It fails with
+// ErrInvoiceChanged if the invoice's status changed since it was read.
+func (r *MySQLRepository) UpdateInvoiceAmounts(invoice *models.Invoice) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var status models.InvoiceStatus
+	if err := tx.QueryRow(`SELECT status FROM invoices WHERE id = ? FOR UPDATE`, invoice.ID).Scan(&status); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("invoice not found")
+		}
+		return fmt.Errorf("failed to get invoice status: %w", err)
+	}
+	if status != invoice.Status {
+		return ErrInvoiceChanged
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(`
+		UPDATE invoices
+		SET fee = ?, fee_rate = ?, consumption_tax = ?, consumption_tax_rate = ?, invoice_amount = ?, updated_at = ?
+		WHERE id = ?`,
+		invoice.Fee, invoice.FeeRate, invoice.ConsumptionTax, invoice.ConsumptionTaxRate, invoice.InvoiceAmount, now, invoice.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update invoice amounts: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit invoice amounts: %w", err)
+	}
+
+	invoice.UpdatedAt = models.NewTimestamp(now)
+	return nil
+}
+
+// GetMonthlyInvoiceTotals gets the invoice count and summed invoice amount per issue month of a year.
+// Months without invoices are omitted and drafts are not counted.
+func (r *MySQLRepository) GetMonthlyInvoiceTotals(companyID uint, year int) ([]*models.MonthlyInvoiceTotal, error) {
+	query := `
+		SELECT MONTH(issue_date), COUNT(*), COALESCE(SUM(invoice_amount), 0)
+		FROM invoices
+		WHERE company_id = ? AND YEAR(issue_date) = ? AND status <> ? AND deleted_at IS NULL
+		GROUP BY YEAR(issue_date), MONTH(issue_date)
+		ORDER BY MONTH(issue_date)
+	`
+	rows, err := r.db.Query(query, companyID, year, models.InvoiceStatusDraft)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get monthly invoice totals: %w", err)
+	}
+	defer rows.Close()
+
+	var totals []*models.MonthlyInvoiceTotal
+	for rows.Next() {
+		total := &models.MonthlyInvoiceTotal{}
+		if err := rows.Scan(&total.Month, &total.InvoiceCount, &total.TotalAmount); err != nil {
+			return nil, fmt.Errorf("failed to scan monthly invoice total: %w", err)
+		}
+		totals = append(totals, total)
+	}
+
+	return totals, nil
+}
+
+// GetFeeRevenueTotals gets the fees and consumption tax of the paid invoices of all companies
+// issued on or after start and before end, per month of issue and currency. Months without paid
+// invoices are omitted.
+func (r *MySQLRepository) GetFeeRevenueTotals(start, end time.Time) ([]*models.FeeRevenueTotal, error) {
+	query := `
+		SELECT YEAR(issue_date), MONTH(issue_date), currency, COUNT(*), COALESCE(SUM(fee), 0), COALESCE(SUM(consumption_tax), 0)
+		FROM invoices
+		WHERE status = ? AND issue_date >= ? AND issue_date < ? AND deleted_at IS NULL
+		GROUP BY YEAR(issue_date), MONTH(issue_date), currency
+		ORDER BY YEAR(issue_date), MONTH(issue_date), currency
+	`
+	rows, err := r.db.Query(query, models.InvoiceStatusPaid, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fee revenue totals: %w", err)
+	}
+	defer rows.Close()
+
+	var totals []*models.FeeRevenueTotal
+	for rows.Next() {
+		total := &models.FeeRevenueTotal{}
+		err := rows.Scan(&total.Year, &total.Month, &total.Currency, &total.InvoiceCount, &total.Fee, &total.ConsumptionTax)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan fee revenue total: %w", err)
+		}
+		totals = append(totals, total)
+	}
+
+	return totals, nil
+}
+
+// GetInvoiceAgingTotals gets the count and outstanding amount of a company's unpaid invoices per
+// aging bucket and currency, by days past the payment due date as of today. Buckets without
+// invoices are omitted.
+func (r *MySQLRepository) GetInvoiceAgingTotals(companyID uint, today time.Time) ([]*models.AgingBucketTotal, error) {
+	query := `
+		SELECT bucket, currency, COUNT(*), COALESCE(SUM(outstanding), 0)
+		FROM (
+			SELECT currency, invoice_amount - paid_amount AS outstanding,
+			       CASE
+			           WHEN DATEDIFF(?, payment_due_date) <= 0 THEN ?
+			           WHEN DATEDIFF(?, payment_due_date) <= 30 THEN ?
+			           WHEN DATEDIFF(?, payment_due_date) <= 60 THEN ?
+			           WHEN DATEDIFF(?, payment_due_date) <= 90 THEN ?
+			           ELSE ?
+			       END AS bucket
+			FROM invoices
+			WHERE company_id = ? AND status NOT IN (?, ?) AND deleted_at IS NULL
+		) aged
+		GROUP BY bucket, currency
+	`
+	date := today.Format("2006-01-02")
+	rows, err := r.db.Query(query,
+		date, models.AgingBucketCurrent,
+		date, models.AgingBucket1To30,
+		date, models.AgingBucket31To60,
+		date, models.AgingBucket61To90,
+		models.AgingBucketOver90,
+		companyID, models.InvoiceStatusDraft, models.InvoiceStatusPaid,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoice aging totals: %w", err)
+	}
+	defer rows.Close()
+
+	var totals []*models.AgingBucketTotal
+	for rows.Next() {
+		total := &models.AgingBucketTotal{}
+		if err := rows.Scan(&total.Bucket, &total.Currency, &total.InvoiceCount, &total.TotalAmount); err != nil {
+			return nil, fmt.Errorf("failed to scan invoice aging total: %w", err)
+		}
+		totals = append(totals, total)
+	}
+
+	return totals, nil
+}
+
+// GetInvoiceStatusHistory gets the status transition history of an invoice, oldest first
+func (r *MySQLRepository) GetInvoiceStatusHistory(invoiceID uint) ([]*models.InvoiceStatusHistory, error) {
+	return r.GetInvoiceStatusHistoryPage(invoiceID, &models.GetInvoiceHistoryRequest{})
+}
+
+// GetInvoiceStatusHistoryPage gets one page of the status transition history of an invoice, oldest
+// first, optionally limited to one action
+func (r *MySQLRepository) GetInvoiceStatusHistoryPage(invoiceID uint, req *models.GetInvoiceHistoryRequest) ([]*models.InvoiceStatusHistory, error) {
+	query := `
+		SELECT id, invoice_id, from_status, to_status, action, user_id, created_at
+		FROM invoice_status_histories
+		WHERE invoice_id = ?
+	`
+	args := []interface{}{invoiceID}
+	if req.Action != nil {
+		query += " AND action = ?"
+		args = append(args, *req.Action)
+	}
+	query += " ORDER BY id"
+
+	if req.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, req.Limit)
+		if req.Page > 1 {
+			query += " OFFSET ?"
+			args = append(args, (req.Page-1)*req.Limit)
+		}
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoice status history: %w", err)
+	}
+	defer rows.Close()
+
+	var histories []*models.InvoiceStatusHistory
+	for rows.Next() {
+		history := &models.InvoiceStatusHistory{}
+		var userID sql.NullInt64
+		err := rows.Scan(&history.ID, &history.InvoiceID, &history.FromStatus, &history.ToStatus,
+			&history.Action, &userID, &history.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan invoice status history: %w", err)
+		}
+		if userID.Valid {
+			id := uint(userID.Int64)
+			history.UserID = &id
+		}
+		histories = append(histories, history)
+	}
+
+	return histories, nil
+}
+
+// CountInvoiceStatusHistory counts the status history entries of an invoice, optionally limited to
+// one action
+func (r *MySQLRepository) CountInvoiceStatusHistory(invoiceID uint, action *string) (int, error) {
+	query := `SELECT COUNT(*) FROM invoice_status_histories WHERE invoice_id = ?`
+	args := []interface{}{invoiceID}
+	if action != nil {
+		query += " AND action = ?"
+		args = append(args, *action)
+	}
+
+	var count int
+	if err := r.db.QueryRow(query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count invoice status history: %w", err)
+	}
+
+	return count, nil
+}
+
+// CreateInvoiceAttachment records the metadata of a document attached to an invoice
+func (r *MySQLRepository) CreateInvoiceAttachment(attachment *models.InvoiceAttachment) error {
+	query := `
+		INSERT INTO invoice_attachments (invoice_id, filename, content_type, storage_url, size, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	now := time.Now()
+	result, err := r.db.Exec(query, attachment.InvoiceID, attachment.Filename, attachment.ContentType,
+		attachment.StorageURL, attachment.Size, now)
+	if err != nil {
+		return fmt.Errorf("failed to create invoice attachment: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	attachment.ID = uint(id)
+	attachment.CreatedAt = models.NewTimestamp(now)
+	return nil
+}
+
+// GetInvoiceAttachments gets the attachments of an invoice, oldest first
+func (r *MySQLRepository) GetInvoiceAttachments(invoiceID uint) ([]*models.InvoiceAttachment, error) {
+	query := `
+		SELECT id, invoice_id, filename, content_type, storage_url, size, created_at
+		FROM invoice_attachments
+		WHERE invoice_id = ?
+		ORDER BY id
+	`
+	rows, err := r.db.Query(query, invoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoice attachments: %w", err)
+	}
+	defer rows.Close()
+
+	var attachments []*models.InvoiceAttachment
+	for rows.Next() {
+		attachment := &models.InvoiceAttachment{}
+		err := rows.Scan(&attachment.ID, &attachment.InvoiceID, &attachment.Filename, &attachment.ContentType,
+			&attachment.StorageURL, &attachment.Size, &attachment.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan invoice attachment: %w", err)
+		}
+		attachments = append(attachments, attachment)
+	}
+
+	return attachments, nil
+}
+
+// GetCompanyWebhook gets the webhook endpoint and signing secret of a company; the URL is nil when
+// webhooks are disabled
+func (r *MySQLRepository) GetCompanyWebhook(companyID uint) (*models.WebhookSettings, error) {
+	var url, secret sql.NullString
+	if err := r.db.QueryRow(`SELECT webhook_url, webhook_secret FROM companies WHERE id = ?`, companyID).Scan(&url, &secret); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("company not found")
+		}
+		return nil, fmt.Errorf("failed to get company webhook: %w", err)
+	}
+
+	settings := &models.WebhookSettings{}
+	if url.Valid {
+		settings.URL = &url.String
+	}
+	if secret.Valid {
+		settings.Secret = &secret.String
+	}
+	return settings, nil
+}
+
+// SetCompanyWebhookURL sets the webhook endpoint of a company; nil disables webhooks. secret becomes
+// the signing secret of the company unless it already has one.
+func (r *MySQLRepository) SetCompanyWebhookURL(companyID uint, url *string, secret string) error {
+	if _, err := r.db.Exec(`UPDATE companies SET webhook_url = ?, webhook_secret = COALESCE(webhook_secret, ?), updated_at = ? WHERE id = ?`,
+		url, secret, time.Now(), companyID); err != nil {
+		return fmt.Errorf("failed to set company webhook url: %w", err)
+	}
+	return nil
+}
+
+// CreateWebhookDelivery queues a webhook delivery for retry
+func (r *MySQLRepository) CreateWebhookDelivery(delivery *models.WebhookDelivery) error {
+	query := `
+		INSERT INTO webhook_deliveries (company_id, event, url, payload, status, attempts, last_error, next_retry_at, delivered_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	now := time.Now()
+	result, err := r.db.Exec(query, delivery.CompanyID, delivery.Event, delivery.URL, string(delivery.Payload), delivery.Status,
+		delivery.Attempts, delivery.LastError, delivery.NextRetryAt, delivery.DeliveredAt, now, now)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook delivery: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	delivery.ID = uint(id)
+	delivery.CreatedAt = models.NewTimestamp(now)
+	delivery.UpdatedAt = models.NewTimestamp(now)
+	return nil
+}
+
+// UpdateWebhookDelivery records the outcome of a retried webhook delivery
+func (r *MySQLRepository) UpdateWebhookDelivery(delivery *models.WebhookDelivery) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = ?, last_error = ?, next_retry_at = ?, delivered_at = ?, updated_at = ?
+		WHERE id = ?
+	`
+	now := time.Now()
+	if _, err := r.db.Exec(query, delivery.Status, delivery.Attempts, delivery.LastError, delivery.NextRetryAt,
+		delivery.DeliveredAt, now, delivery.ID); err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
 	}
+
+	delivery.UpdatedAt = models.NewTimestamp(now)
 	return nil
 }
+
+// GetDueWebhookDeliveries gets up to limit pending webhook deliveries whose next retry is due at
+// now, oldest first
+func (r *MySQLRepository) GetDueWebhookDeliveries(now time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	query := webhookDeliverySelect + `
+		WHERE status = ? AND next_retry_at <= ?
+		ORDER BY next_retry_at, id
+		LIMIT ?
+	`
+	return r.queryWebhookDeliveries(query, models.WebhookDeliveryStatusPending, now, limit)
+}
+
+// GetWebhookDeliveriesByCompanyID gets the webhook deliveries of a company, newest first
+func (r *MySQLRepository) GetWebhookDeliveriesByCompanyID(companyID uint, req *models.GetWebhookDeliveriesRequest) ([]*models.WebhookDelivery, error) {
+	query := webhookDeliverySelect + ` WHERE company_id = ?`
+	args := []interface{}{companyID}
+	if req.Status != nil {
+		query += ` AND status = ?`
+		args = append(args, *req.Status)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, req.Limit)
+
+	return r.queryWebhookDeliveries(query, args...)
+}
+
+const webhookDeliverySelect = `
+	SELECT id, company_id, event, url, payload, status, attempts, last_error, next_retry_at, delivered_at, created_at, updated_at
+	FROM webhook_deliveries
+`
+
+// queryWebhookDeliveries runs a query selecting webhookDeliverySelect columns and scans the deliveries
+func (r *MySQLRepository) queryWebhookDeliveries(query string, args ...interface{}) ([]*models.WebhookDelivery, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []*models.WebhookDelivery
+	for rows.Next() {
+		delivery := &models.WebhookDelivery{}
+		var payload []byte
+		err := rows.Scan(&delivery.ID, &delivery.CompanyID, &delivery.Event, &delivery.URL, &payload, &delivery.Status,
+			&delivery.Attempts, &delivery.LastError, &delivery.NextRetryAt, &delivery.DeliveredAt, &delivery.CreatedAt, &delivery.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		delivery.Payload = payload
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,74 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Job is a unit of periodic work. A returned error is logged and the job keeps its schedule.
+type Job func(ctx context.Context) error
+
+// registeredJob holds a job with its schedule
+type registeredJob struct {
+	name     string
+	interval time.Duration
+	run      Job
+}
+
+// Scheduler runs registered jobs at fixed intervals until stopped
+type Scheduler struct {
+	jobs   []registeredJob
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates a new scheduler
+func New() *Scheduler {
+	return &Scheduler{}
+}
+
+// Register adds a job to run every interval. Jobs with a non-positive interval are ignored.
+// Register must be called before Start.
+func (s *Scheduler) Register(name string, interval time.Duration, job Job) {
+	if interval <= 0 {
+		log.Printf("Scheduler: job %s disabled (interval %s)", name, interval)
+		return
+	}
+	s.jobs = append(s.jobs, registeredJob{name: name, interval: interval, run: job})
+}
+
+// Start runs every registered job on its own ticker until ctx is cancelled or Stop is called
+func (s *Scheduler) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go func(job registeredJob) {
+			defer s.wg.Done()
+
+			ticker := time.NewTicker(job.interval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					if err := job.run(ctx); err != nil {
+						log.Printf("Scheduler: job %s failed: %v", job.name, err)
+					}
+				}
+			}
+		}(job)
+	}
+}
+
+// Stop cancels all running jobs and waits for in-flight runs to finish
+func (s *Scheduler) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
@@ -0,0 +1,132 @@
+package seed
+
+import (
+	"fmt"
+	"super-payment/internal/config"
+	"super-payment/internal/models"
+	"super-payment/internal/repository"
+	"super-payment/internal/service"
+	"time"
+)
+
+// Demo account credentials created by Run
+const (
+	DemoEmail    = "demo@example.com"
+	DemoPassword = "password123"
+)
+
+// Number of months of invoice history created for each business partner
+const invoiceMonths = 6
+
+// Result describes the demo data created by Run
+type Result struct {
+	// Skipped is true when the demo user already existed and nothing was created
+	Skipped            bool
+	CompanyID          uint
+	UserID             uint
+	BusinessPartnerIDs []uint
+	InvoiceIDs         []uint
+}
+
+var demoPartners = []models.BusinessPartner{
+	{CorporateName: "Demo Supplier Ltd.", Representative: "Hanako Suzuki", PhoneNumber: "03-1234-0001", PostalCode: "150-0001", Address: "Tokyo, Shibuya 1-1-1"},
+	{CorporateName: "Demo Logistics Corp.", Representative: "Kenji Tanaka", PhoneNumber: "06-1234-0002", PostalCode: "530-0001", Address: "Osaka, Kita 2-2-2"},
+	{CorporateName: "Demo Consulting Inc.", Representative: "Yumi Sato", PhoneNumber: "052-123-0003", PostalCode: "460-0001", Address: "Nagoya, Naka 3-3-3"},
+}
+
+// invoiceStatuses are cycled through so the demo invoices cover every status
+var invoiceStatuses = []models.InvoiceStatus{
+	models.InvoiceStatusPaid,
+	models.InvoiceStatusPartiallyPaid,
+	models.InvoiceStatusProcessing,
+	models.InvoiceStatusError,
+	models.InvoiceStatusUnprocessed,
+	models.InvoiceStatusDraft,
+}
+
+// Run creates a demo company and user, a few business partners and invoices spread across
+// statuses and issue dates. It does nothing when the demo user already exists.
+func Run(repo repository.Repository, cfg *config.Config) (*Result, error) {
+	return RunFor(repo, cfg, DemoEmail)
+}
+
+// RunFor is Run with the demo user registered under the given email
+func RunFor(repo repository.Repository, cfg *config.Config, email string) (*Result, error) {
+	if _, err := repo.GetUserByEmail(email); err == nil {
+		return &Result{Skipped: true}, nil
+	}
+
+	svc := service.NewInvoiceService(repo, cfg)
+
+	company := &models.Company{
+		CorporateName:  "Demo Company Ltd.",
+		Representative: "Taro Yamada",
+		PhoneNumber:    "03-0000-0000",
+		PostalCode:     "100-0001",
+		Address:        "Tokyo, Chiyoda 1-1-1",
+	}
+	if err := svc.CreateCompany(company); err != nil {
+		return nil, fmt.Errorf("failed to create demo company: %w", err)
+	}
+
+	user := &models.User{
+		CompanyID: company.ID,
+		FullName:  "Demo User",
+		Email:     email,
+		Password:  DemoPassword,
+		Role:      models.UserRoleAdmin,
+	}
+	if err := svc.RegisterUser(user); err != nil {
+		return nil, fmt.Errorf("failed to create demo user: %w", err)
+	}
+
+	result := &Result{CompanyID: company.ID, UserID: user.ID}
+
+	today := models.DateOnly(time.Now(), cfg.GetLocation())
+	for i := range demoPartners {
+		partner := demoPartners[i]
+		if err := svc.CreateBusinessPartner(user.ID, &partner); err != nil {
+			return nil, fmt.Errorf("failed to create demo business partner: %w", err)
+		}
+		result.BusinessPartnerIDs = append(result.BusinessPartnerIDs, partner.ID)
+
+		// One invoice per month, oldest first, moving from paid towards open statuses
+		for month := invoiceMonths - 1; month >= 0; month-- {
+			issueDate := today.AddDate(0, -month, 0)
+			status := invoiceStatuses[(invoiceMonths-1-month+i)%len(invoiceStatuses)]
+			dueDate := issueDate.AddDate(0, 0, cfg.App.DefaultPaymentTermDays)
+			// Invoices still awaiting payment are not yet due, otherwise the overdue job would
+			// move the unprocessed ones to error
+			if status != models.InvoiceStatusPaid && status != models.InvoiceStatusError && dueDate.Before(today) {
+				dueDate = today.AddDate(0, 0, cfg.App.DefaultPaymentTermDays)
+			}
+			invoice := &models.Invoice{
+				CompanyID:          company.ID,
+				BusinessPartnerID:  partner.ID,
+				IssueDate:          models.NewTimestamp(issueDate),
+				PaymentAmount:      float64(50000*(i+1) + 10000*month),
+				FeeRate:            service.DefaultFeeRate,
+				ConsumptionTaxRate: service.DefaultConsumptionTaxRate,
+				PaymentDueDate:     models.NewTimestamp(dueDate),
+				Status:             status,
+				Currency:           models.DefaultCurrency,
+				CreatedByUserID:    &user.ID,
+			}
+			invoice.Fee, invoice.ConsumptionTax, invoice.InvoiceAmount = service.CalculateInvoiceInCurrency(
+				invoice.PaymentAmount, invoice.FeeRate, invoice.ConsumptionTaxRate, cfg.App.TaxAppliesTo, invoice.Currency)
+			switch invoice.Status {
+			case models.InvoiceStatusPaid:
+				invoice.PaidAmount = invoice.InvoiceAmount
+			case models.InvoiceStatusPartiallyPaid:
+				invoice.PaidAmount = models.RoundToCurrency(invoice.InvoiceAmount/2, invoice.Currency)
+			}
+
+			if err := repo.CreateInvoice(invoice); err != nil {
+				return nil, fmt.Errorf("failed to create demo invoice: %w", err)
+			}
+			result.InvoiceIDs = append(result.InvoiceIDs, invoice.ID)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// loginAttempt tracks recent failed logins for one email address
+type loginAttempt struct {
+	failures     int
+	firstFailure time.Time
+	lockedUntil  time.Time
+}
+
+// loginLockout locks an email address out of login after too many failures within a window.
+// State is kept in memory, so it is per process and cleared on restart.
+type loginLockout struct {
+	mu       sync.Mutex
+	attempts map[string]*loginAttempt
+	// lastPrune is when expired attempts were last removed from attempts
+	lastPrune time.Time
+}
+
+func newLoginLockout() *loginLockout {
+	return &loginLockout{attempts: make(map[string]*loginAttempt)}
+}
+
+// lockedUntil returns when the lock on email expires, or the zero time if it is not locked
+func (l *loginLockout) lockedUntil(email string, now time.Time) time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	attempt, ok := l.attempts[lockoutKey(email)]
+	if !ok || !now.Before(attempt.lockedUntil) {
+		return time.Time{}
+	}
+	return attempt.lockedUntil
+}
+
+// recordFailure counts a failed login and locks the email once maxFailures is reached within window
+func (l *loginLockout) recordFailure(email string, now time.Time, maxFailures int, window, lockout time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Failures for addresses that are never tried again would otherwise stay in memory forever
+	if now.Sub(l.lastPrune) >= window {
+		l.prune(now, window)
+	}
+
+	key := lockoutKey(email)
+	attempt, ok := l.attempts[key]
+	if !ok || now.Sub(attempt.firstFailure) > window {
+		attempt = &loginAttempt{firstFailure: now}
+		l.attempts[key] = attempt
+	}
+
+	attempt.failures++
+	if attempt.failures >= maxFailures {
+		// Start counting afresh once the lock expires
+		l.attempts[key] = &loginAttempt{lockedUntil: now.Add(lockout)}
+	}
+}
+
+// prune removes the attempts whose window and lock have both expired. Callers must hold l.mu.
+func (l *loginLockout) prune(now time.Time, window time.Duration) {
+	for key, attempt := range l.attempts {
+		if now.Sub(attempt.firstFailure) > window && !now.Before(attempt.lockedUntil) {
+			delete(l.attempts, key)
+		}
+	}
+	l.lastPrune = now
+}
+
+// reset clears the failure history of email after a successful login
+func (l *loginLockout) reset(email string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.attempts, lockoutKey(email))
+}
+
+func lockoutKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -1,8 +1,16 @@
 package service
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"math"
+	"sort"
+	"strings"
+	"super-payment/internal/config"
+	"super-payment/internal/events"
 	"super-payment/internal/models"
 	"super-payment/internal/repository"
 	"time"
@@ -10,37 +18,201 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	// DefaultFeeRate is the fee rate charged on the payment amount (4%)
+	DefaultFeeRate = 0.04
+	// DefaultConsumptionTaxRate is the consumption tax rate charged on the fee (10%)
+	DefaultConsumptionTaxRate = 0.10
+)
+
+var (
+	// ErrInvoiceNotFound is returned when an invoice does not exist or belongs to another company
+	ErrInvoiceNotFound = errors.New("invoice not found")
+	// ErrInvoiceNotDraft is returned when issuing an invoice that is not a draft
+	ErrInvoiceNotDraft = errors.New("invoice is not a draft")
+	// ErrInvalidStatusTransition is returned when an invoice cannot move to the requested status
+	ErrInvalidStatusTransition = errors.New("invalid status transition")
+	// ErrInvoiceChanged is returned when an invoice was changed by another request while it was
+	// being issued, recalculated or had its status updated or a payment recorded
+	ErrInvoiceChanged = errors.New("invoice was changed concurrently")
+	// ErrInvalidPaymentAmount is returned when a payment exceeds the outstanding amount of an
+	// invoice or is not expressible in its currency
+	ErrInvalidPaymentAmount = errors.New("invalid payment amount")
+	// ErrInvoicesNotDeletable is returned when a bulk delete includes an invoice that does not
+	// exist, belongs to another company or is past the unprocessed status
+	ErrInvoicesNotDeletable = errors.New("invoices cannot be deleted")
+	// ErrInvoiceLocked is returned when recalculating an invoice that is no longer unprocessed
+	ErrInvoiceLocked = errors.New("invoice is locked")
+	// ErrPeriodClosed is returned when updating an invoice issued on or before the accounting cutoff
+	ErrPeriodClosed = errors.New("accounting period is closed")
+	// ErrCompanyNotFound is returned when a company does not exist
+	ErrCompanyNotFound = errors.New("company not found")
+	// ErrCompanyAlreadyExists is returned in strict mode when a company with the same corporate name exists
+	ErrCompanyAlreadyExists = errors.New("company already exists")
+	// ErrBusinessPartnerNotFound is returned when a business partner does not exist or belongs to
+	// another company
+	ErrBusinessPartnerNotFound = errors.New("business partner not found")
+	// ErrBankAccountNotFound is returned when a bank account does not exist or belongs to another
+	// business partner or company
+	ErrBankAccountNotFound = errors.New("bank account not found")
+	// ErrDuplicateBankAccount is returned when a business partner already has a bank account with
+	// the same bank, branch and account number
+	ErrDuplicateBankAccount = errors.New("bank account already registered for this business partner")
+	// ErrMergeIntoSelf is returned when a business partner is merged into itself
+	ErrMergeIntoSelf = errors.New("cannot merge a business partner into itself")
+	// ErrAdminRequired is returned when a member attempts an operation reserved for company admins
+	ErrAdminRequired = errors.New("admin role required")
+	// ErrAccountLocked is returned when logging in to an account locked after repeated failed logins
+	ErrAccountLocked = errors.New("account is locked")
+	// ErrWeakPassword is returned when a password does not meet the configured password policy
+	ErrWeakPassword = errors.New("password does not meet the password policy")
+	// ErrAccountDisabled is returned when logging in to a deactivated account
+	ErrAccountDisabled = errors.New("account is disabled")
+	// ErrUserNotFound is returned when a user does not exist or belongs to another company
+	ErrUserNotFound = errors.New("user not found")
+	// ErrSelfDeactivation is returned when an admin attempts to deactivate their own account
+	ErrSelfDeactivation = errors.New("cannot deactivate your own account")
+	// ErrInvalidContactInfo is returned when a company or business partner would be stored with a
+	// malformed phone number or postal code
+	ErrInvalidContactInfo = errors.New("invalid contact information")
+	// ErrEmailTaken is returned when a user would take an email registered to another user
+	ErrEmailTaken = errors.New("email is already registered")
+)
+
 // Service interface defines the business logic contract
 type Service interface {
 	// Authentication
 	RegisterUser(user *models.User) error
+	ValidatePassword(password string) error
 	LoginUser(email, password string) (*models.User, error)
+	IsEmailAvailable(email string) (bool, error)
+	IsUserActive(userID uint) (bool, error)
+	SetUserActive(userID uint, targetUserID uint, active bool) (*models.User, error)
+	UpdateProfile(userID uint, req *models.UpdateProfileRequest) (*models.User, error)
+	GetCompanyUsers(userID uint, req *models.GetUsersRequest) ([]*models.User, int, error)
+	GetAdminUser(userID uint) (*models.User, error)
 
 	// Invoice operations
 	CreateInvoice(userID uint, req *models.CreateInvoiceRequest) (*models.Invoice, error)
+	FindDuplicateInvoice(userID uint, req *models.CreateInvoiceRequest) (*models.Invoice, error)
 	GetInvoices(userID uint, req *models.GetInvoicesRequest) ([]*models.Invoice, error)
+	GetInvoicesContext(ctx context.Context, userID uint, req *models.GetInvoicesRequest) ([]*models.Invoice, error)
+	CountInvoices(userID uint, req *models.GetInvoicesRequest) (int, error)
+	CountInvoicesContext(ctx context.Context, userID uint, req *models.GetInvoicesRequest) (int, error)
+	GetInvoiceStatusFacets(userID uint) ([]*models.InvoiceStatusCount, error)
+	GetInvoiceSummary(userID uint) ([]*models.StatusSummary, error)
 	GetInvoiceByID(userID uint, invoiceID uint) (*models.Invoice, error)
+	GetInvoiceByIDContext(ctx context.Context, userID uint, invoiceID uint) (*models.Invoice, error)
+	GetInvoiceByNumber(userID uint, number string) (*models.Invoice, error)
+	GetInvoiceByNumberContext(ctx context.Context, userID uint, number string) (*models.Invoice, error)
+	IssueInvoice(userID uint, invoiceID uint) (*models.Invoice, error)
+	CloneInvoice(userID uint, invoiceID uint, req *models.CloneInvoiceRequest, draft bool) (*models.Invoice, error)
+	UpdateInvoiceStatus(userID uint, invoiceID uint, status models.InvoiceStatus) (*models.Invoice, error)
+	RecordInvoicePayment(userID uint, invoiceID uint, req *models.RecordPaymentRequest) (*models.Invoice, error)
+	DeleteInvoices(userID uint, req *models.BulkDeleteInvoicesRequest) ([]models.BulkDeleteInvoiceResult, error)
+	RecalculateInvoice(userID uint, invoiceID uint) (*models.Invoice, error)
+	CalculateBatch(userID uint, req *models.CalculateBatchRequest) ([]models.InvoiceBreakdown, error)
+	GetOverdueInvoices(userID uint, req *models.GetInvoicesRequest) ([]*models.Invoice, error)
+	GetOverdueInvoicesContext(ctx context.Context, userID uint, req *models.GetInvoicesRequest) ([]*models.Invoice, error)
+	GetUpcomingInvoices(userID uint) ([]*models.Invoice, error)
+	MarkOverdueInvoices() (int, error)
+	PurgeDeletedInvoices() (int64, error)
+	GetMonthlyInvoiceTotals(userID uint, year int) ([]*models.MonthlyInvoiceTotal, error)
+	GetInvoiceAging(userID uint) ([]*models.AgingBucketTotal, error)
+	GetFeeRevenue(start, end time.Time) ([]*models.FeeRevenueTotal, error)
+	AddInvoiceAttachment(userID uint, invoiceID uint, req *models.CreateInvoiceAttachmentRequest) (*models.InvoiceAttachment, error)
+	GetInvoiceAttachments(userID uint, invoiceID uint) ([]*models.InvoiceAttachment, error)
+	GetInvoiceHistory(userID uint, invoiceID uint, req *models.GetInvoiceHistoryRequest) ([]*models.InvoiceStatusHistory, int, error)
 
 	// Company operations
 	CreateCompany(company *models.Company) error
+	SetCompanyFeeRate(companyID uint, feeRate *float64) error
+	GetCompanySettings(userID uint) (*models.CompanySettings, error)
+	UpdateCompanySettings(userID uint, req *models.UpdateCompanySettingsRequest) (*models.CompanySettings, error)
+	PreviewRateChange(userID uint, req *models.PreviewRateChangeRequest) (*models.RateChangePreview, error)
+	GetWebhookSettings(userID uint) (*models.WebhookSettings, error)
+	UpdateWebhookSettings(userID uint, req *models.UpdateWebhookSettingsRequest) (*models.WebhookSettings, error)
+	GetWebhookDeliveries(userID uint, req *models.GetWebhookDeliveriesRequest) ([]*models.WebhookDelivery, error)
 
 	// Business Partner operations
 	CreateBusinessPartner(userID uint, partner *models.BusinessPartner) error
-	GetBusinessPartners(userID uint) ([]*models.BusinessPartner, error)
+	FindDuplicateBusinessPartner(userID uint, corporateName string) (*models.BusinessPartner, error)
+	ImportBusinessPartners(userID uint, partners []*models.BusinessPartner) error
+	GetBusinessPartners(userID uint, req *models.GetBusinessPartnersRequest) ([]*models.BusinessPartner, error)
+	GetBusinessPartnerStats(userID uint, partnerID uint) (*models.BusinessPartnerStats, error)
+	PatchBusinessPartner(userID uint, partnerID uint, req *models.BusinessPartnerPatchRequest) (*models.BusinessPartner, error)
+	MergeBusinessPartners(userID uint, sourceID uint, targetID uint, deleteSource bool) (*models.BusinessPartnerMergeResult, error)
+	GetBusinessPartnerInvoices(userID uint, partnerID uint) ([]*models.Invoice, error)
+	GetBusinessPartnerStatement(userID uint, partnerID uint, year int, month time.Month) (*models.BusinessPartnerStatement, error)
+	CreateBankAccount(userID uint, partnerID uint, req *models.BankAccountRequest) (*models.BusinessPartnerBankAccount, error)
+	UpdateBankAccount(userID uint, partnerID uint, accountID uint, req *models.BankAccountRequest) (*models.BusinessPartnerBankAccount, error)
+	SetPrimaryBankAccount(userID uint, partnerID uint, accountID uint) (*models.BusinessPartnerBankAccount, error)
+	GetPrimaryBankAccount(userID uint, partnerID uint) (*models.BusinessPartnerBankAccount, error)
+
+	// Operations
+	GetHealthDetails() *models.HealthDetails
 }
 
 // InvoiceService implements Service interface
 type InvoiceService struct {
-	repo repository.Repository
+	repo      repository.Repository
+	config    *config.Config
+	lockout   *loginLockout
+	events    *events.Bus
+	startedAt time.Time
 }
 
 // NewInvoiceService creates a new invoice service
-func NewInvoiceService(repo repository.Repository) *InvoiceService {
-	return &InvoiceService{repo: repo}
+func NewInvoiceService(repo repository.Repository, cfg *config.Config) *InvoiceService {
+	return &InvoiceService{repo: repo, config: cfg, lockout: newLoginLockout(), events: events.NewBus(), startedAt: time.Now()}
+}
+
+// Events returns the bus the service publishes domain events on once their changes are committed.
+// Subscribers are registered on it at startup.
+func (s *InvoiceService) Events() *events.Bus {
+	return s.events
+}
+
+// publishStatusChanged publishes an InvoiceStatusChanged event for an invoice that has moved out of
+// status from. Nothing is published when its status is unchanged.
+func (s *InvoiceService) publishStatusChanged(invoice *models.Invoice, from models.InvoiceStatus, userID uint) {
+	if invoice.Status == from {
+		return
+	}
+	s.events.Publish(events.InvoiceStatusChanged{
+		InvoiceID:     invoice.ID,
+		CompanyID:     invoice.CompanyID,
+		InvoiceNumber: invoice.InvoiceNumber,
+		From:          from,
+		To:            invoice.Status,
+		UserID:        userID,
+		OccurredAt:    time.Now(),
+	})
+}
+
+// GetHealthDetails reports the service uptime and the state of the database. The status is
+// "ok" when every dependency is reachable and "unavailable" otherwise.
+func (s *InvoiceService) GetHealthDetails() *models.HealthDetails {
+	now := time.Now()
+	details := &models.HealthDetails{
+		Status:        "ok",
+		Timestamp:     now.UTC(),
+		StartedAt:     s.startedAt.UTC(),
+		UptimeSeconds: int64(now.Sub(s.startedAt).Seconds()),
+		Database:      *s.repo.CheckHealth(),
+	}
+	if !details.Database.Connected {
+		details.Status = "unavailable"
+	}
+	return details
 }
 
 // RegisterUser registers a new user
 func (s *InvoiceService) RegisterUser(user *models.User) error {
+	if err := s.ValidatePassword(user.Password); err != nil {
+		return err
+	}
+
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -48,6 +220,10 @@ func (s *InvoiceService) RegisterUser(user *models.User) error {
 	}
 	user.Password = string(hashedPassword)
 
+	if user.Role == "" {
+		user.Role = models.UserRoleMember
+	}
+
 	// Create user
 	if err := s.repo.CreateUser(user); err != nil {
 		return fmt.Errorf("failed to create user: %w", err)
@@ -56,15 +232,41 @@ func (s *InvoiceService) RegisterUser(user *models.User) error {
 	return nil
 }
 
+// ValidatePassword checks a password against the configured password policy, listing the
+// unmet requirements in the returned error
+func (s *InvoiceService) ValidatePassword(password string) error {
+	if unmet := s.config.Auth.PasswordPolicy.Unmet(password); len(unmet) > 0 {
+		return fmt.Errorf("%w: password must contain %s", ErrWeakPassword, strings.Join(unmet, ", "))
+	}
+	return nil
+}
+
 // LoginUser authenticates a user
 func (s *InvoiceService) LoginUser(email, password string) (*models.User, error) {
+	auth := s.config.Auth
+	now := time.Now()
+	if auth.MaxFailedLogins > 0 {
+		if until := s.lockout.lockedUntil(email, now); !until.IsZero() {
+			return nil, fmt.Errorf("%w until %s", ErrAccountLocked, until.Format(time.RFC3339))
+		}
+	}
+
 	user, err := s.repo.GetUserByEmail(email)
+	if err == nil {
+		err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+	}
 	if err != nil {
+		// Unknown emails are counted too so the lockout does not reveal which accounts exist
+		if auth.MaxFailedLogins > 0 {
+			s.lockout.recordFailure(email, now, auth.MaxFailedLogins, auth.FailedLoginWindow, auth.LockoutDuration)
+		}
 		return nil, fmt.Errorf("invalid credentials")
 	}
+	s.lockout.reset(email)
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
-		return nil, fmt.Errorf("invalid credentials")
+	// Checked after the password so the account status is only revealed to its owner
+	if !user.IsActive {
+		return nil, ErrAccountDisabled
 	}
 
 	// Clear password from response
@@ -72,6 +274,166 @@ func (s *InvoiceService) LoginUser(email, password string) (*models.User, error)
 	return user, nil
 }
 
+// GetAdminUser retrieves a user, failing with ErrAdminRequired unless they are a company admin
+func (s *InvoiceService) GetAdminUser(userID uint) (*models.User, error) {
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+	if !user.IsAdmin() {
+		return nil, fmt.Errorf("%w to issue read-only tokens", ErrAdminRequired)
+	}
+
+	user.Password = ""
+	return user, nil
+}
+
+// IsEmailAvailable reports whether no user is registered with the email yet
+func (s *InvoiceService) IsEmailAvailable(email string) (bool, error) {
+	_, err := s.repo.GetUserByEmail(email)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check email: %w", err)
+	}
+	return false, nil
+}
+
+// IsUserActive reports whether a user exists and has not been deactivated
+func (s *InvoiceService) IsUserActive(userID uint) (bool, error) {
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return false, fmt.Errorf("user not found: %w", err)
+	}
+	return user.IsActive, nil
+}
+
+// SetUserActive deactivates or reactivates a user of the admin's company. Deactivated users keep
+// their data but can no longer log in or use previously issued tokens.
+func (s *InvoiceService) SetUserActive(userID uint, targetUserID uint, active bool) (*models.User, error) {
+	admin, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+	if !admin.IsAdmin() {
+		return nil, fmt.Errorf("%w to manage users", ErrAdminRequired)
+	}
+	if !active && targetUserID == admin.ID {
+		return nil, ErrSelfDeactivation
+	}
+
+	target, err := s.repo.GetUserByID(targetUserID)
+	if err != nil || target.CompanyID != admin.CompanyID {
+		return nil, fmt.Errorf("%w: %d", ErrUserNotFound, targetUserID)
+	}
+
+	if err := s.repo.SetUserActive(target.ID, active); err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	target.IsActive = active
+	target.Password = ""
+	return target, nil
+}
+
+// UpdateProfile changes the full name and email of the user's own account and returns the updated
+// user without the password
+func (s *InvoiceService) UpdateProfile(userID uint, req *models.UpdateProfileRequest) (*models.User, error) {
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	if req.FullName != nil {
+		user.FullName = strings.TrimSpace(*req.FullName)
+	}
+	if req.Email != nil && *req.Email != user.Email {
+		existing, err := s.repo.GetUserByEmail(*req.Email)
+		switch {
+		case err == nil && existing.ID != user.ID:
+			return nil, fmt.Errorf("%w: %s", ErrEmailTaken, *req.Email)
+		case err != nil && !errors.Is(err, repository.ErrUserNotFound):
+			return nil, fmt.Errorf("failed to check email: %w", err)
+		}
+		user.Email = *req.Email
+	}
+
+	if err := s.repo.UpdateUser(user); err != nil {
+		// Another user took the email since it was checked
+		if errors.Is(err, repository.ErrDuplicateKey) {
+			return nil, fmt.Errorf("%w: %s", ErrEmailTaken, user.Email)
+		}
+		return nil, fmt.Errorf("failed to update profile: %w", err)
+	}
+
+	user.Password = ""
+	return user, nil
+}
+
+// GetCompanyUsers retrieves one page of the users of the admin's company, without their
+// passwords, along with the total number of matching users
+func (s *InvoiceService) GetCompanyUsers(userID uint, req *models.GetUsersRequest) ([]*models.User, int, error) {
+	admin, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("user not found: %w", err)
+	}
+	if !admin.IsAdmin() {
+		return nil, 0, fmt.Errorf("%w to list users", ErrAdminRequired)
+	}
+
+	total, err := s.repo.CountUsersByCompanyID(admin.CompanyID, req.Search)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	users, err := s.repo.GetUsersByCompanyID(admin.CompanyID, req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get users: %w", err)
+	}
+	if users == nil {
+		users = []*models.User{}
+	}
+	for _, user := range users {
+		user.Password = ""
+	}
+
+	return users, total, nil
+}
+
+// CalculateInvoice calculates the fee, consumption tax and invoice amount for a payment amount.
+// The fee is charged on the payment amount and consumption tax on the fee; the invoice amount
+// is rounded to 2 decimal places.
+func CalculateInvoice(paymentAmount, feeRate, taxRate float64) (fee, tax, total float64) {
+	return CalculateInvoiceWithTaxBase(paymentAmount, feeRate, taxRate, config.TaxAppliesToFee)
+}
+
+// CalculateInvoiceWithTaxBase is CalculateInvoice with a configurable consumption tax base:
+// config.TaxAppliesToFee taxes the fee only, config.TaxAppliesToPaymentAmount taxes the
+// payment amount plus the fee.
+func CalculateInvoiceWithTaxBase(paymentAmount, feeRate, taxRate float64, taxAppliesTo string) (fee, tax, total float64) {
+	fee, tax, total = calculateInvoice(paymentAmount, feeRate, taxRate, taxAppliesTo)
+	return fee, tax, math.Round(total*100) / 100
+}
+
+// CalculateInvoiceInCurrency is CalculateInvoiceWithTaxBase with the invoice amount rounded to the
+// minor unit of the given currency instead of 2 decimal places (e.g. whole yen for JPY)
+func CalculateInvoiceInCurrency(paymentAmount, feeRate, taxRate float64, taxAppliesTo, currency string) (fee, tax, total float64) {
+	fee, tax, total = calculateInvoice(paymentAmount, feeRate, taxRate, taxAppliesTo)
+	return fee, tax, models.RoundToCurrency(total, currency)
+}
+
+// calculateInvoice computes the fee, consumption tax and unrounded invoice amount
+func calculateInvoice(paymentAmount, feeRate, taxRate float64, taxAppliesTo string) (fee, tax, total float64) {
+	fee = paymentAmount * feeRate
+	if taxAppliesTo == config.TaxAppliesToPaymentAmount {
+		tax = (paymentAmount + fee) * taxRate
+	} else {
+		tax = fee * taxRate
+	}
+	return fee, tax, paymentAmount + fee + tax
+}
+
 // CreateInvoice creates a new invoice with automatic calculations
 func (s *InvoiceService) CreateInvoice(userID uint, req *models.CreateInvoiceRequest) (*models.Invoice, error) {
 	// Get user to get company ID
@@ -90,50 +452,141 @@ func (s *InvoiceService) CreateInvoice(userID uint, req *models.CreateInvoiceReq
 		return nil, fmt.Errorf("business partner does not belong to your company")
 	}
 
+	// Line items, when provided, determine the payment amount
+	paymentAmount := req.PaymentAmount
+	if len(req.LineItems) > 0 {
+		paymentAmount = req.LineItemsTotal()
+	}
+
+	settings, err := s.companySettings(user.CompanyID)
+	if err != nil {
+		return nil, err
+	}
+
+	issueDate := models.DateOnly(time.Now(), s.config.GetLocation())
+	// The due date is stored without a time of day, so the returned invoice carries it the same way
+	dueDate := models.DateOnly(paymentDueDate(req, issueDate, settings.PaymentTermDays), s.config.GetLocation())
+
 	// Calculate invoice amounts
 	invoice := &models.Invoice{
 		CompanyID:          user.CompanyID,
 		BusinessPartnerID:  req.BusinessPartnerID,
-		IssueDate:          time.Now(),
-		PaymentAmount:      req.PaymentAmount,
-		FeeRate:            0.04, // 4% fee rate
-		ConsumptionTaxRate: 0.10, // 10% consumption tax rate
-		PaymentDueDate:     req.PaymentDueDate,
-		Status:             models.InvoiceStatusUnprocessed,
+		IssueDate:          models.NewTimestamp(issueDate),
+		PaymentAmount:      paymentAmount,
+		FeeRate:            settings.FeeRate,
+		ConsumptionTaxRate: settings.ConsumptionTaxRate,
+		PaymentDueDate:     models.NewTimestamp(dueDate),
+		Status:             settings.InitialInvoiceStatus,
+		LineItems:          req.ToInvoiceItems(),
+		Memo:               req.Memo,
+		Currency:           invoiceCurrency(req),
+		CreatedByUserID:    &user.ID,
 	}
 
-	// Calculate fee: payment amount * 4%
-	invoice.Fee = invoice.PaymentAmount * invoice.FeeRate
-
-	// Calculate consumption tax: fee * 10%
-	invoice.ConsumptionTax = invoice.Fee * invoice.ConsumptionTaxRate
+	if req.Draft {
+		invoice.Status = models.InvoiceStatusDraft
+	}
 
-	// Calculate invoice amount: payment amount + fee + consumption tax
-	// Round to 2 decimal places
-	invoice.InvoiceAmount = math.Round((invoice.PaymentAmount+invoice.Fee+invoice.ConsumptionTax)*100) / 100
+	invoice.Fee, invoice.ConsumptionTax, invoice.InvoiceAmount = CalculateInvoiceInCurrency(
+		invoice.PaymentAmount, invoice.FeeRate, invoice.ConsumptionTaxRate, s.config.App.TaxAppliesTo, invoice.Currency)
 
 	// Create invoice
 	if err := s.repo.CreateInvoice(invoice); err != nil {
 		return nil, fmt.Errorf("failed to create invoice: %w", err)
 	}
 
-	// Get the created invoice with related data
-	createdInvoice, err := s.repo.GetInvoiceByID(invoice.ID)
+	// The related data was loaded above, so the invoice is returned without reading it back
+	invoice.Company = user.Company
+	invoice.BusinessPartner = partner
+	invoice.CreatedByName = &user.FullName
+
+	s.events.Publish(events.InvoiceCreated{
+		InvoiceID:     invoice.ID,
+		CompanyID:     invoice.CompanyID,
+		InvoiceNumber: invoice.InvoiceNumber,
+		Status:        invoice.Status,
+		InvoiceAmount: invoice.InvoiceAmount,
+		Currency:      invoice.Currency,
+		UserID:        userID,
+		OccurredAt:    invoice.CreatedAt.Time,
+	})
+
+	return invoice, nil
+}
+
+// invoiceCurrency returns the requested currency, or DefaultCurrency when the request omits it
+func invoiceCurrency(req *models.CreateInvoiceRequest) string {
+	if req.Currency == "" {
+		return models.DefaultCurrency
+	}
+	return req.Currency
+}
+
+// paymentDueDate returns the requested due date, or the issue date plus the company's payment terms
+// when the request omits it
+func paymentDueDate(req *models.CreateInvoiceRequest, issueDate time.Time, paymentTermDays int) time.Time {
+	if req.PaymentDueDate.IsZero() {
+		return issueDate.AddDate(0, 0, paymentTermDays)
+	}
+	return req.PaymentDueDate
+}
+
+// FindDuplicateInvoice looks for an unprocessed invoice created today that matches the request's
+// business partner, payment amount and due date. It returns nil when there is no match.
+func (s *InvoiceService) FindDuplicateInvoice(userID uint, req *models.CreateInvoiceRequest) (*models.Invoice, error) {
+	// Get user to get company ID
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	paymentAmount := req.PaymentAmount
+	if len(req.LineItems) > 0 {
+		paymentAmount = req.LineItemsTotal()
+	}
+
+	settings, err := s.companySettings(user.CompanyID)
+	if err != nil {
+		return nil, err
+	}
+
+	issueDate := models.DateOnly(time.Now(), s.config.GetLocation())
+	duplicate, err := s.repo.FindDuplicateInvoice(&models.Invoice{
+		CompanyID:         user.CompanyID,
+		BusinessPartnerID: req.BusinessPartnerID,
+		IssueDate:         models.NewTimestamp(issueDate),
+		PaymentAmount:     paymentAmount,
+		Currency:          invoiceCurrency(req),
+		PaymentDueDate:    models.NewTimestamp(models.DateOnly(paymentDueDate(req, issueDate, settings.PaymentTermDays), s.config.GetLocation())),
+		Status:            settings.InitialInvoiceStatus,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get created invoice: %w", err)
+		return nil, fmt.Errorf("failed to check for duplicate invoice: %w", err)
 	}
 
-	return createdInvoice, nil
+	return duplicate, nil
 }
 
 // GetInvoices retrieves invoices for a user's company with optional filters
 func (s *InvoiceService) GetInvoices(userID uint, req *models.GetInvoicesRequest) ([]*models.Invoice, error) {
+	return s.GetInvoicesContext(context.Background(), userID, req)
+}
+
+// GetInvoicesContext is GetInvoices with the invoice query cancelled once ctx is done
+func (s *InvoiceService) GetInvoicesContext(ctx context.Context, userID uint, req *models.GetInvoicesRequest) ([]*models.Invoice, error) {
 	// Get user to get company ID
 	user, err := s.repo.GetUserByID(userID)
 	if err != nil {
 		return nil, fmt.Errorf("user not found: %w", err)
 	}
 
+	if err := checkInvoiceFilterAccess(user, req); err != nil {
+		return nil, err
+	}
+	if err := s.checkBusinessPartnerFilter(user, req); err != nil {
+		return nil, err
+	}
+
 	// Set default pagination if not provided
 	if req.Page < 1 {
 		req.Page = 1
@@ -146,16 +599,214 @@ func (s *InvoiceService) GetInvoices(userID uint, req *models.GetInvoicesRequest
 	}
 
 	// Get invoices
-	invoices, err := s.repo.GetInvoicesByCompanyID(user.CompanyID, req)
+	invoices, err := s.repo.GetInvoicesByCompanyIDContext(ctx, user.CompanyID, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoices: %w", err)
+	}
+	if invoices == nil {
+		invoices = []*models.Invoice{}
+	}
+
+	// Invoices in a currency without an exchange rate are left without a display amount
+	if req.DisplayCurrency != nil {
+		for _, invoice := range invoices {
+			if amount, ok := models.ConvertCurrency(invoice.InvoiceAmount, invoice.Currency, *req.DisplayCurrency, s.config.App.FXRates); ok {
+				invoice.DisplayAmount = &amount
+				invoice.DisplayCurrency = *req.DisplayCurrency
+			}
+		}
+	}
+
+	return invoices, nil
+}
+
+// CountInvoices counts invoices for a user's company matching the list filters
+func (s *InvoiceService) CountInvoices(userID uint, req *models.GetInvoicesRequest) (int, error) {
+	return s.CountInvoicesContext(context.Background(), userID, req)
+}
+
+// CountInvoicesContext is CountInvoices with the count query cancelled once ctx is done
+func (s *InvoiceService) CountInvoicesContext(ctx context.Context, userID uint, req *models.GetInvoicesRequest) (int, error) {
+	// Get user to get company ID
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return 0, fmt.Errorf("user not found: %w", err)
+	}
+
+	if err := checkInvoiceFilterAccess(user, req); err != nil {
+		return 0, err
+	}
+	if err := s.checkBusinessPartnerFilter(user, req); err != nil {
+		return 0, err
+	}
+
+	count, err := s.repo.CountInvoicesByCompanyIDContext(ctx, user.CompanyID, req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count invoices: %w", err)
+	}
+
+	return count, nil
+}
+
+// checkBusinessPartnerFilter verifies that the business partner invoices are filtered by, if any,
+// belongs to the user's company
+func (s *InvoiceService) checkBusinessPartnerFilter(user *models.User, req *models.GetInvoicesRequest) error {
+	if req.BusinessPartnerID == nil {
+		return nil
+	}
+
+	partner, err := s.repo.GetBusinessPartnerByID(*req.BusinessPartnerID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBusinessPartnerNotFound, err)
+	}
+	if partner.CompanyID != user.CompanyID {
+		return ErrBusinessPartnerNotFound
+	}
+	return nil
+}
+
+// checkInvoiceFilterAccess verifies the user may apply the requested invoice filters:
+// only company admins may filter by another user's invoices
+func checkInvoiceFilterAccess(user *models.User, req *models.GetInvoicesRequest) error {
+	if req.CreatedByUserID != nil && *req.CreatedByUserID != user.ID && !user.IsAdmin() {
+		return fmt.Errorf("%w to filter by another user's invoices", ErrAdminRequired)
+	}
+	return nil
+}
+
+// GetInvoiceStatusFacets retrieves the number of invoices in every status for a user's company,
+// including statuses without invoices
+func (s *InvoiceService) GetInvoiceStatusFacets(userID uint) ([]*models.InvoiceStatusCount, error) {
+	// Get user to get company ID
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	counts, err := s.repo.CountInvoicesByStatus(user.CompanyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count invoices by status: %w", err)
+	}
+
+	byStatus := make(map[models.InvoiceStatus]int, len(counts))
+	for _, count := range counts {
+		byStatus[count.Status] = count.Count
+	}
+
+	facets := make([]*models.InvoiceStatusCount, len(models.InvoiceStatuses))
+	for i, status := range models.InvoiceStatuses {
+		facets[i] = &models.InvoiceStatusCount{Status: status, Count: byStatus[status]}
+	}
+
+	return facets, nil
+}
+
+// GetInvoiceSummary retrieves the number of invoices of a user's company with their invoice amount,
+// fee and consumption tax totals per status and currency
+func (s *InvoiceService) GetInvoiceSummary(userID uint) ([]*models.StatusSummary, error) {
+	// Get user to get company ID
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	summaries, err := s.repo.GetInvoiceStatusSummaries(user.CompanyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize invoices: %w", err)
+	}
+
+	if summaries == nil {
+		summaries = []*models.StatusSummary{}
+	}
+	for _, summary := range summaries {
+		summary.InvoiceAmount = models.RoundToCurrency(summary.InvoiceAmount, summary.Currency)
+		summary.TotalFee = models.RoundToCurrency(summary.TotalFee, summary.Currency)
+		summary.TotalTax = models.RoundToCurrency(summary.TotalTax, summary.Currency)
+	}
+
+	return summaries, nil
+}
+
+// GetOverdueInvoices retrieves unprocessed invoices whose payment due date has passed
+func (s *InvoiceService) GetOverdueInvoices(userID uint, req *models.GetInvoicesRequest) ([]*models.Invoice, error) {
+	return s.GetOverdueInvoicesContext(context.Background(), userID, req)
+}
+
+// GetOverdueInvoicesContext is GetOverdueInvoices with the invoice query cancelled once ctx is done
+func (s *InvoiceService) GetOverdueInvoicesContext(ctx context.Context, userID uint, req *models.GetInvoicesRequest) ([]*models.Invoice, error) {
+	status := string(models.InvoiceStatusUnprocessed)
+	yesterday := models.DateOnly(time.Now(), s.config.GetLocation()).AddDate(0, 0, -1)
+
+	req.Status = &status
+	req.EndDate = &yesterday
+
+	return s.GetInvoicesContext(ctx, userID, req)
+}
+
+// GetUpcomingInvoices retrieves all unprocessed invoices whose payment due date is today or later
+func (s *InvoiceService) GetUpcomingInvoices(userID uint) ([]*models.Invoice, error) {
+	// Get user to get company ID
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	status := string(models.InvoiceStatusUnprocessed)
+	today := models.DateOnly(time.Now(), s.config.GetLocation())
+
+	// No limit: feeds list every upcoming invoice rather than a page of them
+	invoices, err := s.repo.GetInvoicesByCompanyID(user.CompanyID, &models.GetInvoicesRequest{
+		Status:    &status,
+		StartDate: &today,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get invoices: %w", err)
 	}
+	if invoices == nil {
+		invoices = []*models.Invoice{}
+	}
 
 	return invoices, nil
 }
 
+// MarkOverdueInvoices transitions all unprocessed invoices past their due date to error status,
+// except those issued in a closed accounting period. It is intended to be run periodically and returns the number of invoices transitioned.
+func (s *InvoiceService) MarkOverdueInvoices() (int, error) {
+	today := models.DateOnly(time.Now(), s.config.GetLocation())
+
+	invoices, err := s.repo.MarkOverdueInvoices(today, s.periodOpensAt())
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark overdue invoices: %w", err)
+	}
+
+	// The job runs on no user's behalf
+	for _, invoice := range invoices {
+		s.publishStatusChanged(invoice, models.InvoiceStatusUnprocessed, 0)
+	}
+
+	return len(invoices), nil
+}
+
+// PurgeDeletedInvoices permanently removes the invoices deleted longer ago than the retention period,
+// except those issued in a closed accounting period. It is intended to be run periodically and
+// returns the number of invoices removed.
+func (s *InvoiceService) PurgeDeletedInvoices() (int64, error) {
+	deletedBefore := time.Now().AddDate(0, 0, -s.config.App.DeletedInvoiceRetentionDays)
+
+	purged, err := s.repo.PurgeDeletedInvoices(deletedBefore, s.periodOpensAt())
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted invoices: %w", err)
+	}
+	return purged, nil
+}
+
 // GetInvoiceByID retrieves a specific invoice by ID
 func (s *InvoiceService) GetInvoiceByID(userID uint, invoiceID uint) (*models.Invoice, error) {
+	return s.GetInvoiceByIDContext(context.Background(), userID, invoiceID)
+}
+
+// GetInvoiceByIDContext is GetInvoiceByID with the invoice queries cancelled once ctx is done
+func (s *InvoiceService) GetInvoiceByIDContext(ctx context.Context, userID uint, invoiceID uint) (*models.Invoice, error) {
 	// Get user to get company ID
 	user, err := s.repo.GetUserByID(userID)
 	if err != nil {
@@ -163,7 +814,7 @@ func (s *InvoiceService) GetInvoiceByID(userID uint, invoiceID uint) (*models.In
 	}
 
 	// Get invoice
-	invoice, err := s.repo.GetInvoiceByID(invoiceID)
+	invoice, err := s.repo.GetInvoiceByIDContext(ctx, invoiceID)
 	if err != nil {
 		return nil, fmt.Errorf("invoice not found: %w", err)
 	}
@@ -176,43 +827,1108 @@ func (s *InvoiceService) GetInvoiceByID(userID uint, invoiceID uint) (*models.In
 	return invoice, nil
 }
 
-// CreateCompany creates a new company
-func (s *InvoiceService) CreateCompany(company *models.Company) error {
-	if err := s.repo.CreateCompany(company); err != nil {
-		return fmt.Errorf("failed to create company: %w", err)
-	}
-	return nil
+// GetInvoiceByNumber retrieves an invoice of the user's company by its invoice number
+func (s *InvoiceService) GetInvoiceByNumber(userID uint, number string) (*models.Invoice, error) {
+	return s.GetInvoiceByNumberContext(context.Background(), userID, number)
 }
 
-// CreateBusinessPartner creates a new business partner
-func (s *InvoiceService) CreateBusinessPartner(userID uint, partner *models.BusinessPartner) error {
+// GetInvoiceByNumberContext is GetInvoiceByNumber with the invoice queries cancelled once ctx is done
+func (s *InvoiceService) GetInvoiceByNumberContext(ctx context.Context, userID uint, number string) (*models.Invoice, error) {
 	// Get user to get company ID
 	user, err := s.repo.GetUserByID(userID)
 	if err != nil {
-		return fmt.Errorf("user not found: %w", err)
+		return nil, fmt.Errorf("user not found: %w", err)
 	}
 
-	partner.CompanyID = user.CompanyID
-
-	if err := s.repo.CreateBusinessPartner(partner); err != nil {
-		return fmt.Errorf("failed to create business partner: %w", err)
+	invoice, err := s.repo.GetInvoiceByNumberContext(ctx, user.CompanyID, number)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoice: %w", err)
+	}
+	if invoice == nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvoiceNotFound, number)
 	}
 
-	return nil
+	return invoice, nil
 }
 
-// GetBusinessPartners retrieves business partners for a user's company
-func (s *InvoiceService) GetBusinessPartners(userID uint) ([]*models.BusinessPartner, error) {
-	// Get user to get company ID
-	user, err := s.repo.GetUserByID(userID)
+// IssueInvoice issues a draft invoice in the company's initial invoice status, setting its issue
+// date to today
+func (s *InvoiceService) IssueInvoice(userID uint, invoiceID uint) (*models.Invoice, error) {
+	invoice, err := s.GetInvoiceByID(userID, invoiceID)
 	if err != nil {
-		return nil, fmt.Errorf("user not found: %w", err)
+		return nil, fmt.Errorf("%w: %v", ErrInvoiceNotFound, err)
 	}
 
-	partners, err := s.repo.GetBusinessPartnersByCompanyID(user.CompanyID)
+	if err := s.checkPeriodOpen(invoice); err != nil {
+		return nil, err
+	}
+
+	if !invoice.Status.CanTransitionTo(models.InvoiceStatusUnprocessed) {
+		return nil, fmt.Errorf("%w: cannot issue a %s invoice", ErrInvoiceNotDraft, invoice.Status)
+	}
+
+	// Issued drafts start in the same status as the company's other new invoices
+	settings, err := s.companySettings(invoice.CompanyID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get business partners: %w", err)
+		return nil, err
 	}
 
-	return partners, nil
+	issueDate := models.DateOnly(time.Now(), s.config.GetLocation())
+	if err := s.repo.IssueInvoice(invoice.ID, settings.InitialInvoiceStatus, issueDate, userID); err != nil {
+		if errors.Is(err, repository.ErrInvoiceChanged) {
+			return nil, ErrInvoiceChanged
+		}
+		return nil, fmt.Errorf("failed to issue invoice: %w", err)
+	}
+
+	issuedInvoice, err := s.repo.GetInvoiceByID(invoice.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get issued invoice: %w", err)
+	}
+	s.publishStatusChanged(issuedInvoice, invoice.Status, userID)
+
+	return issuedInvoice, nil
+}
+
+// CloneInvoice creates a new invoice for the same business partner, amount, currency and line items as an
+// existing one. The clone gets a fresh issue date, the requested or default due date, and fees
+// recomputed with the current rates.
+func (s *InvoiceService) CloneInvoice(userID uint, invoiceID uint, req *models.CloneInvoiceRequest, draft bool) (*models.Invoice, error) {
+	source, err := s.GetInvoiceByID(userID, invoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvoiceNotFound, err)
+	}
+
+	createReq := &models.CreateInvoiceRequest{
+		BusinessPartnerID: source.BusinessPartnerID,
+		PaymentAmount:     source.PaymentAmount,
+		Currency:          source.Currency,
+		PaymentDueDate:    req.PaymentDueDate,
+		Draft:             draft,
+	}
+	for _, item := range source.LineItems {
+		createReq.LineItems = append(createReq.LineItems, models.CreateInvoiceItemRequest{
+			Description: item.Description,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.UnitPrice,
+		})
+	}
+
+	return s.CreateInvoice(userID, createReq)
+}
+
+// UpdateInvoiceStatus changes the status of an issued invoice
+func (s *InvoiceService) UpdateInvoiceStatus(userID uint, invoiceID uint, status models.InvoiceStatus) (*models.Invoice, error) {
+	invoice, err := s.GetInvoiceByID(userID, invoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvoiceNotFound, err)
+	}
+
+	if err := s.checkPeriodOpen(invoice); err != nil {
+		return nil, err
+	}
+
+	// Drafts only leave the draft status by being issued, and invoices only become partially paid
+	// by recording a payment
+	if invoice.Status == models.InvoiceStatusDraft || status == models.InvoiceStatusPartiallyPaid ||
+		!invoice.Status.CanTransitionTo(status) {
+		return nil, fmt.Errorf("%w: %s to %s", ErrInvalidStatusTransition, invoice.Status, status)
+	}
+
+	if err := s.repo.UpdateInvoiceStatus(invoice.ID, invoice.Status, status, userID); err != nil {
+		if errors.Is(err, repository.ErrInvoiceChanged) {
+			return nil, ErrInvoiceChanged
+		}
+		return nil, fmt.Errorf("failed to update invoice status: %w", err)
+	}
+
+	updatedInvoice, err := s.repo.GetInvoiceByID(invoice.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get updated invoice: %w", err)
+	}
+	s.publishStatusChanged(updatedInvoice, invoice.Status, userID)
+
+	return updatedInvoice, nil
+}
+
+// RecordInvoicePayment records a payment received against an issued invoice. The invoice becomes
+// paid once its paid amount reaches the invoice amount and partially paid until then.
+func (s *InvoiceService) RecordInvoicePayment(userID uint, invoiceID uint, req *models.RecordPaymentRequest) (*models.Invoice, error) {
+	invoice, err := s.GetInvoiceByID(userID, invoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvoiceNotFound, err)
+	}
+
+	if err := s.checkPeriodOpen(invoice); err != nil {
+		return nil, err
+	}
+
+	// Payments are only received on partially paid invoices and those that may become partially
+	// paid: issued invoices that still have an amount outstanding
+	if invoice.Status != models.InvoiceStatusPartiallyPaid && !invoice.Status.CanTransitionTo(models.InvoiceStatusPartiallyPaid) {
+		return nil, fmt.Errorf("%w: cannot record a payment on a %s invoice", ErrInvalidStatusTransition, invoice.Status)
+	}
+
+	if err := models.ValidateCurrencyAmount("amount", req.Amount, invoice.Currency); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPaymentAmount, err)
+	}
+
+	outstanding := models.RoundToCurrency(invoice.InvoiceAmount-invoice.PaidAmount, invoice.Currency)
+	if req.Amount > outstanding {
+		return nil, fmt.Errorf("%w: %.2f exceeds the outstanding %.2f %s", ErrInvalidPaymentAmount, req.Amount, outstanding, invoice.Currency)
+	}
+
+	status := models.InvoiceStatusPartiallyPaid
+	if req.Amount == outstanding {
+		status = models.InvoiceStatusPaid
+	}
+	if status != invoice.Status && !invoice.Status.CanTransitionTo(status) {
+		return nil, fmt.Errorf("%w: %s to %s", ErrInvalidStatusTransition, invoice.Status, status)
+	}
+
+	payment := &models.InvoicePayment{InvoiceID: invoice.ID, Amount: req.Amount, UserID: &userID}
+	if err := s.repo.RecordInvoicePayment(payment, invoice.Status, status, invoice.PaidAmount); err != nil {
+		if errors.Is(err, repository.ErrInvoiceChanged) {
+			return nil, ErrInvoiceChanged
+		}
+		return nil, fmt.Errorf("failed to record invoice payment: %w", err)
+	}
+
+	updatedInvoice, err := s.repo.GetInvoiceByID(invoice.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get updated invoice: %w", err)
+	}
+	s.publishStatusChanged(updatedInvoice, invoice.Status, userID)
+
+	return updatedInvoice, nil
+}
+
+// DeleteInvoices soft-deletes draft and unprocessed invoices of the user's company, all or none. When
+// any invoice cannot be deleted it returns ErrInvoicesNotDeletable along with the per-invoice
+// results explaining why.
+func (s *InvoiceService) DeleteInvoices(userID uint, req *models.BulkDeleteInvoicesRequest) ([]models.BulkDeleteInvoiceResult, error) {
+	// Get user to get company ID
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	// Repeated IDs are reported once
+	ids := make([]uint, 0, len(req.IDs))
+	seen := make(map[uint]bool, len(req.IDs))
+	for _, id := range req.IDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	results, err := s.repo.DeleteInvoices(user.CompanyID, ids, s.periodOpensAt())
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete invoices: %w", err)
+	}
+
+	for _, result := range results {
+		if !result.Deleted {
+			return results, ErrInvoicesNotDeletable
+		}
+	}
+
+	return results, nil
+}
+
+// AddInvoiceAttachment records a document kept in external storage as an attachment of an
+// invoice of the user's company
+func (s *InvoiceService) AddInvoiceAttachment(userID uint, invoiceID uint, req *models.CreateInvoiceAttachmentRequest) (*models.InvoiceAttachment, error) {
+	invoice, err := s.GetInvoiceByID(userID, invoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvoiceNotFound, err)
+	}
+
+	attachment := &models.InvoiceAttachment{
+		InvoiceID:   invoice.ID,
+		Filename:    req.Filename,
+		ContentType: req.ContentType,
+		StorageURL:  req.StorageURL,
+		Size:        req.Size,
+	}
+	if err := s.repo.CreateInvoiceAttachment(attachment); err != nil {
+		return nil, fmt.Errorf("failed to create invoice attachment: %w", err)
+	}
+
+	return attachment, nil
+}
+
+// GetInvoiceAttachments retrieves the attachments of an invoice of the user's company
+func (s *InvoiceService) GetInvoiceAttachments(userID uint, invoiceID uint) ([]*models.InvoiceAttachment, error) {
+	invoice, err := s.GetInvoiceByID(userID, invoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvoiceNotFound, err)
+	}
+
+	attachments, err := s.repo.GetInvoiceAttachments(invoice.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoice attachments: %w", err)
+	}
+	if attachments == nil {
+		attachments = []*models.InvoiceAttachment{}
+	}
+
+	return attachments, nil
+}
+
+// GetInvoiceHistory retrieves one page of the status history of an invoice of the user's company
+// along with the total number of matching entries
+func (s *InvoiceService) GetInvoiceHistory(userID uint, invoiceID uint, req *models.GetInvoiceHistoryRequest) ([]*models.InvoiceStatusHistory, int, error) {
+	invoice, err := s.GetInvoiceByID(userID, invoiceID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrInvoiceNotFound, err)
+	}
+
+	total, err := s.repo.CountInvoiceStatusHistory(invoice.ID, req.Action)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count invoice history: %w", err)
+	}
+
+	histories, err := s.repo.GetInvoiceStatusHistoryPage(invoice.ID, req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get invoice history: %w", err)
+	}
+	if histories == nil {
+		histories = []*models.InvoiceStatusHistory{}
+	}
+
+	return histories, total, nil
+}
+
+// RecalculateInvoice re-prices an unprocessed invoice with its company's current fee and
+// consumption tax rates. Invoices already being processed or settled are locked.
+func (s *InvoiceService) RecalculateInvoice(userID uint, invoiceID uint) (*models.Invoice, error) {
+	invoice, err := s.GetInvoiceByID(userID, invoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvoiceNotFound, err)
+	}
+
+	if err := s.checkPeriodOpen(invoice); err != nil {
+		return nil, err
+	}
+	if invoice.Status != models.InvoiceStatusUnprocessed {
+		return nil, fmt.Errorf("%w: invoice is %s", ErrInvoiceLocked, invoice.Status)
+	}
+
+	settings, err := s.companySettings(invoice.CompanyID)
+	if err != nil {
+		return nil, err
+	}
+
+	invoice.FeeRate = settings.FeeRate
+	invoice.ConsumptionTaxRate = settings.ConsumptionTaxRate
+	invoice.Fee, invoice.ConsumptionTax, invoice.InvoiceAmount = CalculateInvoiceInCurrency(
+		invoice.PaymentAmount, invoice.FeeRate, invoice.ConsumptionTaxRate, s.config.App.TaxAppliesTo, invoice.Currency)
+
+	if err := s.repo.UpdateInvoiceAmounts(invoice); err != nil {
+		if errors.Is(err, repository.ErrInvoiceChanged) {
+			return nil, ErrInvoiceChanged
+		}
+		return nil, fmt.Errorf("failed to recalculate invoice: %w", err)
+	}
+
+	recalculatedInvoice, err := s.repo.GetInvoiceByID(invoice.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recalculated invoice: %w", err)
+	}
+
+	return recalculatedInvoice, nil
+}
+
+// CalculateBatch calculates the breakdown of each requested payment amount the way an invoice
+// for it would be priced, in the same order as the amounts
+func (s *InvoiceService) CalculateBatch(userID uint, req *models.CalculateBatchRequest) ([]models.InvoiceBreakdown, error) {
+	// Get user to get company ID
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	settings, err := s.companySettings(user.CompanyID)
+	if err != nil {
+		return nil, err
+	}
+
+	feeRate := settings.FeeRate
+	if req.FeeRate != nil {
+		feeRate = *req.FeeRate
+	}
+
+	taxRate := settings.ConsumptionTaxRate
+	if req.TaxRate != nil {
+		taxRate = *req.TaxRate
+	}
+
+	breakdowns := make([]models.InvoiceBreakdown, len(req.Amounts))
+	for i, amount := range req.Amounts {
+		breakdown := models.InvoiceBreakdown{PaymentAmount: amount, FeeRate: feeRate, ConsumptionTaxRate: taxRate}
+		breakdown.Fee, breakdown.ConsumptionTax, breakdown.InvoiceAmount = CalculateInvoiceInCurrency(
+			amount, feeRate, taxRate, s.config.App.TaxAppliesTo, models.DefaultCurrency)
+		breakdowns[i] = breakdown
+	}
+
+	return breakdowns, nil
+}
+
+// companySettings returns the rates, payment terms and initial invoice status a company has
+// configured, falling back to DefaultFeeRate, DefaultConsumptionTaxRate, the configured default
+// payment terms and unprocessed
+func (s *InvoiceService) companySettings(companyID uint) (*models.CompanySettings, error) {
+	rates, err := s.repo.GetCompanyRates(companyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get company settings: %w", err)
+	}
+
+	settings := &models.CompanySettings{
+		FeeRate:            DefaultFeeRate,
+		ConsumptionTaxRate: DefaultConsumptionTaxRate,
+		PaymentTermDays:    s.config.App.DefaultPaymentTermDays,
+		// Invoices are worked on by hand unless the company submits them to its payment queue
+		InitialInvoiceStatus: models.InvoiceStatusUnprocessed,
+	}
+	if rates.FeeRate != nil {
+		settings.FeeRate = *rates.FeeRate
+	}
+	if rates.ConsumptionTaxRate != nil {
+		settings.ConsumptionTaxRate = *rates.ConsumptionTaxRate
+	}
+	if rates.PaymentTermDays != nil {
+		settings.PaymentTermDays = *rates.PaymentTermDays
+	}
+	if rates.InitialInvoiceStatus != nil {
+		settings.InitialInvoiceStatus = *rates.InitialInvoiceStatus
+	}
+	return settings, nil
+}
+
+// checkPeriodOpen rejects changes to invoices issued on or before the accounting cutoff date
+func (s *InvoiceService) checkPeriodOpen(invoice *models.Invoice) error {
+	opensAt := s.periodOpensAt()
+	if opensAt == nil {
+		return nil
+	}
+
+	if invoice.IssueDate.Time.Before(*opensAt) {
+		return fmt.Errorf("%w: invoice was issued on or before %s", ErrPeriodClosed, s.config.App.AccountingCutoff.Format("2006-01-02"))
+	}
+	return nil
+}
+
+// periodOpensAt returns the start of the first day after the accounting cutoff, from which
+// invoices may still be changed, or nil when no cutoff is configured
+func (s *InvoiceService) periodOpensAt() *time.Time {
+	cutoff := s.config.App.AccountingCutoff
+	if cutoff == nil {
+		return nil
+	}
+
+	opensAt := models.DateOnly(*cutoff, s.config.GetLocation()).AddDate(0, 0, 1)
+	return &opensAt
+}
+
+// GetMonthlyInvoiceTotals retrieves per-month invoice totals of a year for a user's company,
+// always returning all 12 months
+func (s *InvoiceService) GetMonthlyInvoiceTotals(userID uint, year int) ([]*models.MonthlyInvoiceTotal, error) {
+	// Get user to get company ID
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	totals, err := s.repo.GetMonthlyInvoiceTotals(user.CompanyID, year)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get monthly invoice totals: %w", err)
+	}
+
+	// Fill months without invoices with zero values
+	months := make([]*models.MonthlyInvoiceTotal, 12)
+	for i := range months {
+		months[i] = &models.MonthlyInvoiceTotal{Month: i + 1}
+	}
+	for _, total := range totals {
+		if total.Month >= 1 && total.Month <= 12 {
+			months[total.Month-1] = total
+		}
+	}
+
+	return months, nil
+}
+
+// GetInvoiceAging retrieves the accounts receivable aging of the user's company: the count and
+// outstanding amount of unpaid invoices per aging bucket as of today. Every currency with unpaid
+// invoices, or the default currency when there are none, gets all buckets in bucket order;
+// currencies are sorted by code.
+func (s *InvoiceService) GetInvoiceAging(userID uint) ([]*models.AgingBucketTotal, error) {
+	// Get user to get company ID
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	today := models.DateOnly(time.Now(), s.config.GetLocation())
+	totals, err := s.repo.GetInvoiceAgingTotals(user.CompanyID, today)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoice aging totals: %w", err)
+	}
+
+	// Amounts in different currencies are never added up, so each currency is aged on its own
+	byCurrency := make(map[string]map[string]*models.AgingBucketTotal)
+	for _, total := range totals {
+		if byCurrency[total.Currency] == nil {
+			byCurrency[total.Currency] = make(map[string]*models.AgingBucketTotal, len(models.AgingBuckets))
+		}
+		total.TotalAmount = models.RoundToCurrency(total.TotalAmount, total.Currency)
+		byCurrency[total.Currency][total.Bucket] = total
+	}
+	currencies := make([]string, 0, len(byCurrency))
+	for currency := range byCurrency {
+		currencies = append(currencies, currency)
+	}
+	if len(currencies) == 0 {
+		currencies = append(currencies, models.DefaultCurrency)
+	}
+	sort.Strings(currencies)
+
+	// Fill buckets without invoices with zero values
+	buckets := make([]*models.AgingBucketTotal, 0, len(currencies)*len(models.AgingBuckets))
+	for _, currency := range currencies {
+		for _, bucket := range models.AgingBuckets {
+			if total, ok := byCurrency[currency][bucket]; ok {
+				buckets = append(buckets, total)
+			} else {
+				buckets = append(buckets, &models.AgingBucketTotal{Bucket: bucket, Currency: currency})
+			}
+		}
+	}
+
+	return buckets, nil
+}
+
+// GetFeeRevenue retrieves the fees and consumption tax collected by the platform on the paid
+// invoices of all companies issued between start and end inclusive, per month and currency
+func (s *InvoiceService) GetFeeRevenue(start, end time.Time) ([]*models.FeeRevenueTotal, error) {
+	loc := s.config.GetLocation()
+	totals, err := s.repo.GetFeeRevenueTotals(models.DateOnly(start, loc), models.DateOnly(end, loc).AddDate(0, 0, 1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fee revenue totals: %w", err)
+	}
+
+	if totals == nil {
+		totals = []*models.FeeRevenueTotal{}
+	}
+	for _, total := range totals {
+		total.Fee = models.RoundToCurrency(total.Fee, total.Currency)
+		total.ConsumptionTax = models.RoundToCurrency(total.ConsumptionTax, total.Currency)
+		total.TotalRevenue = models.RoundToCurrency(total.Fee+total.ConsumptionTax, total.Currency)
+	}
+
+	return totals, nil
+}
+
+// NormalizeCompanyName trims a corporate name and collapses runs of whitespace to a single space
+func NormalizeCompanyName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// CreateCompany creates a new company
+func (s *InvoiceService) CreateCompany(company *models.Company) error {
+	if err := models.ValidateContactInfo(company.PhoneNumber, company.PostalCode); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidContactInfo, err)
+	}
+
+	if s.config.App.CompanyNameUniqueness == config.CompanyNameUniquenessStrict {
+		exists, err := s.repo.CompanyNameExists(NormalizeCompanyName(company.CorporateName))
+		if err != nil {
+			return fmt.Errorf("failed to create company: %w", err)
+		}
+		if exists {
+			return fmt.Errorf("%w: %s", ErrCompanyAlreadyExists, company.CorporateName)
+		}
+	}
+
+	if err := s.repo.CreateCompany(company); err != nil {
+		return fmt.Errorf("failed to create company: %w", err)
+	}
+	return nil
+}
+
+// SetCompanyFeeRate sets the fee rate negotiated by a company for its new and recalculated
+// invoices. A nil rate reverts the company to DefaultFeeRate.
+func (s *InvoiceService) SetCompanyFeeRate(companyID uint, feeRate *float64) error {
+	if _, err := s.repo.GetCompanyByID(companyID); err != nil {
+		return fmt.Errorf("%w: %v", ErrCompanyNotFound, err)
+	}
+
+	if err := s.repo.SetCompanyFeeRate(companyID, feeRate); err != nil {
+		return fmt.Errorf("failed to set company fee rate: %w", err)
+	}
+	return nil
+}
+
+// GetCompanySettings retrieves the rates and payment terms applied to the user's company
+func (s *InvoiceService) GetCompanySettings(userID uint) (*models.CompanySettings, error) {
+	// Get user to get company ID
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	return s.companySettings(user.CompanyID)
+}
+
+// UpdateCompanySettings sets the tax rate, payment terms and initial invoice status of the admin's
+// company; its negotiated fee rate is left unchanged
+func (s *InvoiceService) UpdateCompanySettings(userID uint, req *models.UpdateCompanySettingsRequest) (*models.CompanySettings, error) {
+	admin, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+	if !admin.IsAdmin() {
+		return nil, fmt.Errorf("%w to change company settings", ErrAdminRequired)
+	}
+
+	rates := &models.CompanyRates{
+		ConsumptionTaxRate:   req.ConsumptionTaxRate,
+		PaymentTermDays:      req.PaymentTermDays,
+		InitialInvoiceStatus: req.InitialInvoiceStatus,
+	}
+	if err := s.repo.UpdateCompanyRates(admin.CompanyID, rates); err != nil {
+		return nil, fmt.Errorf("failed to update company settings: %w", err)
+	}
+
+	return s.companySettings(admin.CompanyID)
+}
+
+// GetWebhookSettings retrieves the webhook endpoint of the user's company, without its signing
+// secret
+func (s *InvoiceService) GetWebhookSettings(userID uint) (*models.WebhookSettings, error) {
+	// Get user to get company ID
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	settings, err := s.repo.GetCompanyWebhook(user.CompanyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook settings: %w", err)
+	}
+
+	// The secret is only revealed by UpdateWebhookSettings
+	settings.Secret = nil
+	return settings, nil
+}
+
+// UpdateWebhookSettings sets the webhook endpoint of the admin's company and returns it together
+// with its signing secret
+func (s *InvoiceService) UpdateWebhookSettings(userID uint, req *models.UpdateWebhookSettingsRequest) (*models.WebhookSettings, error) {
+	admin, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+	if !admin.IsAdmin() {
+		return nil, fmt.Errorf("%w to change webhook settings", ErrAdminRequired)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	if err := s.repo.SetCompanyWebhookURL(admin.CompanyID, req.URL, models.WebhookSecretPrefix+hex.EncodeToString(secret)); err != nil {
+		return nil, fmt.Errorf("failed to update webhook settings: %w", err)
+	}
+
+	settings, err := s.repo.GetCompanyWebhook(admin.CompanyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook settings: %w", err)
+	}
+	return settings, nil
+}
+
+// GetWebhookDeliveries retrieves the failed webhook deliveries of the user's company with their
+// retry status, newest first
+func (s *InvoiceService) GetWebhookDeliveries(userID uint, req *models.GetWebhookDeliveriesRequest) ([]*models.WebhookDelivery, error) {
+	// Get user to get company ID
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	deliveries, err := s.repo.GetWebhookDeliveriesByCompanyID(user.CompanyID, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook deliveries: %w", err)
+	}
+	if deliveries == nil {
+		deliveries = []*models.WebhookDelivery{}
+	}
+
+	return deliveries, nil
+}
+
+// PreviewRateChange recalculates the unprocessed invoices of the user's company with the proposed
+// rates and compares their fees with the current ones, without changing anything
+func (s *InvoiceService) PreviewRateChange(userID uint, req *models.PreviewRateChangeRequest) (*models.RateChangePreview, error) {
+	// Get user to get company ID
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	settings, err := s.companySettings(user.CompanyID)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &models.RateChangePreview{FeeRate: settings.FeeRate, ConsumptionTaxRate: settings.ConsumptionTaxRate}
+	if req.FeeRate != nil {
+		preview.FeeRate = *req.FeeRate
+	}
+	if req.ConsumptionTaxRate != nil {
+		preview.ConsumptionTaxRate = *req.ConsumptionTaxRate
+	}
+
+	status := string(models.InvoiceStatusUnprocessed)
+	invoices, err := s.repo.GetInvoicesByCompanyID(user.CompanyID, &models.GetInvoicesRequest{Status: &status})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoices: %w", err)
+	}
+
+	// Amounts in different currencies are not added together
+	totals := make(map[string]*models.RateChangeTotal)
+	for _, invoice := range invoices {
+		total, ok := totals[invoice.Currency]
+		if !ok {
+			total = &models.RateChangeTotal{Currency: invoice.Currency}
+			totals[invoice.Currency] = total
+		}
+
+		fee, tax, _ := CalculateInvoiceInCurrency(
+			invoice.PaymentAmount, preview.FeeRate, preview.ConsumptionTaxRate, s.config.App.TaxAppliesTo, invoice.Currency)
+		total.InvoiceCount++
+		total.CurrentFees += invoice.Fee
+		total.ProjectedFees += fee
+		total.CurrentConsumptionTax += invoice.ConsumptionTax
+		total.ProjectedConsumptionTax += tax
+	}
+
+	preview.InvoiceCount = len(invoices)
+	preview.Totals = make([]models.RateChangeTotal, 0, len(totals))
+	for currency, total := range totals {
+		total.CurrentFees = models.RoundToCurrency(total.CurrentFees, currency)
+		total.ProjectedFees = models.RoundToCurrency(total.ProjectedFees, currency)
+		total.FeeDelta = models.RoundToCurrency(total.ProjectedFees-total.CurrentFees, currency)
+		total.CurrentConsumptionTax = models.RoundToCurrency(total.CurrentConsumptionTax, currency)
+		total.ProjectedConsumptionTax = models.RoundToCurrency(total.ProjectedConsumptionTax, currency)
+		preview.Totals = append(preview.Totals, *total)
+	}
+	sort.Slice(preview.Totals, func(i, j int) bool { return preview.Totals[i].Currency < preview.Totals[j].Currency })
+
+	return preview, nil
+}
+
+// CreateBusinessPartner creates a new business partner
+func (s *InvoiceService) CreateBusinessPartner(userID uint, partner *models.BusinessPartner) error {
+	if err := models.ValidateContactInfo(partner.PhoneNumber, partner.PostalCode); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidContactInfo, err)
+	}
+
+	// Get user to get company ID
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return fmt.Errorf("user not found: %w", err)
+	}
+
+	partner.CompanyID = user.CompanyID
+	partner.CorporateName = strings.TrimSpace(partner.CorporateName)
+
+	if err := s.repo.CreateBusinessPartner(partner); err != nil {
+		return fmt.Errorf("failed to create business partner: %w", err)
+	}
+
+	return nil
+}
+
+// NormalizeBusinessPartnerName reduces a corporate name to the form business partners are matched
+// on as possible duplicates: lower-cased, trimmed and with runs of whitespace collapsed
+func NormalizeBusinessPartnerName(name string) string {
+	return strings.ToLower(NormalizeCompanyName(name))
+}
+
+// FindDuplicateBusinessPartner looks for a business partner of the user's company whose name
+// matches corporateName once both are normalized with NormalizeBusinessPartnerName. It returns
+// nil when there is no match.
+func (s *InvoiceService) FindDuplicateBusinessPartner(userID uint, corporateName string) (*models.BusinessPartner, error) {
+	// Get user to get company ID
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	duplicate, err := s.repo.FindBusinessPartnerByNormalizedName(user.CompanyID, NormalizeBusinessPartnerName(corporateName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for duplicate business partner: %w", err)
+	}
+
+	return duplicate, nil
+}
+
+// ImportBusinessPartners creates several business partners for a user's company in a single
+// transaction
+func (s *InvoiceService) ImportBusinessPartners(userID uint, partners []*models.BusinessPartner) error {
+	for _, partner := range partners {
+		if err := models.ValidateContactInfo(partner.PhoneNumber, partner.PostalCode); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidContactInfo, partner.CorporateName, err)
+		}
+	}
+
+	// Get user to get company ID
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return fmt.Errorf("user not found: %w", err)
+	}
+
+	for _, partner := range partners {
+		partner.CompanyID = user.CompanyID
+		partner.CorporateName = strings.TrimSpace(partner.CorporateName)
+	}
+
+	if err := s.repo.CreateBusinessPartners(partners); err != nil {
+		return fmt.Errorf("failed to import business partners: %w", err)
+	}
+
+	return nil
+}
+
+// GetBusinessPartners retrieves business partners for a user's company
+func (s *InvoiceService) GetBusinessPartners(userID uint, req *models.GetBusinessPartnersRequest) ([]*models.BusinessPartner, error) {
+	// Get user to get company ID
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	partners, err := s.repo.GetBusinessPartnersByCompanyID(user.CompanyID, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get business partners: %w", err)
+	}
+	if partners == nil {
+		partners = []*models.BusinessPartner{}
+	}
+
+	return partners, nil
+}
+
+// PatchBusinessPartner updates the fields present in req on a business partner of the user's company
+func (s *InvoiceService) PatchBusinessPartner(userID uint, partnerID uint, req *models.BusinessPartnerPatchRequest) (*models.BusinessPartner, error) {
+	// Get user to get company ID
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	partner, err := s.repo.GetBusinessPartnerByID(partnerID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBusinessPartnerNotFound, err)
+	}
+	if partner.CompanyID != user.CompanyID {
+		return nil, ErrBusinessPartnerNotFound
+	}
+
+	req.Apply(partner)
+	partner.CorporateName = strings.TrimSpace(partner.CorporateName)
+	if err := models.ValidateContactInfo(partner.PhoneNumber, partner.PostalCode); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidContactInfo, err)
+	}
+
+	if err := s.repo.UpdateBusinessPartner(partner); err != nil {
+		return nil, fmt.Errorf("failed to update business partner: %w", err)
+	}
+
+	return partner, nil
+}
+
+// MergeBusinessPartners moves all invoices of the source business partner to the target, both
+// belonging to the admin's company, and optionally deletes the source afterwards
+func (s *InvoiceService) MergeBusinessPartners(userID uint, sourceID uint, targetID uint, deleteSource bool) (*models.BusinessPartnerMergeResult, error) {
+	admin, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+	if !admin.IsAdmin() {
+		return nil, fmt.Errorf("%w to merge business partners", ErrAdminRequired)
+	}
+
+	if sourceID == targetID {
+		return nil, ErrMergeIntoSelf
+	}
+
+	for _, partnerID := range []uint{sourceID, targetID} {
+		partner, err := s.repo.GetBusinessPartnerByID(partnerID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrBusinessPartnerNotFound, err)
+		}
+		if partner.CompanyID != admin.CompanyID {
+			return nil, ErrBusinessPartnerNotFound
+		}
+	}
+
+	// Invoices of a closed accounting period keep their business partner
+	reassigned, err := s.repo.MergeBusinessPartners(sourceID, targetID, deleteSource, s.periodOpensAt())
+	if err != nil {
+		if errors.Is(err, repository.ErrPeriodClosed) {
+			return nil, fmt.Errorf("%w: the source business partner has invoices issued on or before %s",
+				ErrPeriodClosed, s.config.App.AccountingCutoff.Format("2006-01-02"))
+		}
+		return nil, fmt.Errorf("failed to merge business partners: %w", err)
+	}
+
+	return &models.BusinessPartnerMergeResult{
+		SourceID:           sourceID,
+		TargetID:           targetID,
+		ReassignedInvoices: reassigned,
+		SourceDeleted:      deleteSource,
+	}, nil
+}
+
+// GetBusinessPartnerStats retrieves invoice aggregates for a business partner of the user's company
+func (s *InvoiceService) GetBusinessPartnerStats(userID uint, partnerID uint) (*models.BusinessPartnerStats, error) {
+	// Get user to get company ID
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	// Verify business partner belongs to the same company
+	partner, err := s.repo.GetBusinessPartnerByID(partnerID)
+	if err != nil {
+		return nil, fmt.Errorf("business partner not found: %w", err)
+	}
+
+	if partner.CompanyID != user.CompanyID {
+		return nil, fmt.Errorf("business partner not found")
+	}
+
+	stats, err := s.repo.GetBusinessPartnerStats(user.CompanyID, partnerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get business partner stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// GetBusinessPartnerInvoices retrieves all invoices of a business partner of the user's company, newest first
+func (s *InvoiceService) GetBusinessPartnerInvoices(userID uint, partnerID uint) ([]*models.Invoice, error) {
+	// Get user to get company ID
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	// Verify business partner belongs to the same company
+	partner, err := s.repo.GetBusinessPartnerByID(partnerID)
+	if err != nil {
+		return nil, fmt.Errorf("business partner not found: %w", err)
+	}
+
+	if partner.CompanyID != user.CompanyID {
+		return nil, fmt.Errorf("business partner not found")
+	}
+
+	invoices, err := s.repo.GetInvoicesByCompanyID(user.CompanyID, &models.GetInvoicesRequest{BusinessPartnerID: &partnerID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoices: %w", err)
+	}
+	if invoices == nil {
+		invoices = []*models.Invoice{}
+	}
+
+	return invoices, nil
+}
+
+// GetBusinessPartnerStatement retrieves the invoices issued to a business partner of the user's
+// company in the given month, oldest first. Drafts have not been issued and are left out.
+func (s *InvoiceService) GetBusinessPartnerStatement(userID uint, partnerID uint, year int, month time.Month) (*models.BusinessPartnerStatement, error) {
+	// Get user to get company ID
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	// Verify business partner belongs to the same company
+	partner, err := s.repo.GetBusinessPartnerByID(partnerID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBusinessPartnerNotFound, err)
+	}
+	if partner.CompanyID != user.CompanyID {
+		return nil, ErrBusinessPartnerNotFound
+	}
+
+	company, err := s.repo.GetCompanyByID(user.CompanyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get company: %w", err)
+	}
+
+	start := time.Date(year, month, 1, 0, 0, 0, 0, s.config.GetLocation())
+	end := start.AddDate(0, 1, 0)
+	invoices, err := s.repo.GetInvoicesByCompanyID(user.CompanyID, &models.GetInvoicesRequest{
+		BusinessPartnerID: &partnerID,
+		IssuedFrom:        &start,
+		IssuedBefore:      &end,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoices: %w", err)
+	}
+
+	statement := &models.BusinessPartnerStatement{
+		Company:         company,
+		BusinessPartner: partner,
+		Year:            year,
+		Month:           month,
+		Invoices:        []*models.Invoice{},
+	}
+	for _, invoice := range invoices {
+		if invoice.Status == models.InvoiceStatusDraft {
+			continue
+		}
+		statement.Invoices = append(statement.Invoices, invoice)
+	}
+	sort.SliceStable(statement.Invoices, func(i, j int) bool {
+		a, b := statement.Invoices[i], statement.Invoices[j]
+		if !a.IssueDate.Equal(b.IssueDate.Time) {
+			return a.IssueDate.Before(b.IssueDate.Time)
+		}
+		return a.ID < b.ID
+	})
+
+	return statement, nil
+}
+
+// CreateBankAccount adds a bank account to a business partner of the user's company
+func (s *InvoiceService) CreateBankAccount(userID uint, partnerID uint, req *models.BankAccountRequest) (*models.BusinessPartnerBankAccount, error) {
+	// Get user to get company ID
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	partner, err := s.repo.GetBusinessPartnerByID(partnerID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBusinessPartnerNotFound, err)
+	}
+	if partner.CompanyID != user.CompanyID {
+		return nil, ErrBusinessPartnerNotFound
+	}
+
+	account := &models.BusinessPartnerBankAccount{
+		BusinessPartnerID: partnerID,
+		BankName:          strings.TrimSpace(req.BankName),
+		BranchName:        strings.TrimSpace(req.BranchName),
+		AccountNumber:     req.AccountNumber,
+		AccountName:       strings.TrimSpace(req.AccountName),
+	}
+	if err := s.repo.CreateBankAccount(account); err != nil {
+		if errors.Is(err, repository.ErrDuplicateKey) {
+			return nil, ErrDuplicateBankAccount
+		}
+		return nil, fmt.Errorf("failed to create bank account: %w", err)
+	}
+
+	return account, nil
+}
+
+// UpdateBankAccount updates a bank account of a business partner of the user's company
+func (s *InvoiceService) UpdateBankAccount(userID uint, partnerID uint, accountID uint, req *models.BankAccountRequest) (*models.BusinessPartnerBankAccount, error) {
+	account, err := s.companyBankAccount(userID, partnerID, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	account.BankName = strings.TrimSpace(req.BankName)
+	account.BranchName = strings.TrimSpace(req.BranchName)
+	account.AccountNumber = req.AccountNumber
+	account.AccountName = strings.TrimSpace(req.AccountName)
+
+	if err := s.repo.UpdateBankAccount(account); err != nil {
+		if errors.Is(err, repository.ErrDuplicateKey) {
+			return nil, ErrDuplicateBankAccount
+		}
+		return nil, fmt.Errorf("failed to update bank account: %w", err)
+	}
+
+	return account, nil
+}
+
+// SetPrimaryBankAccount marks a bank account as the primary account of its business partner
+func (s *InvoiceService) SetPrimaryBankAccount(userID uint, partnerID uint, accountID uint) (*models.BusinessPartnerBankAccount, error) {
+	account, err := s.companyBankAccount(userID, partnerID, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.SetPrimaryBankAccount(partnerID, account.ID); err != nil {
+		return nil, fmt.Errorf("failed to set primary bank account: %w", err)
+	}
+
+	account.IsPrimary = true
+	return account, nil
+}
+
+// GetPrimaryBankAccount retrieves the primary bank account of a business partner of the user's
+// company, or nil when none is marked
+func (s *InvoiceService) GetPrimaryBankAccount(userID uint, partnerID uint) (*models.BusinessPartnerBankAccount, error) {
+	// Get user to get company ID
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	// Verify business partner belongs to the same company
+	partner, err := s.repo.GetBusinessPartnerByID(partnerID)
+	if err != nil {
+		return nil, fmt.Errorf("business partner not found: %w", err)
+	}
+	if partner.CompanyID != user.CompanyID {
+		return nil, fmt.Errorf("business partner not found")
+	}
+
+	account, err := s.repo.GetPrimaryBankAccount(partnerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get primary bank account: %w", err)
+	}
+
+	return account, nil
+}
+
+// companyBankAccount retrieves a bank account of the given business partner, provided the
+// partner belongs to the user's company
+func (s *InvoiceService) companyBankAccount(userID uint, partnerID uint, accountID uint) (*models.BusinessPartnerBankAccount, error) {
+	// Get user to get company ID
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	account, err := s.repo.GetBankAccountByID(accountID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBankAccountNotFound, err)
+	}
+	if account.BusinessPartnerID != partnerID {
+		return nil, ErrBankAccountNotFound
+	}
+
+	// Verify the account's business partner belongs to the same company
+	partner, err := s.repo.GetBusinessPartnerByID(account.BusinessPartnerID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBankAccountNotFound, err)
+	}
+	if partner.CompanyID != user.CompanyID {
+		return nil, ErrBankAccountNotFound
+	}
+
+	return account, nil
 }
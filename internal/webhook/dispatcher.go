@@ -0,0 +1,241 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"super-payment/internal/config"
+	"super-payment/internal/events"
+	"super-payment/internal/models"
+	"super-payment/internal/repository"
+	"syscall"
+	"time"
+)
+
+// EventHeader is the request header naming the event delivered in the body
+const EventHeader = "X-Webhook-Event"
+
+// SignatureHeader is the request header carrying "sha256=" and the hex HMAC-SHA256 of the body,
+// keyed with the company's webhook secret
+const SignatureHeader = "X-Webhook-Signature"
+
+// retryBatchSize is the most queued deliveries RetryDue attempts in one run
+const retryBatchSize = 100
+
+// maxBackoffDoublings bounds the exponential backoff so long retry chains cannot overflow
+const maxBackoffDoublings = 20
+
+// ErrPrivateTarget is returned when a webhook endpoint resolves to a loopback, private or
+// link-local address, which deliveries must not reach unless configured to
+var ErrPrivateTarget = errors.New("webhook endpoint is not a public address")
+
+// Payload is the JSON body POSTed to webhook endpoints
+type Payload struct {
+	Event string       `json:"event"`
+	Data  events.Event `json:"data"`
+}
+
+// Dispatcher delivers invoice events to the webhook endpoints of companies. A delivery that fails
+// is queued in the repository and retried by RetryDue with exponential backoff until it succeeds
+// or runs out of attempts and is marked dead.
+type Dispatcher struct {
+	repo   repository.Repository
+	config config.WebhookConfig
+	client *http.Client
+}
+
+// NewDispatcher creates a new webhook dispatcher
+func NewDispatcher(repo repository.Repository, cfg *config.Config) *Dispatcher {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !cfg.Webhook.AllowPrivateTargets {
+		// Addresses are checked as connections are made, so neither DNS answers nor redirects
+		// can steer a delivery into the internal network
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: rejectPrivateTargets}
+		transport.DialContext = dialer.DialContext
+		transport.Proxy = nil
+	}
+
+	return &Dispatcher{
+		repo:   repo,
+		config: cfg.Webhook,
+		client: &http.Client{Timeout: cfg.Webhook.Timeout, Transport: transport},
+	}
+}
+
+// rejectPrivateTargets fails connections to addresses outside the public internet
+func rejectPrivateTargets(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return fmt.Errorf("%w: %s", ErrPrivateTarget, host)
+	}
+	return nil
+}
+
+// Subscribe registers the dispatcher for the invoice events published on bus
+func (d *Dispatcher) Subscribe(bus *events.Bus) {
+	bus.Subscribe(events.NameInvoiceCreated, d.dispatch)
+	bus.Subscribe(events.NameInvoiceStatusChanged, d.dispatch)
+}
+
+// dispatch delivers an event to the webhook endpoint of its company, queueing it for retry when
+// the delivery fails
+func (d *Dispatcher) dispatch(event events.Event) error {
+	var companyID uint
+	switch e := event.(type) {
+	case events.InvoiceCreated:
+		companyID = e.CompanyID
+	case events.InvoiceStatusChanged:
+		companyID = e.CompanyID
+	default:
+		return fmt.Errorf("unsupported webhook event %s", event.Name())
+	}
+
+	settings, err := d.repo.GetCompanyWebhook(companyID)
+	if err != nil {
+		return err
+	}
+	if settings.URL == nil {
+		return nil
+	}
+
+	payload, err := json.Marshal(Payload{Event: event.Name(), Data: event})
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	deliveryErr := d.deliver(context.Background(), *settings.URL, settings.Secret, event.Name(), payload)
+	if deliveryErr == nil {
+		return nil
+	}
+
+	delivery := &models.WebhookDelivery{
+		CompanyID: companyID,
+		Event:     event.Name(),
+		URL:       *settings.URL,
+		Payload:   payload,
+		Status:    models.WebhookDeliveryStatusPending,
+	}
+	d.recordFailure(delivery, deliveryErr, time.Now())
+	if err := d.repo.CreateWebhookDelivery(delivery); err != nil {
+		return fmt.Errorf("failed to queue webhook delivery after %v: %w", deliveryErr, err)
+	}
+
+	log.Printf("Webhook: delivery of %s to company %d failed, queued as delivery %d: %v",
+		event.Name(), companyID, delivery.ID, deliveryErr)
+	return nil
+}
+
+// RetryDue retries the queued deliveries whose next retry is due at now and returns the number
+// that were delivered
+func (d *Dispatcher) RetryDue(ctx context.Context, now time.Time) (int, error) {
+	deliveries, err := d.repo.GetDueWebhookDeliveries(now, retryBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get due webhook deliveries: %w", err)
+	}
+
+	delivered := 0
+	for _, delivery := range deliveries {
+		if err := ctx.Err(); err != nil {
+			return delivered, err
+		}
+
+		// Retries are signed with the company's current secret
+		settings, err := d.repo.GetCompanyWebhook(delivery.CompanyID)
+		if err != nil {
+			return delivered, err
+		}
+
+		if err := d.deliver(ctx, delivery.URL, settings.Secret, delivery.Event, delivery.Payload); err != nil {
+			d.recordFailure(delivery, err, now)
+		} else {
+			deliveredAt := models.NewTimestamp(time.Now())
+			delivery.Attempts++
+			delivery.Status = models.WebhookDeliveryStatusDelivered
+			delivery.NextRetryAt = nil
+			delivery.DeliveredAt = &deliveredAt
+			delivered++
+		}
+
+		if err := d.repo.UpdateWebhookDelivery(delivery); err != nil {
+			return delivered, err
+		}
+	}
+
+	return delivered, nil
+}
+
+// recordFailure counts a failed attempt of delivery and schedules its next retry, or marks it
+// dead once it has used up its attempts
+func (d *Dispatcher) recordFailure(delivery *models.WebhookDelivery, err error, now time.Time) {
+	message := err.Error()
+	delivery.Attempts++
+	delivery.LastError = &message
+
+	if delivery.Attempts >= d.config.MaxAttempts {
+		delivery.Status = models.WebhookDeliveryStatusDead
+		delivery.NextRetryAt = nil
+		return
+	}
+
+	nextRetryAt := models.NewTimestamp(now.Add(d.backoff(delivery.Attempts)))
+	delivery.NextRetryAt = &nextRetryAt
+}
+
+// backoff returns the delay before retrying a delivery that has failed the given number of
+// attempts: RetryBackoff after the first, doubling with each further attempt
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	doublings := attempts - 1
+	if doublings > maxBackoffDoublings {
+		doublings = maxBackoffDoublings
+	}
+	return d.config.RetryBackoff << doublings
+}
+
+// deliver POSTs payload to url, signed with secret when the company has one, failing unless the
+// endpoint answers with a 2xx status
+func (d *Dispatcher) deliver(ctx context.Context, url string, secret *string, event string, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("invalid webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event)
+	if secret != nil {
+		req.Header.Set(SignatureHeader, Sign(*secret, payload))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Drain the response so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook endpoint responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// Sign returns the SignatureHeader value of payload for secret, which endpoints compare against
+// the header to check that a delivery is genuine
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,12 @@
+-- Create invoice_status_histories table (audit trail of invoice status transitions)
+CREATE TABLE invoice_status_histories (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    invoice_id INT NOT NULL,
+    from_status VARCHAR(20) NOT NULL,
+    to_status VARCHAR(20) NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    user_id INT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (invoice_id) REFERENCES invoices(id) ON DELETE CASCADE,
+    INDEX idx_invoice_status_histories_invoice_id (invoice_id)
+);
@@ -0,0 +1,4 @@
+-- Add draft status for invoices prepared before they are issued
+-- (appended so the stored values of existing statuses are unchanged)
+ALTER TABLE invoices
+    MODIFY COLUMN status ENUM('unprocessed', 'processing', 'paid', 'error', 'draft') NOT NULL DEFAULT 'unprocessed';
@@ -0,0 +1,3 @@
+-- Add free-text memo (e.g. PO number) to invoices
+ALTER TABLE invoices
+    ADD COLUMN memo VARCHAR(500) NULL;
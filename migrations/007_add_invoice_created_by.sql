@@ -0,0 +1,5 @@
+-- Record which user created each invoice (NULL for invoices created before this migration)
+ALTER TABLE invoices
+    ADD COLUMN created_by_user_id INT NULL;
+
+CREATE INDEX idx_invoices_created_by_user_id ON invoices (created_by_user_id);
@@ -0,0 +1,6 @@
+-- Company roles: admins manage their company's data, members see their company's invoices.
+-- Existing users registered their company, so they become its admins.
+ALTER TABLE users
+    ADD COLUMN role ENUM('member', 'admin') NOT NULL DEFAULT 'member';
+
+UPDATE users SET role = 'admin';
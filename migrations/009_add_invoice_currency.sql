@@ -0,0 +1,3 @@
+-- Add ISO 4217 currency to invoices (existing invoices are in yen)
+ALTER TABLE invoices
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'JPY';
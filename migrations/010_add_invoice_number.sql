@@ -0,0 +1,7 @@
+-- Add a human-friendly invoice number, backfilled for existing invoices
+ALTER TABLE invoices
+    ADD COLUMN invoice_number VARCHAR(32) NOT NULL DEFAULT '';
+
+UPDATE invoices SET invoice_number = CONCAT('INV-', LPAD(id, 6, '0'));
+
+CREATE INDEX idx_invoices_company_number ON invoices (company_id, invoice_number);
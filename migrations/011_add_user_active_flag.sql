@@ -0,0 +1,3 @@
+-- Deactivated users keep their data but can no longer log in or use their tokens
+ALTER TABLE users
+    ADD COLUMN is_active BOOLEAN NOT NULL DEFAULT TRUE;
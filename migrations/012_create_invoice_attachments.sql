@@ -0,0 +1,12 @@
+-- Create invoice_attachments table (metadata of documents kept in external storage)
+CREATE TABLE invoice_attachments (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    invoice_id INT NOT NULL,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    storage_url VARCHAR(2048) NOT NULL,
+    size BIGINT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (invoice_id) REFERENCES invoices(id) ON DELETE CASCADE,
+    INDEX idx_invoice_attachments_invoice_id (invoice_id)
+);
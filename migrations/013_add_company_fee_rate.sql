@@ -0,0 +1,3 @@
+-- Negotiated fee rate per company. NULL charges the default rate.
+ALTER TABLE companies
+    ADD COLUMN fee_rate DECIMAL(5, 4) NULL;
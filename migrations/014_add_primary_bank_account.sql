@@ -0,0 +1,3 @@
+-- Mark the bank account payments to a business partner are made to (at most one per partner)
+ALTER TABLE business_partner_bank_accounts
+    ADD COLUMN is_primary BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,6 @@
+-- Consumption tax rate and payment terms per company. NULL uses the application defaults.
+ALTER TABLE companies
+    ADD COLUMN consumption_tax_rate DECIMAL(5, 4) NULL;
+
+ALTER TABLE companies
+    ADD COLUMN payment_term_days INT NULL;
@@ -0,0 +1,22 @@
+-- Register each bank account at most once per business partner. Duplicates added before the
+-- constraint are removed first, keeping the oldest and moving any primary flag onto it.
+UPDATE business_partner_bank_accounts keep_account
+JOIN business_partner_bank_accounts duplicate
+    ON duplicate.business_partner_id = keep_account.business_partner_id
+    AND duplicate.bank_name = keep_account.bank_name
+    AND duplicate.branch_name = keep_account.branch_name
+    AND duplicate.account_number = keep_account.account_number
+    AND duplicate.id > keep_account.id
+SET keep_account.is_primary = TRUE
+WHERE duplicate.is_primary = TRUE;
+
+DELETE duplicate FROM business_partner_bank_accounts duplicate
+JOIN business_partner_bank_accounts keep_account
+    ON keep_account.business_partner_id = duplicate.business_partner_id
+    AND keep_account.bank_name = duplicate.bank_name
+    AND keep_account.branch_name = duplicate.branch_name
+    AND keep_account.account_number = duplicate.account_number
+    AND keep_account.id < duplicate.id;
+
+CREATE UNIQUE INDEX uq_bank_accounts_partner_account
+    ON business_partner_bank_accounts (business_partner_id, bank_name, branch_name, account_number);
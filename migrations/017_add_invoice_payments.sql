@@ -0,0 +1,17 @@
+-- Track installment payments: the amount paid so far on each invoice, a partially_paid status
+-- (appended so the stored values of existing statuses are unchanged) and the individual payments
+ALTER TABLE invoices
+    ADD COLUMN paid_amount DECIMAL(15,2) NOT NULL DEFAULT 0 AFTER invoice_amount,
+    MODIFY COLUMN status ENUM('unprocessed', 'processing', 'paid', 'error', 'draft', 'partially_paid') NOT NULL DEFAULT 'unprocessed';
+
+UPDATE invoices SET paid_amount = invoice_amount WHERE status = 'paid';
+
+CREATE TABLE invoice_payments (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    invoice_id INT NOT NULL,
+    amount DECIMAL(15,2) NOT NULL,
+    user_id INT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (invoice_id) REFERENCES invoices(id) ON DELETE CASCADE,
+    INDEX idx_invoice_payments_invoice_id (invoice_id)
+);
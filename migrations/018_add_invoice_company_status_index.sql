@@ -0,0 +1,3 @@
+-- Serve the invoice list filtered by status, the most frequent listing, from an index: it matches
+-- company and status and yields invoices already in payment due date order
+CREATE INDEX idx_invoices_company_status_due ON invoices (company_id, status, payment_due_date);
@@ -0,0 +1,3 @@
+-- Soft-delete invoices: deleted invoices keep their row, and with it their invoice number and
+-- history, but are left out of every invoice read
+ALTER TABLE invoices ADD COLUMN deleted_at TIMESTAMP NULL DEFAULT NULL;
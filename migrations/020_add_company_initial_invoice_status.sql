@@ -0,0 +1,3 @@
+-- Status new invoices of a company start in. NULL starts them unprocessed.
+ALTER TABLE companies
+    ADD COLUMN initial_invoice_status VARCHAR(20) NULL;
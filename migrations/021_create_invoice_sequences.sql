@@ -0,0 +1,13 @@
+-- Number invoices from a per-company counter instead of the global invoice ID. Each company's
+-- counter starts after the highest number it was already issued, so numbers stay unique.
+CREATE TABLE invoice_sequences (
+    company_id INT NOT NULL PRIMARY KEY,
+    seq INT NOT NULL,
+    FOREIGN KEY (company_id) REFERENCES companies(id) ON DELETE CASCADE
+);
+
+INSERT INTO invoice_sequences (company_id, seq)
+SELECT company_id, MAX(id) FROM invoices GROUP BY company_id;
+
+DROP INDEX idx_invoices_company_number ON invoices;
+CREATE UNIQUE INDEX uq_invoices_company_number ON invoices (company_id, invoice_number);
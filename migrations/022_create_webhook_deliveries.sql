@@ -0,0 +1,23 @@
+-- Webhook endpoint invoice events of a company are delivered to (NULL disables webhooks), and the
+-- deliveries that failed. Failed deliveries are retried with exponential backoff until they
+-- succeed or run out of attempts and are marked dead.
+ALTER TABLE companies
+    ADD COLUMN webhook_url VARCHAR(2048) NULL;
+
+CREATE TABLE webhook_deliveries (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    company_id INT NOT NULL,
+    event VARCHAR(64) NOT NULL,
+    url VARCHAR(2048) NOT NULL,
+    payload TEXT NOT NULL,
+    status ENUM('pending', 'delivered', 'dead') NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NULL,
+    next_retry_at TIMESTAMP NULL,
+    delivered_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (company_id) REFERENCES companies(id) ON DELETE CASCADE,
+    INDEX idx_webhook_deliveries_status_retry (status, next_retry_at),
+    INDEX idx_webhook_deliveries_company (company_id, id)
+);
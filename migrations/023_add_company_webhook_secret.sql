@@ -0,0 +1,4 @@
+-- Secret each company's webhook deliveries are signed with, generated when the webhook endpoint
+-- is first set
+ALTER TABLE companies
+    ADD COLUMN webhook_secret VARCHAR(100) NULL;
@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"super-payment/internal/api"
 	"super-payment/internal/config"
+	"super-payment/internal/middleware"
 	"super-payment/internal/models"
 	"super-payment/internal/repository"
 	"super-payment/internal/service"
@@ -16,14 +19,18 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // APITestSuite defines the test suite
 type APITestSuite struct {
 	suite.Suite
 	router      *gin.Engine
+	repo        *repository.MySQLRepository
+	cfg         *config.Config
 	authToken   string
 	testUserID  uint
 	testCompany models.Company
@@ -34,13 +41,21 @@ type APITestSuite struct {
 func (suite *APITestSuite) SetupSuite() {
 	// Load test configuration from environment variables
 	cfg := config.Load()
+	if cfg.Admin.Token == "" {
+		cfg.Admin.Token = "test-admin-token"
+	}
+	if cfg.JWT.Secret == "" {
+		cfg.JWT.Secret = "test-jwt-secret-4d1f9a"
+	}
+	suite.cfg = cfg
 
 	// Initialize repository (you might want to use a test database or mock)
 	repo, err := repository.NewMySQLRepository(cfg.GetDSN())
-	suite.NoError(err)
+	suite.Require().NoError(err)
+	suite.repo = repo
 
 	// Initialize service
-	svc := service.NewInvoiceService(repo)
+	svc := service.NewInvoiceService(repo, cfg)
 
 	// Initialize handler
 	handler := api.NewHandler(svc, cfg)
@@ -94,6 +109,158 @@ func (suite *APITestSuite) createTestUser() {
 	suite.testCompany = *response.User.Company
 }
 
+// createTestBusinessPartner creates a business partner for the test user and returns its ID
+func (suite *APITestSuite) createTestBusinessPartner(corporateName string) uint {
+	partnerData := models.BusinessPartnerCreateRequest{
+		CorporateName:  corporateName,
+		Representative: "Helper Partner Rep",
+		PhoneNumber:    "03-5555-5555",
+		PostalCode:     "106-0001",
+		Address:        "Tokyo, Helper Partner Address 7-7-7",
+	}
+
+	jsonData, _ := json.Marshal(partnerData)
+	req, _ := http.NewRequest("POST", "/api/business-partners", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusCreated, w.Code)
+
+	var response models.SuccessResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+
+	partnerMap := response.Data.(map[string]interface{})
+	return uint(partnerMap["id"].(float64))
+}
+
+// createOtherCompanyPartner creates a company other than the test user's with a business partner
+// and returns the partner, whose CompanyID identifies the new company
+func (suite *APITestSuite) createOtherCompanyPartner(name string) *models.BusinessPartner {
+	company := &models.Company{
+		CorporateName:  fmt.Sprintf("%s Company %d", name, time.Now().UnixNano()),
+		Representative: name + " Representative",
+		PhoneNumber:    "03-9999-7777",
+		PostalCode:     "100-0002",
+		Address:        "Tokyo, Other Address 2-2-2",
+	}
+	suite.Require().NoError(suite.repo.CreateCompany(company))
+
+	partner := &models.BusinessPartner{
+		CompanyID:      company.ID,
+		CorporateName:  name + " Partner",
+		Representative: name + " Partner Representative",
+		PhoneNumber:    "03-7777-6666",
+		PostalCode:     "100-0003",
+		Address:        "Tokyo, Partner Address 3-3-3",
+	}
+	suite.Require().NoError(suite.repo.CreateBusinessPartner(partner))
+	return partner
+}
+
+// insertTestInvoice stores an invoice directly through the repository, bypassing API validation
+// so that tests can seed past due dates and arbitrary statuses
+func (suite *APITestSuite) insertTestInvoice(partnerID uint, paymentAmount float64, dueDate time.Time, status models.InvoiceStatus) *models.Invoice {
+	return suite.insertIssuedTestInvoice(partnerID, paymentAmount, time.Now(), dueDate, status)
+}
+
+// insertIssuedTestInvoice inserts an invoice with the given issue date directly through the repository
+func (suite *APITestSuite) insertIssuedTestInvoice(partnerID uint, paymentAmount float64, issueDate, dueDate time.Time, status models.InvoiceStatus) *models.Invoice {
+	fee, tax, total := service.CalculateInvoice(paymentAmount, service.DefaultFeeRate, service.DefaultConsumptionTaxRate)
+	invoice := &models.Invoice{
+		CompanyID:          suite.testCompany.ID,
+		BusinessPartnerID:  partnerID,
+		IssueDate:          models.NewTimestamp(issueDate),
+		PaymentAmount:      paymentAmount,
+		Fee:                fee,
+		FeeRate:            service.DefaultFeeRate,
+		ConsumptionTax:     tax,
+		ConsumptionTaxRate: service.DefaultConsumptionTaxRate,
+		InvoiceAmount:      total,
+		PaymentDueDate:     models.NewTimestamp(dueDate),
+		Status:             status,
+		Currency:           models.DefaultCurrency,
+	}
+	if status == models.InvoiceStatusPaid {
+		invoice.PaidAmount = total
+	}
+	suite.Require().NoError(suite.repo.CreateInvoice(invoice))
+	return invoice
+}
+
+// createTestCompanyUser adds a user with the given role to a company and returns the user with an
+// auth token
+func (suite *APITestSuite) createTestCompanyUser(companyID uint, fullName string, role models.UserRole) (*models.User, string) {
+	user := &models.User{
+		CompanyID: companyID,
+		FullName:  fullName,
+		Email:     fmt.Sprintf("colleague%d@example.com", time.Now().UnixNano()),
+		Password:  "password123",
+		Role:      role,
+	}
+	suite.Require().NoError(service.NewInvoiceService(suite.repo, suite.cfg).RegisterUser(user))
+
+	token, err := middleware.GenerateJWT(user, suite.cfg)
+	suite.Require().NoError(err)
+	return user, token
+}
+
+// createTestInvoice creates an invoice through the API and returns its ID
+func (suite *APITestSuite) createTestInvoice(partnerID uint, paymentAmount float64, dueDate time.Time) uint {
+	return suite.createTestInvoiceAs(suite.authToken, partnerID, paymentAmount, dueDate)
+}
+
+// createTestInvoiceAs creates an invoice through the API as the user of the given token and returns its ID
+func (suite *APITestSuite) createTestInvoiceAs(token string, partnerID uint, paymentAmount float64, dueDate time.Time) uint {
+	invoiceData := models.CreateInvoiceRequest{
+		BusinessPartnerID: partnerID,
+		PaymentAmount:     paymentAmount,
+		PaymentDueDate:    dueDate,
+	}
+
+	jsonData, _ := json.Marshal(invoiceData)
+	req, _ := http.NewRequest("POST", "/api/invoices", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	var response models.SuccessResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+
+	invoiceMap := response.Data.(map[string]interface{})
+	return uint(invoiceMap["id"].(float64))
+}
+
+// listInvoiceIDs lists invoices with the given query string and returns their IDs
+func (suite *APITestSuite) listInvoiceIDs(query string) []uint {
+	return suite.listInvoiceIDsAs(suite.authToken, query)
+}
+
+// listInvoiceIDsAs lists invoices as the user of the given token and returns their IDs
+func (suite *APITestSuite) listInvoiceIDsAs(token, query string) []uint {
+	req, _ := http.NewRequest("GET", "/api/invoices?"+query, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		Data []models.Invoice `json:"data"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+
+	ids := make([]uint, 0, len(response.Data))
+	for _, invoice := range response.Data {
+		ids = append(ids, invoice.ID)
+	}
+	return ids
+}
+
 // TestHealthCheck tests the health check endpoint
 func (suite *APITestSuite) TestHealthCheck() {
 	req, _ := http.NewRequest("GET", "/health", nil)
@@ -108,6 +275,242 @@ func (suite *APITestSuite) TestHealthCheck() {
 	assert.Equal(suite.T(), "ok", response["status"])
 }
 
+// TestDetailedHealthCheck tests the operator health endpoint reporting database and pool details
+func (suite *APITestSuite) TestDetailedHealthCheck() {
+	detailedHealth := func(router http.Handler, adminToken string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/health/detailed", nil)
+		if adminToken != "" {
+			req.Header.Set("X-Admin-Token", adminToken)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(suite.T(), http.StatusForbidden, detailedHealth(suite.router, "").Code)
+	assert.Equal(suite.T(), http.StatusForbidden, detailedHealth(suite.router, "wrong-token").Code)
+
+	w := detailedHealth(suite.router, suite.cfg.Admin.Token)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	var raw map[string]interface{}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &raw))
+	for _, key := range []string{"status", "timestamp", "started_at", "uptime_seconds", "database"} {
+		assert.Contains(suite.T(), raw, key)
+	}
+	database := raw["database"].(map[string]interface{})
+	suite.Require().Contains(database, "pool")
+	pool := database["pool"].(map[string]interface{})
+	for _, key := range []string{"max_open", "open", "in_use", "idle", "wait_count", "wait_duration_ms"} {
+		assert.Contains(suite.T(), pool, key)
+	}
+
+	var details models.HealthDetails
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &details))
+	assert.Equal(suite.T(), "ok", details.Status)
+	assert.True(suite.T(), details.Database.Connected)
+	assert.Empty(suite.T(), details.Database.Error)
+	assert.GreaterOrEqual(suite.T(), details.UptimeSeconds, int64(0))
+	assert.GreaterOrEqual(suite.T(), details.Database.Pool.Open, 1)
+	assert.Equal(suite.T(), details.Database.Pool.Open, details.Database.Pool.InUse+details.Database.Pool.Idle)
+
+	// A lost database is reported with a 503 so load balancers and dashboards can react
+	repo, err := repository.NewMySQLRepository(suite.cfg.GetDSN())
+	suite.Require().NoError(err)
+	suite.Require().NoError(repo.Close())
+	router := api.NewHandler(service.NewInvoiceService(repo, suite.cfg), suite.cfg).SetupRoutes()
+
+	w = detailedHealth(router, suite.cfg.Admin.Token)
+	assert.Equal(suite.T(), http.StatusServiceUnavailable, w.Code)
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &details))
+	assert.Equal(suite.T(), "unavailable", details.Status)
+	assert.False(suite.T(), details.Database.Connected)
+	assert.NotEmpty(suite.T(), details.Database.Error)
+}
+
+// TestQueryLogging tests that a repository in debug mode logs executed statements
+func (suite *APITestSuite) TestQueryLogging() {
+	var buf bytes.Buffer
+	repo, err := repository.NewMySQLRepositoryWithQueryLog(suite.cfg.GetDSN(), log.New(&buf, "", 0))
+	suite.Require().NoError(err)
+	defer repo.Close()
+
+	company, err := repo.GetCompanyByID(suite.testCompany.ID)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), suite.testCompany.ID, company.ID)
+
+	entry := buf.String()
+	assert.Contains(suite.T(), entry, "FROM companies WHERE id = ?")
+	assert.Contains(suite.T(), entry, fmt.Sprintf("[%d]", suite.testCompany.ID))
+
+	// Password hashes are masked
+	buf.Reset()
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	suite.Require().NoError(err)
+	user := &models.User{
+		CompanyID: suite.testCompany.ID,
+		FullName:  "Query Log User",
+		Email:     fmt.Sprintf("querylog%d@example.com", time.Now().UnixNano()),
+		Password:  string(hash),
+		Role:      models.UserRoleMember,
+	}
+	suite.Require().NoError(repo.CreateUser(user))
+	assert.Contains(suite.T(), buf.String(), "INSERT INTO users")
+	assert.Contains(suite.T(), buf.String(), "***")
+	assert.NotContains(suite.T(), buf.String(), string(hash))
+
+	// Webhook secrets are masked
+	company = &models.Company{
+		CorporateName:  fmt.Sprintf("Query Log Company %d", time.Now().UnixNano()),
+		Representative: "Query Log Representative",
+		PhoneNumber:    "03-9999-9997",
+		PostalCode:     "100-0009",
+		Address:        "Tokyo, Query Log Address 9-9-7",
+	}
+	suite.Require().NoError(suite.repo.CreateCompany(company))
+	buf.Reset()
+	endpoint := "https://example.com/querylog-hook"
+	secret := models.WebhookSecretPrefix + "querylogsecret"
+	suite.Require().NoError(repo.SetCompanyWebhookURL(company.ID, &endpoint, secret))
+	assert.Contains(suite.T(), buf.String(), "UPDATE companies")
+	assert.Contains(suite.T(), buf.String(), "***")
+	assert.NotContains(suite.T(), buf.String(), secret)
+
+	// Without a logger nothing is logged
+	buf.Reset()
+	quiet, err := repository.NewMySQLRepositoryWithQueryLog(suite.cfg.GetDSN(), nil)
+	suite.Require().NoError(err)
+	defer quiet.Close()
+	_, err = quiet.GetCompanyByID(suite.testCompany.ID)
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), buf.String())
+}
+
+// TestSlowQueryWarning tests that statements reaching the slow query threshold are logged as warnings
+func (suite *APITestSuite) TestSlowQueryWarning() {
+	slowRepo := func(threshold time.Duration, slowLog *bytes.Buffer) *repository.MySQLRepository {
+		repo, err := repository.NewMySQLRepositoryWithLogging(suite.cfg.GetDSN(), repository.LogOptions{
+			SlowQueryThreshold: threshold,
+			SlowQueryLog:       log.New(slowLog, "", 0),
+		})
+		suite.Require().NoError(err)
+		return repo
+	}
+
+	// A threshold no statement can stay under makes every statement slow
+	var buf bytes.Buffer
+	repo := slowRepo(time.Nanosecond, &buf)
+	defer repo.Close()
+	_, err := repo.GetCompanyByID(suite.testCompany.ID)
+	suite.Require().NoError(err)
+	assert.Contains(suite.T(), buf.String(), "WARNING: slow query over 1ns")
+	assert.Contains(suite.T(), buf.String(), "FROM companies WHERE id = ?")
+	assert.Contains(suite.T(), buf.String(), fmt.Sprintf("[%d]", suite.testCompany.ID))
+
+	// Statements under the threshold are not logged
+	buf.Reset()
+	fast := slowRepo(time.Hour, &buf)
+	defer fast.Close()
+	_, err = fast.GetCompanyByID(suite.testCompany.ID)
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), buf.String())
+}
+
+// TestIdempotencyKeyCorrelation tests that a client-supplied Idempotency-Key is logged with the
+// request and echoed on the error response
+func (suite *APITestSuite) TestIdempotencyKeyCorrelation() {
+	// The request logger writes to gin.DefaultWriter as it was when the router was set up
+	var buf bytes.Buffer
+	original := gin.DefaultWriter
+	gin.DefaultWriter = &buf
+	router := api.NewHandler(service.NewInvoiceService(suite.repo, suite.cfg), suite.cfg).SetupRoutes()
+	gin.DefaultWriter = original
+
+	req, _ := http.NewRequest("GET", "/api/invoices/999999", nil)
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	req.Header.Set(middleware.IdempotencyKeyHeader, "retry-7f3a")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+	assert.Equal(suite.T(), "retry-7f3a", w.Header().Get(middleware.IdempotencyKeyHeader))
+	assert.Contains(suite.T(), buf.String(), "/api/invoices/999999")
+	assert.Contains(suite.T(), buf.String(), `idempotency_key="retry-7f3a"`)
+
+	// Requests without a key log no idempotency_key field
+	buf.Reset()
+	req, _ = http.NewRequest("GET", "/api/invoices/999999", nil)
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Empty(suite.T(), w.Header().Get(middleware.IdempotencyKeyHeader))
+	assert.Contains(suite.T(), buf.String(), "/api/invoices/999999")
+	assert.NotContains(suite.T(), buf.String(), "idempotency_key")
+}
+
+// TestCreateInvoiceWithoutReload tests that the created invoice is returned with its company and
+// business partner without reading the invoice back from the database
+func (suite *APITestSuite) TestCreateInvoiceWithoutReload() {
+	var buf bytes.Buffer
+	repo, err := repository.NewMySQLRepositoryWithQueryLog(suite.cfg.GetDSN(), log.New(&buf, "", 0))
+	suite.Require().NoError(err)
+	defer repo.Close()
+	router := api.NewHandler(service.NewInvoiceService(repo, suite.cfg), suite.cfg).SetupRoutes()
+
+	partnerID := suite.createTestBusinessPartner("No Reload Partner")
+	jsonData, _ := json.Marshal(models.CreateInvoiceRequest{
+		BusinessPartnerID: partnerID,
+		PaymentAmount:     10000,
+		PaymentDueDate:    time.Now().AddDate(0, 1, 0),
+	})
+	req, _ := http.NewRequest("POST", "/api/invoices", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	buf.Reset()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	// The invoice is only read with its company and partner when loading it by ID
+	assert.NotContains(suite.T(), buf.String(), "FROM invoices i JOIN companies c")
+
+	var response struct {
+		Data models.Invoice `json:"data"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	created := response.Data
+	suite.Require().NotNil(created.Company)
+	suite.Require().NotNil(created.BusinessPartner)
+	assert.Equal(suite.T(), suite.testCompany.CorporateName, created.Company.CorporateName)
+	assert.Equal(suite.T(), "No Reload Partner", created.BusinessPartner.CorporateName)
+
+	// The response matches the stored invoice, apart from the sub-second rounding of its timestamps
+	stored, err := suite.repo.GetInvoiceByID(created.ID)
+	suite.Require().NoError(err)
+	assert.WithinDuration(suite.T(), stored.CreatedAt.Time, created.CreatedAt.Time, time.Second)
+	created.CreatedAt, created.UpdatedAt = stored.CreatedAt, stored.UpdatedAt
+	storedJSON, _ := json.Marshal(stored)
+	createdJSON, _ := json.Marshal(created)
+	assert.JSONEq(suite.T(), string(storedJSON), string(createdJSON))
+}
+
+// TestVersion tests the build information endpoint
+func (suite *APITestSuite) TestVersion() {
+	req, _ := http.NewRequest("GET", "/version", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(suite.T(), err)
+	for _, key := range []string{"version", "commit", "build_time"} {
+		assert.NotEmpty(suite.T(), response[key], "missing %s", key)
+	}
+}
+
 // TestUserRegistration tests user registration with a different user
 func (suite *APITestSuite) TestUserRegistration() {
 	// Generate unique email to avoid conflicts with setup user
@@ -145,6 +548,83 @@ func (suite *APITestSuite) TestUserRegistration() {
 	assert.Equal(suite.T(), uniqueEmail, response.User.Email)
 }
 
+// TestPasswordPolicy tests that registration enforces the configured password policy
+func (suite *APITestSuite) TestPasswordPolicy() {
+	register := func(router http.Handler, password string) *httptest.ResponseRecorder {
+		registerData := map[string]interface{}{
+			"company": map[string]interface{}{
+				"corporate_name": "Password Policy Company",
+				"representative": "Password Policy Rep",
+				"phone_number":   "03-9876-0000",
+				"postal_code":    "101-0002",
+				"address":        "Tokyo, Password Policy Address 3-3-3",
+			},
+			"user": map[string]interface{}{
+				"full_name": "Password Policy User",
+				"email":     fmt.Sprintf("policy%d@example.com", time.Now().UnixNano()),
+				"password":  password,
+			},
+		}
+
+		jsonData, _ := json.Marshal(registerData)
+		req, _ := http.NewRequest("POST", "/api/auth/register", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// The default policy only requires 8 characters
+	w := register(suite.router, "short")
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "at least 8 characters")
+	assert.Equal(suite.T(), http.StatusCreated, register(suite.router, "alllowercase").Code)
+
+	cfg := *suite.cfg
+	cfg.Auth.PasswordPolicy = config.PasswordPolicy{
+		MinLength:     10,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+	}
+	svc := service.NewInvoiceService(suite.repo, &cfg)
+	router := api.NewHandler(svc, &cfg).SetupRoutes()
+
+	testCases := []struct {
+		password string
+		unmet    []string
+	}{
+		{"Sh0rt!", []string{"at least 10 characters"}},
+		{"lowercase1!", []string{"an uppercase letter"}},
+		{"UPPERCASE1!", []string{"a lowercase letter"}},
+		{"NoDigitsHere!", []string{"a digit"}},
+		{"NoSymbols123", []string{"a symbol"}},
+		{"password", []string{"at least 10 characters", "an uppercase letter", "a digit", "a symbol"}},
+	}
+	for _, tc := range testCases {
+		w := register(router, tc.password)
+		assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code, tc.password)
+
+		var response models.ErrorResponse
+		suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(suite.T(), "validation_error", response.Error, tc.password)
+		assert.Equal(suite.T(), "password does not meet the password policy: password must contain "+strings.Join(tc.unmet, ", "),
+			response.Message, tc.password)
+	}
+
+	assert.Equal(suite.T(), http.StatusCreated, register(router, "Compliant#Pass1").Code)
+
+	// The policy also applies to users created outside registration
+	err := svc.RegisterUser(&models.User{
+		CompanyID: suite.testCompany.ID,
+		FullName:  "Weak Password User",
+		Email:     fmt.Sprintf("weak%d@example.com", time.Now().UnixNano()),
+		Password:  "password123",
+	})
+	assert.ErrorIs(suite.T(), err, service.ErrWeakPassword)
+}
+
 // TestUserLogin tests user login with the test user created in setup
 func (suite *APITestSuite) TestUserLogin() {
 	loginData := models.LoginRequest{
@@ -167,6 +647,457 @@ func (suite *APITestSuite) TestUserLogin() {
 	assert.NotEmpty(suite.T(), response.Token)
 }
 
+// TestLoginLockout tests that repeated failed logins lock the account until the cooldown elapses
+func (suite *APITestSuite) TestLoginLockout() {
+	cfg := *suite.cfg
+	cfg.Auth.MaxFailedLogins = 3
+	cfg.Auth.FailedLoginWindow = time.Minute
+	cfg.Auth.LockoutDuration = 500 * time.Millisecond
+	router := api.NewHandler(service.NewInvoiceService(suite.repo, &cfg), &cfg).SetupRoutes()
+
+	login := func(password string) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(models.LoginRequest{
+			Email:    suite.testUser.Email,
+			Password: password,
+		})
+		req, _ := http.NewRequest("POST", "/api/auth/login", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// A successful login resets the failure count
+	assert.Equal(suite.T(), http.StatusUnauthorized, login("wrongpassword").Code)
+	assert.Equal(suite.T(), http.StatusUnauthorized, login("wrongpassword").Code)
+	assert.Equal(suite.T(), http.StatusOK, login("password123").Code)
+
+	for i := 0; i < cfg.Auth.MaxFailedLogins; i++ {
+		assert.Equal(suite.T(), http.StatusUnauthorized, login("wrongpassword").Code)
+	}
+
+	// While locked even the correct password is refused
+	w := login("password123")
+	assert.Equal(suite.T(), http.StatusLocked, w.Code)
+	var response models.ErrorResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), "account_locked", response.Error)
+
+	time.Sleep(cfg.Auth.LockoutDuration)
+	assert.Equal(suite.T(), http.StatusOK, login("password123").Code)
+}
+
+// TestEmailAvailable tests checking whether an email is already registered, and that the check is rate limited
+func (suite *APITestSuite) TestEmailAvailable() {
+	cfg := *suite.cfg
+	cfg.Auth.EmailCheckRateLimitPerMinute = 3
+	router := api.NewHandler(service.NewInvoiceService(suite.repo, &cfg), &cfg).SetupRoutes()
+
+	checkEmail := func(email string) (*httptest.ResponseRecorder, models.EmailAvailabilityResponse) {
+		req, _ := http.NewRequest("GET", "/api/auth/email-available?email="+url.QueryEscape(email), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var response models.EmailAvailabilityResponse
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+
+	w, response := checkEmail(suite.testUser.Email)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	assert.False(suite.T(), response.Available)
+
+	w, response = checkEmail(fmt.Sprintf("unused%d@example.com", time.Now().UnixNano()))
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	assert.True(suite.T(), response.Available)
+
+	w, _ = checkEmail("not-an-email")
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+
+	w, _ = checkEmail("another@example.com")
+	assert.Equal(suite.T(), http.StatusTooManyRequests, w.Code)
+}
+
+// TestUserDeactivation tests that deactivated users can neither log in nor use existing tokens
+func (suite *APITestSuite) TestUserDeactivation() {
+	member, memberToken := suite.createTestCompanyUser(suite.testCompany.ID, "Deactivated Member", models.UserRoleMember)
+
+	login := func() *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(models.LoginRequest{
+			Email:    member.Email,
+			Password: "password123",
+		})
+		req, _ := http.NewRequest("POST", "/api/auth/login", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w
+	}
+	setActive := func(token string, userID uint, action string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/company/users/%d/%s", userID, action), nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w
+	}
+	listInvoices := func() int {
+		req, _ := http.NewRequest("GET", "/api/invoices", nil)
+		req.Header.Set("Authorization", "Bearer "+memberToken)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(suite.T(), http.StatusOK, login().Code)
+
+	// Only admins manage users, and admins cannot lock themselves out
+	assert.Equal(suite.T(), http.StatusForbidden, setActive(memberToken, suite.testUser.ID, "deactivate").Code)
+	assert.Equal(suite.T(), http.StatusBadRequest, setActive(suite.authToken, suite.testUser.ID, "deactivate").Code)
+
+	w := setActive(suite.authToken, member.ID, "deactivate")
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var response models.SuccessResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	data := response.Data.(map[string]interface{})
+	assert.Equal(suite.T(), false, data["is_active"])
+	assert.NotContains(suite.T(), data, "password")
+
+	w = login()
+	assert.Equal(suite.T(), http.StatusForbidden, w.Code)
+	var errResponse models.ErrorResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &errResponse))
+	assert.Equal(suite.T(), "account_disabled", errResponse.Error)
+
+	// Tokens issued before the deactivation stop working immediately
+	assert.Equal(suite.T(), http.StatusUnauthorized, listInvoices())
+
+	assert.Equal(suite.T(), http.StatusOK, setActive(suite.authToken, member.ID, "reactivate").Code)
+	assert.Equal(suite.T(), http.StatusOK, login().Code)
+	assert.Equal(suite.T(), http.StatusOK, listInvoices())
+
+	// Users of other companies are not visible
+	company := &models.Company{
+		CorporateName:  fmt.Sprintf("Other User Company %d", time.Now().UnixNano()),
+		Representative: "Other Representative",
+		PhoneNumber:    "03-9999-7777",
+		PostalCode:     "100-0002",
+		Address:        "Tokyo, Other Address 2-2-2",
+	}
+	suite.Require().NoError(suite.repo.CreateCompany(company))
+	outsider := &models.User{
+		CompanyID: company.ID,
+		FullName:  "Outsider",
+		Email:     fmt.Sprintf("outsider%d@example.com", time.Now().UnixNano()),
+		Password:  "password123",
+		Role:      models.UserRoleMember,
+	}
+	suite.Require().NoError(service.NewInvoiceService(suite.repo, suite.cfg).RegisterUser(outsider))
+	assert.Equal(suite.T(), http.StatusNotFound, setActive(suite.authToken, outsider.ID, "deactivate").Code)
+}
+
+// TestUpdateProfile tests users changing their own name and email
+func (suite *APITestSuite) TestUpdateProfile() {
+	user, token := suite.createTestCompanyUser(suite.testCompany.ID, "Profile User", models.UserRoleMember)
+	other, _ := suite.createTestCompanyUser(suite.testCompany.ID, "Profile Other", models.UserRoleMember)
+
+	update := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("PATCH", "/api/me", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Changing the name keeps the email
+	w := update(`{"full_name": "  Renamed Profile User "}`)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var response models.AuthResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), "Renamed Profile User", response.User.FullName)
+	assert.Equal(suite.T(), user.Email, response.User.Email)
+
+	stored, err := suite.repo.GetUserByID(user.ID)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "Renamed Profile User", stored.FullName)
+
+	// An email registered to another user is refused
+	w = update(fmt.Sprintf(`{"email": %q}`, other.Email))
+	assert.Equal(suite.T(), http.StatusConflict, w.Code, w.Body.String())
+	var errResponse models.ErrorResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &errResponse))
+	assert.Equal(suite.T(), "email_taken", errResponse.Error)
+	stored, err = suite.repo.GetUserByID(user.ID)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), user.Email, stored.Email)
+
+	// A new email is reflected in the returned token and used for logging in
+	newEmail := fmt.Sprintf("profile%d@example.com", time.Now().UnixNano())
+	w = update(fmt.Sprintf(`{"email": %q}`, newEmail))
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), newEmail, response.User.Email)
+	claims, err := middleware.ParseJWT(response.Token, suite.cfg)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), newEmail, claims.Email)
+
+	jsonData, _ := json.Marshal(models.LoginRequest{Email: newEmail, Password: "password123"})
+	req, _ := http.NewRequest("POST", "/api/auth/login", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code, w.Body.String())
+
+	// Invalid requests are rejected
+	for body, status := range map[string]int{
+		`{}`:                        http.StatusUnprocessableEntity,
+		`{"full_name": "   "}`:      http.StatusUnprocessableEntity,
+		`{"email": "not-an-email"}`: http.StatusBadRequest,
+	} {
+		assert.Equal(suite.T(), status, update(body).Code, body)
+	}
+}
+
+// TestCompanyUserSearch tests listing and searching the users of the caller's company
+func (suite *APITestSuite) TestCompanyUserSearch() {
+	// A company of its own keeps other tests' users out of the results
+	suffix := fmt.Sprintf("%d", time.Now().UnixNano())
+	company := &models.Company{
+		CorporateName:  "User Search Company " + suffix,
+		Representative: "User Search Representative",
+		PhoneNumber:    "03-9999-4444",
+		PostalCode:     "100-0004",
+		Address:        "Tokyo, User Search Address 4-4-4",
+	}
+	suite.Require().NoError(suite.repo.CreateCompany(company))
+	admin, adminToken := suite.createTestCompanyUser(company.ID, "User Search Admin "+suffix, models.UserRoleAdmin)
+	_, memberToken := suite.createTestCompanyUser(company.ID, "Searchable Alice "+suffix, models.UserRoleMember)
+	suite.createTestCompanyUser(company.ID, "Searchable Alicia "+suffix, models.UserRoleMember)
+	suite.createTestCompanyUser(company.ID, "Searchable Bob "+suffix, models.UserRoleAdmin)
+
+	listUsers := func(token, query string) (*httptest.ResponseRecorder, models.UserListResponse) {
+		req, _ := http.NewRequest("GET", "/api/company/users"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+
+		var response models.UserListResponse
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+	names := func(users []*models.User) []string {
+		var names []string
+		for _, user := range users {
+			names = append(names, user.FullName)
+		}
+		return names
+	}
+
+	w, _ := listUsers(memberToken, "")
+	assert.Equal(suite.T(), http.StatusForbidden, w.Code)
+	w, _ = listUsers(adminToken, "?limit=0")
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+
+	// Partial names match, ordered by name
+	w, response := listUsers(adminToken, "?search="+url.QueryEscape("Alic"))
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(suite.T(), []string{"Searchable Alice " + suffix, "Searchable Alicia " + suffix}, names(response.Data))
+	assert.Equal(suite.T(), 2, response.Total)
+	assert.NotContains(suite.T(), w.Body.String(), "password")
+
+	// Results are paginated
+	w, response = listUsers(adminToken, "?search="+url.QueryEscape("Searchable")+"&limit=2&page=2")
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(suite.T(), 3, response.Total)
+	assert.Equal(suite.T(), 2, response.TotalPages)
+	assert.Equal(suite.T(), []string{"Searchable Bob " + suffix}, names(response.Data))
+
+	// Email addresses match too
+	w, response = listUsers(adminToken, "?search="+url.QueryEscape(admin.Email))
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Require().Len(response.Data, 1)
+	assert.Equal(suite.T(), admin.ID, response.Data[0].ID)
+
+	// LIKE wildcards are matched literally and no match is an empty page
+	w, response = listUsers(adminToken, "?search="+url.QueryEscape("%"))
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(suite.T(), 0, response.Total)
+	assert.NotNil(suite.T(), response.Data)
+	assert.Empty(suite.T(), response.Data)
+}
+
+// TestEmptyJWTSecret tests that tokens are neither issued nor accepted without a JWT secret
+func (suite *APITestSuite) TestEmptyJWTSecret() {
+	cfg := *suite.cfg
+	cfg.JWT.Secret = ""
+
+	assert.Error(suite.T(), cfg.Validate())
+	cfg.JWT.Secret = "too-short"
+	assert.Error(suite.T(), cfg.Validate())
+	// The example secrets from the documentation are public
+	for _, placeholder := range []string{
+		"your-secret-key-change-in-production",
+		"your-super-secret-jwt-key-change-in-production",
+		"your-super-secret-jwt-key-change-in-production-environment",
+	} {
+		cfg.JWT.Secret = placeholder
+		assert.Error(suite.T(), cfg.Validate(), placeholder)
+	}
+	assert.NoError(suite.T(), suite.cfg.Validate())
+	cfg.JWT.Secret = ""
+
+	_, err := middleware.GenerateJWT(&suite.testUser, &cfg)
+	assert.ErrorIs(suite.T(), err, middleware.ErrJWTSecretNotConfigured)
+	_, _, err = middleware.GenerateScopedJWT(&suite.testUser, middleware.ScopeRead, time.Hour, &cfg)
+	assert.ErrorIs(suite.T(), err, middleware.ErrJWTSecretNotConfigured)
+
+	// Tokens signed with an empty key are not accepted either
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, middleware.JWTClaims{UserID: suite.testUser.ID}).SignedString([]byte{})
+	suite.Require().NoError(err)
+	_, err = middleware.ParseJWT(forged, &cfg)
+	assert.Error(suite.T(), err)
+
+	// Logging in fails with a JSON error instead of handing out an insecure token
+	router := api.NewHandler(service.NewInvoiceService(suite.repo, &cfg), &cfg).SetupRoutes()
+	jsonData, _ := json.Marshal(models.LoginRequest{Email: suite.testUser.Email, Password: "password123"})
+	req, _ := http.NewRequest("POST", "/api/auth/login", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusInternalServerError, w.Code)
+	var errResponse models.ErrorResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &errResponse))
+	assert.Equal(suite.T(), "token_generation_failed", errResponse.Error)
+}
+
+// TestConfigSummaryMasksSecrets tests that the logged configuration never contains secrets
+func (suite *APITestSuite) TestConfigSummaryMasksSecrets() {
+	assert.Equal(suite.T(), "(not set)", config.MaskSecret(""))
+	assert.Equal(suite.T(), "********", config.MaskSecret("hunter2"))
+
+	cfg := *suite.cfg
+	cfg.Database.Password = "db-password-4f2a"
+	cfg.JWT.Secret = "jwt-secret-value-91c3"
+	cfg.Admin.Token = "admin-token-7d0e"
+
+	var buf bytes.Buffer
+	cfg.LogSummary(log.New(&buf, "", 0))
+	summary := buf.String()
+
+	for _, secret := range []string{cfg.Database.Password, cfg.JWT.Secret, cfg.Admin.Token} {
+		assert.NotContains(suite.T(), summary, secret)
+	}
+	assert.Contains(suite.T(), summary, "password=********")
+	assert.Contains(suite.T(), summary, "secret=********")
+	assert.Contains(suite.T(), summary, "token=********")
+
+	// Everything else is logged as loaded
+	assert.Contains(suite.T(), summary, "address="+cfg.GetServerAddress())
+	assert.Contains(suite.T(), summary, "host="+cfg.Database.Host)
+	assert.Contains(suite.T(), summary, "name="+cfg.Database.Name)
+	assert.Contains(suite.T(), summary, fmt.Sprintf("expiry_hours=%d", cfg.JWT.ExpiryHours))
+}
+
+// TestRememberMeLogin tests that logins asking to be remembered get a longer-lived token
+func (suite *APITestSuite) TestRememberMeLogin() {
+	login := func(rememberMe bool) *middleware.JWTClaims {
+		jsonData, _ := json.Marshal(models.LoginRequest{Email: suite.testUser.Email, Password: "password123", RememberMe: rememberMe})
+		req, _ := http.NewRequest("POST", "/api/auth/login", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+		var response models.AuthResponse
+		suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+		claims, err := middleware.ParseJWT(response.Token, suite.cfg)
+		suite.Require().NoError(err)
+		return claims
+	}
+
+	start := time.Now()
+	standard := login(false)
+	remembered := login(true)
+
+	assert.True(suite.T(), remembered.ExpiresAt.After(standard.ExpiresAt.Time))
+	assert.WithinDuration(suite.T(), start.Add(time.Duration(suite.cfg.JWT.ExpiryHours)*time.Hour), standard.ExpiresAt.Time, time.Minute)
+	assert.WithinDuration(suite.T(), start.AddDate(0, 0, suite.cfg.JWT.RememberMeExpiryDays), remembered.ExpiresAt.Time, time.Minute)
+
+	// Both tokens identify the user the same way
+	assert.Equal(suite.T(), standard.UserID, remembered.UserID)
+	assert.Equal(suite.T(), standard.CompanyID, remembered.CompanyID)
+	assert.Equal(suite.T(), standard.Email, remembered.Email)
+	assert.Empty(suite.T(), remembered.Scope)
+}
+
+// TestTokenIntrospection tests reporting the validity and remaining lifetime of a token
+func (suite *APITestSuite) TestTokenIntrospection() {
+	req, _ := http.NewRequest("GET", "/api/auth/introspect", nil)
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	var response models.TokenIntrospectionResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(suite.T(), response.Valid)
+	assert.Positive(suite.T(), response.SecondsRemaining)
+	assert.LessOrEqual(suite.T(), response.SecondsRemaining, int64(suite.cfg.JWT.ExpiryHours*3600))
+	assert.True(suite.T(), response.ExpiresAt.After(time.Now()))
+	assert.Equal(suite.T(), suite.testUser.ID, response.UserID)
+	assert.Equal(suite.T(), suite.testCompany.ID, response.CompanyID)
+
+	req, _ = http.NewRequest("GET", "/api/auth/introspect", nil)
+	req.Header.Set("Authorization", "Bearer invalid-token")
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusUnauthorized, w.Code)
+}
+
+// TestReadScopedToken tests that tokens issued to auditors can read but not write
+func (suite *APITestSuite) TestReadScopedToken() {
+	requestToken := func(token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/auth/read-token", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w
+	}
+
+	_, memberToken := suite.createTestCompanyUser(suite.testCompany.ID, "Read Token Member", models.UserRoleMember)
+	assert.Equal(suite.T(), http.StatusForbidden, requestToken(memberToken).Code)
+
+	w := requestToken(suite.authToken)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var response models.ReadTokenResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	suite.Require().NotEmpty(response.Token)
+	assert.True(suite.T(), response.ExpiresAt.Before(time.Now().Add(time.Duration(suite.cfg.JWT.ExpiryHours)*time.Hour)))
+
+	req, _ := http.NewRequest("GET", "/api/invoices", nil)
+	req.Header.Set("Authorization", "Bearer "+response.Token)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code, w.Body.String())
+
+	partnerID := suite.createTestBusinessPartner("Read Token Partner")
+	jsonData, _ := json.Marshal(models.CreateInvoiceRequest{
+		BusinessPartnerID: partnerID,
+		PaymentAmount:     10000,
+		PaymentDueDate:    time.Now().AddDate(0, 1, 0),
+	})
+	req, _ = http.NewRequest("POST", "/api/invoices", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+response.Token)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusForbidden, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "insufficient_scope")
+
+	// A read token cannot be used to mint further tokens
+	assert.Equal(suite.T(), http.StatusForbidden, requestToken(response.Token).Code)
+}
+
 // TestCreateBusinessPartner tests business partner creation
 func (suite *APITestSuite) TestCreateBusinessPartner() {
 	partnerData := models.BusinessPartnerCreateRequest{
@@ -407,6 +1338,209 @@ func (suite *APITestSuite) TestInvoiceCalculationAccuracy() {
 	}
 }
 
+// TestCompanySettings tests reading and updating the caller's company rates and payment terms
+func (suite *APITestSuite) TestCompanySettings() {
+	_, memberToken := suite.createTestCompanyUser(suite.testCompany.ID, "Settings Member", models.UserRoleMember)
+	defer func() {
+		suite.Require().NoError(suite.repo.UpdateCompanyRates(suite.testCompany.ID, &models.CompanyRates{}))
+		suite.Require().NoError(suite.repo.SetCompanyFeeRate(suite.testCompany.ID, nil))
+	}()
+
+	getSettings := func(token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/api/company/settings", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w
+	}
+	updateSettings := func(token, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("PUT", "/api/company/settings", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w
+	}
+	var response struct {
+		Data models.CompanySettings `json:"data"`
+	}
+
+	// Companies start with the application defaults
+	w := getSettings(memberToken)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), service.DefaultFeeRate, response.Data.FeeRate)
+	assert.Equal(suite.T(), service.DefaultConsumptionTaxRate, response.Data.ConsumptionTaxRate)
+	assert.Equal(suite.T(), suite.cfg.App.DefaultPaymentTermDays, response.Data.PaymentTermDays)
+	assert.Equal(suite.T(), models.InvoiceStatusUnprocessed, response.Data.InitialInvoiceStatus)
+
+	valid := `{"consumption_tax_rate": 0.08, "payment_term_days": 45}`
+	assert.Equal(suite.T(), http.StatusForbidden, updateSettings(memberToken, valid).Code)
+
+	for body, status := range map[string]int{
+		`{"consumption_tax_rate": 0.08, "payment_term_days": 0}`:  http.StatusBadRequest,
+		`{"consumption_tax_rate": 0.07, "payment_term_days": 45}`: http.StatusUnprocessableEntity,
+		// New invoices can only start unprocessed or processing
+		`{"consumption_tax_rate": 0.08, "payment_term_days": 45, "initial_invoice_status": "paid"}`:  http.StatusBadRequest,
+		`{"consumption_tax_rate": 0.08, "payment_term_days": 45, "initial_invoice_status": "draft"}`: http.StatusBadRequest,
+	} {
+		w = updateSettings(suite.authToken, body)
+		assert.Equal(suite.T(), status, w.Code, body)
+	}
+
+	// The fee rate is negotiated with the platform, so company admins cannot change it
+	feeRate := 0.03
+	suite.Require().NoError(suite.repo.SetCompanyFeeRate(suite.testCompany.ID, &feeRate))
+	w = updateSettings(suite.authToken, `{"fee_rate": 0.01, "consumption_tax_rate": 0.08, "payment_term_days": 45}`)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), 0.03, response.Data.FeeRate)
+
+	w = getSettings(memberToken)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), models.CompanySettings{FeeRate: 0.03, ConsumptionTaxRate: 0.08, PaymentTermDays: 45, InitialInvoiceStatus: models.InvoiceStatusUnprocessed}, response.Data)
+
+	// New invoices use the company's settings: 10000 + 300 fee + 24 tax, due in 45 days
+	partnerID := suite.createTestBusinessPartner("Settings Partner")
+	jsonData, _ := json.Marshal(map[string]interface{}{"business_partner_id": partnerID, "payment_amount": 10000})
+	req, _ := http.NewRequest("POST", "/api/invoices", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	var invoiceResponse struct {
+		Data models.Invoice `json:"data"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &invoiceResponse))
+	invoice := invoiceResponse.Data
+	assert.Equal(suite.T(), 0.03, invoice.FeeRate)
+	assert.Equal(suite.T(), 0.08, invoice.ConsumptionTaxRate)
+	assert.Equal(suite.T(), 10324.0, invoice.InvoiceAmount)
+	assert.Equal(suite.T(), invoice.IssueDate.AddDate(0, 0, 45).Format("2006-01-02"), invoice.PaymentDueDate.Format("2006-01-02"))
+	assert.Equal(suite.T(), models.InvoiceStatusUnprocessed, invoice.Status)
+
+	// Companies that queue invoices for payment right away can have them start processing
+	w = updateSettings(suite.authToken, `{"consumption_tax_rate": 0.08, "payment_term_days": 45, "initial_invoice_status": "processing"}`)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), models.InvoiceStatusProcessing, response.Data.InitialInvoiceStatus)
+
+	jsonData, _ = json.Marshal(map[string]interface{}{"business_partner_id": partnerID, "payment_amount": 20000})
+	req, _ = http.NewRequest("POST", "/api/invoices", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &invoiceResponse))
+	assert.Equal(suite.T(), models.InvoiceStatusProcessing, invoiceResponse.Data.Status)
+
+	stored, err := suite.repo.GetInvoiceByID(invoiceResponse.Data.ID)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), models.InvoiceStatusProcessing, stored.Status)
+
+	// Drafts are still saved as drafts
+	jsonData, _ = json.Marshal(map[string]interface{}{"business_partner_id": partnerID, "payment_amount": 30000})
+	req, _ = http.NewRequest("POST", "/api/invoices?draft=true", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &invoiceResponse))
+	assert.Equal(suite.T(), models.InvoiceStatusDraft, invoiceResponse.Data.Status)
+
+	// and start processing once they are issued
+	req, _ = http.NewRequest("POST", fmt.Sprintf("/api/invoices/%d/issue", invoiceResponse.Data.ID), nil)
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &invoiceResponse))
+	assert.Equal(suite.T(), models.InvoiceStatusProcessing, invoiceResponse.Data.Status)
+
+	history, err := suite.repo.GetInvoiceStatusHistory(invoiceResponse.Data.ID)
+	suite.Require().NoError(err)
+	suite.Require().Len(history, 1)
+	assert.Equal(suite.T(), models.InvoiceStatusDraft, history[0].FromStatus)
+	assert.Equal(suite.T(), models.InvoiceStatusProcessing, history[0].ToStatus)
+
+	// Null and omitted settings revert to the application defaults
+	w = updateSettings(suite.authToken, `{"consumption_tax_rate": null, "payment_term_days": null}`)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), models.CompanySettings{
+		FeeRate:              0.03,
+		ConsumptionTaxRate:   service.DefaultConsumptionTaxRate,
+		PaymentTermDays:      suite.cfg.App.DefaultPaymentTermDays,
+		InitialInvoiceStatus: models.InvoiceStatusUnprocessed,
+	}, response.Data)
+}
+
+// TestPreviewRateChange tests projecting the fees of unprocessed invoices under proposed rates
+func (suite *APITestSuite) TestPreviewRateChange() {
+	preview := func(body string) (*httptest.ResponseRecorder, models.RateChangePreview) {
+		req, _ := http.NewRequest("POST", "/api/company/settings/preview-rate", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+
+		var response struct {
+			Data models.RateChangePreview `json:"data"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response.Data
+	}
+	jpyTotal := func(p models.RateChangePreview) models.RateChangeTotal {
+		for _, total := range p.Totals {
+			if total.Currency == models.DefaultCurrency {
+				return total
+			}
+		}
+		return models.RateChangeTotal{Currency: models.DefaultCurrency}
+	}
+
+	// Other tests leave unprocessed invoices behind, so compare against a baseline
+	const proposal = `{"fee_rate": 0.05, "consumption_tax_rate": 0.08}`
+	w, before := preview(proposal)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	partnerID := suite.createTestBusinessPartner("Rate Preview Partner")
+	dueDate := time.Now().AddDate(0, 0, 30)
+	suite.insertTestInvoice(partnerID, 10000, dueDate, models.InvoiceStatusUnprocessed)
+	suite.insertTestInvoice(partnerID, 30000, dueDate, models.InvoiceStatusUnprocessed)
+	suite.insertTestInvoice(partnerID, 50000, dueDate, models.InvoiceStatusPaid)
+
+	w, after := preview(proposal)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(suite.T(), 0.05, after.FeeRate)
+	assert.Equal(suite.T(), 0.08, after.ConsumptionTaxRate)
+	assert.Equal(suite.T(), before.InvoiceCount+2, after.InvoiceCount)
+
+	// The fees of 40000 go from 1600 (4%) to 2000 (5%) and their tax from 160 (10%) to 160 (8%)
+	previous, current := jpyTotal(before), jpyTotal(after)
+	assert.Equal(suite.T(), previous.InvoiceCount+2, current.InvoiceCount)
+	assert.InDelta(suite.T(), previous.CurrentFees+1600, current.CurrentFees, 0.001)
+	assert.InDelta(suite.T(), previous.ProjectedFees+2000, current.ProjectedFees, 0.001)
+	assert.InDelta(suite.T(), previous.FeeDelta+400, current.FeeDelta, 0.001)
+	assert.InDelta(suite.T(), previous.CurrentConsumptionTax+160, current.CurrentConsumptionTax, 0.001)
+	assert.InDelta(suite.T(), previous.ProjectedConsumptionTax+160, current.ProjectedConsumptionTax, 0.001)
+
+	// Nothing is persisted
+	settings, err := service.NewInvoiceService(suite.repo, suite.cfg).GetCompanySettings(suite.testUser.ID)
+	suite.Require().NoError(err)
+	assert.NotEqual(suite.T(), 0.05, settings.FeeRate)
+
+	w, _ = preview(`{"consumption_tax_rate": 0.07}`)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code)
+	w, _ = preview(`{"fee_rate": 0.5}`)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+}
+
 // TestSuite runs the test suite
 func TestSuite(t *testing.T) {
 	suite.Run(t, new(APITestSuite))
@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"database/sql"
+	"fmt"
+	"super-payment/internal/config"
+	"super-payment/internal/models"
+	"super-payment/internal/repository"
+	"super-payment/internal/service"
+	"testing"
+	"time"
+)
+
+// benchmarkInvoiceCount is the number of invoices listed per iteration of BenchmarkGetInvoicesByCompanyID
+const benchmarkInvoiceCount = 1000
+
+// BenchmarkGetInvoicesByCompanyID measures listing a page of 1,000 invoices of one company with
+// their company and business partners. Run it with -benchmem to see the allocations per listing.
+// The company and its data are deleted once the benchmark finishes.
+func BenchmarkGetInvoicesByCompanyID(b *testing.B) {
+	cfg := config.Load()
+	repo, err := repository.NewMySQLRepository(cfg.GetDSN())
+	if err != nil {
+		b.Skipf("database unavailable: %v", err)
+	}
+	defer repo.Close()
+
+	company := &models.Company{
+		CorporateName:  fmt.Sprintf("Benchmark Company %d", time.Now().UnixNano()),
+		Representative: "Benchmark Representative",
+		PhoneNumber:    "03-0000-0000",
+		PostalCode:     "100-0001",
+		Address:        "Tokyo, Benchmark Address 1-1-1",
+	}
+	if err := repo.CreateCompany(company); err != nil {
+		b.Fatal(err)
+	}
+	// Deleting the company cascades to its business partners and invoices
+	b.Cleanup(func() {
+		db, err := sql.Open("mysql", cfg.GetDSN())
+		if err != nil {
+			b.Errorf("failed to clean up benchmark company: %v", err)
+			return
+		}
+		defer db.Close()
+		if _, err := db.Exec(`DELETE FROM companies WHERE id = ?`, company.ID); err != nil {
+			b.Errorf("failed to clean up benchmark company: %v", err)
+		}
+	})
+
+	var partnerIDs []uint
+	for i := 0; i < 10; i++ {
+		partner := &models.BusinessPartner{
+			CompanyID:      company.ID,
+			CorporateName:  fmt.Sprintf("Benchmark Partner %d", i),
+			Representative: "Benchmark Partner Rep",
+			PhoneNumber:    "03-0000-0001",
+			PostalCode:     "100-0002",
+			Address:        "Tokyo, Benchmark Partner Address 2-2-2",
+		}
+		if err := repo.CreateBusinessPartner(partner); err != nil {
+			b.Fatal(err)
+		}
+		partnerIDs = append(partnerIDs, partner.ID)
+	}
+
+	now := time.Now()
+	for i := 0; i < benchmarkInvoiceCount; i++ {
+		fee, tax, total := service.CalculateInvoice(10000, service.DefaultFeeRate, service.DefaultConsumptionTaxRate)
+		invoice := &models.Invoice{
+			CompanyID:          company.ID,
+			BusinessPartnerID:  partnerIDs[i%len(partnerIDs)],
+			IssueDate:          models.NewTimestamp(now),
+			PaymentAmount:      10000,
+			Fee:                fee,
+			FeeRate:            service.DefaultFeeRate,
+			ConsumptionTax:     tax,
+			ConsumptionTaxRate: service.DefaultConsumptionTaxRate,
+			InvoiceAmount:      total,
+			PaymentDueDate:     models.NewTimestamp(now.AddDate(0, 0, i%60)),
+			Status:             models.InvoiceStatusUnprocessed,
+			Currency:           models.DefaultCurrency,
+		}
+		if err := repo.CreateInvoice(invoice); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	// A sub-benchmark, so the invoices are created once rather than for every b.N tried
+	req := &models.GetInvoicesRequest{Limit: benchmarkInvoiceCount}
+	b.Run("list", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			invoices, err := repo.GetInvoicesByCompanyID(company.ID, req)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(invoices) != benchmarkInvoiceCount {
+				b.Fatalf("listed %d invoices, want %d", len(invoices), benchmarkInvoiceCount)
+			}
+		}
+	})
+}
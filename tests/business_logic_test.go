@@ -1,7 +1,11 @@
 package tests
 
 import (
+	"math"
+	"strings"
+	"super-payment/internal/config"
 	"super-payment/internal/models"
+	"super-payment/internal/service"
 	"testing"
 	"time"
 
@@ -77,20 +81,40 @@ func TestInvoiceCalculationLogic(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			// Simulate the calculation logic that would be in the service
-			feeRate := 0.04
-			taxRate := 0.10
-
-			fee := tc.paymentAmount * feeRate
-			consumptionTax := fee * taxRate
-			invoiceAmount := tc.paymentAmount + fee + consumptionTax
+			fee, consumptionTax, invoiceAmount := service.CalculateInvoice(tc.paymentAmount, service.DefaultFeeRate, service.DefaultConsumptionTaxRate)
 
 			// Verify calculations
 			assert.InDelta(t, tc.expectedFee, fee, 0.01, "Fee calculation should be correct")
 			assert.InDelta(t, tc.expectedConsumptionTax, consumptionTax, 0.001, "Consumption tax calculation should be correct")
-			assert.InDelta(t, tc.expectedInvoiceAmount, invoiceAmount, 0.001, "Invoice amount calculation should be correct")
-			assert.Equal(t, tc.expectedFeeRate, feeRate, "Fee rate should be 4%")
-			assert.Equal(t, tc.expectedTaxRate, taxRate, "Tax rate should be 10%")
+			assert.InDelta(t, tc.expectedInvoiceAmount, tc.paymentAmount+fee+consumptionTax, 0.001, "Invoice amount calculation should be correct")
+			assert.InDelta(t, math.Round(tc.expectedInvoiceAmount*100)/100, invoiceAmount, 0.001, "Invoice amount should be rounded to 2 decimal places")
+			assert.Equal(t, tc.expectedFeeRate, service.DefaultFeeRate, "Fee rate should be 4%")
+			assert.Equal(t, tc.expectedTaxRate, service.DefaultConsumptionTaxRate, "Tax rate should be 10%")
+		})
+	}
+}
+
+// TestInvoiceCalculationRounding tests that the invoice amount is rounded to 2 decimal places
+// while the fee and consumption tax keep their full precision
+func TestInvoiceCalculationRounding(t *testing.T) {
+	testCases := []struct {
+		name                  string
+		paymentAmount         float64
+		expectedInvoiceAmount float64
+	}{
+		{"Rounded down from 1.044", 1.0, 1.04},
+		{"Rounded up from 128.8818", 123.45, 128.88},
+		{"Rounded up from 0.54288", 0.52, 0.54},
+		{"Already exact", 10000.0, 10440.0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fee, consumptionTax, invoiceAmount := service.CalculateInvoice(tc.paymentAmount, service.DefaultFeeRate, service.DefaultConsumptionTaxRate)
+
+			assert.Equal(t, tc.expectedInvoiceAmount, invoiceAmount)
+			assert.InDelta(t, tc.paymentAmount*0.04, fee, 1e-9, "Fee should not be rounded")
+			assert.InDelta(t, tc.paymentAmount*0.004, consumptionTax, 1e-9, "Consumption tax should not be rounded")
 		})
 	}
 }
@@ -183,92 +207,139 @@ func TestBusinessPartnerValidation(t *testing.T) {
 	})
 }
 
-// TestPhoneNumberValidation tests phone number format validation
-func TestPhoneNumberValidation(t *testing.T) {
-	validPhoneNumbers := []string{
+// Phone numbers and postal codes shared by the validation tests and as fuzzing seeds
+var (
+	validPhoneNumbers = []string{
 		"03-1234-5678",
 		"090-1234-5678",
 		"080-9876-5432",
 		"06-1111-2222",
+		"052-123-4567",
+		"0466-12-3456",
+		"01267-2-3456",
 	}
-
-	invalidPhoneNumbers := []string{
+	invalidPhoneNumbers = []string{
 		"1234567890",     // No hyphens
 		"03-12345678",    // Wrong format
 		"abc-defg-hijk",  // Non-numeric
 		"",               // Empty
 		"123",            // Too short
 		"03-1234-567890", // Too long
+		"01-1-1234",      // Only 7 digits
+		"0120-1234-5678", // 12 digits
+		"03-12-3456",     // Area code and exchange too short for a landline
+		"031-1234-5678",  // 11 digits without a mobile prefix
+		"03-1234-5678\n", // Trailing newline
+		"０３-１２３４-５６７８",   // Full-width digits
 	}
-	// Simple regex pattern for Japanese phone numbers
-	// phonePattern := `^0\d{1,2}-\d{4}-\d{4}$` // Could be used for regex validation
+	validPostalCodes = []string{
+		"100-0001",
+		"150-0002",
+		"160-0023",
+		"104-0061",
+	}
+	invalidPostalCodes = []string{
+		"1000001",    // No hyphen (7 chars)
+		"100-00001",  // Too many digits after hyphen (9 chars)
+		"1000-001",   // Wrong format (8 chars but wrong pattern)
+		"abc-defg",   // Non-numeric (8 chars but invalid)
+		"",           // Empty
+		"123",        // Too short
+		"100-0001\n", // Trailing newline
+		"１００-０００１",   // Full-width digits
+	}
+)
 
+// TestPhoneNumberValidation tests phone number format validation
+func TestPhoneNumberValidation(t *testing.T) {
 	for _, phone := range validPhoneNumbers {
 		t.Run("Valid phone: "+phone, func(t *testing.T) {
-			// In a real implementation, you'd use regexp.MatchString
-			assert.True(t, len(phone) >= 10 && len(phone) <= 13, "Valid phone number should have appropriate length")
-			assert.Contains(t, phone, "-", "Valid phone number should contain hyphens")
+			assert.NoError(t, models.ValidatePhoneNumber(phone))
 		})
 	}
 
 	for _, phone := range invalidPhoneNumbers {
 		t.Run("Invalid phone: "+phone, func(t *testing.T) {
-			// Basic checks for invalid phone numbers
-			if phone == "" {
-				assert.Empty(t, phone, "Empty phone should be empty")
-			} else if len(phone) < 10 {
-				assert.True(t, len(phone) < 10, "Short phone should be too short")
-			} else {
-				// Could add more specific validation logic here
-				assert.NotEmpty(t, phone, "Phone number validation test")
-			}
+			assert.Error(t, models.ValidatePhoneNumber(phone))
 		})
 	}
 }
 
 // TestPostalCodeValidation tests postal code format validation
 func TestPostalCodeValidation(t *testing.T) {
-	validPostalCodes := []string{
-		"100-0001",
-		"150-0002",
-		"160-0023",
-		"104-0061",
-	}
-	invalidPostalCodes := []string{
-		"1000001",   // No hyphen (7 chars)
-		"100-00001", // Too many digits after hyphen (9 chars)
-		"1000-001",  // Wrong format (8 chars but wrong pattern)
-		"abc-defg",  // Non-numeric (8 chars but invalid)
-		"",          // Empty
-		"123",       // Too short
-	}
-
 	for _, postal := range validPostalCodes {
 		t.Run("Valid postal: "+postal, func(t *testing.T) {
-			// Japanese postal code format: XXX-XXXX
-			assert.Equal(t, 8, len(postal), "Valid postal code should be 8 characters")
-			assert.Contains(t, postal, "-", "Valid postal code should contain hyphen")
-			assert.Equal(t, "-", string(postal[3]), "Hyphen should be at position 3")
+			assert.NoError(t, models.ValidatePostalCode(postal))
 		})
 	}
 	for _, postal := range invalidPostalCodes {
 		t.Run("Invalid postal: "+postal, func(t *testing.T) {
-			if postal == "" {
-				assert.Empty(t, postal, "Empty postal should be empty")
-			} else if postal == "1000-001" || postal == "abc-defg" {
-				// These have 8 characters but wrong format
-				assert.Equal(t, 8, len(postal), "These should have 8 characters but wrong format")
-				if postal == "1000-001" {
-					assert.NotEqual(t, "-", string(postal[3]), "Hyphen should not be at position 3")
-				}
-			} else {
-				// Other invalid postal codes should not have exactly 8 characters
-				assert.NotEqual(t, 8, len(postal), "Invalid postal code should not have exactly 8 characters")
-			}
+			assert.Error(t, models.ValidatePostalCode(postal))
 		})
 	}
 }
 
+// FuzzValidatePhoneNumber checks that every accepted phone number is in a canonical form:
+// three hyphen-separated groups of ASCII digits starting with 0 and ending in four digits,
+// 10 digits in total, or 11 for 0X0 mobile and IP phone numbers
+func FuzzValidatePhoneNumber(f *testing.F) {
+	for _, phone := range append(append([]string{}, validPhoneNumbers...), invalidPhoneNumbers...) {
+		f.Add(phone)
+	}
+
+	f.Fuzz(func(t *testing.T, phone string) {
+		if models.ValidatePhoneNumber(phone) != nil {
+			return
+		}
+
+		groups := strings.Split(phone, "-")
+		if len(groups) != 3 {
+			t.Fatalf("accepted %q without exactly three groups", phone)
+		}
+		digits := strings.Join(groups, "")
+		for _, r := range digits {
+			if r < '0' || r > '9' {
+				t.Fatalf("accepted %q with non-digit %q", phone, r)
+			}
+		}
+		if digits[0] != '0' || len(groups[2]) != 4 || groups[0] == "" || groups[1] == "" {
+			t.Fatalf("accepted malformed groups in %q", phone)
+		}
+
+		switch len(digits) {
+		case 10:
+		case 11:
+			if len(groups[0]) != 3 || groups[0][2] != '0' || groups[0][1] == '0' || len(groups[1]) != 4 {
+				t.Fatalf("accepted 11-digit %q without a mobile prefix", phone)
+			}
+		default:
+			t.Fatalf("accepted %q with %d digits", phone, len(digits))
+		}
+	})
+}
+
+// FuzzValidatePostalCode checks that every accepted postal code is exactly XXX-XXXX in ASCII digits
+func FuzzValidatePostalCode(f *testing.F) {
+	for _, postal := range append(append([]string{}, validPostalCodes...), invalidPostalCodes...) {
+		f.Add(postal)
+	}
+
+	f.Fuzz(func(t *testing.T, postal string) {
+		if models.ValidatePostalCode(postal) != nil {
+			return
+		}
+
+		if len(postal) != 8 || postal[3] != '-' {
+			t.Fatalf("accepted %q not in XXX-XXXX form", postal)
+		}
+		for i, r := range postal {
+			if i != 3 && (r < '0' || r > '9') {
+				t.Fatalf("accepted %q with non-digit %q", postal, r)
+			}
+		}
+	})
+}
+
 // TestDateValidation tests date validation logic
 func TestDateValidation(t *testing.T) {
 	now := time.Now()
@@ -363,3 +434,103 @@ func TestRoundingAndPrecision(t *testing.T) {
 		})
 	}
 }
+
+// TestConsumptionTaxBase tests both consumption tax bases on a 10,000 yen invoice
+func TestConsumptionTaxBase(t *testing.T) {
+	t.Run("Tax applies to the fee", func(t *testing.T) {
+		fee, tax, total := service.CalculateInvoiceWithTaxBase(10000, service.DefaultFeeRate, service.DefaultConsumptionTaxRate, config.TaxAppliesToFee)
+		assert.InDelta(t, 400.0, fee, 0.001)
+		assert.InDelta(t, 40.0, tax, 0.001)      // 400 * 0.10
+		assert.InDelta(t, 10440.0, total, 0.001) // 10,000 + 400 + 40
+	})
+
+	t.Run("Tax applies to the payment amount and fee", func(t *testing.T) {
+		fee, tax, total := service.CalculateInvoiceWithTaxBase(10000, service.DefaultFeeRate, service.DefaultConsumptionTaxRate, config.TaxAppliesToPaymentAmount)
+		assert.InDelta(t, 400.0, fee, 0.001)
+		assert.InDelta(t, 1040.0, tax, 0.001)    // (10,000 + 400) * 0.10
+		assert.InDelta(t, 11440.0, total, 0.001) // 10,000 + 400 + 1,040
+	})
+
+	t.Run("Default calculation taxes the fee", func(t *testing.T) {
+		_, tax, total := service.CalculateInvoice(10000, service.DefaultFeeRate, service.DefaultConsumptionTaxRate)
+		assert.InDelta(t, 40.0, tax, 0.001)
+		assert.InDelta(t, 10440.0, total, 0.001)
+	})
+}
+
+// TestPaymentDueDateTimezone tests that due dates are compared as calendar dates in the configured timezone
+func TestPaymentDueDateTimezone(t *testing.T) {
+	jst, err := time.LoadLocation("Asia/Tokyo")
+	assert.NoError(t, err)
+
+	// 20:00 UTC on Dec 30 is already 05:00 on Dec 31 in Tokyo
+	serverNow := time.Date(2024, 12, 30, 20, 0, 0, 0, time.UTC)
+	dueDate := time.Date(2024, 12, 31, 0, 0, 0, 0, jst)
+
+	t.Run("Due date of today in JST is accepted", func(t *testing.T) {
+		assert.NoError(t, models.ValidatePaymentDueDate(dueDate, serverNow, jst))
+	})
+
+	t.Run("Due date before today in JST is rejected", func(t *testing.T) {
+		assert.Error(t, models.ValidatePaymentDueDate(dueDate.AddDate(0, 0, -1), serverNow, jst))
+	})
+
+	t.Run("DateOnly normalizes to the local calendar date", func(t *testing.T) {
+		assert.Equal(t, time.Date(2024, 12, 31, 0, 0, 0, 0, jst), models.DateOnly(serverNow, jst))
+	})
+}
+
+// TestInvoiceStatusTransitions tests the invoice lifecycle against the full transition matrix
+func TestInvoiceStatusTransitions(t *testing.T) {
+	draft := models.InvoiceStatusDraft
+	unprocessed := models.InvoiceStatusUnprocessed
+	processing := models.InvoiceStatusProcessing
+	partiallyPaid := models.InvoiceStatusPartiallyPaid
+	paid := models.InvoiceStatusPaid
+	failed := models.InvoiceStatusError
+
+	allowed := map[models.InvoiceStatus][]models.InvoiceStatus{
+		draft:         {unprocessed},
+		unprocessed:   {processing, partiallyPaid, paid, failed},
+		processing:    {unprocessed, partiallyPaid, paid, failed},
+		partiallyPaid: {paid, failed},
+		paid:          {unprocessed, processing, failed},
+		failed:        {unprocessed, processing, partiallyPaid, paid},
+	}
+
+	for _, from := range models.InvoiceStatuses {
+		for _, to := range models.InvoiceStatuses {
+			assert.Equal(t, containsStatus(allowed[from], to), from.CanTransitionTo(to), "%s to %s", from, to)
+		}
+		assert.Equal(t, allowed[from], from.ValidTransitions(), "transitions from %s", from)
+	}
+
+	t.Run("No status transitions to itself or back to draft", func(t *testing.T) {
+		for _, status := range models.InvoiceStatuses {
+			assert.False(t, status.CanTransitionTo(status), status)
+			assert.False(t, status.CanTransitionTo(draft), status)
+		}
+	})
+
+	t.Run("Unknown statuses have no transitions", func(t *testing.T) {
+		unknown := models.InvoiceStatus("cancelled")
+		assert.Empty(t, unknown.ValidTransitions())
+		assert.False(t, unknown.CanTransitionTo(unprocessed))
+		assert.False(t, unprocessed.CanTransitionTo(unknown))
+	})
+
+	t.Run("ValidTransitions returns a copy", func(t *testing.T) {
+		transitions := unprocessed.ValidTransitions()
+		transitions[0] = draft
+		assert.Equal(t, processing, unprocessed.ValidTransitions()[0])
+	})
+}
+
+func containsStatus(statuses []models.InvoiceStatus, target models.InvoiceStatus) bool {
+	for _, status := range statuses {
+		if status == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,861 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"super-payment/internal/api"
+	"super-payment/internal/models"
+	"super-payment/internal/service"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestExportBusinessPartnersCSV tests the business partner CSV export
+func (suite *APITestSuite) TestExportBusinessPartnersCSV() {
+	partnerID := suite.createTestBusinessPartner("CSV Export Partner")
+
+	req, _ := http.NewRequest("GET", "/api/business-partners/export?format=csv", nil)
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+	assert.Contains(suite.T(), w.Header().Get("Content-Type"), "text/csv")
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	suite.Require().NoError(err)
+	suite.Require().NotEmpty(records)
+
+	assert.Equal(suite.T(),
+		[]string{"id", "corporate_name", "representative", "phone_number", "postal_code", "address", "created_at"},
+		records[0])
+
+	var found []string
+	for _, record := range records[1:] {
+		if record[0] == strconv.FormatUint(uint64(partnerID), 10) {
+			found = record
+		}
+	}
+	suite.Require().NotNil(found, "Exported CSV should contain the created partner")
+	assert.Equal(suite.T(), "CSV Export Partner", found[1])
+	assert.Equal(suite.T(), "Helper Partner Rep", found[2])
+	assert.Equal(suite.T(), "03-5555-5555", found[3])
+	assert.Equal(suite.T(), "106-0001", found[4])
+	assert.Equal(suite.T(), "Tokyo, Helper Partner Address 7-7-7", found[5])
+	assert.NotEmpty(suite.T(), found[6])
+}
+
+// TestExportBusinessPartnersUnsupportedFormat tests rejection of unknown export formats
+func (suite *APITestSuite) TestExportBusinessPartnersUnsupportedFormat() {
+	req, _ := http.NewRequest("GET", "/api/business-partners/export?format=xml", nil)
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+}
+
+// TestBusinessPartnerStats tests the invoice aggregates for a business partner
+func (suite *APITestSuite) TestBusinessPartnerStats() {
+	getStats := func(partnerID uint) (int, models.BusinessPartnerStats) {
+		req, _ := http.NewRequest("GET", "/api/business-partners/"+strconv.FormatUint(uint64(partnerID), 10)+"/stats", nil)
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+
+		var response struct {
+			Data models.BusinessPartnerStats `json:"data"`
+		}
+		if w.Code == http.StatusOK {
+			suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+		}
+		return w.Code, response.Data
+	}
+
+	// A partner without invoices reports zeros
+	emptyPartnerID := suite.createTestBusinessPartner("Stats Empty Partner")
+	code, stats := getStats(emptyPartnerID)
+	suite.Require().Equal(http.StatusOK, code)
+	assert.Equal(suite.T(), 0, stats.InvoiceCount)
+	assert.Equal(suite.T(), 0.0, stats.TotalInvoiced)
+	assert.Equal(suite.T(), 0.0, stats.TotalPaid)
+	assert.Nil(suite.T(), stats.LastIssueDate)
+
+	partnerID := suite.createTestBusinessPartner("Stats Partner")
+	dueDate := time.Now().AddDate(0, 1, 0)
+	unpaid := suite.insertTestInvoice(partnerID, 10000, dueDate, models.InvoiceStatusUnprocessed)
+	paid := suite.insertTestInvoice(partnerID, 20000, dueDate, models.InvoiceStatusPaid)
+	partial := suite.insertTestInvoice(partnerID, 5000, dueDate, models.InvoiceStatusUnprocessed)
+	suite.Require().NoError(suite.repo.RecordInvoicePayment(&models.InvoicePayment{InvoiceID: partial.ID, Amount: 1500},
+		models.InvoiceStatusUnprocessed, models.InvoiceStatusPartiallyPaid, 0))
+
+	code, stats = getStats(partnerID)
+	suite.Require().Equal(http.StatusOK, code)
+	assert.Equal(suite.T(), partnerID, stats.BusinessPartnerID)
+	assert.Equal(suite.T(), 3, stats.InvoiceCount)
+	assert.InDelta(suite.T(), unpaid.InvoiceAmount+paid.InvoiceAmount+partial.InvoiceAmount, stats.TotalInvoiced, 0.001)
+	// Partial payments count towards the total paid
+	assert.InDelta(suite.T(), paid.InvoiceAmount+1500, stats.TotalPaid, 0.001)
+	suite.Require().NotNil(stats.LastIssueDate)
+	assert.Equal(suite.T(), paid.IssueDate.Format("2006-01-02"), stats.LastIssueDate.Format("2006-01-02"))
+
+	code, _ = getStats(999999)
+	assert.Equal(suite.T(), http.StatusNotFound, code)
+}
+
+// TestDownloadBusinessPartnerInvoicesZip tests the ZIP of invoice PDFs for a business partner
+func (suite *APITestSuite) TestDownloadBusinessPartnerInvoicesZip() {
+	partnerID := suite.createTestBusinessPartner("ZIP Partner")
+	dueDate := time.Now().AddDate(0, 1, 0)
+	invoiceIDs := []uint{
+		suite.createTestInvoice(partnerID, 10000, dueDate),
+		suite.createTestInvoice(partnerID, 20000, dueDate),
+		suite.createTestInvoice(partnerID, 30000, dueDate),
+	}
+
+	// Invoices of other partners are not included
+	suite.createTestInvoice(suite.createTestBusinessPartner("Other ZIP Partner"), 40000, dueDate)
+
+	req, _ := http.NewRequest("GET", "/api/business-partners/"+strconv.FormatUint(uint64(partnerID), 10)+"/invoices.zip", nil)
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(suite.T(), "application/zip", w.Header().Get("Content-Type"))
+
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	suite.Require().NoError(err)
+	suite.Require().Len(archive.File, len(invoiceIDs))
+
+	var names []string
+	for _, file := range archive.File {
+		names = append(names, file.Name)
+
+		rc, err := file.Open()
+		suite.Require().NoError(err)
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		suite.Require().NoError(err)
+		assert.True(suite.T(), bytes.HasPrefix(content, []byte("%PDF-")), "entry %s should be a PDF", file.Name)
+	}
+	// Entries are named after the invoice numbers printed on the documents
+	for _, id := range invoiceIDs {
+		invoice, err := suite.repo.GetInvoiceByID(id)
+		suite.Require().NoError(err)
+		assert.Contains(suite.T(), names, invoice.InvoiceNumber+".pdf")
+	}
+
+	req, _ = http.NewRequest("GET", "/api/business-partners/999999/invoices.zip", nil)
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+}
+
+// TestDownloadBusinessPartnerStatement tests downloading a business partner's monthly statement as a PDF
+func (suite *APITestSuite) TestDownloadBusinessPartnerStatement() {
+	partnerID := suite.createTestBusinessPartner("Statement Partner")
+	loc := suite.cfg.GetLocation()
+	issued := func(day int, month time.Month) time.Time { return time.Date(2023, month, day, 12, 0, 0, 0, loc) }
+	due := issued(30, time.June)
+
+	second := suite.insertIssuedTestInvoice(partnerID, 20000, issued(20, time.March), due, models.InvoiceStatusPaid)
+	first := suite.insertIssuedTestInvoice(partnerID, 10000, issued(1, time.March), due, models.InvoiceStatusUnprocessed)
+	last := suite.insertIssuedTestInvoice(partnerID, 30000, issued(31, time.March), due, models.InvoiceStatusProcessing)
+	// Drafts, other months and other partners are left out
+	draft := suite.insertIssuedTestInvoice(partnerID, 40000, issued(10, time.March), due, models.InvoiceStatusDraft)
+	february := suite.insertIssuedTestInvoice(partnerID, 50000, issued(28, time.February), due, models.InvoiceStatusPaid)
+	april := suite.insertIssuedTestInvoice(partnerID, 60000, issued(1, time.April), due, models.InvoiceStatusPaid)
+	otherPartner := suite.insertIssuedTestInvoice(suite.createTestBusinessPartner("Other Statement Partner"), 70000, issued(15, time.March), due, models.InvoiceStatusPaid)
+
+	download := func(partnerID uint, query string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/business-partners/%d/statement%s", partnerID, query), nil)
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := download(partnerID, "?year=2023&month=3")
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(suite.T(), "application/pdf", w.Header().Get("Content-Type"))
+	body := w.Body.String()
+	suite.Require().True(strings.HasPrefix(body, "%PDF-"))
+
+	assert.Equal(suite.T(), 3, strings.Count(body, "Running total:"))
+	for _, invoice := range []*models.Invoice{draft, february, april, otherPartner} {
+		assert.NotContains(suite.T(), body, invoice.InvoiceNumber)
+	}
+
+	// Oldest first, with the total running across the month
+	firstAt := strings.Index(body, first.InvoiceNumber)
+	secondAt := strings.Index(body, second.InvoiceNumber)
+	lastAt := strings.Index(body, last.InvoiceNumber)
+	suite.Require().True(firstAt >= 0 && secondAt >= 0 && lastAt >= 0)
+	assert.True(suite.T(), firstAt < secondAt && secondAt < lastAt)
+	total := first.InvoiceAmount + second.InvoiceAmount + last.InvoiceAmount
+	assert.Contains(suite.T(), body, fmt.Sprintf("Running total: %s %.2f", models.DefaultCurrency, first.InvoiceAmount+second.InvoiceAmount))
+	assert.Contains(suite.T(), body, fmt.Sprintf("Total \\(%s\\): %.2f", models.DefaultCurrency, total))
+
+	// A month without invoices still renders a statement
+	w = download(partnerID, "?year=2023&month=5")
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	assert.Zero(suite.T(), strings.Count(w.Body.String(), "Running total:"))
+
+	for query, status := range map[string]int{"?year=2023&month=13": http.StatusBadRequest, "?year=abc&month=3": http.StatusBadRequest} {
+		assert.Equal(suite.T(), status, download(partnerID, query).Code, query)
+	}
+
+	// Partners of other companies are not found
+	partner := suite.createOtherCompanyPartner("Other Statement")
+	assert.Equal(suite.T(), http.StatusNotFound, download(partner.ID, "?year=2023&month=3").Code)
+	assert.Equal(suite.T(), http.StatusNotFound, download(999999, "?year=2023&month=3").Code)
+}
+
+// patchBusinessPartner sends a partial business partner update for the test user
+func (suite *APITestSuite) patchBusinessPartner(partnerID uint, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("PATCH", fmt.Sprintf("/api/business-partners/%d", partnerID), strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	return w
+}
+
+// TestPatchBusinessPartner tests updating only the fields present in the request
+func (suite *APITestSuite) TestPatchBusinessPartner() {
+	partnerID := suite.createTestBusinessPartner("Patch Partner")
+	original, err := suite.repo.GetBusinessPartnerByID(partnerID)
+	suite.Require().NoError(err)
+
+	w := suite.patchBusinessPartner(partnerID, `{"representative": "New Representative"}`)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	updated, err := suite.repo.GetBusinessPartnerByID(partnerID)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "New Representative", updated.Representative)
+	assert.Equal(suite.T(), original.CorporateName, updated.CorporateName)
+	assert.Equal(suite.T(), original.PhoneNumber, updated.PhoneNumber)
+	assert.Equal(suite.T(), original.PostalCode, updated.PostalCode)
+	assert.Equal(suite.T(), original.Address, updated.Address)
+
+	// Present fields are validated, and an empty string is not the same as an omitted field
+	for _, body := range []string{
+		`{"phone_number": "12345"}`,
+		`{"postal_code": "1000001"}`,
+		`{"address": ""}`,
+	} {
+		w = suite.patchBusinessPartner(partnerID, body)
+		assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code, body)
+	}
+
+	w = suite.patchBusinessPartner(partnerID, `{"phone_number": `)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+
+	w = suite.patchBusinessPartner(999999, `{"representative": "Nobody"}`)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+
+	unchanged, err := suite.repo.GetBusinessPartnerByID(partnerID)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "New Representative", unchanged.Representative)
+	assert.Equal(suite.T(), original.Address, unchanged.Address)
+
+	// Names are stored trimmed
+	w = suite.patchBusinessPartner(partnerID, `{"corporate_name": "  Patched Partner  "}`)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	renamed, err := suite.repo.GetBusinessPartnerByID(partnerID)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "Patched Partner", renamed.CorporateName)
+}
+
+// createTestBankAccount stores a bank account for a business partner directly through the repository
+func (suite *APITestSuite) createTestBankAccount(partnerID uint, accountNumber string) *models.BusinessPartnerBankAccount {
+	account := &models.BusinessPartnerBankAccount{
+		BusinessPartnerID: partnerID,
+		BankName:          "Tokyo Bank",
+		BranchName:        "Shibuya Branch",
+		AccountNumber:     accountNumber,
+		AccountName:       "Test Account",
+	}
+	suite.Require().NoError(suite.repo.CreateBankAccount(account))
+	return account
+}
+
+// updateBankAccount sends a bank account update request for the test user
+func (suite *APITestSuite) updateBankAccount(partnerID, accountID uint, body map[string]interface{}) *httptest.ResponseRecorder {
+	jsonData, _ := json.Marshal(body)
+	req, _ := http.NewRequest("PUT", fmt.Sprintf("/api/business-partners/%d/bank-accounts/%d", partnerID, accountID), bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	return w
+}
+
+// TestUpdateBankAccount tests correcting a business partner's bank account
+func (suite *APITestSuite) TestUpdateBankAccount() {
+	partnerID := suite.createTestBusinessPartner("Bank Account Partner")
+	account := suite.createTestBankAccount(partnerID, "1234567")
+
+	body := map[string]interface{}{
+		"bank_name":      "Mizuho Bank",
+		"branch_name":    "Shinjuku Branch",
+		"account_number": "7654321",
+		"account_name":   "Corrected Account",
+	}
+	w := suite.updateBankAccount(partnerID, account.ID, body)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	updated, err := suite.repo.GetBankAccountByID(account.ID)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "Mizuho Bank", updated.BankName)
+	assert.Equal(suite.T(), "Shinjuku Branch", updated.BranchName)
+	assert.Equal(suite.T(), "7654321", updated.AccountNumber)
+	assert.Equal(suite.T(), "Corrected Account", updated.AccountName)
+
+	// Account numbers must be digits and names must not be blank
+	for _, invalid := range []map[string]interface{}{
+		{"bank_name": "Mizuho Bank", "branch_name": "Shinjuku Branch", "account_number": "76-54321", "account_name": "Corrected Account"},
+		{"bank_name": "Mizuho Bank", "branch_name": "Shinjuku Branch", "account_number": "7654321", "account_name": "   "},
+	} {
+		w = suite.updateBankAccount(partnerID, account.ID, invalid)
+		assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	}
+
+	// The account must belong to the partner in the path
+	otherPartnerID := suite.createTestBusinessPartner("Other Bank Account Partner")
+	w = suite.updateBankAccount(otherPartnerID, account.ID, body)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+}
+
+// TestCreateDuplicateBankAccount tests that a bank account can only be added once to a business partner
+func (suite *APITestSuite) TestCreateDuplicateBankAccount() {
+	partnerID := suite.createTestBusinessPartner("Duplicate Bank Account Partner")
+	createAccount := func(partnerID uint, body map[string]interface{}) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(body)
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/business-partners/%d/bank-accounts", partnerID), bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w
+	}
+	account := map[string]interface{}{
+		"bank_name":      "Osaka Bank",
+		"branch_name":    "Umeda Branch",
+		"account_number": "2468024",
+		"account_name":   "Duplicate Partner Account",
+	}
+
+	w := createAccount(partnerID, account)
+	suite.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+
+	w = createAccount(partnerID, account)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code, w.Body.String())
+	assert.Contains(suite.T(), w.Body.String(), "duplicate_bank_account")
+
+	// The same account may belong to another partner
+	otherPartnerID := suite.createTestBusinessPartner("Other Duplicate Bank Account Partner")
+	assert.Equal(suite.T(), http.StatusCreated, createAccount(otherPartnerID, account).Code)
+
+	// Updating another account to the same details is rejected too
+	other := suite.createTestBankAccount(partnerID, "1357913")
+	w = suite.updateBankAccount(partnerID, other.ID, account)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code, w.Body.String())
+
+	assert.Equal(suite.T(), http.StatusNotFound, createAccount(999999, account).Code)
+}
+
+// TestUpdateBankAccountOtherCompany tests that bank accounts of another company's partners cannot be updated
+func (suite *APITestSuite) TestUpdateBankAccountOtherCompany() {
+	partner := suite.createOtherCompanyPartner("Other Bank")
+	account := suite.createTestBankAccount(partner.ID, "1234567")
+
+	w := suite.updateBankAccount(partner.ID, account.ID, map[string]interface{}{
+		"bank_name":      "Hijacked Bank",
+		"branch_name":    "Hijacked Branch",
+		"account_number": "9999999",
+		"account_name":   "Hijacked Account",
+	})
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+
+	unchanged, err := suite.repo.GetBankAccountByID(account.ID)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "1234567", unchanged.AccountNumber)
+}
+
+// TestInvoiceBankAccountExpansion tests marking a primary bank account and including it in invoice responses
+func (suite *APITestSuite) TestInvoiceBankAccountExpansion() {
+	partnerID := suite.createTestBusinessPartner("Primary Bank Account Partner")
+	first := suite.createTestBankAccount(partnerID, "1234567")
+	second := suite.createTestBankAccount(partnerID, "7654321")
+	invoice := suite.insertTestInvoice(partnerID, 10000, time.Now().AddDate(0, 0, 30), models.InvoiceStatusUnprocessed)
+
+	getInvoice := func(query string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/invoices/%d%s", invoice.ID, query), nil)
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+
+		var response struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response.Data
+	}
+	setPrimary := func(accountID uint) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/business-partners/%d/bank-accounts/%d/primary", partnerID, accountID), nil)
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Without a primary account the expansion is empty
+	w, data := getInvoice("?expand=bank_account")
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	assert.NotContains(suite.T(), data, "bank_account")
+
+	suite.Require().Equal(http.StatusOK, setPrimary(first.ID).Code)
+	w = setPrimary(second.ID)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	// Only one account per partner is primary
+	updated, err := suite.repo.GetBankAccountByID(first.ID)
+	suite.Require().NoError(err)
+	assert.False(suite.T(), updated.IsPrimary)
+	updated, err = suite.repo.GetBankAccountByID(second.ID)
+	suite.Require().NoError(err)
+	assert.True(suite.T(), updated.IsPrimary)
+
+	// The default response omits the bank account
+	w, data = getInvoice("")
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	assert.NotContains(suite.T(), data, "bank_account")
+
+	w, data = getInvoice("?expand=bank_account")
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	account, ok := data["bank_account"].(map[string]interface{})
+	suite.Require().True(ok, w.Body.String())
+	assert.Equal(suite.T(), float64(second.ID), account["id"])
+	assert.Equal(suite.T(), true, account["is_primary"])
+
+	w, _ = getInvoice("?expand=company_secrets")
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+
+	// The account must belong to the partner in the path
+	otherPartnerID := suite.createTestBusinessPartner("Other Primary Bank Account Partner")
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/business-partners/%d/bank-accounts/%d/primary", otherPartnerID, first.ID), nil)
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+}
+
+// TestBusinessPartnerWithNullColumns tests reading a business partner row from a legacy schema
+// that allowed NULL contact details
+func (suite *APITestSuite) TestBusinessPartnerWithNullColumns() {
+	db, err := sql.Open("mysql", suite.cfg.GetDSN())
+	suite.Require().NoError(err)
+	defer db.Close()
+
+	// The current schema forbids NULL here, so relax it for the duration of the test
+	_, err = db.Exec("ALTER TABLE business_partners MODIFY representative VARCHAR(255) NULL, MODIFY address TEXT NULL")
+	suite.Require().NoError(err)
+	result, err := db.Exec(`INSERT INTO business_partners (company_id, corporate_name, representative, phone_number, postal_code, address)
+		VALUES (?, ?, NULL, ?, ?, NULL)`, suite.testCompany.ID, "Legacy Null Partner", "03-0000-0000", "100-0001")
+	suite.Require().NoError(err)
+	partnerID, err := result.LastInsertId()
+	suite.Require().NoError(err)
+	defer func() {
+		_, _ = db.Exec("DELETE FROM business_partners WHERE id = ?", partnerID)
+		_, _ = db.Exec("ALTER TABLE business_partners MODIFY representative VARCHAR(255) NOT NULL, MODIFY address TEXT NOT NULL")
+	}()
+
+	partner, err := suite.repo.GetBusinessPartnerByID(uint(partnerID))
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "Legacy Null Partner", partner.CorporateName)
+	assert.Equal(suite.T(), "", partner.Representative)
+	assert.Equal(suite.T(), "", partner.Address)
+	assert.Equal(suite.T(), "100-0001", partner.PostalCode)
+
+	req, _ := http.NewRequest("GET", "/api/business-partners", nil)
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(suite.T(), w.Body.String(), "Legacy Null Partner")
+}
+
+// TestMergeBusinessPartners tests reassigning all invoices of a duplicate business partner
+func (suite *APITestSuite) TestMergeBusinessPartners() {
+	sourceID := suite.createTestBusinessPartner("Duplicate Partner")
+	targetID := suite.createTestBusinessPartner("Surviving Partner")
+	dueDate := time.Now().AddDate(0, 0, 30)
+	moved := []*models.Invoice{
+		suite.insertTestInvoice(sourceID, 10000, dueDate, models.InvoiceStatusUnprocessed),
+		suite.insertTestInvoice(sourceID, 20000, dueDate, models.InvoiceStatusPaid),
+	}
+	kept := suite.insertTestInvoice(targetID, 30000, dueDate, models.InvoiceStatusUnprocessed)
+
+	merge := func(token string, from, to uint, query string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/business-partners/%d/merge/%d%s", from, to, query), nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w
+	}
+
+	_, memberToken := suite.createTestCompanyUser(suite.testCompany.ID, "Merge Member", models.UserRoleMember)
+	assert.Equal(suite.T(), http.StatusForbidden, merge(memberToken, sourceID, targetID, "").Code)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, merge(suite.authToken, sourceID, sourceID, "").Code)
+	assert.Equal(suite.T(), http.StatusNotFound, merge(suite.authToken, sourceID, 999999, "").Code)
+
+	w := merge(suite.authToken, sourceID, targetID, "?delete_source=true")
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Data models.BusinessPartnerMergeResult `json:"data"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), int64(len(moved)), response.Data.ReassignedInvoices)
+	assert.True(suite.T(), response.Data.SourceDeleted)
+
+	for _, invoice := range append(moved, kept) {
+		updated, err := suite.repo.GetInvoiceByID(invoice.ID)
+		suite.Require().NoError(err)
+		assert.Equal(suite.T(), targetID, updated.BusinessPartnerID)
+	}
+
+	_, err := suite.repo.GetBusinessPartnerByID(sourceID)
+	assert.Error(suite.T(), err)
+}
+
+// TestMergeBusinessPartnersAccountingCutoff tests that a business partner with invoices in a closed
+// accounting period is not merged
+func (suite *APITestSuite) TestMergeBusinessPartnersAccountingCutoff() {
+	loc := suite.cfg.GetLocation()
+	cutoff := time.Date(2020, 12, 31, 0, 0, 0, 0, loc)
+	cfg := *suite.cfg
+	cfg.App.AccountingCutoff = &cutoff
+	router := api.NewHandler(service.NewInvoiceService(suite.repo, &cfg), &cfg).SetupRoutes()
+
+	merge := func(from, to uint) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/business-partners/%d/merge/%d?delete_source=true", from, to), nil)
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	sourceID := suite.createTestBusinessPartner("Closed Period Duplicate Partner")
+	targetID := suite.createTestBusinessPartner("Closed Period Surviving Partner")
+	dueDate := time.Now().AddDate(0, 0, 30)
+	closed := suite.insertIssuedTestInvoice(sourceID, 10000, cutoff, cutoff.AddDate(0, 1, 0), models.InvoiceStatusPaid)
+	open := suite.insertTestInvoice(sourceID, 20000, dueDate, models.InvoiceStatusUnprocessed)
+
+	w := merge(sourceID, targetID)
+	suite.Require().Equal(http.StatusConflict, w.Code, w.Body.String())
+	var errResponse models.ErrorResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &errResponse))
+	assert.Equal(suite.T(), "period_closed", errResponse.Error)
+
+	// Nothing was merged
+	for _, invoice := range []*models.Invoice{closed, open} {
+		unchanged, err := suite.repo.GetInvoiceByID(invoice.ID)
+		suite.Require().NoError(err)
+		assert.Equal(suite.T(), sourceID, unchanged.BusinessPartnerID)
+	}
+	_, err := suite.repo.GetBusinessPartnerByID(sourceID)
+	assert.NoError(suite.T(), err)
+
+	// A partner whose invoices are all in the open period is merged
+	openSourceID := suite.createTestBusinessPartner("Open Period Duplicate Partner")
+	moved := suite.insertIssuedTestInvoice(openSourceID, 30000, cutoff.AddDate(0, 0, 1), dueDate, models.InvoiceStatusUnprocessed)
+	w = merge(openSourceID, targetID)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	updated, err := suite.repo.GetInvoiceByID(moved.ID)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), targetID, updated.BusinessPartnerID)
+}
+
+// importBusinessPartners uploads a CSV file to the business partner import endpoint
+func (suite *APITestSuite) importBusinessPartners(content string) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", "partners.csv")
+	suite.Require().NoError(err)
+	_, err = part.Write([]byte(content))
+	suite.Require().NoError(err)
+	suite.Require().NoError(writer.Close())
+
+	req, _ := http.NewRequest("POST", "/api/business-partners/import", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	return w
+}
+
+// TestImportBusinessPartnersCSV tests importing business partners from CSV with per-row results
+func (suite *APITestSuite) TestImportBusinessPartnersCSV() {
+	w := suite.importBusinessPartners("corporate_name,representative,phone_number,postal_code,address\n" +
+		"  Import Partner A  ,Rep A,03-1234-5678,100-0001,\"Tokyo, Chiyoda 1-1-1\"\n" +
+		"Import Partner B,Rep B,12345,100-0002,\"Tokyo, Chiyoda 2-2-2\"\n" +
+		"Import Partner C,Rep C,06-1234-5678,530-0001,\"Osaka, Kita 3-3-3\"\n")
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		Data models.BusinessPartnerImportResponse `json:"data"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), 2, response.Data.Imported)
+	assert.Equal(suite.T(), 1, response.Data.Failed)
+	suite.Require().Len(response.Data.Results, 3)
+
+	for i, result := range response.Data.Results {
+		assert.Equal(suite.T(), i+2, result.Row)
+	}
+	assert.True(suite.T(), response.Data.Results[0].Success)
+	assert.False(suite.T(), response.Data.Results[1].Success)
+	assert.Contains(suite.T(), response.Data.Results[1].Error, "phone number")
+	assert.Zero(suite.T(), response.Data.Results[1].BusinessPartnerID)
+	assert.True(suite.T(), response.Data.Results[2].Success)
+
+	partner, err := suite.repo.GetBusinessPartnerByID(response.Data.Results[0].BusinessPartnerID)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "Import Partner A", partner.CorporateName)
+	assert.Equal(suite.T(), "Tokyo, Chiyoda 1-1-1", partner.Address)
+	assert.Equal(suite.T(), suite.testCompany.ID, partner.CompanyID)
+
+	// Files whose header does not match the create fields are rejected as a whole
+	for name, content := range map[string]string{
+		"wrong columns":  "name,representative,phone_number,postal_code,address\nX,Rep,03-1234-5678,100-0001,Tokyo\n",
+		"missing column": "corporate_name,representative,phone_number,postal_code\nX,Rep,03-1234-5678,100-0001\n",
+		"empty file":     "",
+	} {
+		w := suite.importBusinessPartners(content)
+		assert.Equal(suite.T(), http.StatusBadRequest, w.Code, name)
+
+		var errResponse models.ErrorResponse
+		suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &errResponse))
+		assert.Equal(suite.T(), "validation_error", errResponse.Error, name)
+	}
+}
+
+// TestBusinessPartnerDuplicateCheck tests warning about a business partner whose name differs from
+// an existing one only in case and whitespace, and creating it anyway with force
+func (suite *APITestSuite) TestBusinessPartnerDuplicateCheck() {
+	name := fmt.Sprintf("Acme Trading %d", time.Now().UnixNano())
+	create := func(corporateName, query string) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(models.BusinessPartnerCreateRequest{
+			CorporateName:  corporateName,
+			Representative: "Duplicate Rep",
+			PhoneNumber:    "03-1234-5678",
+			PostalCode:     "100-0001",
+			Address:        "Tokyo",
+		})
+		req, _ := http.NewRequest("POST", "/api/business-partners"+query, bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Names are stored trimmed
+	w := create(name+"  ", "")
+	suite.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+	var created struct {
+		Data models.BusinessPartner `json:"data"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(suite.T(), name, created.Data.CorporateName)
+
+	// A name differing only in case and whitespace is reported with the existing partner
+	variant := "  " + strings.ToLower(strings.Replace(name, " ", "   ", 1))
+	w = create(variant, "?check_duplicate=true")
+	suite.Require().Equal(http.StatusConflict, w.Code, w.Body.String())
+	var conflict struct {
+		Error string                 `json:"error"`
+		Data  models.BusinessPartner `json:"data"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &conflict))
+	assert.Equal(suite.T(), "possible_duplicate_partner", conflict.Error)
+	assert.Equal(suite.T(), created.Data.ID, conflict.Data.ID)
+
+	// Different names pass the check
+	w = create(name+" Holdings", "?check_duplicate=true")
+	assert.Equal(suite.T(), http.StatusCreated, w.Code, w.Body.String())
+
+	// force creates the duplicate anyway, as does leaving the check off
+	w = create(variant, "?check_duplicate=true&force=true")
+	suite.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+	var forced struct {
+		Data models.BusinessPartner `json:"data"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &forced))
+	assert.NotEqual(suite.T(), created.Data.ID, forced.Data.ID)
+	assert.Equal(suite.T(), strings.TrimSpace(variant), forced.Data.CorporateName)
+
+	w = create(variant, "")
+	assert.Equal(suite.T(), http.StatusCreated, w.Code, w.Body.String())
+}
+
+// TestValidateBusinessPartners tests validating a batch of business partner records without creating them
+func (suite *APITestSuite) TestValidateBusinessPartners() {
+	before, err := suite.repo.GetBusinessPartnersByCompanyID(suite.testCompany.ID, nil)
+	suite.Require().NoError(err)
+
+	validate := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/business-partners/validate", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w
+	}
+
+	records := []models.BusinessPartnerCreateRequest{
+		{CorporateName: "Valid Partner", Representative: "Rep A", PhoneNumber: "03-1234-5678", PostalCode: "100-0001", Address: "Tokyo"},
+		{CorporateName: "Bad Postal Partner", Representative: "Rep B", PhoneNumber: "03-1234-5678", PostalCode: "1000001", Address: "Tokyo"},
+		{CorporateName: "Nameless Rep Partner", PhoneNumber: "06-1234-5678", PostalCode: "530-0001", Address: "Osaka"},
+	}
+	jsonData, _ := json.Marshal(records)
+	w := validate(string(jsonData))
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		Data models.BusinessPartnerValidationResponse `json:"data"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), 1, response.Data.Valid)
+	assert.Equal(suite.T(), 2, response.Data.Invalid)
+	suite.Require().Len(response.Data.Results, 3)
+
+	for i, result := range response.Data.Results {
+		assert.Equal(suite.T(), i, result.Index)
+	}
+	assert.True(suite.T(), response.Data.Results[0].Valid)
+	assert.Empty(suite.T(), response.Data.Results[0].Errors)
+	assert.False(suite.T(), response.Data.Results[1].Valid)
+	suite.Require().Len(response.Data.Results[1].Errors, 1)
+	assert.Contains(suite.T(), response.Data.Results[1].Errors[0], "postal code")
+	assert.False(suite.T(), response.Data.Results[2].Valid)
+	suite.Require().Len(response.Data.Results[2].Errors, 1)
+	assert.Contains(suite.T(), response.Data.Results[2].Errors[0], "representative")
+
+	// Nothing is written
+	after, err := suite.repo.GetBusinessPartnersByCompanyID(suite.testCompany.ID, nil)
+	suite.Require().NoError(err)
+	assert.Len(suite.T(), after, len(before))
+
+	// The body must be an array
+	for _, body := range []string{`{"corporate_name": "Not An Array"}`, `null`, ``} {
+		w := validate(body)
+		assert.Equal(suite.T(), http.StatusBadRequest, w.Code, body)
+	}
+}
+
+// TestBusinessPartnerInvoiceListing tests listing the invoices of one business partner
+func (suite *APITestSuite) TestBusinessPartnerInvoiceListing() {
+	partnerID := suite.createTestBusinessPartner("Listed Invoices Partner")
+	otherPartnerID := suite.createTestBusinessPartner("Unlisted Invoices Partner")
+	dueDate := time.Now().AddDate(0, 0, 30)
+	first := suite.insertTestInvoice(partnerID, 10000, dueDate, models.InvoiceStatusUnprocessed)
+	second := suite.insertTestInvoice(partnerID, 20000, dueDate, models.InvoiceStatusPaid)
+	suite.insertTestInvoice(otherPartnerID, 30000, dueDate, models.InvoiceStatusUnprocessed)
+
+	listInvoices := func(partnerID uint, query string) (*httptest.ResponseRecorder, []uint) {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/business-partners/%d/invoices%s", partnerID, query), nil)
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+
+		var response models.InvoiceListResponse
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		var ids []uint
+		for _, invoice := range response.Data {
+			assert.Equal(suite.T(), partnerID, invoice.BusinessPartnerID)
+			ids = append(ids, invoice.ID)
+		}
+		return w, ids
+	}
+
+	// Only the partner's invoices are listed
+	w, ids := listInvoices(partnerID, "")
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	assert.ElementsMatch(suite.T(), []uint{first.ID, second.ID}, ids)
+
+	// The filters and pagination of the invoice list apply
+	w, ids = listInvoices(partnerID, "?status=paid")
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(suite.T(), []uint{second.ID}, ids)
+	w, ids = listInvoices(partnerID, "?limit=1")
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	assert.Len(suite.T(), ids, 1)
+	assert.Contains(suite.T(), w.Body.String(), `"total":2`)
+
+	// Partners of other companies are not found
+	outsider := suite.createOtherCompanyPartner("Other Listing")
+	w, _ = listInvoices(outsider.ID, "")
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+	w, _ = listInvoices(999999999, "")
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+}
+
+// TestFilterBusinessPartnersByBankAccount tests listing business partners with or without bank details on file
+func (suite *APITestSuite) TestFilterBusinessPartnersByBankAccount() {
+	withAccount := suite.createTestBusinessPartner("Banked Partner")
+	suite.createTestBankAccount(withAccount, "5555555")
+	withoutAccount := suite.createTestBusinessPartner("Unbanked Partner")
+
+	list := func(query string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/api/business-partners"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w
+	}
+	partnerIDs := func(query string) []uint {
+		w := list(query)
+		suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+		var response struct {
+			Data []models.BusinessPartner `json:"data"`
+		}
+		suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+		ids := make([]uint, 0, len(response.Data))
+		for _, partner := range response.Data {
+			ids = append(ids, partner.ID)
+		}
+		return ids
+	}
+
+	banked := partnerIDs("?has_bank_account=true")
+	assert.Contains(suite.T(), banked, withAccount)
+	assert.NotContains(suite.T(), banked, withoutAccount)
+
+	unbanked := partnerIDs("?has_bank_account=false")
+	assert.Contains(suite.T(), unbanked, withoutAccount)
+	assert.NotContains(suite.T(), unbanked, withAccount)
+
+	all := partnerIDs("")
+	assert.Contains(suite.T(), all, withAccount)
+	assert.Contains(suite.T(), all, withoutAccount)
+
+	assert.Equal(suite.T(), http.StatusBadRequest, list("?has_bank_account=maybe").Code)
+}
@@ -0,0 +1,173 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"super-payment/internal/api"
+	"super-payment/internal/events"
+	"super-payment/internal/models"
+	"super-payment/internal/service"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestEventBusDeliversToSubscribers tests that a published event reaches its subscribers
+// asynchronously, even when another subscriber fails or panics
+func TestEventBusDeliversToSubscribers(t *testing.T) {
+	bus := events.NewBus()
+
+	received := make(chan events.Event, 1)
+	release := make(chan struct{})
+	bus.Subscribe(events.NameInvoiceCreated, func(event events.Event) error {
+		<-release
+		received <- event
+		return nil
+	})
+	bus.Subscribe(events.NameInvoiceCreated, func(event events.Event) error {
+		return errors.New("handler failure")
+	})
+	bus.Subscribe(events.NameInvoiceCreated, func(event events.Event) error {
+		panic("handler panic")
+	})
+	bus.Subscribe(events.NameInvoiceStatusChanged, func(event events.Event) error {
+		t.Errorf("Handler for another event should not run, got %s", event.Name())
+		return nil
+	})
+
+	// Publishing returns before the blocked handler has run
+	bus.Publish(events.InvoiceCreated{InvoiceID: 42, InvoiceNumber: "INV-000042"})
+	close(release)
+
+	select {
+	case event := <-received:
+		created, ok := event.(events.InvoiceCreated)
+		assert.True(t, ok, "Subscriber should receive the published event type")
+		assert.Equal(t, uint(42), created.InvoiceID)
+		assert.Equal(t, "INV-000042", created.InvoiceNumber)
+	case <-time.After(time.Second):
+		t.Fatal("Published event should reach the subscriber")
+	}
+
+	bus.Wait()
+}
+
+// TestServicePublishesInvoiceEvents tests that creating an invoice and changing its status publish
+// events to subscribers of the service's bus
+func (suite *APITestSuite) TestServicePublishesInvoiceEvents() {
+	svc := service.NewInvoiceService(suite.repo, suite.cfg)
+	router := api.NewHandler(svc, suite.cfg).SetupRoutes()
+
+	var mu sync.Mutex
+	var published []events.Event
+	record := func(event events.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		published = append(published, event)
+		return nil
+	}
+	svc.Events().Subscribe(events.NameInvoiceCreated, record)
+	svc.Events().Subscribe(events.NameInvoiceStatusChanged, record)
+
+	send := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	partnerID := suite.createTestBusinessPartner("Events Partner")
+	w := send("POST", "/api/invoices", models.CreateInvoiceRequest{
+		BusinessPartnerID: partnerID,
+		PaymentAmount:     10000,
+		PaymentDueDate:    time.Now().AddDate(0, 1, 0),
+	})
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		Data models.Invoice `json:"data"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	invoice := response.Data
+	// Handlers run asynchronously, so the creation is let through before the next change
+	svc.Events().Wait()
+
+	w = send("PATCH", fmt.Sprintf("/api/invoices/%d/status", invoice.ID), models.UpdateInvoiceStatusRequest{Status: models.InvoiceStatusProcessing})
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	svc.Events().Wait()
+	mu.Lock()
+	defer mu.Unlock()
+
+	suite.Require().Len(published, 2)
+	created, ok := published[0].(events.InvoiceCreated)
+	suite.Require().True(ok, "First event should be InvoiceCreated, got %s", published[0].Name())
+	assert.Equal(suite.T(), invoice.ID, created.InvoiceID)
+	assert.Equal(suite.T(), invoice.InvoiceNumber, created.InvoiceNumber)
+	assert.Equal(suite.T(), suite.testCompany.ID, created.CompanyID)
+	assert.Equal(suite.T(), suite.testUser.ID, created.UserID)
+
+	changed, ok := published[1].(events.InvoiceStatusChanged)
+	suite.Require().True(ok, "Second event should be InvoiceStatusChanged, got %s", published[1].Name())
+	assert.Equal(suite.T(), invoice.ID, changed.InvoiceID)
+	assert.Equal(suite.T(), invoice.Status, changed.From)
+	assert.Equal(suite.T(), models.InvoiceStatusProcessing, changed.To)
+}
+
+// TestOverdueJobPublishesStatusChanges tests that marking invoices overdue publishes a status change
+// for each invoice once they have been updated
+func (suite *APITestSuite) TestOverdueJobPublishesStatusChanges() {
+	svc := service.NewInvoiceService(suite.repo, suite.cfg)
+
+	var mu sync.Mutex
+	changes := make(map[uint]events.InvoiceStatusChanged)
+	svc.Events().Subscribe(events.NameInvoiceStatusChanged, func(event events.Event) error {
+		changed := event.(events.InvoiceStatusChanged)
+		// The event is published after the update is committed
+		invoice, err := suite.repo.GetInvoiceByID(changed.InvoiceID)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if invoice.Status == changed.To {
+			changes[changed.InvoiceID] = changed
+		}
+		return nil
+	})
+
+	partnerID := suite.createTestBusinessPartner("Overdue Events Partner")
+	pastDue := time.Now().AddDate(0, 0, -3)
+	first := suite.insertTestInvoice(partnerID, 10000, pastDue, models.InvoiceStatusUnprocessed)
+	second := suite.insertTestInvoice(partnerID, 20000, pastDue, models.InvoiceStatusUnprocessed)
+	notDue := suite.insertTestInvoice(partnerID, 30000, time.Now().AddDate(0, 0, 3), models.InvoiceStatusUnprocessed)
+
+	count, err := svc.MarkOverdueInvoices()
+	suite.Require().NoError(err)
+	assert.GreaterOrEqual(suite.T(), count, 2)
+
+	svc.Events().Wait()
+	mu.Lock()
+	defer mu.Unlock()
+
+	// Other tests may leave overdue invoices too, so only this test's invoices are checked
+	for _, invoice := range []*models.Invoice{first, second} {
+		changed, ok := changes[invoice.ID]
+		suite.Require().True(ok, "Invoice %d should have a status change event", invoice.ID)
+		assert.Equal(suite.T(), suite.testCompany.ID, changed.CompanyID)
+		assert.Equal(suite.T(), invoice.InvoiceNumber, changed.InvoiceNumber)
+		assert.Equal(suite.T(), models.InvoiceStatusUnprocessed, changed.From)
+		assert.Equal(suite.T(), models.InvoiceStatusError, changed.To)
+		assert.Zero(suite.T(), changed.UserID)
+	}
+	assert.NotContains(suite.T(), changes, notDue.ID)
+}
@@ -0,0 +1,2112 @@
+package tests
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"super-payment/internal/api"
+	"super-payment/internal/config"
+	"super-payment/internal/middleware"
+	"super-payment/internal/models"
+	"super-payment/internal/repository"
+	"super-payment/internal/service"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCreateInvoiceWithLineItems tests that an item-based invoice totals the sum of its items
+func (suite *APITestSuite) TestCreateInvoiceWithLineItems() {
+	partnerID := suite.createTestBusinessPartner("Line Item Partner")
+
+	invoiceData := models.CreateInvoiceRequest{
+		BusinessPartnerID: partnerID,
+		PaymentDueDate:    time.Now().AddDate(0, 1, 0),
+		LineItems: []models.CreateInvoiceItemRequest{
+			{Description: "Consulting", Quantity: 2, UnitPrice: 3000},
+			{Description: "Licence", Quantity: 1, UnitPrice: 4000},
+		},
+	}
+
+	jsonData, _ := json.Marshal(invoiceData)
+	req, _ := http.NewRequest("POST", "/api/invoices", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Require().Equal(http.StatusOK, w.Code)
+
+	var response models.SuccessResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+
+	invoiceMap := response.Data.(map[string]interface{})
+	assert.Equal(suite.T(), 10000.00, invoiceMap["payment_amount"]) // 2 * 3000 + 1 * 4000
+	assert.Equal(suite.T(), 400.00, invoiceMap["fee"])
+	assert.Equal(suite.T(), 40.00, invoiceMap["consumption_tax"])
+	assert.Equal(suite.T(), 10440.00, invoiceMap["invoice_amount"])
+
+	items := invoiceMap["line_items"].([]interface{})
+	suite.Require().Len(items, 2)
+	assert.Equal(suite.T(), "Consulting", items[0].(map[string]interface{})["description"])
+	assert.Equal(suite.T(), 6000.00, items[0].(map[string]interface{})["amount"])
+	assert.Equal(suite.T(), 4000.00, items[1].(map[string]interface{})["amount"])
+}
+
+// TestCreateInvoiceLineItemsMismatch tests rejection of a payment amount that disagrees with the items
+func (suite *APITestSuite) TestCreateInvoiceLineItemsMismatch() {
+	partnerID := suite.createTestBusinessPartner("Line Item Mismatch Partner")
+
+	invoiceData := models.CreateInvoiceRequest{
+		BusinessPartnerID: partnerID,
+		PaymentAmount:     9999,
+		PaymentDueDate:    time.Now().AddDate(0, 1, 0),
+		LineItems: []models.CreateInvoiceItemRequest{
+			{Description: "Consulting", Quantity: 2, UnitPrice: 3000},
+		},
+	}
+
+	jsonData, _ := json.Marshal(invoiceData)
+	req, _ := http.NewRequest("POST", "/api/invoices", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code)
+
+	var response models.ErrorResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), "validation_error", response.Error)
+}
+
+// TestOverdueInvoices tests the overdue listing and the batch transition to error status
+func (suite *APITestSuite) TestOverdueInvoices() {
+	partnerID := suite.createTestBusinessPartner("Overdue Partner")
+	overdue := suite.insertTestInvoice(partnerID, 10000, time.Now().AddDate(0, 0, -3), models.InvoiceStatusUnprocessed)
+	upcoming := suite.insertTestInvoice(partnerID, 20000, time.Now().AddDate(0, 0, 3), models.InvoiceStatusUnprocessed)
+
+	listOverdueIDs := func() []uint {
+		req, _ := http.NewRequest("GET", "/api/invoices/overdue?limit=100", nil)
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		suite.Require().Equal(http.StatusOK, w.Code)
+
+		var response struct {
+			Data []models.Invoice `json:"data"`
+		}
+		suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+
+		var ids []uint
+		for _, invoice := range response.Data {
+			assert.Equal(suite.T(), models.InvoiceStatusUnprocessed, invoice.Status)
+			ids = append(ids, invoice.ID)
+		}
+		return ids
+	}
+
+	ids := listOverdueIDs()
+	assert.Contains(suite.T(), ids, overdue.ID)
+	assert.NotContains(suite.T(), ids, upcoming.ID)
+
+	// The operator endpoint requires the admin token
+	req, _ := http.NewRequest("POST", "/api/admin/invoices/process-overdue", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusForbidden, w.Code)
+
+	req, _ = http.NewRequest("POST", "/api/admin/invoices/process-overdue", nil)
+	req.Header.Set("X-Admin-Token", suite.cfg.Admin.Token)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusOK, w.Code)
+
+	var response models.SuccessResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.GreaterOrEqual(suite.T(), response.Data.(map[string]interface{})["processed"], 1.0)
+
+	processed, err := suite.repo.GetInvoiceByID(overdue.ID)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), models.InvoiceStatusError, processed.Status)
+
+	untouched, err := suite.repo.GetInvoiceByID(upcoming.ID)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), models.InvoiceStatusUnprocessed, untouched.Status)
+
+	history, err := suite.repo.GetInvoiceStatusHistory(overdue.ID)
+	suite.Require().NoError(err)
+	suite.Require().Len(history, 1)
+	assert.Equal(suite.T(), models.InvoiceStatusUnprocessed, history[0].FromStatus)
+	assert.Equal(suite.T(), models.InvoiceStatusError, history[0].ToStatus)
+	assert.Equal(suite.T(), models.InvoiceActionMarkedOverdue, history[0].Action)
+
+	assert.NotContains(suite.T(), listOverdueIDs(), overdue.ID)
+}
+
+// TestCountInvoices tests that the invoice count matches the invoices created with the same filters
+func (suite *APITestSuite) TestCountInvoices() {
+	partnerID := suite.createTestBusinessPartner("Count Partner")
+
+	countInvoices := func(query string) int {
+		req, _ := http.NewRequest("GET", "/api/invoices/count?"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		suite.Require().Equal(http.StatusOK, w.Code)
+
+		var response struct {
+			Data struct {
+				Count int `json:"count"`
+			} `json:"data"`
+		}
+		suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+		return response.Data.Count
+	}
+
+	dueDate := time.Now().AddDate(2, 0, 0)
+	dateQuery := "start_date=" + dueDate.Format("2006-01-02") + "&end_date=" + dueDate.Format("2006-01-02")
+
+	unprocessedBefore := countInvoices("status=unprocessed")
+	rangeBefore := countInvoices("status=unprocessed&" + dateQuery)
+
+	for i := 0; i < 3; i++ {
+		suite.insertTestInvoice(partnerID, 10000, dueDate, models.InvoiceStatusUnprocessed)
+	}
+	suite.insertTestInvoice(partnerID, 10000, dueDate, models.InvoiceStatusPaid)
+
+	assert.Equal(suite.T(), unprocessedBefore+3, countInvoices("status=unprocessed"))
+	assert.Equal(suite.T(), rangeBefore+3, countInvoices("status=unprocessed&"+dateQuery))
+
+	// Invalid filters are rejected like the list endpoint
+	req, _ := http.NewRequest("GET", "/api/invoices/count?start_date=invalid", nil)
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+}
+
+// TestGetInvoicesKeysetPagination tests paging through invoices with the after cursor
+func (suite *APITestSuite) TestGetInvoicesKeysetPagination() {
+	partnerID := suite.createTestBusinessPartner("Cursor Partner")
+
+	// Use a unique due date so the dataset is isolated from other tests
+	dueDate := time.Now().AddDate(5, 0, 7)
+	var created []uint
+	for i := 0; i < 5; i++ {
+		created = append(created, suite.insertTestInvoice(partnerID, 10000, dueDate, models.InvoiceStatusUnprocessed).ID)
+	}
+	dateQuery := "start_date=" + dueDate.Format("2006-01-02") + "&end_date=" + dueDate.Format("2006-01-02")
+
+	var seen []uint
+	cursor := "0"
+	for pages := 0; pages < 10; pages++ {
+		req, _ := http.NewRequest("GET", "/api/invoices?limit=2&after="+cursor+"&"+dateQuery, nil)
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		suite.Require().Equal(http.StatusOK, w.Code)
+
+		var response models.InvoiceListResponse
+		suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+		for _, invoice := range response.Data {
+			seen = append(seen, invoice.ID)
+		}
+
+		if response.NextCursor == nil {
+			break
+		}
+		cursor = strconv.FormatUint(uint64(*response.NextCursor), 10)
+	}
+
+	// Every invoice is returned exactly once, newest first
+	suite.Require().Len(seen, len(created))
+	for i, id := range seen {
+		assert.Equal(suite.T(), created[len(created)-1-i], id)
+	}
+
+	req, _ := http.NewRequest("GET", "/api/invoices?after=abc", nil)
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+}
+
+// TestCreateInvoiceDuplicateCheck tests the duplicate warning and forcing creation through it
+func (suite *APITestSuite) TestCreateInvoiceDuplicateCheck() {
+	partnerID := suite.createTestBusinessPartner("Duplicate Partner")
+	dueDate := time.Now().AddDate(0, 1, 0)
+	originalID := suite.createTestInvoice(partnerID, 15000, dueDate)
+
+	postInvoice := func(query string) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(models.CreateInvoiceRequest{
+			BusinessPartnerID: partnerID,
+			PaymentAmount:     15000,
+			PaymentDueDate:    dueDate,
+		})
+		req, _ := http.NewRequest("POST", "/api/invoices?"+query, bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := postInvoice("check_duplicate=true")
+	suite.Require().Equal(http.StatusConflict, w.Code, w.Body.String())
+
+	var conflict struct {
+		Error string         `json:"error"`
+		Data  models.Invoice `json:"data"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &conflict))
+	assert.Equal(suite.T(), "possible_duplicate", conflict.Error)
+	assert.Equal(suite.T(), originalID, conflict.Data.ID)
+
+	w = postInvoice("check_duplicate=true&force=true")
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	var created struct {
+		Data models.Invoice `json:"data"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &created))
+	assert.NotEqual(suite.T(), originalID, created.Data.ID)
+}
+
+// TestCreateInvoiceDefaultPaymentTerms tests the due date defaulting and explicit override
+func (suite *APITestSuite) TestCreateInvoiceDefaultPaymentTerms() {
+	partnerID := suite.createTestBusinessPartner("Payment Terms Partner")
+	loc := suite.cfg.GetLocation()
+
+	createInvoice := func(requestData map[string]interface{}) models.Invoice {
+		jsonData, _ := json.Marshal(requestData)
+		req, _ := http.NewRequest("POST", "/api/invoices", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+		var response struct {
+			Data models.Invoice `json:"data"`
+		}
+		suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+		return response.Data
+	}
+
+	suite.T().Run("Due date defaults to issue date plus payment terms", func(t *testing.T) {
+		invoice := createInvoice(map[string]interface{}{
+			"business_partner_id": partnerID,
+			"payment_amount":      10000.0,
+		})
+
+		expected := models.DateOnly(time.Now(), loc).AddDate(0, 0, suite.cfg.App.DefaultPaymentTermDays)
+		assert.Equal(t, expected.Format("2006-01-02"), invoice.PaymentDueDate.In(loc).Format("2006-01-02"))
+		assert.Equal(t, invoice.IssueDate.In(loc).AddDate(0, 0, suite.cfg.App.DefaultPaymentTermDays).Format("2006-01-02"),
+			invoice.PaymentDueDate.In(loc).Format("2006-01-02"))
+	})
+
+	suite.T().Run("Explicit due date overrides payment terms", func(t *testing.T) {
+		dueDate := models.DateOnly(time.Now(), loc).AddDate(0, 0, 7)
+		invoice := createInvoice(map[string]interface{}{
+			"business_partner_id": partnerID,
+			"payment_amount":      10000.0,
+			"payment_due_date":    dueDate.Format(time.RFC3339),
+		})
+
+		assert.Equal(t, dueDate.Format("2006-01-02"), invoice.PaymentDueDate.In(loc).Format("2006-01-02"))
+	})
+}
+
+// TestDraftInvoiceLifecycle tests creating a draft, issuing it, and drafts being excluded from due-date reports
+func (suite *APITestSuite) TestDraftInvoiceLifecycle() {
+	partnerID := suite.createTestBusinessPartner("Draft Partner")
+
+	jsonData, _ := json.Marshal(models.CreateInvoiceRequest{
+		BusinessPartnerID: partnerID,
+		PaymentAmount:     10000,
+		PaymentDueDate:    time.Now().AddDate(0, 1, 0),
+	})
+	req, _ := http.NewRequest("POST", "/api/invoices?draft=true", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	var created struct {
+		Data models.Invoice `json:"data"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(suite.T(), models.InvoiceStatusDraft, created.Data.Status)
+
+	issue := func(invoiceID uint) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/invoices/"+strconv.FormatUint(uint64(invoiceID), 10)+"/issue", nil)
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w = issue(created.Data.ID)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	var issued struct {
+		Data models.Invoice `json:"data"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &issued))
+	loc := suite.cfg.GetLocation()
+	assert.Equal(suite.T(), models.InvoiceStatusUnprocessed, issued.Data.Status)
+	assert.Equal(suite.T(), time.Now().In(loc).Format("2006-01-02"), issued.Data.IssueDate.In(loc).Format("2006-01-02"))
+
+	history, err := suite.repo.GetInvoiceStatusHistory(created.Data.ID)
+	suite.Require().NoError(err)
+	suite.Require().Len(history, 1)
+	assert.Equal(suite.T(), models.InvoiceActionIssued, history[0].Action)
+
+	// Only drafts can be issued
+	assert.Equal(suite.T(), http.StatusConflict, issue(created.Data.ID).Code)
+	assert.Equal(suite.T(), http.StatusNotFound, issue(999999).Code)
+
+	// Requests that lose the race to change the draft are told so rather than failing
+	err = suite.repo.IssueInvoice(created.Data.ID, models.InvoiceStatusUnprocessed, time.Now(), suite.testUser.ID)
+	assert.ErrorIs(suite.T(), err, repository.ErrInvoiceChanged)
+	stale := created.Data
+	assert.ErrorIs(suite.T(), suite.repo.UpdateInvoiceAmounts(&stale), repository.ErrInvoiceChanged)
+
+	// Drafts dated in a closed accounting period stay drafts
+	cutoff := time.Date(2020, 12, 31, 0, 0, 0, 0, loc)
+	cfg := *suite.cfg
+	cfg.App.AccountingCutoff = &cutoff
+	closedDraft := suite.insertIssuedTestInvoice(partnerID, 10000, cutoff, cutoff.AddDate(0, 1, 0), models.InvoiceStatusDraft)
+	_, err = service.NewInvoiceService(suite.repo, &cfg).IssueInvoice(suite.testUserID, closedDraft.ID)
+	assert.ErrorIs(suite.T(), err, service.ErrPeriodClosed)
+
+	// Drafts past their due date are neither reported nor processed as overdue
+	draft := suite.insertTestInvoice(partnerID, 10000, time.Now().AddDate(0, 0, -3), models.InvoiceStatusDraft)
+
+	req, _ = http.NewRequest("GET", "/api/invoices/overdue?limit=100", nil)
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusOK, w.Code)
+
+	var overdue struct {
+		Data []models.Invoice `json:"data"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &overdue))
+	for _, invoice := range overdue.Data {
+		assert.NotEqual(suite.T(), draft.ID, invoice.ID)
+	}
+
+	_, err = suite.repo.MarkOverdueInvoices(time.Now(), nil)
+	suite.Require().NoError(err)
+	unchanged, err := suite.repo.GetInvoiceByID(draft.ID)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), models.InvoiceStatusDraft, unchanged.Status)
+}
+
+// TestCreateInvoiceTaxOnPaymentAmount tests invoice creation when consumption tax applies to the payment amount
+func (suite *APITestSuite) TestCreateInvoiceTaxOnPaymentAmount() {
+	cfg := *suite.cfg
+	cfg.App.TaxAppliesTo = config.TaxAppliesToPaymentAmount
+	svc := service.NewInvoiceService(suite.repo, &cfg)
+
+	partnerID := suite.createTestBusinessPartner("Tax Base Partner")
+	invoice, err := svc.CreateInvoice(suite.testUserID, &models.CreateInvoiceRequest{
+		BusinessPartnerID: partnerID,
+		PaymentAmount:     10000,
+		PaymentDueDate:    time.Now().AddDate(0, 1, 0),
+	})
+	suite.Require().NoError(err)
+
+	assert.InDelta(suite.T(), 400.0, invoice.Fee, 0.001)
+	assert.InDelta(suite.T(), 1040.0, invoice.ConsumptionTax, 0.001)
+	assert.InDelta(suite.T(), 11440.0, invoice.InvoiceAmount, 0.001)
+}
+
+// TestCreateInvoiceWithMemo tests persisting, returning and searching an invoice memo
+func (suite *APITestSuite) TestCreateInvoiceWithMemo() {
+	partnerID := suite.createTestBusinessPartner("Memo Partner")
+
+	postInvoice := func(memo string) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(models.CreateInvoiceRequest{
+			BusinessPartnerID: partnerID,
+			PaymentAmount:     10000,
+			PaymentDueDate:    time.Now().AddDate(0, 1, 0),
+			Memo:              &memo,
+		})
+		req, _ := http.NewRequest("POST", "/api/invoices", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w
+	}
+
+	memo := "PO-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	w := postInvoice(memo)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	var created struct {
+		Data models.Invoice `json:"data"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &created))
+	suite.Require().NotNil(created.Data.Memo)
+	assert.Equal(suite.T(), memo, *created.Data.Memo)
+
+	ids := suite.listInvoiceIDs("limit=100&search=" + memo[3:])
+	assert.Equal(suite.T(), []uint{created.Data.ID}, ids)
+
+	// Memos longer than 500 characters are rejected
+	w = postInvoice(strings.Repeat("m", 501))
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+
+	var response models.ErrorResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), "validation_error", response.Error)
+}
+
+// TestUpdateInvoiceStatusAccountingCutoff tests that invoices in a closed accounting period cannot be updated
+func (suite *APITestSuite) TestUpdateInvoiceStatusAccountingCutoff() {
+	loc := suite.cfg.GetLocation()
+	cutoff := time.Date(2020, 12, 31, 0, 0, 0, 0, loc)
+	cfg := *suite.cfg
+	cfg.App.AccountingCutoff = &cutoff
+	router := api.NewHandler(service.NewInvoiceService(suite.repo, &cfg), &cfg).SetupRoutes()
+
+	partnerID := suite.createTestBusinessPartner("Cutoff Partner")
+	closed := suite.insertIssuedTestInvoice(partnerID, 10000, cutoff, cutoff.AddDate(0, 1, 0), models.InvoiceStatusUnprocessed)
+	open := suite.insertTestInvoice(partnerID, 10000, time.Now().AddDate(0, 1, 0), models.InvoiceStatusUnprocessed)
+
+	updateStatus := func(invoiceID uint, status models.InvoiceStatus) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(models.UpdateInvoiceStatusRequest{Status: status})
+		req, _ := http.NewRequest("PATCH", "/api/invoices/"+strconv.FormatUint(uint64(invoiceID), 10)+"/status", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := updateStatus(open.ID, models.InvoiceStatusPaid)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	var updated struct {
+		Data models.Invoice `json:"data"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.Equal(suite.T(), models.InvoiceStatusPaid, updated.Data.Status)
+
+	history, err := suite.repo.GetInvoiceStatusHistory(open.ID)
+	suite.Require().NoError(err)
+	suite.Require().Len(history, 1)
+	assert.Equal(suite.T(), models.InvoiceActionStatusUpdated, history[0].Action)
+
+	w = updateStatus(closed.ID, models.InvoiceStatusPaid)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+
+	var response models.ErrorResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), "period_closed", response.Error)
+
+	unchanged, err := suite.repo.GetInvoiceByID(closed.ID)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), models.InvoiceStatusUnprocessed, unchanged.Status)
+
+	// Unknown statuses are rejected
+	assert.Equal(suite.T(), http.StatusBadRequest, updateStatus(open.ID, "archived").Code)
+}
+
+// TestMarkOverdueInvoicesAccountingCutoff tests that the overdue job leaves invoices issued in a
+// closed accounting period unprocessed
+func (suite *APITestSuite) TestMarkOverdueInvoicesAccountingCutoff() {
+	loc := suite.cfg.GetLocation()
+	cutoff := time.Date(2020, 12, 31, 0, 0, 0, 0, loc)
+	cfg := *suite.cfg
+	cfg.App.AccountingCutoff = &cutoff
+	svc := service.NewInvoiceService(suite.repo, &cfg)
+
+	partnerID := suite.createTestBusinessPartner("Overdue Cutoff Partner")
+	closed := suite.insertIssuedTestInvoice(partnerID, 10000, cutoff, cutoff.AddDate(0, 1, 0), models.InvoiceStatusUnprocessed)
+	open := suite.insertTestInvoice(partnerID, 10000, time.Now().AddDate(0, 0, -3), models.InvoiceStatusUnprocessed)
+
+	_, err := svc.MarkOverdueInvoices()
+	suite.Require().NoError(err)
+
+	unchanged, err := suite.repo.GetInvoiceByID(closed.ID)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), models.InvoiceStatusUnprocessed, unchanged.Status)
+	history, err := suite.repo.GetInvoiceStatusHistory(closed.ID)
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), history)
+
+	overdue, err := suite.repo.GetInvoiceByID(open.ID)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), models.InvoiceStatusError, overdue.Status)
+}
+
+// TestGetInvoiceHistory tests paginating and filtering the status history of an invoice
+func (suite *APITestSuite) TestGetInvoiceHistory() {
+	partnerID := suite.createTestBusinessPartner("History Partner")
+	invoice := suite.insertTestInvoice(partnerID, 10000, time.Now().AddDate(0, 0, 30), models.InvoiceStatusDraft)
+
+	// One issue followed by four status updates
+	suite.Require().NoError(suite.repo.IssueInvoice(invoice.ID, models.InvoiceStatusUnprocessed, time.Now(), suite.testUser.ID))
+	for i := 0; i < 2; i++ {
+		suite.Require().NoError(suite.repo.UpdateInvoiceStatus(invoice.ID, models.InvoiceStatusUnprocessed, models.InvoiceStatusProcessing, suite.testUser.ID))
+		suite.Require().NoError(suite.repo.UpdateInvoiceStatus(invoice.ID, models.InvoiceStatusProcessing, models.InvoiceStatusUnprocessed, suite.testUser.ID))
+	}
+
+	getHistory := func(query string) (*httptest.ResponseRecorder, models.InvoiceHistoryResponse) {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/invoices/%d/history%s", invoice.ID, query), nil)
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+
+		var response models.InvoiceHistoryResponse
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+
+	var ids []uint
+	for page, size := range []int{2, 2, 1} {
+		w, response := getHistory(fmt.Sprintf("?limit=2&page=%d", page+1))
+		suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+		suite.Require().Len(response.Data, size)
+		assert.Equal(suite.T(), page+1, response.Page)
+		assert.Equal(suite.T(), 5, response.Total)
+		assert.Equal(suite.T(), 3, response.TotalPages)
+		for _, entry := range response.Data {
+			ids = append(ids, entry.ID)
+		}
+	}
+	// Oldest first, without repeats across pages
+	assert.IsIncreasing(suite.T(), ids)
+	assert.Len(suite.T(), ids, 5)
+
+	w, response := getHistory("?limit=2&page=4")
+	suite.Require().Equal(http.StatusOK, w.Code)
+	assert.Empty(suite.T(), response.Data)
+	assert.NotNil(suite.T(), response.Data)
+
+	w, response = getHistory("?action=" + models.InvoiceActionIssued)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Require().Len(response.Data, 1)
+	assert.Equal(suite.T(), models.InvoiceActionIssued, response.Data[0].Action)
+	assert.Equal(suite.T(), 1, response.Total)
+	assert.Equal(suite.T(), 20, response.Limit)
+
+	w, _ = getHistory("?action=deleted")
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+	w, _ = getHistory("?limit=101")
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+	w, _ = getHistory("?page=0")
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+}
+
+// TestTimestampFormat tests that timestamps are serialized as second-precision RFC 3339 in the
+// configured timezone
+func (suite *APITestSuite) TestTimestampFormat() {
+	partnerID := suite.createTestBusinessPartner("Timestamp Partner")
+	invoice := suite.insertTestInvoice(partnerID, 10000, time.Now().AddDate(0, 0, 30), models.InvoiceStatusUnprocessed)
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/invoices/%d", invoice.ID), nil)
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+
+	_, offset := time.Now().In(suite.cfg.GetLocation()).Zone()
+	zone := "Z"
+	if offset != 0 {
+		zone = time.Now().In(suite.cfg.GetLocation()).Format("-07:00")
+	}
+	format := regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}` + regexp.QuoteMeta(zone) + `$`)
+
+	partner := response.Data["business_partner"].(map[string]interface{})
+	for field, value := range map[string]interface{}{
+		"created_at":                  response.Data["created_at"],
+		"updated_at":                  response.Data["updated_at"],
+		"issue_date":                  response.Data["issue_date"],
+		"payment_due_date":            response.Data["payment_due_date"],
+		"business_partner.created_at": partner["created_at"],
+		"business_partner.updated_at": partner["updated_at"],
+	} {
+		timestamp, ok := value.(string)
+		suite.Require().True(ok, "%s is not a string", field)
+		assert.Regexp(suite.T(), format, timestamp, field)
+	}
+
+	// The serialized values still denote the stored instants
+	stored, err := suite.repo.GetInvoiceByID(invoice.ID)
+	suite.Require().NoError(err)
+	for field, expected := range map[string]time.Time{
+		"created_at":       stored.CreatedAt.Time,
+		"payment_due_date": stored.PaymentDueDate.Time,
+	} {
+		parsed, err := time.Parse(time.RFC3339, response.Data[field].(string))
+		suite.Require().NoError(err)
+		assert.True(suite.T(), parsed.Equal(expected.Truncate(time.Second)), "%s: %s != %s", field, parsed, expected)
+	}
+}
+
+// TestCloneInvoice tests cloning an invoice with a new due date and recomputed totals
+func (suite *APITestSuite) TestCloneInvoice() {
+	partnerID := suite.createTestBusinessPartner("Clone Partner")
+	source := suite.insertIssuedTestInvoice(partnerID, 10000, time.Now().AddDate(0, -2, 0), time.Now().AddDate(0, -1, 0), models.InvoiceStatusPaid)
+
+	// Rates changed since the source was issued: tax now applies to the payment amount
+	cfg := *suite.cfg
+	cfg.App.TaxAppliesTo = config.TaxAppliesToPaymentAmount
+	router := api.NewHandler(service.NewInvoiceService(suite.repo, &cfg), &cfg).SetupRoutes()
+
+	loc := suite.cfg.GetLocation()
+	dueDate := models.DateOnly(time.Now(), loc).AddDate(0, 0, 14)
+	jsonData, _ := json.Marshal(map[string]interface{}{"payment_due_date": dueDate.Format(time.RFC3339)})
+	req, _ := http.NewRequest("POST", "/api/invoices/"+strconv.FormatUint(uint64(source.ID), 10)+"/clone", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		Data models.Invoice `json:"data"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	clone := response.Data
+
+	assert.NotEqual(suite.T(), source.ID, clone.ID)
+	assert.Equal(suite.T(), partnerID, clone.BusinessPartnerID)
+	assert.Equal(suite.T(), models.InvoiceStatusUnprocessed, clone.Status)
+	assert.InDelta(suite.T(), source.PaymentAmount, clone.PaymentAmount, 0.001)
+	assert.InDelta(suite.T(), 1040.0, clone.ConsumptionTax, 0.001)
+	assert.InDelta(suite.T(), 11440.0, clone.InvoiceAmount, 0.001)
+	assert.Equal(suite.T(), time.Now().In(loc).Format("2006-01-02"), clone.IssueDate.In(loc).Format("2006-01-02"))
+	assert.Equal(suite.T(), dueDate.Format("2006-01-02"), clone.PaymentDueDate.In(loc).Format("2006-01-02"))
+
+	// Without a body the clone uses the default payment terms
+	req, _ = http.NewRequest("POST", "/api/invoices/"+strconv.FormatUint(uint64(source.ID), 10)+"/clone?draft=true", nil)
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), models.InvoiceStatusDraft, response.Data.Status)
+	assert.Equal(suite.T(),
+		models.DateOnly(time.Now(), loc).AddDate(0, 0, cfg.App.DefaultPaymentTermDays).Format("2006-01-02"),
+		response.Data.PaymentDueDate.In(loc).Format("2006-01-02"))
+
+	req, _ = http.NewRequest("POST", "/api/invoices/999999/clone", nil)
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+}
+
+// TestDownloadInvoicePDFTemplates tests rendering an invoice PDF with each template
+func (suite *APITestSuite) TestDownloadInvoicePDFTemplates() {
+	partnerID := suite.createTestBusinessPartner("PDF Template Partner")
+	invoiceID := suite.createTestInvoice(partnerID, 10000, time.Now().AddDate(0, 1, 0))
+	path := "/api/invoices/" + strconv.FormatUint(uint64(invoiceID), 10) + "/pdf"
+
+	download := func(query string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path+query, nil)
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w
+	}
+
+	rendered := map[string][]byte{}
+	for _, template := range []string{"standard", "detailed"} {
+		w := download("?template=" + template)
+		suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(suite.T(), "application/pdf", w.Header().Get("Content-Type"))
+		assert.True(suite.T(), bytes.HasPrefix(w.Body.Bytes(), []byte("%PDF-")), "%s should render a PDF", template)
+		rendered[template] = w.Body.Bytes()
+	}
+	assert.NotEqual(suite.T(), rendered["standard"], rendered["detailed"])
+
+	// Without a template the standard layout is used
+	w := download("")
+	suite.Require().Equal(http.StatusOK, w.Code)
+	assert.Equal(suite.T(), rendered["standard"], w.Body.Bytes())
+
+	// The file is named after the invoice number printed on the document
+	invoice, err := suite.repo.GetInvoiceByID(invoiceID)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), `attachment; filename="`+invoice.InvoiceNumber+`.pdf"`, w.Header().Get("Content-Disposition"))
+
+	w = download("?template=fancy")
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+	var response models.ErrorResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), "validation_error", response.Error)
+
+	req, _ := http.NewRequest("GET", "/api/invoices/999999/pdf", nil)
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+}
+
+// TestGetInvoiceStatusFacets tests listing invoice counts per status, including empty statuses
+func (suite *APITestSuite) TestGetInvoiceStatusFacets() {
+	partnerID := suite.createTestBusinessPartner("Facet Partner")
+
+	getFacets := func() map[models.InvoiceStatus]int {
+		req, _ := http.NewRequest("GET", "/api/invoices/status-facets", nil)
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+		var response struct {
+			Data []models.InvoiceStatusCount `json:"data"`
+		}
+		suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+
+		facets := map[models.InvoiceStatus]int{}
+		for _, facet := range response.Data {
+			facets[facet.Status] = facet.Count
+		}
+		return facets
+	}
+
+	before := getFacets()
+	for _, status := range []models.InvoiceStatus{
+		models.InvoiceStatusUnprocessed,
+		models.InvoiceStatusProcessing,
+		models.InvoiceStatusPaid,
+		models.InvoiceStatusError,
+	} {
+		assert.Contains(suite.T(), before, status, "status %s should be listed even without invoices", status)
+	}
+
+	dueDate := time.Now().AddDate(0, 1, 0)
+	added := map[models.InvoiceStatus]int{
+		models.InvoiceStatusUnprocessed: 3,
+		models.InvoiceStatusProcessing:  1,
+		models.InvoiceStatusPaid:        2,
+		models.InvoiceStatusError:       1,
+	}
+	for status, n := range added {
+		for i := 0; i < n; i++ {
+			suite.insertTestInvoice(partnerID, 10000, dueDate, status)
+		}
+	}
+
+	after := getFacets()
+	for status, n := range added {
+		assert.Equal(suite.T(), before[status]+n, after[status], "count for %s", status)
+	}
+}
+
+// TestGetInvoicesFlat tests the flat invoice shape for BI tools
+func (suite *APITestSuite) TestGetInvoicesFlat() {
+	partnerID := suite.createTestBusinessPartner("Flat Partner")
+	dueDate := time.Now().AddDate(6, 0, 3)
+	invoice := suite.insertTestInvoice(partnerID, 10000, dueDate, models.InvoiceStatusUnprocessed)
+	dateQuery := "start_date=" + dueDate.Format("2006-01-02") + "&end_date=" + dueDate.Format("2006-01-02")
+
+	for _, query := range []string{"flat=true&" + dateQuery, "flat=true&after=0&" + dateQuery} {
+		req, _ := http.NewRequest("GET", "/api/invoices?"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+		var response struct {
+			Data []map[string]interface{} `json:"data"`
+		}
+		suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+		suite.Require().Len(response.Data, 1, query)
+
+		record := response.Data[0]
+		assert.EqualValues(suite.T(), invoice.ID, record["id"])
+		assert.Equal(suite.T(), "Flat Partner", record["business_partner_name"])
+		assert.Equal(suite.T(), suite.testCompany.CorporateName, record["company_name"])
+		for key, value := range record {
+			switch value.(type) {
+			case map[string]interface{}, []interface{}:
+				assert.Fail(suite.T(), "flat invoice should not contain nested values", "field %s", key)
+			}
+		}
+	}
+}
+
+// TestInvoiceCreatedByUser tests that invoices record the user who created them
+func (suite *APITestSuite) TestInvoiceCreatedByUser() {
+	partnerID := suite.createTestBusinessPartner("Creator Partner")
+	invoiceID := suite.createTestInvoice(partnerID, 10000, time.Now().AddDate(0, 1, 0))
+
+	req, _ := http.NewRequest("GET", "/api/invoices/"+strconv.FormatUint(uint64(invoiceID), 10), nil)
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		Data models.Invoice `json:"data"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	suite.Require().NotNil(response.Data.CreatedByUserID)
+	assert.Equal(suite.T(), suite.testUser.ID, *response.Data.CreatedByUserID)
+	suite.Require().NotNil(response.Data.CreatedByName)
+	assert.Equal(suite.T(), suite.testUser.FullName, *response.Data.CreatedByName)
+}
+
+// TestGetInvoicesCreatedBy tests filtering invoices by the user who created them
+func (suite *APITestSuite) TestGetInvoicesCreatedBy() {
+	partnerID := suite.createTestBusinessPartner("Created By Partner")
+	dueDate := time.Now().AddDate(0, 1, 0)
+	partnerQuery := "partner_name=Created+By+Partner"
+
+	alice, aliceToken := suite.createTestCompanyUser(suite.testCompany.ID, "Alice Member", models.UserRoleMember)
+	_, bobToken := suite.createTestCompanyUser(suite.testCompany.ID, "Bob Member", models.UserRoleMember)
+
+	aliceInvoices := []uint{
+		suite.createTestInvoiceAs(aliceToken, partnerID, 10000, dueDate),
+		suite.createTestInvoiceAs(aliceToken, partnerID, 20000, dueDate),
+	}
+	bobInvoices := []uint{suite.createTestInvoiceAs(bobToken, partnerID, 30000, dueDate)}
+
+	assert.ElementsMatch(suite.T(), aliceInvoices, suite.listInvoiceIDsAs(aliceToken, "created_by=me&"+partnerQuery))
+	assert.ElementsMatch(suite.T(), bobInvoices, suite.listInvoiceIDsAs(bobToken, "created_by=me&"+partnerQuery))
+	assert.Len(suite.T(), suite.listInvoiceIDsAs(bobToken, partnerQuery), len(aliceInvoices)+len(bobInvoices))
+
+	// Company admins may filter by any user, members only by themselves
+	assert.ElementsMatch(suite.T(), aliceInvoices,
+		suite.listInvoiceIDs("created_by="+strconv.FormatUint(uint64(alice.ID), 10)+"&"+partnerQuery))
+
+	for query, expected := range map[string]int{
+		"created_by=" + strconv.FormatUint(uint64(alice.ID), 10): http.StatusForbidden,
+		"created_by=someone": http.StatusBadRequest,
+	} {
+		req, _ := http.NewRequest("GET", "/api/invoices?"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+bobToken)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		assert.Equal(suite.T(), expected, w.Code, query)
+	}
+}
+
+// TestGetInvoicesPartnerNameFilter tests searching invoices by a partner name substring
+func (suite *APITestSuite) TestGetInvoicesPartnerNameFilter() {
+	sakuraID := suite.createTestBusinessPartner("Sakura Trading 100%_Co")
+	fujiID := suite.createTestBusinessPartner("Fuji Logistics")
+
+	dueDate := time.Now().AddDate(0, 2, 3)
+	sakuraInvoice := suite.createTestInvoice(sakuraID, 10000, dueDate)
+	fujiInvoice := suite.createTestInvoice(fujiID, 20000, dueDate)
+
+	dateQuery := "limit=100&start_date=" + dueDate.Format("2006-01-02") + "&end_date=" + dueDate.Format("2006-01-02")
+
+	ids := suite.listInvoiceIDs(dateQuery + "&partner_name=" + url.QueryEscape("kura Trad"))
+	assert.Contains(suite.T(), ids, sakuraInvoice)
+	assert.NotContains(suite.T(), ids, fujiInvoice)
+
+	// Wildcards in the search text match literally
+	ids = suite.listInvoiceIDs(dateQuery + "&partner_name=" + url.QueryEscape("100%_Co"))
+	assert.Contains(suite.T(), ids, sakuraInvoice)
+	assert.NotContains(suite.T(), ids, fujiInvoice)
+
+	ids = suite.listInvoiceIDs(dateQuery + "&partner_name=" + url.QueryEscape("%"))
+	assert.Contains(suite.T(), ids, sakuraInvoice)
+	assert.NotContains(suite.T(), ids, fujiInvoice)
+}
+
+// TestInvoiceCalendarFeed tests the iCalendar feed of upcoming unprocessed invoices and its scoped token
+func (suite *APITestSuite) TestInvoiceCalendarFeed() {
+	partnerID := suite.createTestBusinessPartner("Calendar; Partner")
+	upcoming := suite.insertTestInvoice(partnerID, 10000, time.Now().AddDate(0, 0, 5), models.InvoiceStatusUnprocessed)
+	overdue := suite.insertTestInvoice(partnerID, 20000, time.Now().AddDate(0, 0, -5), models.InvoiceStatusUnprocessed)
+	paid := suite.insertTestInvoice(partnerID, 30000, time.Now().AddDate(0, 0, 5), models.InvoiceStatusPaid)
+
+	req, _ := http.NewRequest("POST", "/api/auth/calendar-token", nil)
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	var tokenResponse models.CalendarTokenResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &tokenResponse))
+	suite.Require().NotEmpty(tokenResponse.Token)
+	assert.True(suite.T(), tokenResponse.ExpiresAt.After(time.Now().AddDate(0, 0, suite.cfg.JWT.CalendarTokenExpiryDays-1)))
+
+	req, _ = http.NewRequest("GET", tokenResponse.FeedPath, nil)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	assert.True(suite.T(), strings.HasPrefix(w.Header().Get("Content-Type"), "text/calendar"))
+
+	body := w.Body.String()
+	assert.True(suite.T(), strings.HasPrefix(body, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	assert.True(suite.T(), strings.HasSuffix(body, "END:VCALENDAR\r\n"))
+
+	expected, err := service.NewInvoiceService(suite.repo, suite.cfg).GetUpcomingInvoices(suite.testUserID)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), len(expected), strings.Count(body, "BEGIN:VEVENT\r\n"))
+	assert.Equal(suite.T(), len(expected), strings.Count(body, "END:VEVENT\r\n"))
+
+	uid := func(id uint) string {
+		return "UID:invoice-" + strconv.FormatUint(uint64(id), 10) + "@super-payment\r\n"
+	}
+	assert.Contains(suite.T(), body, uid(upcoming.ID))
+	assert.NotContains(suite.T(), body, uid(overdue.ID))
+	assert.NotContains(suite.T(), body, uid(paid.ID))
+	assert.Contains(suite.T(), body, "DTSTART;VALUE=DATE:"+upcoming.PaymentDueDate.Format("20060102")+"\r\n")
+	assert.Contains(suite.T(), body, fmt.Sprintf(`SUMMARY:Calendar\; Partner %.2f`, upcoming.InvoiceAmount))
+
+	// The feed requires a calendar-scoped token, which in turn grants no other API access
+	for _, path := range []string{"/api/invoices/calendar.ics", "/api/invoices/calendar.ics?token=" + suite.authToken} {
+		req, _ = http.NewRequest("GET", path, nil)
+		w = httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		assert.Equal(suite.T(), http.StatusUnauthorized, w.Code, path)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/invoices", nil)
+	req.Header.Set("Authorization", "Bearer "+tokenResponse.Token)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusUnauthorized, w.Code)
+}
+
+// TestCreateInvoiceCurrency tests storing invoice currencies and rounding to their minor unit
+func (suite *APITestSuite) TestCreateInvoiceCurrency() {
+	cfg := *suite.cfg
+	cfg.App.AllowedCurrencies = []string{"JPY", "USD"}
+	router := api.NewHandler(service.NewInvoiceService(suite.repo, &cfg), &cfg).SetupRoutes()
+
+	partnerID := suite.createTestBusinessPartner("Currency Partner")
+	dueDate := time.Now().AddDate(0, 1, 0).Format(time.RFC3339)
+
+	createInvoice := func(body map[string]interface{}) *httptest.ResponseRecorder {
+		body["business_partner_id"] = partnerID
+		body["payment_due_date"] = dueDate
+		jsonData, _ := json.Marshal(body)
+		req, _ := http.NewRequest("POST", "/api/invoices", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	var response struct {
+		Data models.Invoice `json:"data"`
+	}
+
+	// Yen has no minor unit: 1111 + 4% fee + 10% tax on the fee = 1159.884, billed as 1160
+	w := createInvoice(map[string]interface{}{"payment_amount": 1111})
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), "JPY", response.Data.Currency)
+	assert.Equal(suite.T(), 1160.0, response.Data.InvoiceAmount)
+
+	// Cents are kept: 100.25 + 4.01 fee + 0.401 tax = 104.661, billed as 104.66
+	w = createInvoice(map[string]interface{}{"payment_amount": 100.25, "currency": "usd"})
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), "USD", response.Data.Currency)
+	assert.Equal(suite.T(), 104.66, response.Data.InvoiceAmount)
+
+	stored, err := suite.repo.GetInvoiceByID(response.Data.ID)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "USD", stored.Currency)
+	assert.Equal(suite.T(), 100.25, stored.PaymentAmount)
+
+	for name, body := range map[string]map[string]interface{}{
+		"fractional yen":        {"payment_amount": 100.5, "currency": "JPY"},
+		"fractional cents":      {"payment_amount": 100.255, "currency": "USD"},
+		"currency not allowed":  {"payment_amount": 100, "currency": "EUR"},
+		"fractional line items": {"line_items": []map[string]interface{}{{"description": "Item", "quantity": 1, "unit_price": 99.5}}},
+	} {
+		w := createInvoice(body)
+		assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code, name)
+
+		var errResponse models.ErrorResponse
+		suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &errResponse))
+		assert.Equal(suite.T(), "validation_error", errResponse.Error, name)
+	}
+}
+
+// TestGetInvoiceByNumber tests retrieving an invoice by its invoice number within the caller's company
+func (suite *APITestSuite) TestGetInvoiceByNumber() {
+	// Companies number their invoices independently, so two fresh companies are used to know
+	// exactly which numbers each of them has
+	newInvoice := func(partner *models.BusinessPartner) *models.Invoice {
+		invoice := &models.Invoice{
+			CompanyID:         partner.CompanyID,
+			BusinessPartnerID: partner.ID,
+			IssueDate:         models.NewTimestamp(time.Now()),
+			PaymentAmount:     10000,
+			InvoiceAmount:     10440,
+			PaymentDueDate:    models.NewTimestamp(time.Now().AddDate(0, 1, 0)),
+			Status:            models.InvoiceStatusUnprocessed,
+			Currency:          models.DefaultCurrency,
+		}
+		suite.Require().NoError(suite.repo.CreateInvoice(invoice))
+		return invoice
+	}
+
+	partner := suite.createOtherCompanyPartner("Number")
+	_, token := suite.createTestCompanyUser(partner.CompanyID, "Number User", models.UserRoleAdmin)
+
+	invoiceID := suite.createTestInvoiceAs(token, partner.ID, 10000, time.Now().AddDate(0, 1, 0))
+	created, err := suite.repo.GetInvoiceByID(invoiceID)
+	suite.Require().NoError(err)
+	suite.Require().Equal("INV-000001", created.InvoiceNumber)
+
+	otherPartner := suite.createOtherCompanyPartner("Other Number")
+	otherFirst := newInvoice(otherPartner)
+	otherSecond := newInvoice(otherPartner)
+	suite.Require().Equal("INV-000001", otherFirst.InvoiceNumber)
+	suite.Require().Equal("INV-000002", otherSecond.InvoiceNumber)
+
+	getByNumber := func(number string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/api/invoices/by-number/"+number, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// A number both companies use resolves to the caller's own invoice
+	w := getByNumber(created.InvoiceNumber)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		Data models.Invoice `json:"data"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), invoiceID, response.Data.ID)
+	assert.Equal(suite.T(), partner.CompanyID, response.Data.CompanyID)
+	assert.Equal(suite.T(), created.InvoiceNumber, response.Data.InvoiceNumber)
+
+	// A number only the other company uses is not found
+	w = getByNumber(otherSecond.InvoiceNumber)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+
+	w = getByNumber("INV-NOPE")
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+
+	var errResponse models.ErrorResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &errResponse))
+	assert.Equal(suite.T(), "invoice_not_found", errResponse.Error)
+}
+
+// TestInvoiceAttachments tests recording and listing document metadata attached to an invoice
+func (suite *APITestSuite) TestInvoiceAttachments() {
+	partnerID := suite.createTestBusinessPartner("Attachment Partner")
+	invoiceID := suite.createTestInvoice(partnerID, 10000, time.Now().AddDate(0, 1, 0))
+
+	attach := func(invoiceID uint, body map[string]interface{}) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(body)
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/invoices/%d/attachments", invoiceID), bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w
+	}
+	listAttachments := func(invoiceID uint) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/invoices/%d/attachments", invoiceID), nil)
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w
+	}
+
+	var listResponse struct {
+		Data []models.InvoiceAttachment `json:"data"`
+	}
+	w := listAttachments(invoiceID)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &listResponse))
+	assert.Empty(suite.T(), listResponse.Data)
+
+	w = attach(invoiceID, map[string]interface{}{
+		"filename":     "contract.pdf",
+		"content_type": "Application/PDF; charset=binary",
+		"storage_url":  "https://storage.example.com/contracts/contract.pdf",
+		"size":         524288,
+	})
+	suite.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+	var createResponse struct {
+		Data models.InvoiceAttachment `json:"data"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &createResponse))
+	assert.NotZero(suite.T(), createResponse.Data.ID)
+	assert.Equal(suite.T(), invoiceID, createResponse.Data.InvoiceID)
+	assert.Equal(suite.T(), "application/pdf", createResponse.Data.ContentType)
+
+	w = attach(invoiceID, map[string]interface{}{
+		"filename":     "signature.png",
+		"content_type": "image/png",
+		"storage_url":  "https://storage.example.com/contracts/signature.png",
+		"size":         2048,
+	})
+	suite.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+
+	w = listAttachments(invoiceID)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &listResponse))
+	suite.Require().Len(listResponse.Data, 2)
+	assert.Equal(suite.T(), "contract.pdf", listResponse.Data[0].Filename)
+	assert.Equal(suite.T(), "https://storage.example.com/contracts/contract.pdf", listResponse.Data[0].StorageURL)
+	assert.Equal(suite.T(), int64(524288), listResponse.Data[0].Size)
+	assert.Equal(suite.T(), "signature.png", listResponse.Data[1].Filename)
+
+	valid := map[string]interface{}{
+		"filename":     "contract.pdf",
+		"content_type": "application/pdf",
+		"storage_url":  "https://storage.example.com/contracts/contract.pdf",
+		"size":         1024,
+	}
+	invalid := func(key string, value interface{}) map[string]interface{} {
+		body := make(map[string]interface{}, len(valid))
+		for k, v := range valid {
+			body[k] = v
+		}
+		body[key] = value
+		return body
+	}
+	for name, tc := range map[string]struct {
+		body           map[string]interface{}
+		expectedStatus int
+	}{
+		"missing filename":        {invalid("filename", ""), http.StatusBadRequest},
+		"zero size":               {invalid("size", 0), http.StatusBadRequest},
+		"disallowed content type": {invalid("content_type", "application/x-msdownload"), http.StatusUnprocessableEntity},
+		"malformed content type":  {invalid("content_type", "pdf"), http.StatusUnprocessableEntity},
+		"relative storage url":    {invalid("storage_url", "/contracts/contract.pdf"), http.StatusUnprocessableEntity},
+		"non-http storage url":    {invalid("storage_url", "file:///etc/passwd"), http.StatusUnprocessableEntity},
+		"filename with a path":    {invalid("filename", "../contract.pdf"), http.StatusUnprocessableEntity},
+	} {
+		w := attach(invoiceID, tc.body)
+		assert.Equal(suite.T(), tc.expectedStatus, w.Code, name)
+	}
+
+	// Attachments of another company's invoices can neither be added nor listed
+	partner := suite.createOtherCompanyPartner("Other Attachment")
+	other := &models.Invoice{
+		CompanyID:         partner.CompanyID,
+		BusinessPartnerID: partner.ID,
+		IssueDate:         models.NewTimestamp(time.Now()),
+		PaymentAmount:     10000,
+		InvoiceAmount:     10440,
+		PaymentDueDate:    models.NewTimestamp(time.Now().AddDate(0, 1, 0)),
+		Status:            models.InvoiceStatusUnprocessed,
+		Currency:          models.DefaultCurrency,
+	}
+	suite.Require().NoError(suite.repo.CreateInvoice(other))
+	suite.Require().NoError(suite.repo.CreateInvoiceAttachment(&models.InvoiceAttachment{
+		InvoiceID:   other.ID,
+		Filename:    "secret.pdf",
+		ContentType: "application/pdf",
+		StorageURL:  "https://storage.example.com/secret.pdf",
+		Size:        1024,
+	}))
+
+	assert.Equal(suite.T(), http.StatusNotFound, attach(other.ID, valid).Code)
+	w = listAttachments(other.ID)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+	assert.NotContains(suite.T(), w.Body.String(), "secret.pdf")
+
+	attachments, err := suite.repo.GetInvoiceAttachments(other.ID)
+	suite.Require().NoError(err)
+	assert.Len(suite.T(), attachments, 1)
+}
+
+// TestRecalculateInvoice tests re-pricing unprocessed invoices after the company's fee rate changes
+func (suite *APITestSuite) TestRecalculateInvoice() {
+	partnerID := suite.createTestBusinessPartner("Recalculation Partner")
+	invoiceID := suite.createTestInvoice(partnerID, 10000, time.Now().AddDate(0, 1, 0))
+	paid := suite.insertTestInvoice(partnerID, 10000, time.Now().AddDate(0, 1, 0), models.InvoiceStatusPaid)
+
+	setFeeRate := func(companyID uint, body string, adminToken string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("PUT", fmt.Sprintf("/api/admin/companies/%d/fee-rate", companyID), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Admin-Token", adminToken)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w
+	}
+	recalculate := func(invoiceID uint) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/invoices/%d/recalculate", invoiceID), nil)
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// The negotiated rate is set by operators only
+	assert.Equal(suite.T(), http.StatusForbidden, setFeeRate(suite.testCompany.ID, `{"fee_rate": 0.03}`, "wrong-token").Code)
+	assert.Equal(suite.T(), http.StatusBadRequest, setFeeRate(suite.testCompany.ID, `{"fee_rate": 1.5}`, suite.cfg.Admin.Token).Code)
+	assert.Equal(suite.T(), http.StatusNotFound, setFeeRate(999999999, `{"fee_rate": 0.03}`, suite.cfg.Admin.Token).Code)
+
+	w := setFeeRate(suite.testCompany.ID, `{"fee_rate": 0.03}`, suite.cfg.Admin.Token)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	defer func() {
+		suite.Require().NoError(suite.repo.SetCompanyFeeRate(suite.testCompany.ID, nil))
+	}()
+
+	// Recalculation leaves the payment amount and charges 3%: 10000 + 300 fee + 30 tax
+	w = recalculate(invoiceID)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Data models.Invoice `json:"data"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), 10000.0, response.Data.PaymentAmount)
+	assert.Equal(suite.T(), 0.03, response.Data.FeeRate)
+	assert.Equal(suite.T(), 300.0, response.Data.Fee)
+	assert.Equal(suite.T(), 30.0, response.Data.ConsumptionTax)
+	assert.Equal(suite.T(), 10330.0, response.Data.InvoiceAmount)
+
+	stored, err := suite.repo.GetInvoiceByID(invoiceID)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 0.03, stored.FeeRate)
+	assert.Equal(suite.T(), 10330.0, stored.InvoiceAmount)
+	assert.Equal(suite.T(), models.InvoiceStatusUnprocessed, stored.Status)
+
+	// New invoices use the negotiated rate too
+	newInvoice, err := suite.repo.GetInvoiceByID(suite.createTestInvoice(partnerID, 10000, time.Now().AddDate(0, 1, 0)))
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 10330.0, newInvoice.InvoiceAmount)
+
+	// Invoices past the unprocessed status keep their amounts
+	w = recalculate(paid.ID)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+	var errResponse models.ErrorResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &errResponse))
+	assert.Equal(suite.T(), "invoice_locked", errResponse.Error)
+	unchanged, err := suite.repo.GetInvoiceByID(paid.ID)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), paid.InvoiceAmount, unchanged.InvoiceAmount)
+
+	assert.Equal(suite.T(), http.StatusNotFound, recalculate(999999999).Code)
+
+	// Reverting to the default rate re-prices at 4% again
+	suite.Require().Equal(http.StatusOK, setFeeRate(suite.testCompany.ID, `{"fee_rate": null}`, suite.cfg.Admin.Token).Code)
+	w = recalculate(invoiceID)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), 10440.0, response.Data.InvoiceAmount)
+}
+
+// TestCalculateBatch tests quoting several payment amounts at once
+func (suite *APITestSuite) TestCalculateBatch() {
+	calculate := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/invoices/calculate-batch", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Default rates: 4% fee and 10% consumption tax on the fee, rounded to whole yen
+	w := calculate(`{"amounts": [10000, 12345, 500]}`)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Data []models.InvoiceBreakdown `json:"data"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	suite.Require().Len(response.Data, 3)
+
+	expected := []models.InvoiceBreakdown{
+		{PaymentAmount: 10000, FeeRate: 0.04, Fee: 400, ConsumptionTaxRate: 0.10, ConsumptionTax: 40, InvoiceAmount: 10440},
+		{PaymentAmount: 12345, FeeRate: 0.04, Fee: 493.8, ConsumptionTaxRate: 0.10, ConsumptionTax: 49.38, InvoiceAmount: 12888},
+		{PaymentAmount: 500, FeeRate: 0.04, Fee: 20, ConsumptionTaxRate: 0.10, ConsumptionTax: 2, InvoiceAmount: 522},
+	}
+	for i, want := range expected {
+		got := response.Data[i]
+		assert.Equal(suite.T(), want.PaymentAmount, got.PaymentAmount)
+		assert.Equal(suite.T(), want.FeeRate, got.FeeRate)
+		assert.InDelta(suite.T(), want.Fee, got.Fee, 0.001)
+		assert.Equal(suite.T(), want.ConsumptionTaxRate, got.ConsumptionTaxRate)
+		assert.InDelta(suite.T(), want.ConsumptionTax, got.ConsumptionTax, 0.001)
+		assert.Equal(suite.T(), want.InvoiceAmount, got.InvoiceAmount)
+	}
+
+	// Explicit rates override the defaults
+	w = calculate(`{"amounts": [10000], "fee_rate": 0.03, "tax_rate": 0.08}`)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	suite.Require().Len(response.Data, 1)
+	assert.InDelta(suite.T(), 300.0, response.Data[0].Fee, 0.001)
+	assert.InDelta(suite.T(), 24.0, response.Data[0].ConsumptionTax, 0.001)
+	assert.Equal(suite.T(), 10324.0, response.Data[0].InvoiceAmount)
+
+	// Amounts must be positive and at least one must be given
+	for _, body := range []string{
+		`{"amounts": []}`,
+		`{"amounts": [10000, 0]}`,
+		`{"amounts": [-100]}`,
+		`{"amounts": [10000], "fee_rate": 1.5}`,
+		`{}`,
+	} {
+		w = calculate(body)
+		assert.Equal(suite.T(), http.StatusBadRequest, w.Code, body)
+	}
+}
+
+// TestGetInvoicesDisplayCurrency tests presenting invoice amounts in another currency
+func (suite *APITestSuite) TestGetInvoicesDisplayCurrency() {
+	cfg := *suite.cfg
+	cfg.App.FXRates = map[string]float64{"JPY": 1, "USD": 0.0067}
+	router := api.NewHandler(service.NewInvoiceService(suite.repo, &cfg), &cfg).SetupRoutes()
+
+	partnerName := fmt.Sprintf("Display Currency Partner %d", time.Now().UnixNano())
+	partnerID := suite.createTestBusinessPartner(partnerName)
+	invoice := suite.insertTestInvoice(partnerID, 10000, time.Now().AddDate(0, 1, 0), models.InvoiceStatusUnprocessed)
+
+	listInvoices := func(query string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/api/invoices?partner_name="+url.QueryEscape(partnerName)+query, nil)
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	var response struct {
+		Data []models.Invoice `json:"data"`
+	}
+
+	// 10440 JPY at 0.0067 USD per yen is 69.948, shown as 69.95
+	w := listInvoices("&display_currency=usd")
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	suite.Require().Len(response.Data, 1)
+	assert.Equal(suite.T(), "JPY", response.Data[0].Currency)
+	assert.Equal(suite.T(), 10440.0, response.Data[0].InvoiceAmount)
+	assert.Equal(suite.T(), "USD", response.Data[0].DisplayCurrency)
+	suite.Require().NotNil(response.Data[0].DisplayAmount)
+	assert.Equal(suite.T(), 69.95, *response.Data[0].DisplayAmount)
+
+	// The flat shape carries the display amount too
+	w = listInvoices("&display_currency=USD&flat=true")
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var flat struct {
+		Data []models.FlatInvoice `json:"data"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &flat))
+	suite.Require().Len(flat.Data, 1)
+	suite.Require().NotNil(flat.Data[0].DisplayAmount)
+	assert.Equal(suite.T(), 69.95, *flat.Data[0].DisplayAmount)
+
+	// Without display_currency the response only has the stored values
+	w = listInvoices("")
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	assert.NotContains(suite.T(), w.Body.String(), "display_amount")
+
+	w = listInvoices("&display_currency=GBP")
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+	var errResponse models.ErrorResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &errResponse))
+	assert.Equal(suite.T(), "validation_error", errResponse.Error)
+
+	stored, err := suite.repo.GetInvoiceByID(invoice.ID)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "JPY", stored.Currency)
+	assert.Equal(suite.T(), 10440.0, stored.InvoiceAmount)
+}
+
+// TestRecordInvoicePayments tests that a partial payment leaves an invoice partially paid and that
+// the payment settling the outstanding amount marks it paid
+func (suite *APITestSuite) TestRecordInvoicePayments() {
+	partnerID := suite.createTestBusinessPartner("Installment Partner")
+	invoice := suite.insertTestInvoice(partnerID, 10000, time.Now().AddDate(0, 0, 30), models.InvoiceStatusUnprocessed)
+	suite.Require().Equal(10440.0, invoice.InvoiceAmount)
+
+	recordPayment := func(amount float64) (*httptest.ResponseRecorder, *models.Invoice) {
+		jsonData, _ := json.Marshal(models.RecordPaymentRequest{Amount: amount})
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/invoices/%d/payments", invoice.ID), bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+
+		var response struct {
+			Data *models.Invoice `json:"data"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response.Data
+	}
+
+	w, updated := recordPayment(4000)
+	suite.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(suite.T(), models.InvoiceStatusPartiallyPaid, updated.Status)
+	assert.Equal(suite.T(), 4000.0, updated.PaidAmount)
+
+	// More than the outstanding 6440 is rejected and leaves the invoice unchanged
+	w, _ = recordPayment(6441)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code, w.Body.String())
+
+	w, updated = recordPayment(6440)
+	suite.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(suite.T(), models.InvoiceStatusPaid, updated.Status)
+	assert.Equal(suite.T(), 10440.0, updated.PaidAmount)
+
+	w, _ = recordPayment(1)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code, w.Body.String())
+
+	db, err := sql.Open("mysql", suite.cfg.GetDSN())
+	suite.Require().NoError(err)
+	defer db.Close()
+
+	var payments []float64
+	rows, err := db.Query("SELECT amount FROM invoice_payments WHERE invoice_id = ? ORDER BY id", invoice.ID)
+	suite.Require().NoError(err)
+	defer rows.Close()
+	for rows.Next() {
+		var amount float64
+		suite.Require().NoError(rows.Scan(&amount))
+		payments = append(payments, amount)
+	}
+	assert.Equal(suite.T(), []float64{4000, 6440}, payments)
+
+	history, err := suite.repo.GetInvoiceStatusHistory(invoice.ID)
+	suite.Require().NoError(err)
+	suite.Require().Len(history, 2)
+	assert.Equal(suite.T(), models.InvoiceActionPaymentRecorded, history[1].Action)
+	assert.Equal(suite.T(), models.InvoiceStatusPartiallyPaid, history[1].FromStatus)
+	assert.Equal(suite.T(), models.InvoiceStatusPaid, history[1].ToStatus)
+}
+
+// TestInvoiceStatusChangesKeepPaidAmount tests that payments follow the status graph and that
+// changing an invoice's status by hand keeps its paid amount in line with the new status
+func (suite *APITestSuite) TestInvoiceStatusChangesKeepPaidAmount() {
+	partnerID := suite.createTestBusinessPartner("Paid Amount Partner")
+	invoice := suite.insertTestInvoice(partnerID, 10000, time.Now().AddDate(0, 0, 30), models.InvoiceStatusUnprocessed)
+
+	send := func(method, path string, body interface{}) *models.Invoice {
+		jsonData, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		suite.Require().Contains([]int{http.StatusOK, http.StatusCreated}, w.Code, w.Body.String())
+
+		var response struct {
+			Data *models.Invoice `json:"data"`
+		}
+		suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+		return response.Data
+	}
+	recordPayment := func(id uint, amount float64) *models.Invoice {
+		return send("POST", fmt.Sprintf("/api/invoices/%d/payments", id), models.RecordPaymentRequest{Amount: amount})
+	}
+	updateStatus := func(id uint, status models.InvoiceStatus) *models.Invoice {
+		return send("PATCH", fmt.Sprintf("/api/invoices/%d/status", id), models.UpdateInvoiceStatusRequest{Status: status})
+	}
+
+	updated := recordPayment(invoice.ID, 4000)
+	assert.Equal(suite.T(), models.InvoiceStatusPartiallyPaid, updated.Status)
+	updated = recordPayment(invoice.ID, 1000)
+	assert.Equal(suite.T(), models.InvoiceStatusPartiallyPaid, updated.Status)
+	assert.Equal(suite.T(), 5000.0, updated.PaidAmount)
+
+	// Settling by hand records no money beyond the payments
+	updated = updateStatus(invoice.ID, models.InvoiceStatusPaid)
+	assert.Equal(suite.T(), models.InvoiceStatusPaid, updated.Status)
+	assert.Equal(suite.T(), 5000.0, updated.PaidAmount)
+
+	// Reopening keeps the recorded payments, so only the rest is outstanding
+	updateStatus(invoice.ID, models.InvoiceStatusError)
+	updated = updateStatus(invoice.ID, models.InvoiceStatusUnprocessed)
+	assert.Equal(suite.T(), models.InvoiceStatusUnprocessed, updated.Status)
+	assert.Equal(suite.T(), 5000.0, updated.PaidAmount)
+
+	updated = recordPayment(invoice.ID, invoice.InvoiceAmount-5000)
+	assert.Equal(suite.T(), models.InvoiceStatusPaid, updated.Status)
+	assert.Equal(suite.T(), invoice.InvoiceAmount, updated.PaidAmount)
+
+	// Invoices in error still receive payments
+	failed := suite.insertTestInvoice(partnerID, 10000, time.Now().AddDate(0, 0, 30), models.InvoiceStatusError)
+	updated = recordPayment(failed.ID, 1000)
+	assert.Equal(suite.T(), models.InvoiceStatusPartiallyPaid, updated.Status)
+	assert.Equal(suite.T(), 1000.0, updated.PaidAmount)
+
+	// A payment based on a stale read of the invoice is refused
+	err := suite.repo.RecordInvoicePayment(&models.InvoicePayment{InvoiceID: failed.ID, Amount: 1000},
+		models.InvoiceStatusError, models.InvoiceStatusPartiallyPaid, 0)
+	assert.ErrorIs(suite.T(), err, repository.ErrInvoiceChanged)
+	err = suite.repo.UpdateInvoiceStatus(failed.ID, models.InvoiceStatusError, models.InvoiceStatusPaid, suite.testUser.ID)
+	assert.ErrorIs(suite.T(), err, repository.ErrInvoiceChanged)
+}
+
+// TestInvoiceStatusListingUsesIndex tests that listing a company's invoices in one status is served
+// by the company, status and due date index
+func (suite *APITestSuite) TestInvoiceStatusListingUsesIndex() {
+	// Args are interpolated by the driver, as not every server can prepare an EXPLAIN
+	dsn, err := mysql.ParseDSN(suite.cfg.GetDSN())
+	suite.Require().NoError(err)
+	dsn.InterpolateParams = true
+	db, err := sql.Open("mysql", dsn.FormatDSN())
+	suite.Require().NoError(err)
+	defer db.Close()
+
+	var indexColumns []string
+	rows, err := db.Query("SHOW INDEX FROM invoices")
+	suite.Require().NoError(err)
+	columns, err := rows.Columns()
+	suite.Require().NoError(err)
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		suite.Require().NoError(rows.Scan(pointers...))
+		row := make(map[string]string, len(columns))
+		for i, column := range columns {
+			row[column] = values[i].String
+		}
+		if row["Key_name"] == "idx_invoices_company_status_due" {
+			indexColumns = append(indexColumns, row["Column_name"])
+		}
+	}
+	rows.Close()
+	assert.Equal(suite.T(), []string{"company_id", "status", "payment_due_date"}, indexColumns)
+
+	// The listing query is taken from the query log, so the plan checked is that of the statement
+	// the repository actually runs
+	var queryLog bytes.Buffer
+	repo, err := repository.NewMySQLRepositoryWithQueryLog(suite.cfg.GetDSN(), log.New(&queryLog, "", 0))
+	suite.Require().NoError(err)
+	defer repo.Close()
+	status := string(models.InvoiceStatusUnprocessed)
+	_, err = repo.GetInvoicesByCompanyID(suite.testCompany.ID, &models.GetInvoicesRequest{Status: &status, Page: 1, Limit: 20})
+	suite.Require().NoError(err)
+
+	var query string
+	var args []interface{}
+	for _, line := range strings.Split(queryLog.String(), "\n") {
+		if !strings.HasPrefix(line, "SQL SELECT") || !strings.Contains(line, "FROM invoices i") {
+			continue
+		}
+		query, args = parseQueryLogLine(suite.T(), line)
+		break
+	}
+	suite.Require().NotEmpty(query, "Listing query should be in the query log:\n%s", queryLog.String())
+	suite.Require().Contains(query, "ORDER BY i.payment_due_date DESC")
+
+	// The traditional format has one row per table with the chosen key in its own column
+	rows, err = db.Query("EXPLAIN FORMAT=TRADITIONAL "+query, args...)
+	var serverErr *mysql.MySQLError
+	if errors.As(err, &serverErr) {
+		suite.T().Skipf("database cannot EXPLAIN in the traditional format (%v), so the index used cannot be checked", err)
+	}
+	suite.Require().NoError(err)
+	defer rows.Close()
+	columns, err = rows.Columns()
+	suite.Require().NoError(err)
+	suite.Require().Contains(columns, "key")
+	suite.Require().True(rows.Next(), "EXPLAIN should report a plan row: %v", rows.Err())
+
+	values := make([]sql.NullString, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	suite.Require().NoError(rows.Scan(pointers...))
+	for i, column := range columns {
+		if column == "key" {
+			assert.Equal(suite.T(), "idx_invoices_company_status_due", values[i].String)
+		}
+		if column == "Extra" {
+			assert.NotContains(suite.T(), values[i].String, "filesort")
+		}
+	}
+}
+
+// parseQueryLogLine splits a query log line into its statement and args
+func parseQueryLogLine(t *testing.T, line string) (string, []interface{}) {
+	open := strings.LastIndex(line, " [")
+	closing := strings.LastIndex(line, "] (")
+	if !strings.HasPrefix(line, "SQL ") || open < 0 || closing < open {
+		t.Fatalf("malformed query log line: %s", line)
+	}
+
+	var args []interface{}
+	rest := line[open+2 : closing]
+	for rest != "" {
+		var arg string
+		if strings.HasPrefix(rest, `"`) {
+			quoted, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				t.Fatalf("malformed query log arg in %s: %v", line, err)
+			}
+			arg = quoted
+			unquoted, _ := strconv.Unquote(quoted)
+			args = append(args, unquoted)
+		} else {
+			arg, _, _ = strings.Cut(rest, ", ")
+			if integer, err := strconv.ParseInt(arg, 10, 64); err == nil {
+				args = append(args, integer)
+			} else if number, err := strconv.ParseFloat(arg, 64); err == nil {
+				args = append(args, number)
+			} else {
+				t.Fatalf("unsupported query log arg %q in %s", arg, line)
+			}
+		}
+		rest = strings.TrimPrefix(rest[len(arg):], ", ")
+	}
+	return line[len("SQL "):open], args
+}
+
+// TestBulkDeleteInvoices tests deleting draft and unprocessed invoices together, and that a batch
+// including a paid invoice or one in a closed accounting period deletes nothing
+func (suite *APITestSuite) TestBulkDeleteInvoices() {
+	partnerID := suite.createTestBusinessPartner("Bulk Delete Partner")
+	dueDate := time.Now().AddDate(0, 0, 30)
+
+	bulkDelete := func(ids ...uint) (*httptest.ResponseRecorder, []models.BulkDeleteInvoiceResult) {
+		jsonData, _ := json.Marshal(models.BulkDeleteInvoicesRequest{IDs: ids})
+		req, _ := http.NewRequest("POST", "/api/invoices/bulk-delete", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+
+		var response struct {
+			Data []models.BulkDeleteInvoiceResult `json:"data"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response.Data
+	}
+
+	suite.Run("All drafts", func() {
+		first := suite.insertTestInvoice(partnerID, 1000, dueDate, models.InvoiceStatusDraft)
+		second := suite.insertTestInvoice(partnerID, 2000, dueDate, models.InvoiceStatusDraft)
+
+		w, results := bulkDelete(first.ID, second.ID, first.ID)
+		suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(suite.T(), []models.BulkDeleteInvoiceResult{
+			{ID: first.ID, Deleted: true},
+			{ID: second.ID, Deleted: true},
+		}, results)
+
+		for _, id := range []uint{first.ID, second.ID} {
+			_, err := suite.repo.GetInvoiceByID(id)
+			assert.Error(suite.T(), err, "deleted invoices are no longer found")
+		}
+
+		// Deleting again finds nothing to delete
+		w, results = bulkDelete(first.ID)
+		assert.Equal(suite.T(), http.StatusConflict, w.Code)
+		suite.Require().Len(results, 1)
+		assert.Equal(suite.T(), "invoice not found", results[0].Error)
+	})
+
+	suite.Run("Batch with a paid invoice", func() {
+		draft := suite.insertTestInvoice(partnerID, 1000, dueDate, models.InvoiceStatusDraft)
+		unprocessed := suite.insertTestInvoice(partnerID, 2000, dueDate, models.InvoiceStatusUnprocessed)
+		paid := suite.insertTestInvoice(partnerID, 3000, dueDate, models.InvoiceStatusPaid)
+
+		w, results := bulkDelete(draft.ID, unprocessed.ID, paid.ID)
+		suite.Require().Equal(http.StatusConflict, w.Code, w.Body.String())
+		assert.Equal(suite.T(), []models.BulkDeleteInvoiceResult{
+			{ID: draft.ID},
+			{ID: unprocessed.ID},
+			{ID: paid.ID, Error: "paid invoices cannot be deleted"},
+		}, results)
+
+		// Nothing was deleted
+		for _, id := range []uint{draft.ID, unprocessed.ID, paid.ID} {
+			_, err := suite.repo.GetInvoiceByID(id)
+			assert.NoError(suite.T(), err)
+		}
+	})
+
+	suite.Run("Batch with an invoice in a closed period", func() {
+		loc := suite.cfg.GetLocation()
+		cutoff := time.Date(2020, 12, 31, 0, 0, 0, 0, loc)
+		cfg := *suite.cfg
+		cfg.App.AccountingCutoff = &cutoff
+		router := api.NewHandler(service.NewInvoiceService(suite.repo, &cfg), &cfg).SetupRoutes()
+
+		open := suite.insertIssuedTestInvoice(partnerID, 1000, cutoff.AddDate(0, 0, 1), dueDate, models.InvoiceStatusUnprocessed)
+		closed := suite.insertIssuedTestInvoice(partnerID, 2000, cutoff, dueDate, models.InvoiceStatusUnprocessed)
+
+		bulkDeleteWithCutoff := func(ids ...uint) (*httptest.ResponseRecorder, []models.BulkDeleteInvoiceResult) {
+			jsonData, _ := json.Marshal(models.BulkDeleteInvoicesRequest{IDs: ids})
+			req, _ := http.NewRequest("POST", "/api/invoices/bulk-delete", bytes.NewBuffer(jsonData))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+suite.authToken)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			var response struct {
+				Data []models.BulkDeleteInvoiceResult `json:"data"`
+			}
+			_ = json.Unmarshal(w.Body.Bytes(), &response)
+			return w, response.Data
+		}
+
+		w, results := bulkDeleteWithCutoff(open.ID, closed.ID)
+		suite.Require().Equal(http.StatusConflict, w.Code, w.Body.String())
+		assert.Equal(suite.T(), []models.BulkDeleteInvoiceResult{
+			{ID: open.ID},
+			{ID: closed.ID, Error: "invoices issued in a closed accounting period cannot be deleted"},
+		}, results)
+
+		for _, id := range []uint{open.ID, closed.ID} {
+			_, err := suite.repo.GetInvoiceByID(id)
+			assert.NoError(suite.T(), err)
+		}
+
+		// Without the closed invoice the batch goes through
+		w, results = bulkDeleteWithCutoff(open.ID)
+		suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(suite.T(), []models.BulkDeleteInvoiceResult{{ID: open.ID, Deleted: true}}, results)
+	})
+}
+
+// TestPurgeDeletedInvoices tests that the purge job permanently removes invoices deleted longer ago
+// than the retention period, and keeps recently deleted ones and those in a closed accounting period
+func (suite *APITestSuite) TestPurgeDeletedInvoices() {
+	loc := suite.cfg.GetLocation()
+	cutoff := time.Date(2020, 12, 31, 0, 0, 0, 0, loc)
+	cfg := *suite.cfg
+	cfg.App.AccountingCutoff = &cutoff
+	cfg.App.DeletedInvoiceRetentionDays = 90
+	router := api.NewHandler(service.NewInvoiceService(suite.repo, &cfg), &cfg).SetupRoutes()
+
+	db, err := sql.Open("mysql", suite.cfg.GetDSN())
+	suite.Require().NoError(err)
+	defer db.Close()
+
+	partnerID := suite.createTestBusinessPartner("Purge Partner")
+	dueDate := time.Now().AddDate(0, 1, 0)
+	deleteAt := func(invoice *models.Invoice, deletedAt time.Time) {
+		_, err := suite.repo.DeleteInvoices(suite.testCompany.ID, []uint{invoice.ID}, nil)
+		suite.Require().NoError(err)
+		_, err = db.Exec(`UPDATE invoices SET deleted_at = ? WHERE id = ?`, deletedAt, invoice.ID)
+		suite.Require().NoError(err)
+	}
+	exists := func(invoice *models.Invoice) bool {
+		var count int
+		suite.Require().NoError(db.QueryRow(`SELECT COUNT(*) FROM invoices WHERE id = ?`, invoice.ID).Scan(&count))
+		return count == 1
+	}
+
+	// An old deletion is purged together with the invoice's history
+	old := suite.insertTestInvoice(partnerID, 10000, dueDate, models.InvoiceStatusDraft)
+	suite.Require().NoError(suite.repo.IssueInvoice(old.ID, models.InvoiceStatusUnprocessed, time.Now(), suite.testUser.ID))
+	deleteAt(old, time.Now().AddDate(0, 0, -91))
+
+	recent := suite.insertTestInvoice(partnerID, 10000, dueDate, models.InvoiceStatusUnprocessed)
+	deleteAt(recent, time.Now().AddDate(0, 0, -89))
+	closed := suite.insertIssuedTestInvoice(partnerID, 10000, cutoff, cutoff.AddDate(0, 1, 0), models.InvoiceStatusUnprocessed)
+	deleteAt(closed, time.Now().AddDate(-1, 0, 0))
+	live := suite.insertTestInvoice(partnerID, 10000, dueDate, models.InvoiceStatusUnprocessed)
+
+	purge := func(adminToken string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/admin/invoices/purge-deleted", nil)
+		req.Header.Set("X-Admin-Token", adminToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(suite.T(), http.StatusForbidden, purge("wrong-token").Code)
+	assert.True(suite.T(), exists(old))
+
+	w := purge(cfg.Admin.Token)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Data struct {
+			Purged int64 `json:"purged"`
+		} `json:"data"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.GreaterOrEqual(suite.T(), response.Data.Purged, int64(1))
+
+	assert.False(suite.T(), exists(old), "Invoice deleted before the retention period should be purged")
+	var historyCount int
+	suite.Require().NoError(db.QueryRow(`SELECT COUNT(*) FROM invoice_status_histories WHERE invoice_id = ?`, old.ID).Scan(&historyCount))
+	assert.Zero(suite.T(), historyCount, "History of a purged invoice should be purged with it")
+
+	assert.True(suite.T(), exists(recent), "Invoice deleted within the retention period should be kept")
+	assert.True(suite.T(), exists(closed), "Invoice issued in a closed accounting period should be kept")
+	assert.True(suite.T(), exists(live), "Invoice that is not deleted should be kept")
+}
+
+// TestInvoiceListAPIVersions tests that the X-Api-Version header selects the shape of the invoice list
+func (suite *APITestSuite) TestInvoiceListAPIVersions() {
+	partnerName := fmt.Sprintf("Versioned Partner %d", time.Now().UnixNano())
+	partnerID := suite.createTestBusinessPartner(partnerName)
+	for i := 0; i < 3; i++ {
+		suite.insertTestInvoice(partnerID, 10000, time.Now().AddDate(0, 0, 30), models.InvoiceStatusUnprocessed)
+	}
+
+	listInvoices := func(version, query string) (*httptest.ResponseRecorder, map[string]json.RawMessage) {
+		req, _ := http.NewRequest("GET", "/api/invoices?limit=2&partner_name="+url.QueryEscape(partnerName)+query, nil)
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		if version != "" {
+			req.Header.Set("X-Api-Version", version)
+		}
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+
+		var body map[string]json.RawMessage
+		_ = json.Unmarshal(w.Body.Bytes(), &body)
+		return w, body
+	}
+	dataLen := func(body map[string]json.RawMessage) int {
+		var data []json.RawMessage
+		suite.Require().NoError(json.Unmarshal(body["data"], &data))
+		return len(data)
+	}
+
+	// v1 serves the bare page of invoices
+	for _, version := range []string{"v1", "1"} {
+		w, body := listInvoices(version, "")
+		suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(suite.T(), "v1", w.Header().Get("X-Api-Version"))
+		assert.Len(suite.T(), body, 2)
+		assert.Contains(suite.T(), body, "message")
+		assert.Equal(suite.T(), 2, dataLen(body))
+	}
+
+	// v2, the default, wraps the page in the paginated envelope
+	for _, version := range []string{"v2", ""} {
+		w, _ := listInvoices(version, "&page=2")
+		suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(suite.T(), "v2", w.Header().Get("X-Api-Version"))
+
+		var response models.InvoicePageResponse
+		suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(suite.T(), 2, response.Page)
+		assert.Equal(suite.T(), 2, response.Limit)
+		assert.Equal(suite.T(), 3, response.Total)
+		assert.Equal(suite.T(), 2, response.TotalPages)
+		assert.Len(suite.T(), response.Data, 1)
+	}
+
+	w, body := listInvoices("v2", "&flat=true")
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(suite.T(), 2, dataLen(body))
+	assert.Contains(suite.T(), string(body["data"]), "business_partner_name")
+	assert.JSONEq(suite.T(), "3", string(body["total"]))
+
+	w, body = listInvoices("v3", "")
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+	assert.JSONEq(suite.T(), `"unsupported_api_version"`, string(body["error"]))
+}
+
+// TestEmptyListsSerializeAsArrays tests that list endpoints with no results return an empty array
+// rather than null
+func (suite *APITestSuite) TestEmptyListsSerializeAsArrays() {
+	company := &models.Company{
+		CorporateName:  fmt.Sprintf("Empty Lists Company %d", time.Now().UnixNano()),
+		Representative: "Empty Representative",
+		PhoneNumber:    "03-9999-3333",
+		PostalCode:     "100-0003",
+		Address:        "Tokyo, Empty Address 3-3-3",
+	}
+	suite.Require().NoError(suite.repo.CreateCompany(company))
+	_, token := suite.createTestCompanyUser(company.ID, "Empty Lists User", models.UserRoleAdmin)
+
+	for _, tc := range []struct {
+		path       string
+		apiVersion string
+	}{
+		{path: "/api/invoices"},
+		{path: "/api/invoices", apiVersion: "1"},
+		{path: "/api/invoices?flat=true"},
+		{path: "/api/invoices?after="},
+		{path: "/api/invoices/overdue"},
+		{path: "/api/business-partners"},
+	} {
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		if tc.apiVersion != "" {
+			req.Header.Set(middleware.APIVersionHeader, tc.apiVersion)
+		}
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		suite.Require().Equal(http.StatusOK, w.Code, tc.path+": "+w.Body.String())
+
+		var response map[string]json.RawMessage
+		suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(suite.T(), "[]", string(response["data"]), "%s (version %q)", tc.path, tc.apiVersion)
+	}
+}
+
+// TestHeadInvoice tests checking that an invoice exists with a HEAD request
+func (suite *APITestSuite) TestHeadInvoice() {
+	partnerID := suite.createTestBusinessPartner("Head Partner")
+	invoice := suite.insertTestInvoice(partnerID, 10000, time.Now().AddDate(0, 0, 30), models.InvoiceStatusUnprocessed)
+
+	partner := suite.createOtherCompanyPartner("Other Head")
+	other := &models.Invoice{
+		CompanyID:         partner.CompanyID,
+		BusinessPartnerID: partner.ID,
+		IssueDate:         models.NewTimestamp(time.Now()),
+		PaymentAmount:     10000,
+		InvoiceAmount:     10440,
+		PaymentDueDate:    models.NewTimestamp(time.Now().AddDate(0, 0, 30)),
+		Status:            models.InvoiceStatusUnprocessed,
+		Currency:          models.DefaultCurrency,
+	}
+	suite.Require().NoError(suite.repo.CreateInvoice(other))
+
+	for _, tc := range []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{"own invoice", fmt.Sprintf("/api/invoices/%d", invoice.ID), http.StatusOK},
+		{"missing invoice", "/api/invoices/999999", http.StatusNotFound},
+		{"other company's invoice", fmt.Sprintf("/api/invoices/%d", other.ID), http.StatusNotFound},
+	} {
+		req, _ := http.NewRequest("HEAD", tc.path, nil)
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+
+		assert.Equal(suite.T(), tc.status, w.Code, tc.name)
+		assert.Empty(suite.T(), w.Body.String(), tc.name)
+	}
+}
+
+// TestInvoiceSummaryTotals tests summarizing invoices per status with their fee and tax totals
+func (suite *APITestSuite) TestInvoiceSummaryTotals() {
+	// A company of its own keeps other tests' invoices out of the totals
+	partner := suite.createOtherCompanyPartner("Summary")
+	_, token := suite.createTestCompanyUser(partner.CompanyID, "Summary User", models.UserRoleAdmin)
+
+	insert := func(paymentAmount float64, status models.InvoiceStatus) *models.Invoice {
+		fee, tax, total := service.CalculateInvoice(paymentAmount, service.DefaultFeeRate, service.DefaultConsumptionTaxRate)
+		invoice := &models.Invoice{
+			CompanyID:          partner.CompanyID,
+			BusinessPartnerID:  partner.ID,
+			IssueDate:          models.NewTimestamp(time.Now()),
+			PaymentAmount:      paymentAmount,
+			Fee:                fee,
+			FeeRate:            service.DefaultFeeRate,
+			ConsumptionTax:     tax,
+			ConsumptionTaxRate: service.DefaultConsumptionTaxRate,
+			InvoiceAmount:      total,
+			PaymentDueDate:     models.NewTimestamp(time.Now().AddDate(0, 1, 0)),
+			Status:             status,
+			Currency:           models.DefaultCurrency,
+		}
+		suite.Require().NoError(suite.repo.CreateInvoice(invoice))
+		return invoice
+	}
+	paid := []*models.Invoice{insert(10000, models.InvoiceStatusPaid), insert(25000, models.InvoiceStatusPaid)}
+	unprocessed := insert(40000, models.InvoiceStatusUnprocessed)
+
+	req, _ := http.NewRequest("GET", "/api/invoices/summary", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		Data []models.StatusSummary `json:"data"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	suite.Require().Len(response.Data, 2)
+	summaries := map[models.InvoiceStatus]models.StatusSummary{}
+	for _, summary := range response.Data {
+		assert.Equal(suite.T(), models.DefaultCurrency, summary.Currency)
+		summaries[summary.Status] = summary
+	}
+
+	paidSummary := summaries[models.InvoiceStatusPaid]
+	assert.Equal(suite.T(), 2, paidSummary.Count)
+	assert.InDelta(suite.T(), paid[0].InvoiceAmount+paid[1].InvoiceAmount, paidSummary.InvoiceAmount, 0.001)
+	assert.InDelta(suite.T(), paid[0].Fee+paid[1].Fee, paidSummary.TotalFee, 0.001)
+	assert.InDelta(suite.T(), paid[0].ConsumptionTax+paid[1].ConsumptionTax, paidSummary.TotalTax, 0.001)
+
+	unprocessedSummary := summaries[models.InvoiceStatusUnprocessed]
+	assert.Equal(suite.T(), 1, unprocessedSummary.Count)
+	assert.InDelta(suite.T(), unprocessed.InvoiceAmount, unprocessedSummary.InvoiceAmount, 0.001)
+	assert.InDelta(suite.T(), unprocessed.Fee, unprocessedSummary.TotalFee, 0.001)
+	assert.InDelta(suite.T(), unprocessed.ConsumptionTax, unprocessedSummary.TotalTax, 0.001)
+}
+
+// TestInvoiceSparseFieldsets tests requesting only some fields of invoices
+func (suite *APITestSuite) TestInvoiceSparseFieldsets() {
+	partnerID := suite.createTestBusinessPartner("Sparse Fields Partner")
+	invoice := suite.insertTestInvoice(partnerID, 10000, time.Now().AddDate(0, 0, 30), models.InvoiceStatusUnprocessed)
+
+	const fields = "fields=id,invoice_amount,%20status,unknown_field"
+	expected := []string{"id", "invoice_amount", "status"}
+
+	get := func(path string) map[string]json.RawMessage {
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		suite.Require().Equal(http.StatusOK, w.Code, path+": "+w.Body.String())
+
+		var response map[string]json.RawMessage
+		suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+	keys := func(object map[string]json.RawMessage) []string {
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		return names
+	}
+
+	response := get(fmt.Sprintf("/api/invoices/%d?%s", invoice.ID, fields))
+	var single map[string]json.RawMessage
+	suite.Require().NoError(json.Unmarshal(response["data"], &single))
+	assert.ElementsMatch(suite.T(), expected, keys(single))
+	assert.Equal(suite.T(), fmt.Sprint(invoice.ID), string(single["id"]))
+	assert.Equal(suite.T(), `"unprocessed"`, string(single["status"]))
+
+	for _, query := range []string{fields, fields + "&flat=true", fields + "&after=0"} {
+		response := get("/api/invoices?" + query)
+		var list []map[string]json.RawMessage
+		suite.Require().NoError(json.Unmarshal(response["data"], &list))
+		suite.Require().NotEmpty(list, query)
+		for _, item := range list {
+			assert.ElementsMatch(suite.T(), expected, keys(item), query)
+		}
+	}
+
+	// Without the parameter every field is returned
+	response = get(fmt.Sprintf("/api/invoices/%d", invoice.ID))
+	var full map[string]json.RawMessage
+	suite.Require().NoError(json.Unmarshal(response["data"], &full))
+	assert.Contains(suite.T(), full, "business_partner_id")
+}
@@ -87,6 +87,50 @@ func (suite *APITestSuite) TestConcurrentInvoiceCreation() {
 	assert.Equal(suite.T(), concurrentRequests, successCount, "All concurrent invoice creations should succeed")
 }
 
+// TestConcurrentInvoiceNumbering tests that invoices created concurrently are numbered uniquely
+// and without gaps from their company's sequence
+func (suite *APITestSuite) TestConcurrentInvoiceNumbering() {
+	partner := suite.createOtherCompanyPartner("Numbering")
+
+	concurrentRequests := 20
+	var wg sync.WaitGroup
+	numbers := make([]string, concurrentRequests)
+	errs := make([]error, concurrentRequests)
+
+	for i := 0; i < concurrentRequests; i++ {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+
+			invoice := &models.Invoice{
+				CompanyID:         partner.CompanyID,
+				BusinessPartnerID: partner.ID,
+				IssueDate:         models.NewTimestamp(time.Now()),
+				PaymentAmount:     float64(10000 + index*1000),
+				InvoiceAmount:     float64(10000 + index*1000),
+				PaymentDueDate:    models.NewTimestamp(time.Now().AddDate(0, 1, 0)),
+				Status:            models.InvoiceStatusUnprocessed,
+				Currency:          models.DefaultCurrency,
+			}
+			errs[index] = suite.repo.CreateInvoice(invoice)
+			numbers[index] = invoice.InvoiceNumber
+		}(i)
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		suite.Require().NoError(err)
+	}
+
+	// Every number from 1 up to the number of invoices is assigned exactly once
+	expected := make([]string, concurrentRequests)
+	for i := range expected {
+		expected[i] = fmt.Sprintf("INV-%06d", i+1)
+	}
+	assert.ElementsMatch(suite.T(), expected, numbers)
+}
+
 // TestConcurrentInvoiceRetrieval tests concurrent invoice retrieval
 func (suite *APITestSuite) TestConcurrentInvoiceRetrieval() {
 	concurrentRequests := 20
@@ -0,0 +1,224 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"super-payment/internal/models"
+	"super-payment/internal/service"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMonthlyReport tests the monthly invoice totals report
+func (suite *APITestSuite) TestMonthlyReport() {
+	partnerID := suite.createTestBusinessPartner("Monthly Report Partner")
+	loc := suite.cfg.GetLocation()
+
+	// Use a past year no other test issues invoices in
+	february := suite.insertIssuedTestInvoice(partnerID, 1000, time.Date(2019, 2, 3, 0, 0, 0, 0, loc), time.Date(2019, 3, 3, 0, 0, 0, 0, loc), models.InvoiceStatusPaid)
+	february2 := suite.insertIssuedTestInvoice(partnerID, 2500, time.Date(2019, 2, 20, 0, 0, 0, 0, loc), time.Date(2019, 3, 20, 0, 0, 0, 0, loc), models.InvoiceStatusPaid)
+	july := suite.insertIssuedTestInvoice(partnerID, 4000, time.Date(2019, 7, 1, 0, 0, 0, 0, loc), time.Date(2019, 8, 1, 0, 0, 0, 0, loc), models.InvoiceStatusPaid)
+
+	req, _ := http.NewRequest("GET", "/api/reports/monthly?year=2019", nil)
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		Data []models.MonthlyInvoiceTotal `json:"data"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	suite.Require().Len(response.Data, 12)
+
+	for i, month := range response.Data {
+		assert.Equal(suite.T(), i+1, month.Month)
+		switch month.Month {
+		case 2:
+			assert.Equal(suite.T(), 2, month.InvoiceCount)
+			assert.InDelta(suite.T(), february.InvoiceAmount+february2.InvoiceAmount, month.TotalAmount, 0.001)
+		case 7:
+			assert.Equal(suite.T(), 1, month.InvoiceCount)
+			assert.InDelta(suite.T(), july.InvoiceAmount, month.TotalAmount, 0.001)
+		default:
+			assert.Equal(suite.T(), 0, month.InvoiceCount)
+			assert.Equal(suite.T(), 0.0, month.TotalAmount)
+		}
+	}
+
+	req, _ = http.NewRequest("GET", "/api/reports/monthly?year=abc", nil)
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+}
+
+// TestAgingReport tests that unpaid invoices are bucketed by days past their due date, per currency
+func (suite *APITestSuite) TestAgingReport() {
+	getAging := func() map[string]map[string]models.AgingBucketTotal {
+		req, _ := http.NewRequest("GET", "/api/reports/aging", nil)
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+		var response struct {
+			Data []models.AgingBucketTotal `json:"data"`
+		}
+		suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+		suite.Require().NotEmpty(response.Data)
+		suite.Require().Zero(len(response.Data) % len(models.AgingBuckets))
+
+		// Every currency lists all buckets in order
+		byCurrency := make(map[string]map[string]models.AgingBucketTotal)
+		for i, bucket := range response.Data {
+			first := response.Data[i-i%len(models.AgingBuckets)]
+			assert.Equal(suite.T(), models.AgingBuckets[i%len(models.AgingBuckets)], bucket.Bucket)
+			assert.Equal(suite.T(), first.Currency, bucket.Currency)
+			if byCurrency[bucket.Currency] == nil {
+				byCurrency[bucket.Currency] = make(map[string]models.AgingBucketTotal)
+			}
+			byCurrency[bucket.Currency][bucket.Bucket] = bucket
+		}
+		return byCurrency
+	}
+
+	// Other tests share the company, so compare against the report before seeding
+	agingBefore := getAging()
+	before := agingBefore[models.DefaultCurrency]
+
+	partnerID := suite.createTestBusinessPartner("Aging Report Partner")
+	today := models.DateOnly(time.Now(), suite.cfg.GetLocation())
+	overdue := func(days int, status models.InvoiceStatus) *models.Invoice {
+		return suite.insertIssuedTestInvoice(partnerID, 1000, today.AddDate(0, 0, -days-30), today.AddDate(0, 0, -days), status)
+	}
+
+	overdue(-10, models.InvoiceStatusUnprocessed)
+	overdue(0, models.InvoiceStatusProcessing)
+	overdue(1, models.InvoiceStatusUnprocessed)
+	overdue(30, models.InvoiceStatusError)
+	partial := overdue(45, models.InvoiceStatusUnprocessed)
+	overdue(90, models.InvoiceStatusUnprocessed)
+	overdue(91, models.InvoiceStatusUnprocessed)
+	overdue(400, models.InvoiceStatusUnprocessed)
+	// Settled invoices and drafts are not receivables
+	overdue(45, models.InvoiceStatusPaid)
+	overdue(45, models.InvoiceStatusDraft)
+
+	suite.Require().NoError(suite.repo.RecordInvoicePayment(&models.InvoicePayment{InvoiceID: partial.ID, Amount: 400},
+		models.InvoiceStatusUnprocessed, models.InvoiceStatusPartiallyPaid, 0))
+
+	// Amounts in other currencies are aged separately
+	usd := &models.Invoice{
+		CompanyID:          suite.testCompany.ID,
+		BusinessPartnerID:  partnerID,
+		IssueDate:          models.NewTimestamp(today.AddDate(0, 0, -75)),
+		PaymentAmount:      100,
+		FeeRate:            service.DefaultFeeRate,
+		ConsumptionTaxRate: service.DefaultConsumptionTaxRate,
+		InvoiceAmount:      104.4,
+		PaymentDueDate:     models.NewTimestamp(today.AddDate(0, 0, -45)),
+		Status:             models.InvoiceStatusUnprocessed,
+		Currency:           "USD",
+	}
+	suite.Require().NoError(suite.repo.CreateInvoice(usd))
+
+	aging := getAging()
+	after := aging[models.DefaultCurrency]
+	amount := partial.InvoiceAmount
+	expected := map[string]struct {
+		count  int
+		amount float64
+	}{
+		models.AgingBucketCurrent: {2, 2 * amount},
+		models.AgingBucket1To30:   {2, 2 * amount},
+		models.AgingBucket31To60:  {1, amount - 400},
+		models.AgingBucket61To90:  {1, amount},
+		models.AgingBucketOver90:  {2, 2 * amount},
+	}
+	for bucket, want := range expected {
+		assert.Equal(suite.T(), want.count, after[bucket].InvoiceCount-before[bucket].InvoiceCount, bucket)
+		assert.InDelta(suite.T(), want.amount, after[bucket].TotalAmount-before[bucket].TotalAmount, 0.001, bucket)
+	}
+
+	suite.Require().Contains(aging, "USD")
+	usdBefore, usdAfter := agingBefore["USD"][models.AgingBucket31To60], aging["USD"][models.AgingBucket31To60]
+	assert.Equal(suite.T(), 1, usdAfter.InvoiceCount-usdBefore.InvoiceCount)
+	assert.InDelta(suite.T(), usd.InvoiceAmount, usdAfter.TotalAmount-usdBefore.TotalAmount, 0.001)
+}
+
+// TestFeeRevenueReport tests the platform-wide fee revenue report
+func (suite *APITestSuite) TestFeeRevenueReport() {
+	loc := suite.cfg.GetLocation()
+	partnerID := suite.createTestBusinessPartner("Fee Revenue Partner")
+
+	otherPartner := suite.createOtherCompanyPartner("Fee Revenue")
+
+	feeRevenue := func(query, adminToken string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/api/admin/reports/fee-revenue"+query, nil)
+		req.Header.Set("X-Admin-Token", adminToken)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w
+	}
+	getMonths := func() map[int]models.FeeRevenueTotal {
+		w := feeRevenue("?start=2018-03-01&end=2018-05-31", suite.cfg.Admin.Token)
+		suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+		var response struct {
+			Data []models.FeeRevenueTotal `json:"data"`
+		}
+		suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+
+		months := make(map[int]models.FeeRevenueTotal)
+		for _, total := range response.Data {
+			assert.Equal(suite.T(), 2018, total.Year)
+			assert.Equal(suite.T(), models.DefaultCurrency, total.Currency)
+			months[total.Month] = total
+		}
+		return months
+	}
+
+	// The report covers every company and earlier runs leave their invoices behind, so compare
+	// against the report before seeding
+	before := getMonths()
+
+	date := func(month, day int) time.Time { return time.Date(2018, time.Month(month), day, 0, 0, 0, 0, loc) }
+	march := suite.insertIssuedTestInvoice(partnerID, 10000, date(3, 1), date(4, 1), models.InvoiceStatusPaid)
+	otherMarch := suite.insertIssuedTestInvoice(partnerID, 25000, date(3, 31), date(4, 30), models.InvoiceStatusPaid)
+	otherCompanyMarch := *otherMarch
+	otherCompanyMarch.ID = 0
+	otherCompanyMarch.CompanyID = otherPartner.CompanyID
+	otherCompanyMarch.BusinessPartnerID = otherPartner.ID
+	suite.Require().NoError(suite.repo.CreateInvoice(&otherCompanyMarch))
+	may := suite.insertIssuedTestInvoice(partnerID, 4000, date(5, 15), date(6, 15), models.InvoiceStatusPaid)
+	// Unpaid invoices and invoices outside the period earn nothing yet
+	suite.insertIssuedTestInvoice(partnerID, 7000, date(3, 10), date(4, 10), models.InvoiceStatusProcessing)
+	suite.insertIssuedTestInvoice(partnerID, 7000, date(6, 1), date(7, 1), models.InvoiceStatusPaid)
+
+	assert.Equal(suite.T(), http.StatusForbidden, feeRevenue("?start=2018-03-01&end=2018-05-31", "wrong-token").Code)
+	assert.Equal(suite.T(), http.StatusBadRequest, feeRevenue("?start=2018-03-01", suite.cfg.Admin.Token).Code)
+	assert.Equal(suite.T(), http.StatusBadRequest, feeRevenue("?start=2018-06-01&end=2018-03-01", suite.cfg.Admin.Token).Code)
+
+	after := getMonths()
+	suite.Require().Len(after, 2, "Only months with paid invoices should be reported")
+
+	// Invoices of both companies are summed per month
+	marchFee := march.Fee + otherMarch.Fee + otherCompanyMarch.Fee
+	marchTax := march.ConsumptionTax + otherMarch.ConsumptionTax + otherCompanyMarch.ConsumptionTax
+	expected := map[int]struct {
+		count int
+		fee   float64
+		tax   float64
+	}{
+		3: {3, marchFee, marchTax},
+		5: {1, may.Fee, may.ConsumptionTax},
+	}
+	for month, want := range expected {
+		assert.Equal(suite.T(), want.count, after[month].InvoiceCount-before[month].InvoiceCount, month)
+		assert.InDelta(suite.T(), want.fee, after[month].Fee-before[month].Fee, 0.001, month)
+		assert.InDelta(suite.T(), want.tax, after[month].ConsumptionTax-before[month].ConsumptionTax, 0.001, month)
+		assert.InDelta(suite.T(), after[month].Fee+after[month].ConsumptionTax, after[month].TotalRevenue, 0.001, month)
+	}
+}
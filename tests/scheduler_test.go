@@ -0,0 +1,44 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"super-payment/internal/scheduler"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSchedulerRunsJobsRepeatedly tests that a registered job runs on its interval until stopped
+func TestSchedulerRunsJobsRepeatedly(t *testing.T) {
+	var runs int32
+	var failingRuns int32
+
+	s := scheduler.New()
+	s.Register("counter", 10*time.Millisecond, func(ctx context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	})
+	s.Register("failing", 10*time.Millisecond, func(ctx context.Context) error {
+		atomic.AddInt32(&failingRuns, 1)
+		return errors.New("job failure")
+	})
+	s.Register("disabled", 0, func(ctx context.Context) error {
+		t.Error("Disabled job should never run")
+		return nil
+	})
+
+	s.Start(context.Background())
+
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&runs) >= 2 && atomic.LoadInt32(&failingRuns) >= 2
+	}, time.Second, 5*time.Millisecond, "Jobs should run at least twice, even after returning errors")
+
+	s.Stop()
+
+	stoppedAt := atomic.LoadInt32(&runs)
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, stoppedAt, atomic.LoadInt32(&runs), "Jobs should not run after Stop")
+}
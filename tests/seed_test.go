@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"fmt"
+	"super-payment/internal/models"
+	"super-payment/internal/seed"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSeedDemoData tests that the seeder creates the demo data once and skips later runs
+func (suite *APITestSuite) TestSeedDemoData() {
+	// Seed under a fresh email so demo data left by an earlier run against the same database
+	// does not count
+	email := fmt.Sprintf("demo%d@example.com", time.Now().UnixNano())
+	first, err := seed.RunFor(suite.repo, suite.cfg, email)
+	suite.Require().NoError(err)
+	suite.Require().False(first.Skipped)
+	assert.Len(suite.T(), first.BusinessPartnerIDs, 3)
+	assert.Len(suite.T(), first.InvoiceIDs, 18)
+
+	user, err := suite.repo.GetUserByEmail(email)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), models.UserRoleAdmin, user.Role)
+	assert.Equal(suite.T(), first.CompanyID, user.CompanyID)
+
+	partners, err := suite.repo.GetBusinessPartnersByCompanyID(user.CompanyID, nil)
+	suite.Require().NoError(err)
+	assert.Len(suite.T(), partners, 3)
+
+	count, err := suite.repo.CountInvoicesByCompanyID(user.CompanyID, &models.GetInvoicesRequest{})
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 18, count)
+
+	// The overdue job leaves the open demo invoices alone
+	_, err = suite.repo.MarkOverdueInvoices(models.DateOnly(time.Now(), suite.cfg.GetLocation()), nil)
+	suite.Require().NoError(err)
+
+	statuses, err := suite.repo.CountInvoicesByStatus(user.CompanyID)
+	suite.Require().NoError(err)
+	assert.Len(suite.T(), statuses, len(models.InvoiceStatuses), "every status should be represented")
+
+	second, err := seed.RunFor(suite.repo, suite.cfg, email)
+	suite.Require().NoError(err)
+	assert.True(suite.T(), second.Skipped)
+
+	count, err = suite.repo.CountInvoicesByCompanyID(user.CompanyID, &models.GetInvoicesRequest{})
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 18, count)
+}
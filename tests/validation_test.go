@@ -4,13 +4,21 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
+	"super-payment/internal/api"
+	"super-payment/internal/config"
 	"super-payment/internal/models"
+	"super-payment/internal/repository"
+	"super-payment/internal/service"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -48,16 +56,7 @@ func (suite *APITestSuite) TestCreateInvoiceValidation() {
 				"payment_amount":      0.0,
 				"payment_due_date":    time.Now().AddDate(0, 1, 0).Format(time.RFC3339),
 			},
-			expectedStatus: http.StatusBadRequest,
-			expectedError:  "validation_error",
-		},
-		{
-			name: "Missing payment due date",
-			requestData: map[string]interface{}{
-				"business_partner_id": 1,
-				"payment_amount":      10000.0,
-			},
-			expectedStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusUnprocessableEntity,
 			expectedError:  "validation_error",
 		},
 		{
@@ -67,7 +66,7 @@ func (suite *APITestSuite) TestCreateInvoiceValidation() {
 				"payment_amount":      10000.0,
 				"payment_due_date":    time.Now().AddDate(0, 0, -1).Format(time.RFC3339),
 			},
-			expectedStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusUnprocessableEntity,
 			expectedError:  "validation_error",
 		},
 	}
@@ -214,6 +213,48 @@ func (suite *APITestSuite) TestGetInvoicesFiltering() {
 	}
 }
 
+// TestMalformedVersusInvalidRequests tests that unparseable bodies get 400 while well-formed
+// bodies breaking a business rule get 422, both with the same error body
+func (suite *APITestSuite) TestMalformedVersusInvalidRequests() {
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w
+	}
+
+	partnerID := suite.createTestBusinessPartner("Status Code Partner")
+	pastDue := time.Now().AddDate(0, 0, -1).Format(time.RFC3339)
+	futureDue := time.Now().AddDate(0, 1, 0).Format(time.RFC3339)
+
+	testCases := []struct {
+		name           string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{"Truncated invoice JSON", "/api/invoices", `{"business_partner_id": 1, "payment_amount":`, http.StatusBadRequest},
+		{"Invoice amount of the wrong type", "/api/invoices", fmt.Sprintf(`{"business_partner_id": %d, "payment_amount": "lots", "payment_due_date": %q}`, partnerID, futureDue), http.StatusBadRequest},
+		{"Invoice due in the past", "/api/invoices", fmt.Sprintf(`{"business_partner_id": %d, "payment_amount": 10000, "payment_due_date": %q}`, partnerID, pastDue), http.StatusUnprocessableEntity},
+		{"Truncated business partner JSON", "/api/business-partners", `{"corporate_name": "Broken`, http.StatusBadRequest},
+		{"Business partner with a malformed phone number", "/api/business-partners", `{"corporate_name": "Test Corp", "representative": "Test Rep", "phone_number": "not-a-phone", "postal_code": "100-0001", "address": "Test Address"}`, http.StatusUnprocessableEntity},
+	}
+
+	for _, tc := range testCases {
+		suite.T().Run(tc.name, func(t *testing.T) {
+			w := post(tc.path, tc.body)
+			assert.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+
+			var response models.ErrorResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, "validation_error", response.Error)
+			assert.NotEmpty(t, response.Message)
+		})
+	}
+}
+
 // TestBusinessPartnerValidation tests business partner creation validation
 func (suite *APITestSuite) TestBusinessPartnerValidation() {
 	testCases := []struct {
@@ -253,7 +294,7 @@ func (suite *APITestSuite) TestBusinessPartnerValidation() {
 				"postal_code":    "100-0001",
 				"address":        "Test Address",
 			},
-			expectedStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusUnprocessableEntity,
 			expectedError:  "validation_error",
 		},
 		{
@@ -265,7 +306,7 @@ func (suite *APITestSuite) TestBusinessPartnerValidation() {
 				"postal_code":    "invalid-postal",
 				"address":        "Test Address",
 			},
-			expectedStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusUnprocessableEntity,
 			expectedError:  "validation_error",
 		},
 	}
@@ -390,3 +431,722 @@ func (suite *APITestSuite) TestErrorHandling() {
 		assert.Equal(t, "invalid_id", response.Error)
 	})
 }
+
+// TestGetInvoicesAmountRangeFilter tests filtering invoices by invoice amount range
+func (suite *APITestSuite) TestGetInvoicesAmountRangeFilter() {
+	partnerID := suite.createTestBusinessPartner("Amount Range Partner")
+	dueDate := time.Now().AddDate(0, 1, 0)
+
+	small := suite.createTestInvoice(partnerID, 1111, dueDate)    // invoice amount 1160
+	medium := suite.createTestInvoice(partnerID, 222222, dueDate) // invoice amount 232000
+	large := suite.createTestInvoice(partnerID, 3333333, dueDate) // invoice amount 3480000
+
+	ids := suite.listInvoiceIDs("min_amount=200000&max_amount=300000&limit=100")
+	assert.Contains(suite.T(), ids, medium)
+	assert.NotContains(suite.T(), ids, small)
+	assert.NotContains(suite.T(), ids, large)
+
+	ids = suite.listInvoiceIDs("min_amount=3000000&limit=100")
+	assert.Contains(suite.T(), ids, large)
+	assert.NotContains(suite.T(), ids, medium)
+
+	testCases := []struct {
+		name  string
+		query string
+	}{
+		{"Min greater than max", "min_amount=500&max_amount=100"},
+		{"Non-numeric min", "min_amount=abc"},
+		{"Non-numeric max", "max_amount=abc"},
+	}
+
+	for _, tc := range testCases {
+		suite.T().Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/api/invoices?"+tc.query, nil)
+			req.Header.Set("Authorization", "Bearer "+suite.authToken)
+
+			w := httptest.NewRecorder()
+			suite.router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			var response models.ErrorResponse
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			assert.NoError(t, err)
+			assert.Equal(t, "validation_error", response.Error)
+		})
+	}
+}
+
+// TestValidationErrorListsAllFields tests that every failing field is reported in the error response
+func (suite *APITestSuite) TestValidationErrorListsAllFields() {
+	requestData := map[string]interface{}{
+		"phone_number": "03-1111-1111",
+		"postal_code":  "100-0001",
+		"address":      "Test Address",
+	}
+
+	jsonData, _ := json.Marshal(requestData)
+	req, _ := http.NewRequest("POST", "/api/business-partners", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+
+	var response models.ErrorResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), "validation_error", response.Error)
+	assert.ElementsMatch(suite.T(), []models.FieldError{
+		{Field: "corporate_name", Rule: "required"},
+		{Field: "representative", Rule: "required"},
+	}, response.Fields)
+}
+
+// TestRegistrationValidationErrorFields tests nested field paths in registration validation errors
+func (suite *APITestSuite) TestRegistrationValidationErrorFields() {
+	registerData := map[string]interface{}{
+		"company": map[string]interface{}{
+			"corporate_name": "Field Error Company",
+			"representative": "Field Error Rep",
+			"phone_number":   "03-1234-5678",
+			"postal_code":    "100-0001",
+			"address":        "Tokyo",
+		},
+		"user": map[string]interface{}{
+			"full_name": "Field Error User",
+			"email":     "not-an-email",
+		},
+	}
+
+	jsonData, _ := json.Marshal(registerData)
+	req, _ := http.NewRequest("POST", "/api/auth/register", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+
+	var response models.ErrorResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.ElementsMatch(suite.T(), []models.FieldError{
+		{Field: "user.email", Rule: "email"},
+		{Field: "user.password", Rule: "required"},
+	}, response.Fields)
+}
+
+// TestMethodNotAllowed tests that an unsupported method on an existing route returns 405
+func (suite *APITestSuite) TestMethodNotAllowed() {
+	req, _ := http.NewRequest("DELETE", "/api/invoices", nil)
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusMethodNotAllowed, w.Code)
+
+	var response models.ErrorResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "method_not_allowed", response.Error)
+}
+
+// TestUnknownRouteReturnsJSON tests that unknown routes return a JSON 404 error
+func (suite *APITestSuite) TestUnknownRouteReturnsJSON() {
+	req, _ := http.NewRequest("GET", "/api/does-not-exist", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+
+	var response models.ErrorResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "not_found", response.Error)
+	assert.Equal(suite.T(), "route not found", response.Message)
+}
+
+// TestDatabaseUnavailable tests that a lost database connection is reported as 503
+func (suite *APITestSuite) TestDatabaseUnavailable() {
+	repo, err := repository.NewMySQLRepository(suite.cfg.GetDSN())
+	suite.Require().NoError(err)
+	suite.Require().NoError(repo.Close())
+
+	router := api.NewHandler(service.NewInvoiceService(repo, suite.cfg), suite.cfg).SetupRoutes()
+
+	req, _ := http.NewRequest("GET", "/api/invoices", nil)
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusServiceUnavailable, w.Code)
+
+	var response models.ErrorResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), "service_unavailable", response.Error)
+}
+
+// TestStrictCompanyNameUniqueness tests that strict mode rejects companies with the same normalized name
+func (suite *APITestSuite) TestStrictCompanyNameUniqueness() {
+	cfg := *suite.cfg
+	cfg.App.CompanyNameUniqueness = config.CompanyNameUniquenessStrict
+	router := api.NewHandler(service.NewInvoiceService(suite.repo, &cfg), &cfg).SetupRoutes()
+
+	corporateName := fmt.Sprintf("Strict Name Company %d", time.Now().UnixNano())
+	register := func(name string) *httptest.ResponseRecorder {
+		registerData := map[string]interface{}{
+			"company": map[string]interface{}{
+				"corporate_name": name,
+				"representative": "Strict Representative",
+				"phone_number":   "03-1111-2222",
+				"postal_code":    "100-0001",
+				"address":        "Tokyo, Strict Address 1-1-1",
+			},
+			"user": map[string]interface{}{
+				"full_name": "Strict User",
+				"email":     fmt.Sprintf("strict%d@example.com", time.Now().UnixNano()),
+				"password":  "password123",
+			},
+		}
+
+		jsonData, _ := json.Marshal(registerData)
+		req, _ := http.NewRequest("POST", "/api/auth/register", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	suite.Require().Equal(http.StatusCreated, register(corporateName).Code)
+
+	w := register("  " + strings.ReplaceAll(corporateName, " ", "   ") + " ")
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+
+	var response models.ErrorResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), "company_already_exists", response.Error)
+}
+
+// TestRegistrationBlockedEmailDomain tests that registrations from blocked email domains are rejected
+func (suite *APITestSuite) TestRegistrationBlockedEmailDomain() {
+	cfg := *suite.cfg
+	cfg.App.BlockedEmailDomains = []string{"mailinator.com"}
+	router := api.NewHandler(service.NewInvoiceService(suite.repo, &cfg), &cfg).SetupRoutes()
+
+	register := func(email string) *httptest.ResponseRecorder {
+		registerData := map[string]interface{}{
+			"company": map[string]interface{}{
+				"corporate_name": "Email Domain Company",
+				"representative": "Email Representative",
+				"phone_number":   "03-1111-3333",
+				"postal_code":    "100-0001",
+				"address":        "Tokyo, Email Address 1-1-1",
+			},
+			"user": map[string]interface{}{
+				"full_name": "Email User",
+				"email":     email,
+				"password":  "password123",
+			},
+		}
+
+		jsonData, _ := json.Marshal(registerData)
+		req, _ := http.NewRequest("POST", "/api/auth/register", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := register(fmt.Sprintf("spam%d@Mailinator.COM", time.Now().UnixNano()))
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code)
+
+	var response models.ErrorResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), "validation_error", response.Error)
+	assert.Contains(suite.T(), response.Message, "mailinator.com")
+
+	w = register(fmt.Sprintf("allowed%d@example.com", time.Now().UnixNano()))
+	assert.Equal(suite.T(), http.StatusCreated, w.Code, w.Body.String())
+}
+
+// TestRateLimitUsesForwardedClientIP tests that the rate limiter keys on the X-Forwarded-For
+// client when the request comes through a trusted proxy, and on the remote address otherwise
+func (suite *APITestSuite) TestRateLimitUsesForwardedClientIP() {
+	cfg := *suite.cfg
+	cfg.Server.TrustedProxies = []string{"10.0.0.1"}
+	cfg.Server.RateLimitPerMinute = 2
+	router := api.NewHandler(service.NewInvoiceService(suite.repo, &cfg), &cfg).SetupRoutes()
+
+	healthFrom := func(remoteAddr, forwardedFor string) int {
+		req, _ := http.NewRequest("GET", "/health", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Through the trusted proxy each forwarded client has its own allowance
+	assert.Equal(suite.T(), http.StatusOK, healthFrom("10.0.0.1:40000", "203.0.113.5"))
+	assert.Equal(suite.T(), http.StatusOK, healthFrom("10.0.0.1:40001", "203.0.113.5"))
+	assert.Equal(suite.T(), http.StatusTooManyRequests, healthFrom("10.0.0.1:40002", "203.0.113.5"))
+	assert.Equal(suite.T(), http.StatusOK, healthFrom("10.0.0.1:40003", "203.0.113.6"))
+
+	// An untrusted peer cannot dodge the limit by spoofing X-Forwarded-For
+	assert.Equal(suite.T(), http.StatusOK, healthFrom("198.51.100.7:40000", "203.0.113.10"))
+	assert.Equal(suite.T(), http.StatusOK, healthFrom("198.51.100.7:40001", "203.0.113.11"))
+	assert.Equal(suite.T(), http.StatusTooManyRequests, healthFrom("198.51.100.7:40002", "203.0.113.12"))
+}
+
+// TestRequestTimeout tests that a handler exceeding the request timeout is answered with 503
+func (suite *APITestSuite) TestRequestTimeout() {
+	cfg := *suite.cfg
+	cfg.Server.RequestTimeout = 50 * time.Millisecond
+	router := api.NewHandler(service.NewInvoiceService(suite.repo, &cfg), &cfg).SetupRoutes()
+
+	handlerCancelled := make(chan bool, 1)
+	router.GET("/slow", func(c *gin.Context) {
+		select {
+		case <-c.Request.Context().Done():
+			handlerCancelled <- true
+		case <-time.After(5 * time.Second):
+			handlerCancelled <- false
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "finished"})
+	})
+
+	start := time.Now()
+	req, _ := http.NewRequest("GET", "/slow", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Less(suite.T(), time.Since(start), 2*time.Second)
+	assert.True(suite.T(), <-handlerCancelled, "the handler's context should be cancelled")
+	assert.Equal(suite.T(), http.StatusServiceUnavailable, w.Code)
+
+	var response models.ErrorResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), "request_timeout", response.Error)
+
+	// Requests finishing within the deadline are unaffected
+	req, _ = http.NewRequest("GET", "/health", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+}
+
+// TestRequestTimeoutCancelsInvoiceQueries tests that an invoice query still running when the
+// request times out is cancelled rather than holding the request until the database answers
+func (suite *APITestSuite) TestRequestTimeoutCancelsInvoiceQueries() {
+	partnerID := suite.createTestBusinessPartner("Slow Query Partner")
+	invoiceID := suite.createTestInvoice(partnerID, 10000, time.Now().AddDate(0, 1, 0))
+
+	// The database never answers the invoice queries made through the proxy
+	proxy, err := newStallingProxy(net.JoinHostPort(suite.cfg.Database.Host, suite.cfg.Database.Port), "FROM invoices i")
+	suite.Require().NoError(err)
+	defer proxy.Close()
+
+	cfg := *suite.cfg
+	cfg.Database.Host, cfg.Database.Port, err = net.SplitHostPort(proxy.Addr())
+	suite.Require().NoError(err)
+	cfg.Server.RequestTimeout = 200 * time.Millisecond
+	repo, err := repository.NewMySQLRepository(cfg.GetDSN())
+	suite.Require().NoError(err)
+	defer repo.Close()
+	router := api.NewHandler(service.NewInvoiceService(repo, &cfg), &cfg).SetupRoutes()
+
+	for _, path := range []string{"/api/invoices", fmt.Sprintf("/api/invoices/%d", invoiceID), "/api/invoices/overdue"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		w := httptest.NewRecorder()
+		served := make(chan struct{})
+		go func() {
+			defer close(served)
+			router.ServeHTTP(w, req)
+		}()
+
+		// The timeout middleware waits for the handler, so the request only ends when the stalled
+		// query was cancelled
+		select {
+		case <-served:
+		case <-time.After(5 * time.Second):
+			suite.FailNowf("Request was held by the stalled query", "%s did not cancel its query", path)
+		}
+		assert.Equal(suite.T(), http.StatusServiceUnavailable, w.Code, path)
+		select {
+		case <-proxy.abandoned:
+		case <-time.After(2 * time.Second):
+			suite.Failf("Stalled query was not cancelled", "%s should close its database connection", path)
+		}
+	}
+}
+
+// stallingProxy forwards database connections and stops relaying the replies on a connection once
+// it has sent a statement containing match, like a database stuck on a slow query
+type stallingProxy struct {
+	listener net.Listener
+	target   string
+	match    []byte
+	// abandoned receives a value whenever a client gives up on a connection with a stalled reply
+	abandoned chan struct{}
+	closed    chan struct{}
+}
+
+func newStallingProxy(target, match string) (*stallingProxy, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	p := &stallingProxy{
+		listener:  listener,
+		target:    target,
+		match:     []byte(match),
+		abandoned: make(chan struct{}, 10),
+		closed:    make(chan struct{}),
+	}
+	go func() {
+		for {
+			client, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go p.handle(client)
+		}
+	}()
+	return p, nil
+}
+
+// Addr returns the address clients connect to
+func (p *stallingProxy) Addr() string {
+	return p.listener.Addr().String()
+}
+
+// Close stops accepting connections and releases the stalled ones
+func (p *stallingProxy) Close() {
+	close(p.closed)
+	_ = p.listener.Close()
+}
+
+func (p *stallingProxy) handle(client net.Conn) {
+	defer client.Close()
+	server, err := net.Dial("tcp", p.target)
+	if err != nil {
+		return
+	}
+	defer server.Close()
+
+	var stalled atomic.Bool
+	go func() {
+		defer client.Close()
+		buf := make([]byte, 32<<10)
+		for {
+			n, err := server.Read(buf)
+			if n > 0 {
+				if stalled.Load() {
+					<-p.closed
+					return
+				}
+				if _, err := client.Write(buf[:n]); err != nil {
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := client.Read(buf)
+		if n > 0 {
+			if bytes.Contains(buf[:n], p.match) {
+				stalled.Store(true)
+			}
+			if _, err := server.Write(buf[:n]); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	if stalled.Load() {
+		select {
+		case p.abandoned <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// TestCreateInvoicePaymentAmountNotation tests that payment amounts must be plain decimal numbers
+func (suite *APITestSuite) TestCreateInvoicePaymentAmountNotation() {
+	partnerID := suite.createTestBusinessPartner("Notation Partner")
+
+	testCases := []struct {
+		name           string
+		amount         string
+		expectedStatus int
+	}{
+		{"Scientific notation", "1e4", http.StatusBadRequest},
+		{"Scientific notation with fraction", "1.5E+4", http.StatusBadRequest},
+		{"NaN", "NaN", http.StatusBadRequest},
+		{"Quoted NaN", `"NaN"`, http.StatusBadRequest},
+		{"Infinity", "Infinity", http.StatusBadRequest},
+		{"Plain decimal", "10000.00", http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		suite.T().Run(tc.name, func(t *testing.T) {
+			body := fmt.Sprintf(`{"business_partner_id": %d, "payment_amount": %s}`, partnerID, tc.amount)
+			req, _ := http.NewRequest("POST", "/api/invoices", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+suite.authToken)
+
+			w := httptest.NewRecorder()
+			suite.router.ServeHTTP(w, req)
+			assert.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+
+			if tc.expectedStatus == http.StatusBadRequest {
+				var response models.ErrorResponse
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(t, "validation_error", response.Error)
+			}
+		})
+	}
+}
+
+// TestCORSPreflightMaxAge tests that preflight responses carry the configured Access-Control-Max-Age
+func (suite *APITestSuite) TestCORSPreflightMaxAge() {
+	cfg := *suite.cfg
+	cfg.Server.CORSMaxAge = 2 * time.Hour
+	router := api.NewHandler(service.NewInvoiceService(suite.repo, &cfg), &cfg).SetupRoutes()
+
+	req, _ := http.NewRequest("OPTIONS", "/api/invoices", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusNoContent, w.Code)
+	assert.Equal(suite.T(), "7200", w.Header().Get("Access-Control-Max-Age"))
+
+	// A zero max-age leaves caching to the browser default
+	cfg.Server.CORSMaxAge = 0
+	router = api.NewHandler(service.NewInvoiceService(suite.repo, &cfg), &cfg).SetupRoutes()
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusNoContent, w.Code)
+	assert.Empty(suite.T(), w.Header().Get("Access-Control-Max-Age"))
+}
+
+// TestConditionalGetETag tests ETag and Cache-Control headers on GET responses and 304 revalidation
+func (suite *APITestSuite) TestConditionalGetETag() {
+	suite.createTestBusinessPartner("ETag Partner")
+
+	cfg := *suite.cfg
+	cfg.Server.CacheControl = "private, max-age=30"
+	router := api.NewHandler(service.NewInvoiceService(suite.repo, &cfg), &cfg).SetupRoutes()
+
+	get := func(path, ifNoneMatch string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+suite.authToken)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, path := range []string{"/api/business-partners", "/api/invoices"} {
+		w := get(path, "")
+		suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+		etag := w.Header().Get("ETag")
+		suite.Require().NotEmpty(etag, path)
+		assert.True(suite.T(), strings.HasPrefix(etag, `W/"`), etag)
+		assert.Equal(suite.T(), "private, max-age=30", w.Header().Get("Cache-Control"))
+		assert.NotEmpty(suite.T(), w.Body.String())
+
+		// An unchanged response is not sent again
+		w = get(path, etag)
+		assert.Equal(suite.T(), http.StatusNotModified, w.Code, path)
+		assert.Empty(suite.T(), w.Body.String())
+		assert.Equal(suite.T(), etag, w.Header().Get("ETag"))
+
+		w = get(path, `W/"stale"`)
+		assert.Equal(suite.T(), http.StatusOK, w.Code)
+		assert.NotEmpty(suite.T(), w.Body.String())
+	}
+
+	// A change to the data changes the ETag
+	w := get("/api/business-partners", "")
+	etag := w.Header().Get("ETag")
+	suite.createTestBusinessPartner("Another ETag Partner")
+	w = get("/api/business-partners", etag)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+	assert.NotEqual(suite.T(), etag, w.Header().Get("ETag"))
+
+	// Writes and failed requests are never cached
+	req, _ := http.NewRequest("POST", "/api/invoices/calculate-batch", strings.NewReader(`{"amounts": [10000]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+	assert.Empty(suite.T(), w.Header().Get("ETag"))
+	assert.Empty(suite.T(), w.Header().Get("Cache-Control"))
+
+	w = get("/api/invoices/999999999", "")
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+	assert.Empty(suite.T(), w.Header().Get("ETag"))
+
+	// Without a configured Cache-Control the headers are omitted
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/business-partners", nil)
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+	assert.Empty(suite.T(), w.Header().Get("ETag"))
+}
+
+// TestUnsupportedMediaType tests that request bodies must be sent as JSON
+func (suite *APITestSuite) TestUnsupportedMediaType() {
+	testCases := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+	}{
+		{"Plain text invoice", "/api/invoices", "text/plain", `{"business_partner_id": 1, "payment_amount": 10000}`},
+		{"Form-encoded invoice", "/api/invoices", "application/x-www-form-urlencoded", "business_partner_id=1&payment_amount=10000"},
+		{"Missing content type", "/api/business-partners", "", `{"corporate_name": "Media Type Partner"}`},
+	}
+
+	for _, tc := range testCases {
+		suite.T().Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", tc.path, strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			req.Header.Set("Authorization", "Bearer "+suite.authToken)
+			w := httptest.NewRecorder()
+			suite.router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+
+			var response models.ErrorResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, "unsupported_media_type", response.Error)
+		})
+	}
+
+	// Media type parameters are allowed
+	req, _ := http.NewRequest("POST", "/api/invoices", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+suite.authToken)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+}
+
+// TestCORSPreflightAllowedMethods tests that preflight responses list only the methods registered
+// for the requested path
+func (suite *APITestSuite) TestCORSPreflightAllowedMethods() {
+	preflight := func(path string) string {
+		req, _ := http.NewRequest("OPTIONS", path, nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Access-Control-Request-Method", "GET")
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		suite.Require().Equal(http.StatusNoContent, w.Code)
+		return w.Header().Get("Access-Control-Allow-Methods")
+	}
+
+	assert.Equal(suite.T(), "GET, OPTIONS", preflight("/api/reports/monthly"))
+	assert.Equal(suite.T(), "GET, HEAD, OPTIONS", preflight("/api/invoices/42"))
+	assert.Equal(suite.T(), "GET, POST, OPTIONS", preflight("/api/invoices"))
+	assert.Equal(suite.T(), "PATCH, OPTIONS", preflight("/api/invoices/42/status"))
+	assert.Equal(suite.T(), "OPTIONS", preflight("/api/unknown"))
+}
+
+// TestContactInfoValidatedOnEveryWritePath tests that every path creating or updating a company or
+// business partner rejects a malformed phone number
+func (suite *APITestSuite) TestContactInfoValidatedOnEveryWritePath() {
+	const badPhone = "12345"
+	send := func(method, path string, body interface{}, token string) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w
+	}
+	company := map[string]interface{}{
+		"corporate_name": fmt.Sprintf("Bad Phone Company %d", time.Now().UnixNano()),
+		"representative": "Bad Phone Representative",
+		"phone_number":   badPhone,
+		"postal_code":    "100-0001",
+		"address":        "Tokyo, Bad Phone Address 1-1-1",
+	}
+	partner := models.BusinessPartnerCreateRequest{
+		CorporateName:  "Bad Phone Partner",
+		Representative: "Bad Phone Rep",
+		PhoneNumber:    badPhone,
+		PostalCode:     "100-0001",
+		Address:        "Tokyo, Bad Phone Address 2-2-2",
+	}
+	svc := service.NewInvoiceService(suite.repo, suite.cfg)
+
+	suite.Run("register", func() {
+		email := fmt.Sprintf("badphone%d@example.com", time.Now().UnixNano())
+		w := send("POST", "/api/auth/register", map[string]interface{}{
+			"company": company,
+			"user":    map[string]interface{}{"full_name": "Bad Phone User", "email": email, "password": "password123"},
+		}, "")
+		assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code, w.Body.String())
+		_, err := suite.repo.GetUserByEmail(email)
+		assert.ErrorIs(suite.T(), err, repository.ErrUserNotFound)
+	})
+
+	suite.Run("create company", func() {
+		w := send("POST", "/api/companies", company, suite.authToken)
+		assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code, w.Body.String())
+
+		err := svc.CreateCompany(&models.Company{CorporateName: "Bad Phone Company", PhoneNumber: badPhone, PostalCode: "100-0001"})
+		assert.ErrorIs(suite.T(), err, service.ErrInvalidContactInfo)
+	})
+
+	suite.Run("create business partner", func() {
+		w := send("POST", "/api/business-partners", partner, suite.authToken)
+		assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code, w.Body.String())
+
+		err := svc.CreateBusinessPartner(suite.testUser.ID, partner.ToBusinessPartner())
+		assert.ErrorIs(suite.T(), err, service.ErrInvalidContactInfo)
+	})
+
+	suite.Run("import business partners", func() {
+		err := svc.ImportBusinessPartners(suite.testUser.ID, []*models.BusinessPartner{partner.ToBusinessPartner()})
+		assert.ErrorIs(suite.T(), err, service.ErrInvalidContactInfo)
+	})
+
+	suite.Run("update business partner", func() {
+		partnerID := suite.createTestBusinessPartner("Good Phone Partner")
+		w := send("PATCH", fmt.Sprintf("/api/business-partners/%d", partnerID), map[string]string{"phone_number": badPhone}, suite.authToken)
+		assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code, w.Body.String())
+
+		phone := badPhone
+		_, err := svc.PatchBusinessPartner(suite.testUser.ID, partnerID, &models.BusinessPartnerPatchRequest{PhoneNumber: &phone})
+		assert.ErrorIs(suite.T(), err, service.ErrInvalidContactInfo)
+
+		stored, err := suite.repo.GetBusinessPartnerByID(partnerID)
+		suite.Require().NoError(err)
+		assert.NotEqual(suite.T(), badPhone, stored.PhoneNumber)
+	})
+}
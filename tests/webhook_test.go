@@ -0,0 +1,230 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"super-payment/internal/api"
+	"super-payment/internal/events"
+	"super-payment/internal/middleware"
+	"super-payment/internal/models"
+	"super-payment/internal/service"
+	"super-payment/internal/webhook"
+	"sync"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWebhookDeliveryRetry tests that a webhook delivery failing on its first attempt is queued
+// and delivered by a later retry
+func (suite *APITestSuite) TestWebhookDeliveryRetry() {
+	var mu sync.Mutex
+	var attempts int
+	var deliveredEvent, deliveredSignature string
+	var deliveredBody []byte
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		deliveredEvent = r.Header.Get(webhook.EventHeader)
+		deliveredSignature = r.Header.Get(webhook.SignatureHeader)
+		deliveredBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer endpoint.Close()
+
+	partner := suite.createOtherCompanyPartner("Webhook")
+	user := &models.User{
+		CompanyID: partner.CompanyID,
+		FullName:  "Webhook User",
+		Email:     fmt.Sprintf("webhook%d@example.com", time.Now().UnixNano()),
+		Password:  "password123",
+		Role:      models.UserRoleAdmin,
+	}
+	svc := service.NewInvoiceService(suite.repo, suite.cfg)
+	suite.Require().NoError(svc.RegisterUser(user))
+	token, err := middleware.GenerateJWT(user, suite.cfg)
+	suite.Require().NoError(err)
+
+	// The test endpoint listens on loopback
+	cfg := *suite.cfg
+	cfg.Webhook.AllowPrivateTargets = true
+	dispatcher := webhook.NewDispatcher(suite.repo, &cfg)
+	dispatcher.Subscribe(svc.Events())
+	router := api.NewHandler(svc, suite.cfg).SetupRoutes()
+
+	send := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		var reader io.Reader
+		if body != nil {
+			jsonData, _ := json.Marshal(body)
+			reader = bytes.NewBuffer(jsonData)
+		}
+		req, _ := http.NewRequest(method, path, reader)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	listDeliveries := func() []models.WebhookDelivery {
+		w := send("GET", "/api/company/webhooks/deliveries", nil)
+		suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+		var response struct {
+			Data []models.WebhookDelivery `json:"data"`
+		}
+		suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+		return response.Data
+	}
+
+	w := send("PUT", "/api/company/webhooks", map[string]string{"url": "ftp://example.com/hook"})
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, w.Code)
+	w = send("PUT", "/api/company/webhooks", models.UpdateWebhookSettingsRequest{URL: &endpoint.URL})
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var settings struct {
+		Data models.WebhookSettings `json:"data"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &settings))
+	suite.Require().NotNil(settings.Data.Secret, "Setting the endpoint should generate a signing secret")
+	secret := *settings.Data.Secret
+	assert.True(suite.T(), strings.HasPrefix(secret, models.WebhookSecretPrefix))
+
+	// Setting the endpoint again keeps the secret endpoints already verify against
+	w = send("PUT", "/api/company/webhooks", models.UpdateWebhookSettingsRequest{URL: &endpoint.URL})
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &settings))
+	suite.Require().NotNil(settings.Data.Secret)
+	assert.Equal(suite.T(), secret, *settings.Data.Secret)
+
+	// Reading the settings shows the endpoint but never the secret, even to admins
+	w = send("GET", "/api/company/webhooks", nil)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	assert.NotContains(suite.T(), w.Body.String(), secret)
+	settings.Data = models.WebhookSettings{}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &settings))
+	suite.Require().NotNil(settings.Data.URL)
+	assert.Nil(suite.T(), settings.Data.Secret)
+
+	member := &models.User{
+		CompanyID: partner.CompanyID,
+		FullName:  "Webhook Member",
+		Email:     fmt.Sprintf("webhookmember%d@example.com", time.Now().UnixNano()),
+		Password:  "password123",
+		Role:      models.UserRoleMember,
+	}
+	suite.Require().NoError(svc.RegisterUser(member))
+	memberSettings, err := svc.GetWebhookSettings(member.ID)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(memberSettings.URL)
+	assert.Equal(suite.T(), endpoint.URL, *memberSettings.URL)
+	assert.Nil(suite.T(), memberSettings.Secret)
+
+	w = send("POST", "/api/invoices", models.CreateInvoiceRequest{
+		BusinessPartnerID: partner.ID,
+		PaymentAmount:     10000,
+		PaymentDueDate:    time.Now().AddDate(0, 1, 0),
+	})
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var created struct {
+		Data models.Invoice `json:"data"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &created))
+	svc.Events().Wait()
+
+	// The endpoint was down, so the delivery waits in the queue for its retry
+	deliveries := listDeliveries()
+	suite.Require().Len(deliveries, 1)
+	queued := deliveries[0]
+	assert.Equal(suite.T(), models.WebhookDeliveryStatusPending, queued.Status)
+	assert.Equal(suite.T(), events.NameInvoiceCreated, queued.Event)
+	assert.Equal(suite.T(), 1, queued.Attempts)
+	suite.Require().NotNil(queued.LastError)
+	assert.Contains(suite.T(), *queued.LastError, "503")
+	suite.Require().NotNil(queued.NextRetryAt)
+	assert.True(suite.T(), queued.NextRetryAt.After(time.Now()), "Retry should be scheduled after a backoff")
+
+	// Nothing is retried before the backoff has passed
+	delivered, err := dispatcher.RetryDue(context.Background(), time.Now())
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 0, delivered)
+
+	delivered, err = dispatcher.RetryDue(context.Background(), queued.NextRetryAt.Add(time.Second))
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 1, delivered)
+
+	deliveries = listDeliveries()
+	suite.Require().Len(deliveries, 1)
+	assert.Equal(suite.T(), models.WebhookDeliveryStatusDelivered, deliveries[0].Status)
+	assert.Equal(suite.T(), 2, deliveries[0].Attempts)
+	assert.Nil(suite.T(), deliveries[0].NextRetryAt)
+	assert.NotNil(suite.T(), deliveries[0].DeliveredAt)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(suite.T(), 2, attempts)
+	assert.Equal(suite.T(), events.NameInvoiceCreated, deliveredEvent)
+	assert.Equal(suite.T(), webhook.Sign(secret, deliveredBody), deliveredSignature)
+	var payload struct {
+		Event string `json:"event"`
+		Data  struct {
+			InvoiceID     uint   `json:"invoice_id"`
+			InvoiceNumber string `json:"invoice_number"`
+		} `json:"data"`
+	}
+	suite.Require().NoError(json.Unmarshal(deliveredBody, &payload))
+	assert.Equal(suite.T(), events.NameInvoiceCreated, payload.Event)
+	assert.Equal(suite.T(), created.Data.ID, payload.Data.InvoiceID)
+	assert.Equal(suite.T(), created.Data.InvoiceNumber, payload.Data.InvoiceNumber)
+}
+
+// TestWebhookDeliveryRejectsPrivateTargets tests that deliveries never reach loopback addresses
+// unless private targets are allowed, and that the refused delivery is queued with the reason
+func (suite *APITestSuite) TestWebhookDeliveryRejectsPrivateTargets() {
+	var mu sync.Mutex
+	var attempts int
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer endpoint.Close()
+
+	company := &models.Company{
+		CorporateName:  fmt.Sprintf("Private Webhook Company %d", time.Now().UnixNano()),
+		Representative: "Private Webhook Representative",
+		PhoneNumber:    "03-9999-9998",
+		PostalCode:     "100-0009",
+		Address:        "Tokyo, Webhook Address 9-9-8",
+	}
+	suite.Require().NoError(suite.repo.CreateCompany(company))
+	suite.Require().NoError(suite.repo.SetCompanyWebhookURL(company.ID, &endpoint.URL, models.WebhookSecretPrefix+"private-target-secret"))
+
+	cfg := *suite.cfg
+	cfg.Webhook.AllowPrivateTargets = false
+	svc := service.NewInvoiceService(suite.repo, &cfg)
+	webhook.NewDispatcher(suite.repo, &cfg).Subscribe(svc.Events())
+
+	svc.Events().Publish(events.InvoiceCreated{InvoiceID: 1, CompanyID: company.ID, InvoiceNumber: "INV-000001"})
+	svc.Events().Wait()
+
+	mu.Lock()
+	assert.Zero(suite.T(), attempts, "Delivery should not reach a loopback endpoint")
+	mu.Unlock()
+
+	deliveries, err := suite.repo.GetWebhookDeliveriesByCompanyID(company.ID, &models.GetWebhookDeliveriesRequest{Limit: 10})
+	suite.Require().NoError(err)
+	suite.Require().Len(deliveries, 1)
+	suite.Require().NotNil(deliveries[0].LastError)
+	assert.Contains(suite.T(), *deliveries[0].LastError, webhook.ErrPrivateTarget.Error())
+}